@@ -518,8 +518,32 @@ func (rcv *Index) MutateSystemDefined(n bool) bool {
 	return rcv._tab.MutateBoolSlot(18, n)
 }
 
+func (rcv *Index) Deferred() bool {
+	o := flatbuffers.UOffsetT(rcv._tab.Offset(20))
+	if o != 0 {
+		return rcv._tab.GetBool(o + rcv._tab.Pos)
+	}
+	return false
+}
+
+func (rcv *Index) MutateDeferred(n bool) bool {
+	return rcv._tab.MutateBoolSlot(20, n)
+}
+
+func (rcv *Index) DeFactoUnique() bool {
+	o := flatbuffers.UOffsetT(rcv._tab.Offset(22))
+	if o != 0 {
+		return rcv._tab.GetBool(o + rcv._tab.Pos)
+	}
+	return false
+}
+
+func (rcv *Index) MutateDeFactoUnique(n bool) bool {
+	return rcv._tab.MutateBoolSlot(22, n)
+}
+
 func IndexStart(builder *flatbuffers.Builder) {
-	builder.StartObject(8)
+	builder.StartObject(10)
 }
 func IndexAddName(builder *flatbuffers.Builder, name flatbuffers.UOffsetT) {
 	builder.PrependUOffsetTSlot(0, flatbuffers.UOffsetT(name), 0)
@@ -554,6 +578,12 @@ func IndexAddUniqueKey(builder *flatbuffers.Builder, uniqueKey bool) {
 func IndexAddSystemDefined(builder *flatbuffers.Builder, systemDefined bool) {
 	builder.PrependBoolSlot(7, systemDefined, false)
 }
+func IndexAddDeferred(builder *flatbuffers.Builder, deferred bool) {
+	builder.PrependBoolSlot(8, deferred, false)
+}
+func IndexAddDeFactoUnique(builder *flatbuffers.Builder, deFactoUnique bool) {
+	builder.PrependBoolSlot(9, deFactoUnique, false)
+}
 func IndexEnd(builder *flatbuffers.Builder) flatbuffers.UOffsetT {
 	return builder.EndObject()
 }
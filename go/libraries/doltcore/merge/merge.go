@@ -31,6 +31,7 @@ import (
 	"github.com/dolthub/dolt/go/libraries/doltcore/row"
 	"github.com/dolthub/dolt/go/libraries/doltcore/schema"
 	"github.com/dolthub/dolt/go/libraries/doltcore/table/editor"
+	"github.com/dolthub/dolt/go/libraries/doltcore/table/editor/creation"
 	"github.com/dolthub/dolt/go/libraries/utils/valutil"
 	"github.com/dolthub/dolt/go/store/atomicerr"
 	"github.com/dolthub/dolt/go/store/hash"
@@ -219,6 +220,11 @@ func (merger *Merger) MergeTable(ctx context.Context, tblName string, opts edito
 			return nil, nil, err
 		}
 
+		updatedTbl, err = creation.ClearChangedDeFactoUnique(ctx, postMergeSchema, updatedTbl, tbl)
+		if err != nil {
+			return nil, nil, err
+		}
+
 		n, err := updatedTbl.NumRowsInConflict(ctx)
 		if err != nil {
 			return nil, nil, err
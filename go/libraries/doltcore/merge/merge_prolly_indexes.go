@@ -242,6 +242,7 @@ func buildIndex(ctx context.Context, vrw types.ValueReadWriter, postMergeSchema
 			postMergeSchema,
 			index,
 			m,
+			creation.BuildOptions{},
 			func(ctx context.Context, existingKey, newKey val.Tuple) (err error) {
 				eK := getSuffix(kb, p, existingKey)
 				nK := getSuffix(kb, p, newKey)
@@ -261,7 +262,7 @@ func buildIndex(ctx context.Context, vrw types.ValueReadWriter, postMergeSchema
 		return mergedMap, nil
 	}
 
-	mergedIndex, err := creation.BuildSecondaryProllyIndex(ctx, vrw, postMergeSchema, index, m)
+	mergedIndex, err := creation.BuildSecondaryProllyIndex(ctx, vrw, postMergeSchema, index, m, creation.BuildOptions{})
 	if err != nil {
 		return nil, err
 	}
@@ -361,7 +362,10 @@ func getMutableSecondaryIdxs(ctx context.Context, schema schema.Schema, tbl *dol
 		}
 		m := durable.ProllyMapFromIndex(idx)
 
-		mods[i] = NewMutableSecondaryIdx(m, schema, index, m.Pool())
+		mods[i], err = NewMutableSecondaryIdx(m, schema, index, m.Pool())
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	return mods, nil
@@ -37,16 +37,20 @@ type MutableSecondaryIdx struct {
 }
 
 // NewMutableSecondaryIdx returns a MutableSecondaryIdx. |m| is the secondary idx data.
-func NewMutableSecondaryIdx(m prolly.Map, sch schema.Schema, index schema.Index, syncPool pool.BuffPool) MutableSecondaryIdx {
+func NewMutableSecondaryIdx(m prolly.Map, sch schema.Schema, index schema.Index, syncPool pool.BuffPool) (MutableSecondaryIdx, error) {
 	kD, _ := m.Descriptors()
+	keyMap, err := creation.GetIndexKeyMapping(sch, index)
+	if err != nil {
+		return MutableSecondaryIdx{}, err
+	}
 	return MutableSecondaryIdx{
 		index.Name(),
 		m.Mutate(),
-		creation.GetIndexKeyMapping(sch, index),
+		keyMap,
 		sch.GetPKCols().Size(),
 		val.NewTupleBuilder(kD),
 		syncPool,
-	}
+	}, nil
 }
 
 // InsertEntry inserts a secondary index entry given the key and new value
@@ -62,6 +62,9 @@ type SqlEngineTableWriter struct {
 	importOption       TableImportOp
 	tableSchema        sql.PrimaryKeySchema
 	rowOperationSchema sql.PrimaryKeySchema
+	// indexDefs are the secondary indexes of a created table. They are created with the table, before the import
+	// writes any rows, so they are populated as rows are imported rather than built by a separate scan afterwards.
+	indexDefs []*plan.IndexDefinition
 }
 
 func NewSqlEngineTableWriter(ctx context.Context, dEnv *env.DoltEnv, createTableSchema, rowOperationSchema schema.Schema, options *MoverOptions, statsCB noms.StatsCB) (*SqlEngineTableWriter, error) {
@@ -133,6 +136,7 @@ func NewSqlEngineTableWriter(ctx context.Context, dEnv *env.DoltEnv, createTable
 		importOption:       options.Operation,
 		tableSchema:        doltCreateTableSchema,
 		rowOperationSchema: doltRowOperationSchema,
+		indexDefs:          indexDefinitions(createTableSchema),
 	}, nil
 }
 
@@ -172,6 +176,7 @@ func NewSqlEngineTableWriterWithEngine(ctx *sql.Context, eng *sqle.Engine, db ds
 		importOption:       options.Operation,
 		tableSchema:        doltCreateTableSchema,
 		rowOperationSchema: doltRowOperationSchema,
+		indexDefs:          indexDefinitions(createTableSchema),
 	}, nil
 }
 
@@ -313,7 +318,7 @@ func (s *SqlEngineTableWriter) createOrEmptyTableIfNeeded() error {
 
 // createTable creates a table.
 func (s *SqlEngineTableWriter) createTable() error {
-	cr := plan.NewCreateTable(sql.UnresolvedDatabase(s.database), s.tableName, false, false, &plan.TableSpec{Schema: s.tableSchema})
+	cr := plan.NewCreateTable(sql.UnresolvedDatabase(s.database), s.tableName, false, false, &plan.TableSpec{Schema: s.tableSchema, IdxDefs: s.indexDefs})
 	analyzed, err := s.se.Analyze(s.sqlCtx, cr)
 	if err != nil {
 		return err
@@ -334,6 +339,34 @@ func (s *SqlEngineTableWriter) createTable() error {
 	}
 }
 
+// indexDefinitions returns the definitions of the user-defined secondary indexes of |sch|.
+func indexDefinitions(sch schema.Schema) []*plan.IndexDefinition {
+	if sch == nil {
+		return nil
+	}
+	var defs []*plan.IndexDefinition
+	for _, idx := range sch.Indexes().AllIndexes() {
+		if !idx.IsUserDefined() {
+			continue
+		}
+		constraint := sql.IndexConstraint_None
+		if idx.IsUnique() {
+			constraint = sql.IndexConstraint_Unique
+		}
+		var cols []sql.IndexColumn
+		for _, name := range idx.ColumnNames() {
+			cols = append(cols, sql.IndexColumn{Name: name})
+		}
+		defs = append(defs, &plan.IndexDefinition{
+			IndexName:  idx.Name(),
+			Constraint: constraint,
+			Columns:    cols,
+			Comment:    idx.Comment(),
+		})
+	}
+	return defs
+}
+
 // getInsertNode returns the sql.Node to be iterated on given the import option.
 func (s *SqlEngineTableWriter) getInsertNode(inputChannel chan sql.Row) (sql.Node, error) {
 	switch s.importOption {
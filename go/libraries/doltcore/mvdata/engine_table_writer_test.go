@@ -0,0 +1,87 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mvdata
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/dolthub/go-mysql-server/sql"
+	"github.com/stretchr/testify/require"
+
+	"github.com/dolthub/dolt/go/libraries/doltcore/dtestutils"
+	"github.com/dolthub/dolt/go/libraries/doltcore/schema"
+	"github.com/dolthub/dolt/go/libraries/doltcore/table/pipeline"
+	"github.com/dolthub/dolt/go/libraries/doltcore/table/untyped/csv"
+	"github.com/dolthub/dolt/go/store/types"
+)
+
+func TestImportCreatesIndexes(t *testing.T) {
+	ctx := context.Background()
+	dEnv := dtestutils.CreateTestEnv()
+
+	sch, err := schema.SchemaFromCols(schema.NewColCollection(
+		schema.NewColumn("id", 0, types.IntKind, true, schema.NotNullConstraint{}),
+		schema.NewColumn("name", 1, types.StringKind, false),
+		schema.NewColumn("age", 2, types.IntKind, false),
+	))
+	require.NoError(t, err)
+	_, err = sch.Indexes().AddIndexByColNames("name_idx", []string{"name"}, schema.IndexProperties{IsUserDefined: true})
+	require.NoError(t, err)
+	_, err = sch.Indexes().AddIndexByColNames("age_name_idx", []string{"age", "name"}, schema.IndexProperties{IsUnique: true, IsUserDefined: true})
+	require.NoError(t, err)
+
+	wr, err := NewSqlEngineTableWriter(ctx, dEnv, sch, sch, &MoverOptions{Operation: CreateOp, TableToWriteTo: testTableName}, nil)
+	require.NoError(t, err)
+
+	const data = "id,name,age\n1,bill,30\n2,jane,25\n3,bill,40\n"
+	rd, err := csv.NewCSVReader(types.Format_Default, io.NopCloser(strings.NewReader(data)), csv.NewCSVInfo())
+	require.NoError(t, err)
+	rows := make(chan sql.Row)
+	go func() {
+		defer close(rows)
+		for {
+			r, err := rd.ReadSqlRow(ctx)
+			if err != nil {
+				return
+			}
+			rows <- r
+		}
+	}()
+
+	err = wr.WriteRows(ctx, rows, func(trf *pipeline.TransformRowFailure) bool {
+		t.Errorf("bad row: %s", trf.Details)
+		return true
+	})
+	require.Equal(t, io.EOF, err)
+	require.NoError(t, wr.Commit(ctx))
+
+	root, err := dEnv.WorkingRoot(ctx)
+	require.NoError(t, err)
+	tbl, ok, err := root.GetTable(ctx, testTableName)
+	require.NoError(t, err)
+	require.True(t, ok)
+	tblSch, err := tbl.GetSchema(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 2, tblSch.Indexes().Count())
+	for _, idx := range tblSch.Indexes().AllIndexes() {
+		rows, err := tbl.GetIndexRowData(ctx, idx.Name())
+		require.NoError(t, err)
+		require.Equal(t, uint64(3), rows.Count(), idx.Name())
+	}
+	require.True(t, tblSch.Indexes().GetByName("age_name_idx").IsUnique())
+}
@@ -152,6 +152,9 @@ type encodedIndex struct {
 	Comment         string   `noms:"comment" json:"comment"`
 	Unique          bool     `noms:"unique" json:"unique"`
 	IsSystemDefined bool     `noms:"hidden,omitempty" json:"hidden,omitempty"` // Was previously named Hidden, do not change noms name
+	PkSuffixOrder   []uint64 `noms:"pk_suffix_order,omitempty" json:"pk_suffix_order,omitempty"`
+	IsDeferred      bool     `noms:"deferred,omitempty" json:"deferred,omitempty"`
+	DeFactoUnique   bool     `noms:"de_facto_unique,omitempty" json:"de_facto_unique,omitempty"`
 }
 
 type encodedCheck struct {
@@ -236,6 +239,9 @@ func toSchemaData(sch schema.Schema) (schemaData, error) {
 			Comment:         index.Comment(),
 			Unique:          index.IsUnique(),
 			IsSystemDefined: !index.IsUserDefined(),
+			PkSuffixOrder:   index.PkSuffixOrder(),
+			IsDeferred:      index.IsDeferred(),
+			DeFactoUnique:   index.IsDeFactoUnique(),
 		}
 	}
 
@@ -294,9 +300,12 @@ func (sd schemaData) addChecksIndexesAndPkOrderingToSchema(sch schema.Schema) er
 			encodedIndex.Name,
 			encodedIndex.Tags,
 			schema.IndexProperties{
-				IsUnique:      encodedIndex.Unique,
-				IsUserDefined: !encodedIndex.IsSystemDefined,
-				Comment:       encodedIndex.Comment,
+				IsUnique:        encodedIndex.Unique,
+				IsUserDefined:   !encodedIndex.IsSystemDefined,
+				Comment:         encodedIndex.Comment,
+				PkSuffixOrder:   encodedIndex.PkSuffixOrder,
+				IsDeferred:      encodedIndex.IsDeferred,
+				IsDeFactoUnique: encodedIndex.DeFactoUnique,
 			},
 		)
 		if err != nil {
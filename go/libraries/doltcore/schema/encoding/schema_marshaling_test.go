@@ -340,3 +340,42 @@ func getTestVRW(nbf *types.NomsBinFormat) types.ValueReadWriter {
 	cs := ts.NewViewWithFormat(nbf.VersionString())
 	return types.NewValueStore(cs)
 }
+
+func TestIndexPropertiesMarshalling(t *testing.T) {
+	ctx := context.Background()
+	sch := schema.MustSchemaFromCols(schema.NewColCollection(
+		schema.NewColumn("a", 1, types.IntKind, true, schema.NotNullConstraint{}),
+		schema.NewColumn("b", 2, types.IntKind, true, schema.NotNullConstraint{}),
+		schema.NewColumn("c", 3, types.IntKind, false),
+		schema.NewColumn("d", 4, types.TimestampKind, false),
+		schema.NewColumn("e", 5, types.StringKind, false),
+	))
+	_, err := sch.Indexes().AddIndexByColTags("idx_c", []uint64{3}, schema.IndexProperties{PkSuffixOrder: []uint64{2, 1}})
+	require.NoError(t, err)
+	_, err = sch.Indexes().AddIndexByColTags("idx_cb", []uint64{3, 2}, schema.IndexProperties{})
+	require.NoError(t, err)
+	_, err = sch.Indexes().AddIndexByColTags("idx_b", []uint64{2}, schema.IndexProperties{IsDeferred: true})
+	require.NoError(t, err)
+	_, err = sch.Indexes().AddIndexByColTags("idx_d", []uint64{4}, schema.IndexProperties{IsDeFactoUnique: true})
+	require.NoError(t, err)
+
+	for _, nbf := range []*types.NomsBinFormat{types.Format_LD_1, types.Format_DOLT_1} {
+		t.Run(nbf.VersionString(), func(t *testing.T) {
+			v, err := MarshalSchemaAsNomsValue(ctx, getTestVRW(nbf), sch)
+			require.NoError(t, err)
+			s, err := UnmarshalSchemaNomsValue(ctx, nbf, v)
+			require.NoError(t, err)
+
+			idx := s.Indexes().GetByName("idx_c")
+			assert.Equal(t, []uint64{2, 1}, idx.PkSuffixOrder())
+			assert.Equal(t, []uint64{3, 2, 1}, idx.AllTags())
+			idx = s.Indexes().GetByName("idx_cb")
+			assert.Nil(t, idx.PkSuffixOrder())
+			assert.Equal(t, []uint64{3, 2, 1}, idx.AllTags())
+			assert.False(t, idx.IsDeferred())
+			assert.True(t, s.Indexes().GetByName("idx_b").IsDeferred())
+			assert.False(t, idx.IsDeFactoUnique())
+			assert.True(t, s.Indexes().GetByName("idx_d").IsDeFactoUnique())
+		})
+	}
+}
@@ -335,6 +335,8 @@ func serializeSecondaryIndexes(b *fb.Builder, sch schema.Schema, indexes []schem
 		serial.IndexAddPrimaryKey(b, false)
 		serial.IndexAddUniqueKey(b, idx.IsUnique())
 		serial.IndexAddSystemDefined(b, !idx.IsUserDefined())
+		serial.IndexAddDeferred(b, idx.IsDeferred())
+		serial.IndexAddDeFactoUnique(b, idx.IsDeFactoUnique())
 		offs[i] = serial.IndexEnd(b)
 	}
 
@@ -354,9 +356,11 @@ func deserializeSecondaryIndexes(sch schema.Schema, s *serial.TableSchema) error
 
 		name := string(idx.Name())
 		props := schema.IndexProperties{
-			IsUnique:      idx.UniqueKey(),
-			IsUserDefined: !idx.SystemDefined(),
-			Comment:       string(idx.Comment()),
+			IsUnique:        idx.UniqueKey(),
+			IsUserDefined:   !idx.SystemDefined(),
+			Comment:         string(idx.Comment()),
+			IsDeferred:      idx.Deferred(),
+			IsDeFactoUnique: idx.DeFactoUnique(),
 		}
 
 		tags := make([]uint64, idx.IndexColumnsLength())
@@ -366,6 +370,17 @@ func deserializeSecondaryIndexes(sch schema.Schema, s *serial.TableSchema) error
 			tags[j] = col.Tag()
 		}
 
+		// key columns are the index columns followed by the primary key suffix
+		suffix := make([]uint64, idx.KeyColumnsLength()-len(tags))
+		for j := range suffix {
+			pos := idx.KeyColumns(len(tags) + j)
+			s.Columns(&col, int(pos))
+			suffix[j] = col.Tag()
+		}
+		if !isDefaultPkSuffix(sch, tags, suffix) {
+			props.PkSuffixOrder = suffix
+		}
+
 		_, err := sch.Indexes().AddIndexByColTags(name, tags, props)
 		if err != nil {
 			return err
@@ -374,6 +389,27 @@ func deserializeSecondaryIndexes(sch schema.Schema, s *serial.TableSchema) error
 	return nil
 }
 
+// isDefaultPkSuffix returns whether |suffix| lists the primary key columns of |sch| that are not in |tags| in primary
+// key order.
+func isDefaultPkSuffix(sch schema.Schema, tags, suffix []uint64) bool {
+	indexed := make(map[uint64]struct{}, len(tags))
+	for _, tag := range tags {
+		indexed[tag] = struct{}{}
+	}
+	i := 0
+	for _, pk := range sch.GetPkOrdinals() {
+		tag := sch.GetAllCols().GetByIndex(pk).Tag
+		if _, ok := indexed[tag]; ok {
+			continue
+		}
+		if i >= len(suffix) || suffix[i] != tag {
+			return false
+		}
+		i++
+	}
+	return i == len(suffix)
+}
+
 func serializeChecks(b *fb.Builder, checks []schema.Check) fb.UOffsetT {
 	offs := make([]fb.UOffsetT, len(checks))
 	for i := len(offs) - 1; i >= 0; i-- {
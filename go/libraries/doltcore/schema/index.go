@@ -17,6 +17,7 @@ package schema
 import (
 	"context"
 	"io"
+	"time"
 
 	"github.com/dolthub/dolt/go/store/types"
 )
@@ -45,10 +46,32 @@ type Index interface {
 	IsUnique() bool
 	// IsUserDefined returns whether the given index was created by a user or automatically generated.
 	IsUserDefined() bool
+	// IsDeferred returns whether the index's data has not yet been built.
+	IsDeferred() bool
 	// Name returns the name of the index.
 	Name() string
 	// PrimaryKeyTags returns the primary keys of the indexed table, in the order that they're stored for that table.
 	PrimaryKeyTags() []uint64
+	// PkSuffixOrder returns the order in which non-indexed primary key columns are appended to the index key, or nil
+	// if they are appended in primary key order.
+	PkSuffixOrder() []uint64
+	// TimeBucket returns the granularity of the time bucket prepended to the index key, or zero if the index key has no
+	// time bucket.
+	TimeBucket() time.Duration
+	// ReverseStrings returns whether the char, varchar, binary and varbinary fields of the index key are stored with
+	// their characters in reverse order.
+	ReverseStrings() bool
+	// IsDeFactoUnique returns whether the non-unique index was found to have no duplicate indexed values when it was
+	// built and has not been written since. It is a hint for planning, not a constraint.
+	IsDeFactoUnique() bool
+	// Normalization returns the name of the normalization applied to the char and varchar fields of the index key, or
+	// the empty string if they are not normalized.
+	Normalization() string
+	// SamplePercent returns the percent of the rows of the table included in a sampled index, or zero if the index is
+	// not sampled.
+	SamplePercent() uint32
+	// SampleSeed returns the seed of the hash of the primary keys that chooses the rows of a sampled index.
+	SampleSeed() uint64
 	// Schema returns the schema for the internal index map. Can be used for table operations.
 	Schema() Schema
 	// ToTableTuple returns a tuple that may be used to retrieve the original row from the indexed table when given
@@ -66,6 +89,14 @@ type indexImpl struct {
 	isUnique      bool
 	isUserDefined bool
 	comment       string
+	pkSuffixOrder []uint64
+	isDeferred    bool
+	timeBucket    time.Duration
+	reverseStr    bool
+	deFactoUnique bool
+	normalization string
+	samplePercent uint32
+	sampleSeed    uint64
 }
 
 func NewIndex(name string, tags, allTags []uint64, indexColl *indexCollectionImpl, props IndexProperties) Index {
@@ -77,6 +108,32 @@ func NewIndex(name string, tags, allTags []uint64, indexColl *indexCollectionImp
 		isUnique:      props.IsUnique,
 		isUserDefined: props.IsUserDefined,
 		comment:       props.Comment,
+		pkSuffixOrder: props.PkSuffixOrder,
+		isDeferred:    props.IsDeferred,
+		timeBucket:    props.TimeBucket,
+		reverseStr:    props.ReverseStrings,
+		deFactoUnique: props.IsDeFactoUnique,
+		normalization: props.Normalization,
+		samplePercent: props.SamplePercent,
+		sampleSeed:    props.SampleSeed,
+	}
+}
+
+// IndexPropertiesOf returns the properties of |idx|, so that it can be redefined over other columns with the same
+// properties.
+func IndexPropertiesOf(idx Index) IndexProperties {
+	return IndexProperties{
+		IsUnique:        idx.IsUnique(),
+		IsUserDefined:   idx.IsUserDefined(),
+		Comment:         idx.Comment(),
+		PkSuffixOrder:   idx.PkSuffixOrder(),
+		IsDeferred:      idx.IsDeferred(),
+		TimeBucket:      idx.TimeBucket(),
+		ReverseStrings:  idx.ReverseStrings(),
+		IsDeFactoUnique: idx.IsDeFactoUnique(),
+		Normalization:   idx.Normalization(),
+		SamplePercent:   idx.SamplePercent(),
+		SampleSeed:      idx.SampleSeed(),
 	}
 }
 
@@ -144,6 +201,38 @@ func (ix *indexImpl) DeepEquals(other Index) bool {
 		ix.Name() == other.Name()
 }
 
+// IndexesAreDataCompatible returns whether the data of index |a| can be reused as the data of index |b|, e.g. when
+// an index is renamed or copied. This is the case if both indexes key the same columns, including the appended primary
+// key columns, in the same order and with the same types, collations included, and agree on uniqueness, time buckets
+// reversed strings, normalization and sampling. The names and comments of the indexes are ignored. A deferred index has
+// no data, so it is compatible with no index.
+func IndexesAreDataCompatible(a, b Index) bool {
+	if a.IsDeferred() || b.IsDeferred() || a.IsUnique() != b.IsUnique() || a.Count() != b.Count() || a.TimeBucket() != b.TimeBucket() {
+		return false
+	}
+	if a.ReverseStrings() != b.ReverseStrings() || a.Normalization() != b.Normalization() {
+		return false
+	}
+	if a.SamplePercent() != b.SamplePercent() || a.SampleSeed() != b.SampleSeed() {
+		return false
+	}
+	at, bt := a.AllTags(), b.AllTags()
+	if len(at) != len(bt) {
+		return false
+	}
+	for i := range at {
+		if at[i] != bt[i] {
+			return false
+		}
+		ac, aok := a.GetColumn(at[i])
+		bc, bok := b.GetColumn(bt[i])
+		if !aok || !bok || !ac.TypeInfo.Equals(bc.TypeInfo) {
+			return false
+		}
+	}
+	return true
+}
+
 // GetColumn implements Index.
 func (ix *indexImpl) GetColumn(tag uint64) (Column, bool) {
 	return ix.indexColl.colColl.GetByTag(tag)
@@ -164,6 +253,11 @@ func (ix *indexImpl) IsUserDefined() bool {
 	return ix.isUserDefined
 }
 
+// IsDeferred implements Index.
+func (ix *indexImpl) IsDeferred() bool {
+	return ix.isDeferred
+}
+
 // Name implements Index.
 func (ix *indexImpl) Name() string {
 	return ix.name
@@ -174,6 +268,41 @@ func (ix *indexImpl) PrimaryKeyTags() []uint64 {
 	return ix.indexColl.pks
 }
 
+// TimeBucket implements Index.
+func (ix *indexImpl) TimeBucket() time.Duration {
+	return ix.timeBucket
+}
+
+// ReverseStrings implements Index.
+func (ix *indexImpl) ReverseStrings() bool {
+	return ix.reverseStr
+}
+
+// IsDeFactoUnique implements Index.
+func (ix *indexImpl) IsDeFactoUnique() bool {
+	return ix.deFactoUnique
+}
+
+// Normalization implements Index.
+func (ix *indexImpl) Normalization() string {
+	return ix.normalization
+}
+
+// SamplePercent implements Index.
+func (ix *indexImpl) SamplePercent() uint32 {
+	return ix.samplePercent
+}
+
+// SampleSeed implements Index.
+func (ix *indexImpl) SampleSeed() uint64 {
+	return ix.sampleSeed
+}
+
+// PkSuffixOrder implements Index.
+func (ix *indexImpl) PkSuffixOrder() []uint64 {
+	return ix.pkSuffixOrder
+}
+
 // Schema implements Index.
 func (ix *indexImpl) Schema() Schema {
 	cols := make([]Column, len(ix.allTags))
@@ -243,5 +372,9 @@ func (ix *indexImpl) copy() *indexImpl {
 	_ = copy(newIx.tags, ix.tags)
 	newIx.allTags = make([]uint64, len(ix.allTags))
 	_ = copy(newIx.allTags, ix.allTags)
+	if ix.pkSuffixOrder != nil {
+		newIx.pkSuffixOrder = make([]uint64, len(ix.pkSuffixOrder))
+		_ = copy(newIx.pkSuffixOrder, ix.pkSuffixOrder)
+	}
 	return &newIx
 }
@@ -18,6 +18,7 @@ import (
 	"fmt"
 	"sort"
 	"strings"
+	"time"
 )
 
 type IndexCollection interface {
@@ -70,6 +71,33 @@ type IndexProperties struct {
 	IsUnique      bool
 	IsUserDefined bool
 	Comment       string
+	// PkSuffixOrder, if non-empty, is the order in which the primary key columns that are not indexed columns are
+	// appended to the index key. It must contain each of those columns exactly once. By default, they are appended in
+	// the table's primary key order.
+	PkSuffixOrder []uint64
+	// IsDeferred is true if the index's data has not been built. A deferred index must be materialized before it can
+	// be read.
+	IsDeferred bool
+	// TimeBucket, if non-zero, is the granularity of a time bucket that is prepended to the index key, so that entries
+	// are clustered by bucket. The bucket of an entry is the value of the first indexed datetime or timestamp column,
+	// truncated to a multiple of TimeBucket.
+	TimeBucket time.Duration
+	// ReverseStrings is true if the char, varchar, binary and varbinary fields of the index key are stored with their
+	// characters in reverse order, so that a suffix match of the indexed strings is a prefix scan of the index.
+	ReverseStrings bool
+	// IsDeFactoUnique is true if a non-unique index had no two entries with the same non-NULL indexed values when it
+	// was built, so that the planner may treat it as unique. It is cleared when the index data is written.
+	IsDeFactoUnique bool
+	// Normalization, if non-empty, is the name of a normalization, such as the E.164 phone number or email address
+	// normalizations of the creation package, applied to the char and varchar fields of the index key before they are
+	// encoded, so that lookups of differently formatted values find the same entries. Lookups must apply it too.
+	Normalization string
+	// SamplePercent, if non-zero, makes the index a sampled index, which includes only the rows whose primary key hashes,
+	// with SampleSeed, to a value below SamplePercent modulo 100. The same rows are included by every build, so the
+	// index supports approximate queries over a stable fraction of the table. Writes do not sample rows.
+	SamplePercent uint32
+	// SampleSeed is the seed of the hash of the primary keys that chooses the rows of a sampled index.
+	SampleSeed uint64
 }
 
 type indexCollectionImpl struct {
@@ -109,7 +137,7 @@ func (ixc *indexCollectionImpl) AddIndex(indexes ...Index) {
 		}
 		index = index.copy()
 		index.indexColl = ixc
-		index.allTags = combineAllTags(index.tags, ixc.pks)
+		index.allTags = combineAllTags(index.tags, pkSuffix(index.tags, index.pkSuffixOrder, ixc.pks))
 		oldNamedIndex, ok := ixc.indexes[index.name]
 		if ok {
 			ixc.removeIndex(oldNamedIndex)
@@ -152,15 +180,41 @@ func (ixc *indexCollectionImpl) AddIndexByColTags(indexName string, tags []uint6
 			return nil, err
 		}
 	}
+	if len(props.PkSuffixOrder) > 0 && !isPkSuffix(tags, props.PkSuffixOrder, ixc.pks) {
+		return nil, fmt.Errorf("primary key suffix order %v must contain each non-indexed primary key column of the table exactly once", props.PkSuffixOrder)
+	}
+	if err := ixc.validateTimeBucket(tags, props); err != nil {
+		return nil, err
+	}
+	if err := ixc.validateReverseStrings(tags, props); err != nil {
+		return nil, err
+	}
+	if err := ixc.validateNormalization(tags, props); err != nil {
+		return nil, err
+	}
+	if err := validateSample(props); err != nil {
+		return nil, err
+	}
+	if props.IsDeFactoUnique && (props.IsUnique || props.IsDeferred) {
+		return nil, fmt.Errorf("a unique or deferred index cannot be de facto unique")
+	}
 
 	index := &indexImpl{
 		indexColl:     ixc,
 		name:          indexName,
 		tags:          tags,
-		allTags:       combineAllTags(tags, ixc.pks),
+		allTags:       combineAllTags(tags, pkSuffix(tags, props.PkSuffixOrder, ixc.pks)),
 		isUnique:      props.IsUnique,
 		isUserDefined: props.IsUserDefined,
 		comment:       props.Comment,
+		pkSuffixOrder: props.PkSuffixOrder,
+		isDeferred:    props.IsDeferred,
+		timeBucket:    props.TimeBucket,
+		reverseStr:    props.ReverseStrings,
+		deFactoUnique: props.IsDeFactoUnique,
+		normalization: props.Normalization,
+		samplePercent: props.SamplePercent,
+		sampleSeed:    props.SampleSeed,
 	}
 	ixc.indexes[indexName] = index
 	for _, tag := range tags {
@@ -177,15 +231,86 @@ func validateColumnIndexable(c Column) error {
 	return nil
 }
 
+// validateTimeBucket returns an error if an index over |tags| cannot have the time bucket of |props|.
+func (ixc *indexCollectionImpl) validateTimeBucket(tags []uint64, props IndexProperties) error {
+	if props.TimeBucket == 0 {
+		return nil
+	}
+	if props.TimeBucket < 0 {
+		return fmt.Errorf("invalid index time bucket %s", props.TimeBucket)
+	}
+	if props.IsUnique {
+		return fmt.Errorf("unique indexes cannot have a time bucket")
+	}
+	for _, tag := range tags {
+		c, _ := ixc.colColl.GetByTag(tag)
+		if IsColTimeBucketType(c) {
+			return nil
+		}
+	}
+	return fmt.Errorf("an index with a time bucket must include a datetime or timestamp column")
+}
+
+// validateReverseStrings returns an error if an index over |tags| cannot reverse its strings as |props| asks.
+func (ixc *indexCollectionImpl) validateReverseStrings(tags []uint64, props IndexProperties) error {
+	if !props.ReverseStrings {
+		return nil
+	}
+	for _, tag := range tags {
+		c, _ := ixc.colColl.GetByTag(tag)
+		if IsColReverseStringType(c) {
+			return nil
+		}
+	}
+	return fmt.Errorf("an index with reversed strings must include a char, varchar, binary or varbinary column")
+}
+
+func (ixc *indexCollectionImpl) validateNormalization(tags []uint64, props IndexProperties) error {
+	if props.Normalization == "" {
+		return nil
+	}
+	for _, tag := range tags {
+		c, _ := ixc.colColl.GetByTag(tag)
+		if IsColNormalizedStringType(c) {
+			return nil
+		}
+	}
+	return fmt.Errorf("a normalized index must include a char or varchar column")
+}
+
+func validateSample(props IndexProperties) error {
+	if props.SamplePercent == 0 {
+		if props.SampleSeed != 0 {
+			return fmt.Errorf("only a sampled index can have a sample seed")
+		}
+		return nil
+	}
+	if props.SamplePercent >= 100 {
+		return fmt.Errorf("the sample percent of an index must be between 1 and 99, not %d", props.SamplePercent)
+	}
+	if props.IsUnique {
+		return fmt.Errorf("a unique index cannot be sampled")
+	}
+	return nil
+}
+
 func (ixc *indexCollectionImpl) UnsafeAddIndexByColTags(indexName string, tags []uint64, props IndexProperties) (Index, error) {
 	index := &indexImpl{
 		indexColl:     ixc,
 		name:          indexName,
 		tags:          tags,
-		allTags:       combineAllTags(tags, ixc.pks),
+		allTags:       combineAllTags(tags, pkSuffix(tags, props.PkSuffixOrder, ixc.pks)),
 		isUnique:      props.IsUnique,
 		isUserDefined: props.IsUserDefined,
 		comment:       props.Comment,
+		pkSuffixOrder: props.PkSuffixOrder,
+		isDeferred:    props.IsDeferred,
+		timeBucket:    props.TimeBucket,
+		reverseStr:    props.ReverseStrings,
+		deFactoUnique: props.IsDeFactoUnique,
+		normalization: props.Normalization,
+		samplePercent: props.SamplePercent,
+		sampleSeed:    props.SampleSeed,
 	}
 	ixc.indexes[indexName] = index
 	for _, tag := range tags {
@@ -428,6 +553,37 @@ func (ixc *indexCollectionImpl) SetPks(tags []uint64) error {
 	return nil
 }
 
+// pkSuffix returns the order in which the primary key columns |pks| are appended to the key of an index over |tags|.
+// |order| is used if it is a valid suffix order for the index, otherwise the primary key order is used.
+func pkSuffix(tags, order, pks []uint64) []uint64 {
+	if len(order) > 0 && isPkSuffix(tags, order, pks) {
+		return order
+	}
+	return pks
+}
+
+// isPkSuffix returns whether |order| contains each of the primary key columns |pks| that are not in |tags| exactly
+// once, and nothing else.
+func isPkSuffix(tags, order, pks []uint64) bool {
+	remaining := make(map[uint64]struct{}, len(pks))
+	for _, pk := range pks {
+		remaining[pk] = struct{}{}
+	}
+	for _, tag := range tags {
+		delete(remaining, tag)
+	}
+	if len(order) != len(remaining) {
+		return false
+	}
+	for _, tag := range order {
+		if _, ok := remaining[tag]; !ok {
+			return false
+		}
+		delete(remaining, tag)
+	}
+	return true
+}
+
 func combineAllTags(tags []uint64, pks []uint64) []uint64 {
 	allTags := make([]uint64, len(tags))
 	_ = copy(allTags, tags)
@@ -16,10 +16,14 @@ package schema
 
 import (
 	"testing"
+	"time"
 
+	"github.com/dolthub/go-mysql-server/sql"
+	"github.com/dolthub/vitess/go/sqltypes"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"github.com/dolthub/dolt/go/libraries/doltcore/schema/typeinfo"
 	"github.com/dolthub/dolt/go/store/types"
 )
 
@@ -545,9 +549,209 @@ func TestIndexCollectionDuplicateIndexes(t *testing.T) {
 	indexColl.clear(t)
 }
 
+func TestIndexCollectionPkSuffixOrder(t *testing.T) {
+	colColl := NewColCollection(
+		NewColumn("pk1", 1, types.IntKind, true, NotNullConstraint{}),
+		NewColumn("pk2", 2, types.IntKind, true, NotNullConstraint{}),
+		NewColumn("pk3", 3, types.IntKind, true, NotNullConstraint{}),
+		NewColumn("v1", 4, types.IntKind, false),
+	)
+	indexColl := NewIndexCollection(colColl, nil)
+
+	idx, err := indexColl.AddIndexByColTags("idx_v1", []uint64{4}, IndexProperties{PkSuffixOrder: []uint64{3, 1, 2}})
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{4, 3, 1, 2}, idx.AllTags())
+	assert.Equal(t, []uint64{3, 1, 2}, idx.PkSuffixOrder())
+
+	// indexed primary key columns are not part of the suffix
+	idx, err = indexColl.AddIndexByColTags("idx_pk2v1", []uint64{2, 4}, IndexProperties{PkSuffixOrder: []uint64{3, 1}})
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{2, 4, 3, 1}, idx.AllTags())
+
+	idx, err = indexColl.AddIndexByColTags("idx_v1pk1", []uint64{4, 1}, IndexProperties{})
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{4, 1, 2, 3}, idx.AllTags())
+	assert.Nil(t, idx.PkSuffixOrder())
+
+	for _, order := range [][]uint64{{3}, {3, 1, 2}, {3, 1, 4}, {2, 2}} {
+		_, err = indexColl.AddIndexByColTags("idx_bad", []uint64{4, 2}, IndexProperties{PkSuffixOrder: order})
+		assert.Error(t, err, "%v", order)
+	}
+
+	// copies keep their suffix order
+	other := NewIndexCollection(colColl, nil)
+	other.AddIndex(indexColl.GetByName("idx_v1"))
+	assert.Equal(t, []uint64{4, 3, 1, 2}, other.GetByName("idx_v1").AllTags())
+}
+
 func (ixc *indexCollectionImpl) clear(_ *testing.T) {
 	ixc.indexes = make(map[string]*indexImpl)
 	for key := range ixc.colTagToIndex {
 		ixc.colTagToIndex[key] = nil
 	}
 }
+
+func TestIndexesAreDataCompatible(t *testing.T) {
+	colColl := NewColCollection(
+		NewColumn("pk1", 1, types.IntKind, true, NotNullConstraint{}),
+		NewColumn("pk2", 2, types.IntKind, true, NotNullConstraint{}),
+		NewColumn("v1", 3, types.IntKind, false),
+		NewColumn("v2", 4, types.StringKind, false),
+	)
+	indexColl := NewIndexCollection(colColl, nil)
+	add := func(name string, tags []uint64, props IndexProperties) Index {
+		idx, err := indexColl.AddIndexByColTags(name, tags, props)
+		require.NoError(t, err)
+		return idx
+	}
+	idx := add("idx", []uint64{3, 4}, IndexProperties{IsUserDefined: true})
+
+	// the same columns of a table whose v2 has a different collation
+	ci, err := typeinfo.FromSqlType(sql.MustCreateString(sqltypes.VarChar, 16383, sql.Collation_utf8mb4_general_ci))
+	require.NoError(t, err)
+	ciCol, err := NewColumnWithTypeInfo("v2", 4, ci, false, "", false, "")
+	require.NoError(t, err)
+	collated := NewColCollection(
+		NewColumn("pk1", 1, types.IntKind, true, NotNullConstraint{}),
+		NewColumn("pk2", 2, types.IntKind, true, NotNullConstraint{}),
+		NewColumn("v1", 3, types.IntKind, false),
+		ciCol,
+	)
+	collatedIdx, err := NewIndexCollection(collated, nil).AddIndexByColTags("idx", []uint64{3, 4}, IndexProperties{IsUserDefined: true})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		other      Index
+		compatible bool
+	}{
+		{"itself", idx, true},
+		{"renamed", add("renamed", []uint64{3, 4}, IndexProperties{IsUserDefined: true, Comment: "renamed"}), true},
+		{"reordered columns", add("reordered", []uint64{4, 3}, IndexProperties{}), false},
+		{"fewer columns", add("fewer", []uint64{3}, IndexProperties{}), false},
+		{"more columns", add("more", []uint64{3, 4, 2}, IndexProperties{}), false},
+		{"unique", add("unique", []uint64{3, 4}, IndexProperties{IsUnique: true}), false},
+		{"primary key suffix order", add("suffix", []uint64{3, 4}, IndexProperties{PkSuffixOrder: []uint64{2, 1}}), false},
+		{"deferred", add("deferred", []uint64{3, 4}, IndexProperties{IsDeferred: true}), false},
+		{"collation", collatedIdx, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.compatible, IndexesAreDataCompatible(idx, test.other))
+			assert.Equal(t, test.compatible, IndexesAreDataCompatible(test.other, idx))
+		})
+	}
+}
+
+func TestIndexCollectionTimeBucket(t *testing.T) {
+	colColl := NewColCollection(
+		NewColumn("pk", 1, types.IntKind, true, NotNullConstraint{}),
+		NewColumn("v1", 2, types.IntKind, false),
+		NewColumn("ts", 3, types.TimestampKind, false),
+	)
+	indexColl := NewIndexCollection(colColl, nil)
+
+	idx, err := indexColl.AddIndexByColTags("idx_v1_ts", []uint64{2, 3}, IndexProperties{TimeBucket: time.Hour})
+	require.NoError(t, err)
+	assert.Equal(t, time.Hour, idx.TimeBucket())
+	assert.Equal(t, []uint64{2, 3, 1}, idx.AllTags())
+
+	_, err = indexColl.AddIndexByColTags("idx_v1", []uint64{2}, IndexProperties{TimeBucket: time.Hour})
+	assert.Error(t, err)
+	_, err = indexColl.AddIndexByColTags("idx_ts", []uint64{3}, IndexProperties{TimeBucket: time.Hour, IsUnique: true})
+	assert.Error(t, err)
+	_, err = indexColl.AddIndexByColTags("idx_ts", []uint64{3}, IndexProperties{TimeBucket: -time.Hour})
+	assert.Error(t, err)
+
+	// an index with a different time bucket keys its data differently
+	other, err := indexColl.AddIndexByColTags("idx_v1_ts_day", []uint64{2, 3}, IndexProperties{TimeBucket: 24 * time.Hour})
+	require.NoError(t, err)
+	assert.False(t, IndexesAreDataCompatible(idx, other))
+}
+
+func TestIndexCollectionReverseStrings(t *testing.T) {
+	colColl := NewColCollection(
+		NewColumn("pk", 1, types.IntKind, true, NotNullConstraint{}),
+		NewColumn("v1", 2, types.IntKind, false),
+		NewColumn("name", 3, types.StringKind, false),
+	)
+	indexColl := NewIndexCollection(colColl, nil)
+
+	idx, err := indexColl.AddIndexByColTags("idx_name", []uint64{3}, IndexProperties{ReverseStrings: true})
+	require.NoError(t, err)
+	assert.True(t, idx.ReverseStrings())
+	_, err = indexColl.AddIndexByColTags("idx_v1", []uint64{2}, IndexProperties{ReverseStrings: true})
+	assert.Error(t, err)
+
+	// an index with reversed strings keys its data differently
+	other, err := indexColl.AddIndexByColTags("idx_name_fwd", []uint64{3}, IndexProperties{})
+	require.NoError(t, err)
+	assert.False(t, IndexesAreDataCompatible(idx, other))
+}
+
+func TestIndexCollectionDeFactoUnique(t *testing.T) {
+	colColl := NewColCollection(
+		NewColumn("pk", 1, types.IntKind, true, NotNullConstraint{}),
+		NewColumn("v1", 2, types.IntKind, false),
+	)
+	indexColl := NewIndexCollection(colColl, nil)
+
+	idx, err := indexColl.AddIndexByColTags("idx_v1", []uint64{2}, IndexProperties{IsDeFactoUnique: true})
+	require.NoError(t, err)
+	assert.True(t, idx.IsDeFactoUnique())
+	_, err = indexColl.AddIndexByColTags("idx_v1_uniq", []uint64{2}, IndexProperties{IsUnique: true, IsDeFactoUnique: true})
+	assert.Error(t, err)
+	_, err = indexColl.AddIndexByColTags("idx_v1_deferred", []uint64{2}, IndexProperties{IsDeferred: true, IsDeFactoUnique: true})
+	assert.Error(t, err)
+
+	// the hint does not change the data of the index
+	other, err := indexColl.AddIndexByColTags("idx_v1_plain", []uint64{2}, IndexProperties{})
+	require.NoError(t, err)
+	assert.False(t, other.IsDeFactoUnique())
+	assert.True(t, IndexesAreDataCompatible(idx, other))
+}
+
+func TestIndexCollectionNormalization(t *testing.T) {
+	colColl := NewColCollection(
+		NewColumn("pk", 1, types.IntKind, true, NotNullConstraint{}),
+		NewColumn("v1", 2, types.IntKind, false),
+		NewColumn("email", 3, types.StringKind, false),
+	)
+	indexColl := NewIndexCollection(colColl, nil)
+
+	idx, err := indexColl.AddIndexByColTags("idx_email", []uint64{3}, IndexProperties{Normalization: "email"})
+	require.NoError(t, err)
+	assert.Equal(t, "email", idx.Normalization())
+	_, err = indexColl.AddIndexByColTags("idx_v1", []uint64{2}, IndexProperties{Normalization: "email"})
+	assert.Error(t, err)
+
+	// a normalized index keys its data differently
+	other, err := indexColl.AddIndexByColTags("idx_email_raw", []uint64{3}, IndexProperties{})
+	require.NoError(t, err)
+	assert.Empty(t, other.Normalization())
+	assert.False(t, IndexesAreDataCompatible(idx, other))
+}
+
+func TestIndexCollectionSample(t *testing.T) {
+	colColl := NewColCollection(
+		NewColumn("pk", 1, types.IntKind, true, NotNullConstraint{}),
+		NewColumn("v1", 2, types.IntKind, false),
+	)
+	indexColl := NewIndexCollection(colColl, nil)
+
+	idx, err := indexColl.AddIndexByColTags("idx_sample", []uint64{2}, IndexProperties{SamplePercent: 10, SampleSeed: 7})
+	require.NoError(t, err)
+	assert.Equal(t, uint32(10), idx.SamplePercent())
+	assert.Equal(t, uint64(7), idx.SampleSeed())
+	_, err = indexColl.AddIndexByColTags("idx_all", []uint64{2}, IndexProperties{SamplePercent: 100})
+	assert.Error(t, err)
+	_, err = indexColl.AddIndexByColTags("idx_seed", []uint64{2}, IndexProperties{SampleSeed: 7})
+	assert.Error(t, err)
+	_, err = indexColl.AddIndexByColTags("idx_unique", []uint64{2}, IndexProperties{SamplePercent: 10, IsUnique: true})
+	assert.Error(t, err)
+
+	// indexes sampled with another seed include other rows
+	other, err := indexColl.AddIndexByColTags("idx_other", []uint64{2}, IndexProperties{SamplePercent: 10, SampleSeed: 8})
+	require.NoError(t, err)
+	assert.False(t, IndexesAreDataCompatible(idx, other))
+}
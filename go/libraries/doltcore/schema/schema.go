@@ -217,6 +217,35 @@ func IsColSpatialType(c Column) bool {
 	return c.TypeInfo.ToSqlType().Type() == query.Type_GEOMETRY
 }
 
+// IsColTimeBucketType returns whether a column's values can be truncated to the time bucket of an index, which is the
+// case for datetime and timestamp columns.
+func IsColTimeBucketType(c Column) bool {
+	typ := c.TypeInfo.ToSqlType().Type()
+	return typ == query.Type_DATETIME || typ == query.Type_TIMESTAMP
+}
+
+// IsColReverseStringType returns whether a column's values can be reversed in the keys of an index with reversed
+// strings, which is the case for char, varchar, binary and varbinary columns.
+func IsColReverseStringType(c Column) bool {
+	switch c.TypeInfo.ToSqlType().Type() {
+	case query.Type_CHAR, query.Type_VARCHAR, query.Type_BINARY, query.Type_VARBINARY:
+		return true
+	default:
+		return false
+	}
+}
+
+// IsColNormalizedStringType returns whether a column's values are normalized in the keys of an index with a
+// normalization, which is the case for char and varchar columns.
+func IsColNormalizedStringType(c Column) bool {
+	switch c.TypeInfo.ToSqlType().Type() {
+	case query.Type_CHAR, query.Type_VARCHAR:
+		return true
+	default:
+		return false
+	}
+}
+
 // IsUsingSpatialColAsKey is a utility function that checks for any spatial types being used as a primary key
 func IsUsingSpatialColAsKey(sch Schema) bool {
 	pkCols := sch.GetPKCols()
@@ -268,11 +268,16 @@ func replaceColumnInSchema(sch schema.Schema, oldCol schema.Column, newCol schem
 				tags[i] = newCol.Tag
 			}
 		}
-		_, err = newSch.Indexes().AddIndexByColTags(index.Name(), tags, schema.IndexProperties{
-			IsUnique:      index.IsUnique(),
-			IsUserDefined: index.IsUserDefined(),
-			Comment:       index.Comment(),
-		})
+		var pkSuffix []uint64
+		for _, tag := range index.PkSuffixOrder() {
+			if tag == oldCol.Tag {
+				tag = newCol.Tag
+			}
+			pkSuffix = append(pkSuffix, tag)
+		}
+		props := schema.IndexPropertiesOf(index)
+		props.PkSuffixOrder = pkSuffix
+		_, err = newSch.Indexes().AddIndexByColTags(index.Name(), tags, props)
 		if err != nil {
 			return nil, err
 		}
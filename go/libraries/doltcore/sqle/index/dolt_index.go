@@ -116,6 +116,10 @@ func DoltIndexesFromTable(ctx context.Context, db, tbl string, t *doltdb.Table)
 	}
 
 	for _, definition := range sch.Indexes().AllIndexes() {
+		if definition.IsDeferred() {
+			// deferred indexes have no data until they are materialized
+			continue
+		}
 		idx, err := getSecondaryIndex(ctx, db, tbl, t, sch, definition)
 		if err != nil {
 			return nil, err
@@ -143,6 +147,9 @@ func TableHasIndex(ctx context.Context, db, tbl string, t *doltdb.Table, i sql.I
 	}
 
 	for _, definition := range sch.Indexes().AllIndexes() {
+		if definition.IsDeferred() {
+			continue
+		}
 		idx, err := getSecondaryIndex(ctx, db, tbl, t, sch, definition)
 		if err != nil {
 			return false, err
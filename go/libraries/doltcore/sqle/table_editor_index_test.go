@@ -27,7 +27,9 @@ import (
 	"github.com/dolthub/dolt/go/libraries/doltcore/dtestutils"
 	"github.com/dolthub/dolt/go/libraries/doltcore/env"
 	"github.com/dolthub/dolt/go/libraries/doltcore/row"
+	"github.com/dolthub/dolt/go/libraries/doltcore/schema"
 	"github.com/dolthub/dolt/go/libraries/doltcore/sqle/sqlutil"
+	"github.com/dolthub/dolt/go/libraries/doltcore/table/editor/creation"
 	"github.com/dolthub/dolt/go/store/types"
 )
 
@@ -343,6 +345,123 @@ UPDATE oneuni SET v1 = v1 + pk1;
 	}
 }
 
+func TestDeferredIndex(t *testing.T) {
+	if !types.IsFormat_DOLT_1(types.Format_Default) {
+		t.Skip()
+	}
+	ctx := context.Background()
+	dEnv := dtestutils.CreateTestEnv()
+	root, err := dEnv.WorkingRoot(ctx)
+	require.NoError(t, err)
+	root, err = ExecuteSql(t, dEnv, root, `
+CREATE TABLE onepk (
+  pk1 BIGINT PRIMARY KEY,
+  v1 BIGINT
+);
+INSERT INTO onepk VALUES (1, 10), (2, 20), (3, 30);
+`)
+	require.NoError(t, err)
+
+	tbl, _, err := root.GetTable(ctx, "onepk")
+	require.NoError(t, err)
+	sch, err := tbl.GetSchema(ctx)
+	require.NoError(t, err)
+	v1, ok := sch.GetAllCols().GetByName("v1")
+	require.True(t, ok)
+	res, err := creation.CreateIndexWithProperties(ctx, tbl, "idx_v1", []uint64{v1.Tag}, schema.IndexProperties{IsDeferred: true, IsUserDefined: true}, creation.BuildOptions{})
+	require.NoError(t, err)
+	root, err = root.PutTable(ctx, "onepk", res.NewTable)
+	require.NoError(t, err)
+	require.NoError(t, dEnv.UpdateWorkingRoot(ctx, root))
+
+	indexCount := func(root *doltdb.RootValue) uint64 {
+		tbl, _, err := root.GetTable(ctx, "onepk")
+		require.NoError(t, err)
+		rows, err := tbl.GetIndexRowData(ctx, "idx_v1")
+		require.NoError(t, err)
+		return rows.Count()
+	}
+	selectV1 := func(root *doltdb.RootValue) []sql.Row {
+		rows, err := ExecuteSelect(t, dEnv, dEnv.DoltDB, root, "SELECT pk1 FROM onepk WHERE v1 = 20")
+		require.NoError(t, err)
+		return rows
+	}
+
+	// the planner does not use the empty data of the deferred index, and writes do not maintain it
+	assert.Equal(t, []sql.Row{{int64(2)}}, selectV1(root))
+	root, err = executeModify(t, ctx, dEnv, root, "INSERT INTO onepk VALUES (4, 20)")
+	require.NoError(t, err)
+	assert.Equal(t, []sql.Row{{int64(2)}, {int64(4)}}, selectV1(root))
+	assert.Equal(t, uint64(0), indexCount(root))
+
+	tbl, _, err = root.GetTable(ctx, "onepk")
+	require.NoError(t, err)
+	tbl, err = creation.MaterializeDeferredIndex(ctx, tbl, "idx_v1", creation.BuildOptions{})
+	require.NoError(t, err)
+	root, err = root.PutTable(ctx, "onepk", tbl)
+	require.NoError(t, err)
+	require.NoError(t, dEnv.UpdateWorkingRoot(ctx, root))
+	assert.Equal(t, uint64(4), indexCount(root))
+	assert.Equal(t, []sql.Row{{int64(2)}, {int64(4)}}, selectV1(root))
+	root, err = executeModify(t, ctx, dEnv, root, "DELETE FROM onepk WHERE pk1 = 2")
+	require.NoError(t, err)
+	assert.Equal(t, uint64(3), indexCount(root))
+	assert.Equal(t, []sql.Row{{int64(4)}}, selectV1(root))
+}
+
+func TestDeferredIndexSurvivesAlter(t *testing.T) {
+	if !types.IsFormat_DOLT_1(types.Format_Default) {
+		t.Skip()
+	}
+	ctx := context.Background()
+	dEnv := dtestutils.CreateTestEnv()
+	root, err := dEnv.WorkingRoot(ctx)
+	require.NoError(t, err)
+	root, err = ExecuteSql(t, dEnv, root, `
+CREATE TABLE onepk (
+  pk1 BIGINT PRIMARY KEY,
+  v1 BIGINT
+);
+INSERT INTO onepk VALUES (1, 10), (2, 20), (3, 30);
+`)
+	require.NoError(t, err)
+
+	tbl, _, err := root.GetTable(ctx, "onepk")
+	require.NoError(t, err)
+	sch, err := tbl.GetSchema(ctx)
+	require.NoError(t, err)
+	v1, ok := sch.GetAllCols().GetByName("v1")
+	require.True(t, ok)
+	res, err := creation.CreateIndexWithProperties(ctx, tbl, "idx_v1", []uint64{v1.Tag}, schema.IndexProperties{IsDeferred: true, IsUserDefined: true}, creation.BuildOptions{})
+	require.NoError(t, err)
+	root, err = root.PutTable(ctx, "onepk", res.NewTable)
+	require.NoError(t, err)
+
+	alter := func(root *doltdb.RootValue, query string) *doltdb.RootValue {
+		require.NoError(t, dEnv.UpdateWorkingRoot(ctx, root))
+		root, err := executeModify(t, ctx, dEnv, root, query)
+		require.NoError(t, err)
+		tbl, _, err := root.GetTable(ctx, "onepk")
+		require.NoError(t, err)
+		sch, err := tbl.GetSchema(ctx)
+		require.NoError(t, err)
+		idx := sch.Indexes().GetByName("idx_v1")
+		require.NotNil(t, idx)
+		assert.True(t, idx.IsDeferred())
+		rows, err := tbl.GetIndexRowData(ctx, "idx_v1")
+		require.NoError(t, err)
+		assert.Equal(t, uint64(0), rows.Count())
+		return root
+	}
+
+	// renaming a column redefines its indexes, and modifying its type rewrites the table
+	root = alter(root, "ALTER TABLE onepk RENAME COLUMN v1 TO v2")
+	root = alter(root, "ALTER TABLE onepk MODIFY COLUMN v2 VARCHAR(20)")
+	rows, err := ExecuteSelect(t, dEnv, dEnv.DoltDB, root, "SELECT pk1 FROM onepk WHERE v2 = '20'")
+	require.NoError(t, err)
+	assert.Equal(t, []sql.Row{{int64(2)}}, rows)
+}
+
 func convertSqlRowToInt64(sqlRows []sql.Row) []sql.Row {
 	if sqlRows == nil {
 		return nil
@@ -1209,11 +1209,7 @@ func (t *AlterableDoltTable) RewriteInserter(
 					colNames = append(colNames, colName)
 				}
 			}
-			newSch.Indexes().AddIndexByColNames(index.Name(), colNames, schema.IndexProperties{
-				IsUnique:      index.IsUnique(),
-				IsUserDefined: index.IsUserDefined(),
-				Comment:       index.Comment(),
-			})
+			newSch.Indexes().AddIndexByColNames(index.Name(), colNames, schema.IndexPropertiesOf(index))
 		}
 	} else {
 		newSch = schema.CopyIndexes(oldSch, newSch)
@@ -1619,7 +1615,7 @@ func (t *AlterableDoltTable) CreateIndex(
 		constraint == sql.IndexConstraint_Unique,
 		true,
 		comment,
-		t.opts,
+		creation.BuildOptions{EditOptions: t.opts},
 	)
 	if err != nil {
 		return err
@@ -1809,11 +1805,11 @@ func (t *AlterableDoltTable) AddForeignKey(ctx *sql.Context, sqlFk sql.ForeignKe
 			// schema.Index interface (which is used internally to represent indexes across the codebase). In the
 			// meantime, we must generate a duplicate key over the primary key.
 			//TODO: use the primary key as-is
-			idxReturn, err := creation.CreateIndex(ctx, tbl, "", sqlFk.Columns, false, false, "", editor.Options{
+			idxReturn, err := creation.CreateIndex(ctx, tbl, "", sqlFk.Columns, false, false, "", creation.BuildOptions{EditOptions: editor.Options{
 				ForeignKeyChecksDisabled: true,
 				Deaf:                     t.opts.Deaf,
 				Tempdir:                  t.opts.Tempdir,
-			})
+			}})
 			if err != nil {
 				return err
 			}
@@ -1847,11 +1843,11 @@ func (t *AlterableDoltTable) AddForeignKey(ctx *sql.Context, sqlFk sql.ForeignKe
 
 			// Our duplicate index is only unique if it's the entire primary key (which is by definition unique)
 			unique := len(refPkTags) == len(refColTags)
-			idxReturn, err := creation.CreateIndex(ctx, refTbl, "", colNames, unique, false, "", editor.Options{
+			idxReturn, err := creation.CreateIndex(ctx, refTbl, "", colNames, unique, false, "", creation.BuildOptions{EditOptions: editor.Options{
 				ForeignKeyChecksDisabled: true,
 				Deaf:                     t.opts.Deaf,
 				Tempdir:                  t.opts.Tempdir,
-			})
+			}})
 			if err != nil {
 				return err
 			}
@@ -2021,11 +2017,11 @@ func (t *AlterableDoltTable) UpdateForeignKey(ctx *sql.Context, fkName string, s
 			// schema.Index interface (which is used internally to represent indexes across the codebase). In the
 			// meantime, we must generate a duplicate key over the primary key.
 			//TODO: use the primary key as-is
-			idxReturn, err := creation.CreateIndex(ctx, tbl, "", sqlFk.Columns, false, false, "", editor.Options{
+			idxReturn, err := creation.CreateIndex(ctx, tbl, "", sqlFk.Columns, false, false, "", creation.BuildOptions{EditOptions: editor.Options{
 				ForeignKeyChecksDisabled: true,
 				Deaf:                     t.opts.Deaf,
 				Tempdir:                  t.opts.Tempdir,
-			})
+			}})
 			if err != nil {
 				return err
 			}
@@ -2059,11 +2055,11 @@ func (t *AlterableDoltTable) UpdateForeignKey(ctx *sql.Context, fkName string, s
 
 			// Our duplicate index is only unique if it's the entire primary key (which is by definition unique)
 			unique := len(refPkTags) == len(refColTags)
-			idxReturn, err := creation.CreateIndex(ctx, refTbl, "", colNames, unique, false, "", editor.Options{
+			idxReturn, err := creation.CreateIndex(ctx, refTbl, "", colNames, unique, false, "", creation.BuildOptions{EditOptions: editor.Options{
 				ForeignKeyChecksDisabled: true,
 				Deaf:                     t.opts.Deaf,
 				Tempdir:                  t.opts.Tempdir,
-			})
+			}})
 			if err != nil {
 				return err
 			}
@@ -2119,7 +2115,7 @@ func (t *AlterableDoltTable) CreateIndexForForeignKey(ctx *sql.Context, indexNam
 		constraint == sql.IndexConstraint_Unique,
 		false,
 		"",
-		t.opts,
+		creation.BuildOptions{EditOptions: t.opts},
 	)
 	if err != nil {
 		return err
@@ -2522,6 +2518,10 @@ func findIndexWithPrefix(sch schema.Schema, prefixCols []string) (schema.Index,
 	colLen := len(prefixCols)
 	var indexesWithLen []idxWithLen
 	for _, idx := range indexes {
+		if idx.IsDeferred() {
+			// deferred indexes have no data until they are materialized
+			continue
+		}
 		idxCols := lowercaseSlice(idx.ColumnNames())
 		if ok, prefixCount := colsAreIndexSubset(prefixCols, idxCols); ok && prefixCount == colLen {
 			indexesWithLen = append(indexesWithLen, idxWithLen{idx, len(idxCols)})
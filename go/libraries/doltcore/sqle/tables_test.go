@@ -18,9 +18,36 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/dolthub/dolt/go/libraries/doltcore/schema"
+	"github.com/dolthub/dolt/go/store/types"
 )
 
 func TestMinRowsPerPartitionInTests(t *testing.T) {
 	// If this fails then the method for determining if we are running in a test doesn't work all the time.
 	assert.Equal(t, uint64(2), MinRowsPerPartition)
 }
+
+func TestFindIndexWithPrefixSkipsDeferredIndexes(t *testing.T) {
+	sch, err := schema.SchemaFromCols(schema.NewColCollection(
+		schema.NewColumn("pk", 1, types.IntKind, true, schema.NotNullConstraint{}),
+		schema.NewColumn("v1", 2, types.IntKind, false),
+		schema.NewColumn("v2", 3, types.IntKind, false),
+	))
+	require.NoError(t, err)
+	_, err = sch.Indexes().AddIndexByColNames("deferred_v1", []string{"v1"}, schema.IndexProperties{IsDeferred: true})
+	require.NoError(t, err)
+
+	// a deferred index has no data, so it cannot back a foreign key
+	_, ok, err := findIndexWithPrefix(sch, []string{"v1"})
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	_, err = sch.Indexes().AddIndexByColNames("v1v2", []string{"v1", "v2"}, schema.IndexProperties{})
+	require.NoError(t, err)
+	idx, ok, err := findIndexWithPrefix(sch, []string{"v1"})
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, "v1v2", idx.Name())
+}
@@ -268,7 +268,7 @@ func (t *TempTable) CreateIndex(ctx *sql.Context, indexName string, using sql.In
 		constraint == sql.IndexConstraint_Unique,
 		true,
 		comment,
-		t.opts,
+		creation.BuildOptions{EditOptions: t.opts},
 	)
 	if err != nil {
 		return err
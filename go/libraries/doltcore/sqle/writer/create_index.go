@@ -0,0 +1,50 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package writer
+
+import (
+	"context"
+
+	"github.com/dolthub/dolt/go/libraries/doltcore/doltdb"
+	"github.com/dolthub/dolt/go/libraries/doltcore/table/editor/creation"
+)
+
+// CreateIndexInSession creates an index on the table |tableName| of the working set of |sess|, as creation.CreateIndex
+// does. The pending edits of |sess| are flushed first, so the index is built over them, and the session's table
+// writers are reset to the updated table, so that writes made after the index is created maintain it.
+func CreateIndexInSession(
+	ctx context.Context,
+	sess WriteSession,
+	tableName string,
+	indexName string,
+	columns []string,
+	isUnique bool,
+	isUserDefined bool,
+	comment string,
+) (*creation.CreateIndexReturn, error) {
+	var ret *creation.CreateIndexReturn
+	err := sess.UpdateWorkingSet(ctx, func(ctx context.Context, current *doltdb.WorkingSet) (*doltdb.WorkingSet, error) {
+		root, r, err := creation.CreateIndexOnRoot(ctx, current.WorkingRoot(), tableName, indexName, columns, isUnique, isUserDefined, comment, creation.BuildOptions{EditOptions: sess.GetOptions()})
+		if err != nil {
+			return nil, err
+		}
+		ret = r
+		return current.WithWorkingRoot(root), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
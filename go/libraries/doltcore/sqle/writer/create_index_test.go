@@ -0,0 +1,68 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package writer_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/dolthub/dolt/go/libraries/doltcore/dtestutils"
+	"github.com/dolthub/dolt/go/libraries/doltcore/sqle"
+	"github.com/dolthub/dolt/go/libraries/doltcore/sqle/globalstate"
+	"github.com/dolthub/dolt/go/libraries/doltcore/sqle/writer"
+	"github.com/dolthub/dolt/go/libraries/doltcore/table/editor"
+)
+
+func TestCreateIndexInSession(t *testing.T) {
+	dEnv := dtestutils.CreateTestEnv()
+	sqle.CreateTestDatabase(dEnv, t)
+	ctx := sqle.NewTestSQLCtx(context.Background())
+
+	ws, err := dEnv.WorkingSet(ctx)
+	require.NoError(t, err)
+	tracker, err := globalstate.NewAutoIncrementTracker(ctx, ws)
+	require.NoError(t, err)
+	opts := editor.Options{Deaf: dEnv.DbEaFactory(), Tempdir: dEnv.TempTableFilesDir()}
+	sess := writer.NewWriteSession(dEnv.DoltDB.Format(), ws, tracker, opts)
+
+	tw, err := sess.GetTableWriter(ctx, "people", "dolt", nil, false)
+	require.NoError(t, err)
+	edna := sqle.NewPeopleRow(10, "Edna", "Krabapple", false, 38, 8.0)
+	krusty := sqle.NewPeopleRow(11, "Krusty", "Klown", false, 48, 9.5)
+
+	// the pending insert is flushed before the index is built, and the later insert maintains it
+	require.NoError(t, tw.Insert(ctx, r(edna, sqle.PeopleTestSchema)))
+	ret, err := writer.CreateIndexInSession(ctx, sess, "PEOPLE", "idx_age", []string{"age"}, false, true, "")
+	require.NoError(t, err)
+	require.Equal(t, "idx_age", ret.NewIndex.Name())
+	require.NoError(t, tw.Insert(ctx, r(krusty, sqle.PeopleTestSchema)))
+
+	ws, err = sess.Flush(ctx)
+	require.NoError(t, err)
+	tbl, ok, err := ws.WorkingRoot().GetTable(ctx, "people")
+	require.NoError(t, err)
+	require.True(t, ok)
+	rows, err := tbl.GetRowData(ctx)
+	require.NoError(t, err)
+	idxRows, err := tbl.GetIndexRowData(ctx, "idx_age")
+	require.NoError(t, err)
+	require.Equal(t, rows.Count(), idxRows.Count())
+	require.Equal(t, uint64(len(sqle.AllPeopleRows)+2), idxRows.Count())
+
+	_, err = writer.CreateIndexInSession(ctx, sess, "missing", "idx", []string{"age"}, false, true, "")
+	require.Error(t, err)
+}
@@ -80,6 +80,7 @@ func NewWriteSession(nbf *types.NomsBinFormat, ws *doltdb.WorkingSet, tracker gl
 			tables:     make(map[string]*prollyTableWriter),
 			tracker:    tracker,
 			mut:        &sync.RWMutex{},
+			opts:       opts,
 		}
 	}
 
@@ -25,6 +25,7 @@ import (
 	"github.com/dolthub/dolt/go/libraries/doltcore/sqle/globalstate"
 	"github.com/dolthub/dolt/go/libraries/doltcore/sqle/index"
 	"github.com/dolthub/dolt/go/libraries/doltcore/sqle/sqlutil"
+	"github.com/dolthub/dolt/go/libraries/doltcore/table/editor/creation"
 	"github.com/dolthub/dolt/go/store/pool"
 	"github.com/dolthub/dolt/go/store/prolly"
 	"github.com/dolthub/dolt/go/store/val"
@@ -64,6 +65,10 @@ func getSecondaryProllyIndexWriters(ctx context.Context, t *doltdb.Table, sqlSch
 	writers := make(map[string]indexWriter)
 
 	for _, def := range definitions {
+		if def.IsDeferred() {
+			// deferred indexes are built from the primary rows when they are materialized
+			continue
+		}
 		defName := def.Name()
 		idxRows, err := s.GetIndex(ctx, sch, defName)
 		if err != nil {
@@ -98,6 +103,10 @@ func getSecondaryKeylessProllyWriters(ctx context.Context, t *doltdb.Table, sqlS
 	writers := make(map[string]indexWriter)
 
 	for _, def := range definitions {
+		if def.IsDeferred() {
+			// deferred indexes are built from the primary rows when they are materialized
+			continue
+		}
 		defName := def.Name()
 		idxRows, err := s.GetIndex(ctx, sch, defName)
 		if err != nil {
@@ -316,6 +325,11 @@ func (w *prollyTableWriter) table(ctx context.Context) (t *doltdb.Table, err err
 		return nil, err
 	}
 
+	t, err = creation.ClearChangedDeFactoUnique(ctx, w.sch, t, w.tbl)
+	if err != nil {
+		return nil, err
+	}
+
 	if w.aiCol.AutoIncrement {
 		seq := w.aiTracker.Current(w.tableName)
 		t, err = t.SetAutoIncrementValue(ctx, seq)
@@ -34,6 +34,7 @@ type prollyWriteSession struct {
 	tables     map[string]*prollyTableWriter
 	tracker    globalstate.AutoIncrementTracker
 	mut        *sync.RWMutex
+	opts       editor.Options
 }
 
 var _ WriteSession = &prollyWriteSession{}
@@ -135,17 +136,21 @@ func (s *prollyWriteSession) UpdateWorkingSet(ctx context.Context, cb func(ctx c
 		return err
 	}
 
-	return s.SetWorkingSet(ctx, mutated)
+	return s.setWorkingSet(ctx, mutated)
 }
 
 // GetOptions implemented WriteSession.
 func (s *prollyWriteSession) GetOptions() editor.Options {
-	return editor.Options{}
+	s.mut.RLock()
+	defer s.mut.RUnlock()
+	return s.opts
 }
 
 // SetOptions implemented WriteSession.
 func (s *prollyWriteSession) SetOptions(opts editor.Options) {
-	return
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	s.opts = opts
 }
 
 // flush is the inner implementation for Flush that does not acquire any locks
@@ -0,0 +1,71 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package writer_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/dolthub/dolt/go/libraries/doltcore/doltdb"
+	"github.com/dolthub/dolt/go/libraries/doltcore/dtestutils"
+	"github.com/dolthub/dolt/go/libraries/doltcore/sqle/globalstate"
+	"github.com/dolthub/dolt/go/libraries/doltcore/sqle/writer"
+	"github.com/dolthub/dolt/go/libraries/doltcore/table/editor"
+	"github.com/dolthub/dolt/go/store/types"
+)
+
+func TestProllyUpdateWorkingSet(t *testing.T) {
+	dEnv := dtestutils.CreateTestEnv()
+	ctx := context.Background()
+	ws, err := dEnv.WorkingSet(ctx)
+	require.NoError(t, err)
+	tracker, err := globalstate.NewAutoIncrementTracker(ctx, ws)
+	require.NoError(t, err)
+	opts := editor.Options{Tempdir: dEnv.TempTableFilesDir()}
+	sess := writer.NewWriteSession(types.Format_DOLT_1, ws, tracker, opts)
+	require.Equal(t, opts.Tempdir, sess.GetOptions().Tempdir)
+
+	// options are set under the session lock, so they can be set while other writers read them
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				sess.SetOptions(opts)
+				_ = sess.GetOptions()
+			}
+		}()
+	}
+	wg.Wait()
+
+	// UpdateWorkingSet holds the session lock while it sets the updated working set, so it must not take it again
+	done := make(chan error)
+	go func() {
+		done <- sess.UpdateWorkingSet(ctx, func(ctx context.Context, current *doltdb.WorkingSet) (*doltdb.WorkingSet, error) {
+			return current, nil
+		})
+	}()
+	select {
+	case err = <-done:
+		require.NoError(t, err)
+	case <-time.After(10 * time.Second):
+		t.Fatal("UpdateWorkingSet deadlocked")
+	}
+}
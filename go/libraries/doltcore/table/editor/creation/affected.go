@@ -0,0 +1,72 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"github.com/dolthub/go-mysql-server/sql"
+
+	"github.com/dolthub/dolt/go/libraries/doltcore/schema"
+)
+
+// IndexesAffectedByColumnChange returns the secondary indexes of |sch| whose
+// key encoding changes when the type of the column |colName| changes from
+// |oldType| to |newType|, so that a column modification only has to rebuild
+// those indexes. An index is affected if it keys the column, either as an
+// indexed column or in its primary key suffix, and the column's key encoding
+// changes. Deferred indexes have no data, so they are never affected.
+func IndexesAffectedByColumnChange(sch schema.Schema, colName string, oldType, newType sql.Type) []schema.Index {
+	col, ok := sch.GetAllCols().GetByNameCaseInsensitive(colName)
+	if !ok || !keyEncodingChanged(oldType, newType) {
+		return nil
+	}
+
+	var affected []schema.Index
+	for _, idx := range sch.Indexes().AllIndexes() {
+		if idx.IsDeferred() {
+			continue
+		}
+		for _, tag := range idx.AllTags() {
+			if tag == col.Tag {
+				affected = append(affected, idx)
+				break
+			}
+		}
+	}
+	return affected
+}
+
+// keyEncodingChanged returns whether values of |oldType| are encoded or ordered
+// differently in index keys once converted to |newType|. Only changes that are
+// known to preserve the key encoding return false, such as changing the length
+// of a string type without changing its collation.
+func keyEncodingChanged(oldType, newType sql.Type) bool {
+	if oldType.Equals(newType) {
+		return false
+	}
+	if oldType.Type() != newType.Type() {
+		return true
+	}
+	ost, ok := oldType.(sql.StringType)
+	if !ok {
+		return true
+	}
+	nst, ok := newType.(sql.StringType)
+	if !ok {
+		return true
+	}
+	// a longer string type holds every value of the shorter type unchanged, but
+	// a shorter one could truncate them
+	return !ost.Collation().Equals(nst.Collation()) || nst.MaxCharacterLength() < ost.MaxCharacterLength()
+}
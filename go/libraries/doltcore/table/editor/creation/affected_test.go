@@ -0,0 +1,115 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"testing"
+
+	"github.com/dolthub/go-mysql-server/sql"
+	"github.com/dolthub/vitess/go/sqltypes"
+	"github.com/stretchr/testify/require"
+
+	"github.com/dolthub/dolt/go/libraries/doltcore/schema"
+)
+
+func TestIndexesAffectedByColumnChange(t *testing.T) {
+	sch := newTestSchema(t)
+	for name, cols := range map[string][]string{
+		"c1_idx":    {"c1"},
+		"c2_idx":    {"c2"},
+		"c1_c2_idx": {"c1", "c2"},
+	} {
+		_, err := sch.Indexes().AddIndexByColNames(name, cols, schema.IndexProperties{IsUserDefined: true})
+		require.NoError(t, err)
+	}
+	_, err := sch.Indexes().AddIndexByColNames("deferred_idx", []string{"c2"}, schema.IndexProperties{IsDeferred: true})
+	require.NoError(t, err)
+
+	varchar := func(length int64, collation sql.Collation) sql.Type {
+		return sql.MustCreateString(sqltypes.VarChar, length, collation)
+	}
+	tests := []struct {
+		name     string
+		col      string
+		old, new sql.Type
+		affected []string
+	}{
+		{
+			name:     "longer varchar",
+			col:      "c2",
+			old:      varchar(10, sql.Collation_utf8mb4_0900_bin),
+			new:      varchar(20, sql.Collation_utf8mb4_0900_bin),
+			affected: nil,
+		},
+		{
+			name:     "shorter varchar",
+			col:      "c2",
+			old:      varchar(20, sql.Collation_utf8mb4_0900_bin),
+			new:      varchar(10, sql.Collation_utf8mb4_0900_bin),
+			affected: []string{"c1_c2_idx", "c2_idx"},
+		},
+		{
+			name:     "collation",
+			col:      "c2",
+			old:      varchar(10, sql.Collation_utf8mb4_0900_bin),
+			new:      varchar(10, sql.Collation_utf8mb4_general_ci),
+			affected: []string{"c1_c2_idx", "c2_idx"},
+		},
+		{
+			name:     "varchar to char",
+			col:      "c2",
+			old:      varchar(10, sql.Collation_utf8mb4_0900_bin),
+			new:      sql.MustCreateString(sqltypes.Char, 10, sql.Collation_utf8mb4_0900_bin),
+			affected: []string{"c1_c2_idx", "c2_idx"},
+		},
+		{
+			name:     "wider int",
+			col:      "c1",
+			old:      sql.Int32,
+			new:      sql.Int64,
+			affected: []string{"c1_c2_idx", "c1_idx"},
+		},
+		{
+			name:     "unchanged",
+			col:      "c1",
+			old:      sql.Int64,
+			new:      sql.Int64,
+			affected: nil,
+		},
+		{
+			name:     "primary key",
+			col:      "pk",
+			old:      sql.Int32,
+			new:      sql.Int64,
+			affected: []string{"c1_c2_idx", "c1_idx", "c2_idx"},
+		},
+		{
+			name:     "unknown column",
+			col:      "c3",
+			old:      sql.Int32,
+			new:      sql.Int64,
+			affected: nil,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var names []string
+			for _, idx := range IndexesAffectedByColumnChange(sch, test.col, test.old, test.new) {
+				names = append(names, idx.Name())
+			}
+			require.ElementsMatch(t, test.affected, names)
+		})
+	}
+}
@@ -0,0 +1,233 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+
+	"github.com/dolthub/dolt/go/libraries/doltcore/doltdb"
+	"github.com/dolthub/dolt/go/libraries/doltcore/doltdb/durable"
+	"github.com/dolthub/dolt/go/libraries/doltcore/schema"
+	"github.com/dolthub/dolt/go/store/prolly"
+	"github.com/dolthub/dolt/go/store/types"
+	"github.com/dolthub/dolt/go/store/val"
+)
+
+const (
+	// candidateSampleSize is the maximum number of rows read by AnalyzeIndexCandidate.
+	candidateSampleSize = 10_000
+	// candidateSampleBlock is the number of contiguous rows read per sample block.
+	candidateSampleBlock = 100
+	// minUsefulSelectivity is the selectivity below which an index is unlikely to be chosen over a table scan.
+	minUsefulSelectivity = 0.01
+)
+
+// IndexCandidateReport describes the estimated cardinality and selectivity of a proposed index.
+type IndexCandidateReport struct {
+	// Columns are the resolved names of the proposed index columns.
+	Columns []string
+	// TotalRows is the number of rows in the table.
+	TotalRows uint64
+	// SampledRows is the number of rows read to produce the estimate.
+	SampledRows uint64
+	// EstimatedDistinct is the estimated number of distinct values of the index columns.
+	EstimatedDistinct uint64
+	// Selectivity is EstimatedDistinct / TotalRows. An index over a unique column has a selectivity of 1.
+	Selectivity float64
+	// Confidence is the fraction of the table that was sampled, in the range [0, 1].
+	Confidence float64
+	// LikelyUseful is true if the index is selective enough to be worth building.
+	LikelyUseful bool
+}
+
+// AnalyzeIndexCandidate estimates the distinct-value count and selectivity of an index over |columns| of |tbl| from a
+// bounded sample of the table's rows. Nothing is built and the table's schema is not modified. The rows sampled are
+// chosen with the IndexSampleSeed of |opts|.
+func AnalyzeIndexCandidate(ctx context.Context, tbl *doltdb.Table, columns []string, opts BuildOptions) (IndexCandidateReport, error) {
+	if !types.IsFormat_DOLT_1(tbl.Format()) {
+		return IndexCandidateReport{}, fmt.Errorf("index candidate analysis is not supported for format %s", tbl.Format().VersionString())
+	}
+
+	sch, err := tbl.GetSchema(ctx)
+	if err != nil {
+		return IndexCandidateReport{}, err
+	}
+	realColNames, err := resolveColumnNames(sch, columns)
+	if err != nil {
+		return IndexCandidateReport{}, err
+	}
+	idx, err := candidateIndex(sch, realColNames)
+	if err != nil {
+		return IndexCandidateReport{}, err
+	}
+
+	m, err := tbl.GetRowData(ctx)
+	if err != nil {
+		return IndexCandidateReport{}, err
+	}
+	primary := durable.ProllyMapFromIndex(m)
+
+	counts, sampled, err := samplePrefixCounts(ctx, sch, idx, primary, candidateSampleSize, opts.IndexSampleSeed)
+	if err != nil {
+		return IndexCandidateReport{}, err
+	}
+
+	total := uint64(primary.Count())
+	report := IndexCandidateReport{
+		Columns:     realColNames,
+		TotalRows:   total,
+		SampledRows: sampled,
+	}
+	if total == 0 {
+		return report, nil
+	}
+
+	report.EstimatedDistinct = estimateDistinct(counts, sampled, total)
+	report.Selectivity = float64(report.EstimatedDistinct) / float64(total)
+	report.Confidence = float64(sampled) / float64(total)
+	report.LikelyUseful = report.Selectivity >= minUsefulSelectivity
+	return report, nil
+}
+
+// candidateIndex returns an index over |cols| that belongs to a scratch index collection, leaving |sch| unchanged.
+func candidateIndex(sch schema.Schema, cols []string) (schema.Index, error) {
+	coll := schema.NewIndexCollection(sch.GetAllCols(), sch.GetPKCols())
+	return coll.AddIndexByColNames("candidate", cols, schema.IndexProperties{})
+}
+
+// samplePrefixCounts reads up to |limit| rows of |primary| in evenly spaced blocks and counts the occurrences of each
+// index prefix of |idx|, as sampleBlocks does with |seed|. Prefixes containing a NULL are not counted.
+func samplePrefixCounts(ctx context.Context, sch schema.Schema, idx schema.Index, primary prolly.Map, limit uint64, seed int64) (map[string]uint64, uint64, error) {
+	pkLen := sch.GetPKCols().Size()
+	keyMap, err := GetIndexKeyMapping(sch, idx)
+	if err != nil {
+		return nil, 0, err
+	}
+	keyMap = keyMap[:idx.Count()]
+
+	counts := make(map[string]uint64)
+	var buf []byte
+	sampled, err := sampleBlocks(ctx, primary, limit, seed, func(k, v val.Tuple) error {
+		var hasNull bool
+		buf, hasNull = appendPrefixBytes(buf[:0], keyMap, pkLen, k, v)
+		if !hasNull {
+			counts[string(buf)]++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return counts, sampled, nil
+}
+
+// sampleBlocks calls |cb| for up to |limit| entries of |m|, read in blocks of candidateSampleBlock entries, one from
+// each of a series of evenly spaced strides of |m|. A zero |seed| reads each block from the start of its stride, and a
+// non-zero |seed| reads it from an offset into its stride drawn from a source seeded with |seed|, so that the sample
+// is not aligned with periodic data but is the same for the same |seed|. It returns the number of entries sampled.
+func sampleBlocks(ctx context.Context, m prolly.Map, limit uint64, seed int64, cb func(k, v val.Tuple) error) (uint64, error) {
+	total := uint64(m.Count())
+	blocks := limit / candidateSampleBlock
+	stride := uint64(candidateSampleBlock)
+	if total > limit && blocks > 0 {
+		stride = total / blocks
+	}
+	var rng *rand.Rand
+	if seed != 0 {
+		rng = rand.New(rand.NewSource(seed))
+	}
+
+	var sampled uint64
+	for base := uint64(0); base < total && sampled < limit; base += stride {
+		start := base
+		span := stride
+		if base+span > total {
+			span = total - base
+		}
+		if rng != nil && span > candidateSampleBlock {
+			start += uint64(rng.Int63n(int64(span - candidateSampleBlock + 1)))
+		}
+		stop := start + candidateSampleBlock
+		if stop > total {
+			stop = total
+		}
+		iter, err := m.IterOrdinalRange(ctx, start, stop)
+		if err != nil {
+			return 0, err
+		}
+		for {
+			k, v, err := iter.Next(ctx)
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return 0, err
+			}
+			sampled++
+			if err = cb(k, v); err != nil {
+				return 0, err
+			}
+		}
+	}
+	return sampled, nil
+}
+
+// appendPrefixBytes appends a length-prefixed encoding of the fields of |k| and |v| selected by |keyMap| to |buf|.
+func appendPrefixBytes(buf []byte, keyMap val.OrdinalMapping, pkLen int, k, v val.Tuple) ([]byte, bool) {
+	hasNull := false
+	for to := range keyMap {
+		from := keyMap.MapOrdinal(to)
+		var f []byte
+		if from < pkLen {
+			f = k.GetField(from)
+		} else {
+			f = v.GetField(from - pkLen)
+		}
+		if f == nil {
+			hasNull = true
+		}
+		buf = append(buf, byte(len(f)>>24), byte(len(f)>>16), byte(len(f)>>8), byte(len(f)))
+		buf = append(buf, f...)
+	}
+	return buf, hasNull
+}
+
+// estimateDistinct scales the number of distinct values seen in a sample of |sampled| rows to a table of |total|
+// rows using the Haas-Stokes Duj1 estimator, which weights the scale-up by the fraction of values seen only once.
+func estimateDistinct(counts map[string]uint64, sampled, total uint64) uint64 {
+	if sampled == 0 {
+		return 0
+	}
+	if sampled >= total {
+		return uint64(len(counts))
+	}
+
+	var singletons float64
+	for _, c := range counts {
+		if c == 1 {
+			singletons++
+		}
+	}
+	n, d := float64(sampled), float64(len(counts))
+	est := n * d / (n - singletons + singletons*n/float64(total))
+	if est > float64(total) {
+		est = float64(total)
+	}
+	return uint64(math.Round(est))
+}
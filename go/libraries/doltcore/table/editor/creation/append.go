@@ -0,0 +1,106 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dolthub/dolt/go/libraries/doltcore/schema"
+	"github.com/dolthub/dolt/go/store/prolly"
+	"github.com/dolthub/dolt/go/store/prolly/message"
+	"github.com/dolthub/dolt/go/store/prolly/tree"
+	"github.com/dolthub/dolt/go/store/val"
+)
+
+// leadsWithAutoIncrementKey returns whether the first indexed column of |idx|
+// is the auto increment primary key of |sch|. The primary key of each row is
+// then the leading field of its index key, so a scan of the primary index
+// returns rows in the order of their index keys.
+func leadsWithAutoIncrementKey(sch schema.Schema, idx schema.Index) bool {
+	pks := sch.GetPKCols()
+	tags := idx.IndexedColumnTags()
+	if pks.Size() != 1 || len(tags) == 0 {
+		return false
+	}
+	col := pks.GetByIndex(0)
+	return col.AutoIncrement && tags[0] == col.Tag
+}
+
+// canAppendIndex returns whether a build of |idx| from a scan of its primary
+// index can append its entries to an appendIndexBuilder. Keys prefixed with a
+// time bucket or geohash, or in reverse order, are not in scan order, and a
+// build that flushes a partial index needs a prolly.MutableMap.
+func canAppendIndex(sch schema.Schema, idx schema.Index, opts BuildOptions) bool {
+	return leadsWithAutoIncrementKey(sch, idx) &&
+		idx.TimeBucket() == 0 &&
+		opts.IndexGeohashPrecision == 0 &&
+		!opts.ReverseIndexOrder &&
+		!opts.FlushPartialIndexOnCancel
+}
+
+// appendIndexBuilder builds index data from entries in ascending key order.
+// Edits to a prolly.MutableMap are buffered, sorted and then applied to the
+// tree through a cursor, which searches the tree for each edit. Entries that
+// are already sorted can instead be appended to a tree.Chunker, which writes
+// each node once, as prolly.NewMapFromTuples does. Content defined chunking
+// gives both the same tree.
+type appendIndexBuilder struct {
+	ch   tree.Chunker
+	ns   tree.NodeStore
+	kd   val.TupleDesc
+	vd   val.TupleDesc
+	last val.Tuple
+	// strict, if true, makes put fail with ErrIndexKeyOrder on an entry that
+	// is out of order, rather than return false.
+	strict bool
+}
+
+// newAppendIndexBuilder returns an appendIndexBuilder for the data of the
+// empty map |m|.
+func newAppendIndexBuilder(ctx context.Context, m prolly.Map) (*appendIndexBuilder, error) {
+	ns := m.NodeStore()
+	ch, err := tree.NewEmptyChunker(ctx, ns, message.ProllyMapSerializer{Pool: ns.Pool()})
+	if err != nil {
+		return nil, err
+	}
+	kd, vd := m.Descriptors()
+	return &appendIndexBuilder{ch: ch, ns: ns, kd: kd, vd: vd}, nil
+}
+
+// put appends the entry |k|, |v|, returning false without appending it if |k|
+// is not greater than the last key appended.
+func (b *appendIndexBuilder) put(ctx context.Context, k, v val.Tuple) (bool, error) {
+	if b.last != nil && b.kd.Compare(k, b.last) <= 0 {
+		if b.strict {
+			return false, fmt.Errorf("%w: key %s follows key %s", ErrIndexKeyOrder, b.kd.Format(k), b.kd.Format(b.last))
+		}
+		return false, nil
+	}
+	if err := b.ch.AddPair(ctx, tree.Item(k), tree.Item(v)); err != nil {
+		return false, err
+	}
+	b.last = k
+	return true, nil
+}
+
+// build returns the map of the entries appended.
+func (b *appendIndexBuilder) build(ctx context.Context) (prolly.Map, error) {
+	root, err := b.ch.Done(ctx)
+	if err != nil {
+		return prolly.Map{}, err
+	}
+	return prolly.NewMap(root, b.ns, b.kd, b.vd), nil
+}
@@ -0,0 +1,173 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/dolthub/dolt/go/libraries/doltcore/schema"
+	"github.com/dolthub/dolt/go/store/prolly/shim"
+	"github.com/dolthub/dolt/go/store/types"
+	"github.com/dolthub/dolt/go/store/val"
+)
+
+// newAutoIncrementTestSchema returns the schema of newTestSchema, with an auto
+// increment primary key if |autoIncrement| is true.
+func newAutoIncrementTestSchema(t testing.TB, autoIncrement bool) schema.Schema {
+	pk := schema.NewColumn("pk", pkTag, types.IntKind, true, schema.NotNullConstraint{})
+	pk.AutoIncrement = autoIncrement
+	sch, err := schema.SchemaFromCols(schema.NewColCollection(
+		pk,
+		schema.NewColumn("c1", c1Tag, types.IntKind, false),
+		schema.NewColumn("c2", c2Tag, types.StringKind, false),
+	))
+	require.NoError(t, err)
+	return sch
+}
+
+func TestAppendAutoIncrementIndex(t *testing.T) {
+	ctx := context.Background()
+	vrw := newTestVRW()
+	sch := newAutoIncrementTestSchema(t, true)
+	plain := newAutoIncrementTestSchema(t, false)
+	var rows [][]interface{}
+	for i := 0; i < 5000; i++ {
+		rows = append(rows, []interface{}{i + 1, i % 7, "row"})
+	}
+	primary := newTestPrimary(t, ctx, vrw, sch, rows)
+
+	coll := schema.NewIndexCollection(sch.GetAllCols(), sch.GetPKCols())
+	pkIdx, err := coll.AddIndexByColNames("pk_c1", []string{"pk", "c1"}, schema.IndexProperties{})
+	require.NoError(t, err)
+	c1Idx, err := coll.AddIndexByColNames("c1_pk", []string{"c1", "pk"}, schema.IndexProperties{})
+	require.NoError(t, err)
+	require.True(t, leadsWithAutoIncrementKey(sch, pkIdx))
+	require.False(t, leadsWithAutoIncrementKey(sch, c1Idx))
+	require.False(t, leadsWithAutoIncrementKey(plain, pkIdx))
+	require.True(t, canAppendIndex(sch, pkIdx, BuildOptions{}))
+	require.False(t, canAppendIndex(sch, pkIdx, BuildOptions{KeyEncodingOptions: KeyEncodingOptions{ReverseIndexOrder: true}}))
+
+	// appended entries give the same tree as edits
+	expected, err := BuildSecondaryProllyIndex(ctx, vrw, plain, pkIdx, primary, BuildOptions{})
+	require.NoError(t, err)
+	var stats IndexBuildStats
+	appended, err := BuildSecondaryProllyIndex(ctx, vrw, sch, pkIdx, primary, BuildOptions{ExecutionOptions: ExecutionOptions{IndexBuildStats: &stats}})
+	require.NoError(t, err)
+	requireSameIndex(t, expected, appended)
+	require.Equal(t, uint64(len(rows)), stats.RowsIndexed)
+
+	// rows out of scan order are edited into the entries appended before them
+	iter, err := primary.IterAll(ctx)
+	require.NoError(t, err)
+	var kvs [][2]val.Tuple
+	for {
+		k, v, err := iter.Next(ctx)
+		if err != nil {
+			break
+		}
+		kvs = append(kvs, [2]val.Tuple{k, v})
+	}
+	kvs[100], kvs[4000] = kvs[4000], kvs[100]
+	swapped, err := BuildSecondaryProllyIndexFromIter(ctx, vrw, sch, pkIdx, &sliceMapIter{kvs: kvs}, BuildOptions{})
+	require.NoError(t, err)
+	requireSameIndex(t, expected, swapped)
+
+	// skipped rows leave the rest in scan order
+	_, pvd := shim.MapDescriptorsFromSchema(sch)
+	filtered, err := BuildSecondaryProllyIndex(ctx, vrw, sch, pkIdx, primary, BuildOptions{
+		ExecutionOptions: ExecutionOptions{
+			IndexRowFilter: func(ctx context.Context, k, v val.Tuple) (bool, error) {
+				c1, _ := pvd.GetInt64(0, v)
+				return c1 == 0, nil
+			},
+		},
+	})
+	require.NoError(t, err)
+	require.Equal(t, uint64(715), filtered.Count())
+}
+
+func TestAssertIndexKeyOrder(t *testing.T) {
+	ctx := context.Background()
+	vrw := newTestVRW()
+	sch := newAutoIncrementTestSchema(t, true)
+	var rows [][]interface{}
+	for i := 0; i < 1000; i++ {
+		rows = append(rows, []interface{}{i + 1, i % 7, "row"})
+	}
+	primary := newTestPrimary(t, ctx, vrw, sch, rows)
+	idx, err := schema.NewIndexCollection(sch.GetAllCols(), sch.GetPKCols()).AddIndexByColNames(
+		"pk_c1", []string{"pk", "c1"}, schema.IndexProperties{})
+	require.NoError(t, err)
+	opts := BuildOptions{ExecutionOptions: ExecutionOptions{AssertIndexKeyOrder: true}}
+
+	_, err = BuildSecondaryProllyIndex(ctx, vrw, sch, idx, primary, opts)
+	require.NoError(t, err)
+
+	iter, err := primary.IterAll(ctx)
+	require.NoError(t, err)
+	var kvs [][2]val.Tuple
+	for {
+		k, v, err := iter.Next(ctx)
+		if err != nil {
+			break
+		}
+		kvs = append(kvs, [2]val.Tuple{k, v})
+	}
+	kvs[10], kvs[500] = kvs[500], kvs[10]
+	_, err = BuildSecondaryProllyIndexFromIter(ctx, vrw, sch, idx, &sliceMapIter{kvs: kvs}, opts)
+	require.ErrorIs(t, err, ErrIndexKeyOrder)
+	require.Contains(t, err.Error(), "key ( 12, 4 ) follows key ( 501, 3 )")
+
+	// repeated keys are out of order too
+	app, err := newAppendIndexBuilder(ctx, primary)
+	require.NoError(t, err)
+	app.strict = true
+	ok, err := app.put(ctx, kvs[0][0], kvs[0][1])
+	require.NoError(t, err)
+	require.True(t, ok)
+	ok, err = app.put(ctx, kvs[0][0], kvs[0][1])
+	require.ErrorIs(t, err, ErrIndexKeyOrder)
+	require.False(t, ok)
+}
+
+func BenchmarkAutoIncrementIndexBuild(b *testing.B) {
+	ctx := context.Background()
+	vrw := newTestVRW()
+	sch := newAutoIncrementTestSchema(b, true)
+	var rows [][]interface{}
+	for i := 0; i < 50_000; i++ {
+		rows = append(rows, []interface{}{i + 1, i % 100, "row"})
+	}
+	primary := newTestPrimary(b, ctx, vrw, sch, rows)
+	idx, err := schema.NewIndexCollection(sch.GetAllCols(), sch.GetPKCols()).AddIndexByColNames(
+		"pk_c1", []string{"pk", "c1"}, schema.IndexProperties{})
+	require.NoError(b, err)
+
+	for name, sch := range map[string]schema.Schema{
+		"append":  sch,
+		"general": newAutoIncrementTestSchema(b, false),
+	} {
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				_, err := BuildSecondaryProllyIndex(ctx, vrw, sch, idx, primary, BuildOptions{})
+				require.NoError(b, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/dolthub/dolt/go/libraries/doltcore/doltdb/durable"
+	"github.com/dolthub/dolt/go/store/types"
+	"github.com/dolthub/dolt/go/store/val"
+)
+
+// IndexMinKey returns the smallest key of the secondary index |idx| whose
+// first field is not NULL, as needed to answer MIN() over the index's leading
+// column. |ok| is false if the index has no such key. The key is read from a
+// cursor seeked to the first leaf, so this is O(log n) in the size of the index.
+func IndexMinKey(ctx context.Context, idx durable.Index) (key val.Tuple, ok bool, err error) {
+	if !types.IsFormat_DOLT_1(idx.Format()) {
+		return nil, false, fmt.Errorf("index bounds are not supported for format %s", idx.Format().VersionString())
+	}
+	m := durable.ProllyMapFromIndex(idx)
+	kd, _ := m.Descriptors()
+
+	iter, err := m.IterAll(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	key, _, err = iter.Next(ctx)
+	if err == io.EOF {
+		return nil, false, nil
+	} else if err != nil {
+		return nil, false, err
+	}
+	// NULLs sort last, so if the first key is NULL they all are
+	if kd.IsNull(0, key) {
+		return nil, false, nil
+	}
+	return key, true, nil
+}
+
+// IndexMaxKey returns the largest key of the secondary index |idx| whose first
+// field is not NULL, as needed to answer MAX() over the index's leading column.
+// |ok| is false if the index has no such key. The key is read from a cursor
+// seeked to the last leaf, so this is O(log n) in the size of the index plus
+// the number of trailing NULL keys that must be skipped.
+func IndexMaxKey(ctx context.Context, idx durable.Index) (key val.Tuple, ok bool, err error) {
+	if !types.IsFormat_DOLT_1(idx.Format()) {
+		return nil, false, fmt.Errorf("index bounds are not supported for format %s", idx.Format().VersionString())
+	}
+	m := durable.ProllyMapFromIndex(idx)
+	kd, _ := m.Descriptors()
+
+	iter, err := m.IterAllReverse(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	for {
+		key, _, err = iter.Next(ctx)
+		if err == io.EOF {
+			return nil, false, nil
+		} else if err != nil {
+			return nil, false, err
+		}
+		if !kd.IsNull(0, key) {
+			return key, true, nil
+		}
+	}
+}
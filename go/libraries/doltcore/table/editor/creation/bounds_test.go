@@ -0,0 +1,71 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/dolthub/dolt/go/libraries/doltcore/doltdb/durable"
+)
+
+func TestIndexMinMaxKey(t *testing.T) {
+	ctx := context.Background()
+	vrw := newTestVRW()
+	sch := newTestSchema(t)
+
+	build := func(rows [][]interface{}) durable.Index {
+		tbl := newTestTable(t, ctx, vrw, sch, rows)
+		ret, err := CreateIndex(ctx, tbl, "c1_idx", []string{"c1"}, false, true, "", BuildOptions{})
+		require.NoError(t, err)
+		idx, err := ret.NewTable.GetIndexRowData(ctx, "c1_idx")
+		require.NoError(t, err)
+		return idx
+	}
+
+	idx := build([][]interface{}{
+		{1, 30, "a"},
+		{2, 10, "b"},
+		{3, nil, "c"},
+		{4, 20, "d"},
+		{5, nil, "e"},
+	})
+	kd, _ := durable.ProllyMapFromIndex(idx).Descriptors()
+
+	min, ok, err := IndexMinKey(ctx, idx)
+	require.NoError(t, err)
+	require.True(t, ok)
+	v, _ := kd.GetInt64(0, min)
+	assert.Equal(t, int64(10), v)
+
+	max, ok, err := IndexMaxKey(ctx, idx)
+	require.NoError(t, err)
+	require.True(t, ok)
+	v, _ = kd.GetInt64(0, max)
+	assert.Equal(t, int64(30), v)
+
+	for _, rows := range [][][]interface{}{nil, {{1, nil, "a"}}} {
+		idx = build(rows)
+		_, ok, err = IndexMinKey(ctx, idx)
+		require.NoError(t, err)
+		assert.False(t, ok)
+		_, ok, err = IndexMaxKey(ctx, idx)
+		require.NoError(t, err)
+		assert.False(t, ok)
+	}
+}
@@ -0,0 +1,154 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/dolthub/dolt/go/libraries/doltcore/doltdb/durable"
+	"github.com/dolthub/dolt/go/libraries/doltcore/schema"
+	"github.com/dolthub/dolt/go/store/prolly"
+	"github.com/dolthub/dolt/go/store/prolly/tree"
+	"github.com/dolthub/dolt/go/store/types"
+	"github.com/dolthub/dolt/go/store/val"
+)
+
+// IndexCardinality is the cardinality of a secondary index: the number of
+// distinct values of its indexed columns.
+type IndexCardinality struct {
+	// Distinct is the number of distinct values of the indexed columns.
+	Distinct uint64
+	// Entries is the number of entries in the index.
+	Entries uint64
+}
+
+// ComputeIndexCardinality computes the cardinality of the index |idx| with the
+// data |rows| with a full scan of the index.
+func ComputeIndexCardinality(ctx context.Context, idx schema.Index, rows durable.Index) (IndexCardinality, error) {
+	if !types.IsFormat_DOLT_1(rows.Format()) {
+		return IndexCardinality{}, fmt.Errorf("index cardinality is not supported for format %s", rows.Format().VersionString())
+	}
+	m := durable.ProllyMapFromIndex(rows)
+	iter, err := m.IterAll(ctx)
+	if err != nil {
+		return IndexCardinality{}, err
+	}
+
+	var c IndexCardinality
+	var prev val.Tuple
+	for {
+		k, _, err := iter.Next(ctx)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return IndexCardinality{}, err
+		}
+		if prev == nil || !samePrefix(prev, k, idx.Count()) {
+			c.Distinct++
+		}
+		c.Entries++
+		prev = k
+	}
+	return c, nil
+}
+
+// AdjustIndexCardinality returns the cardinality |c| of the index |idx| with
+// the data |from|, adjusted for the changes that produced the data |to|, e.g.
+// by incremental index maintenance. Only the changed entries are read: an
+// added entry adds a distinct value if it is the first entry of its value in
+// |to| and the value is not in |from|, and a removed entry removes a distinct
+// value if it was the first entry of its value in |from| and the value is not
+// in |to|. The result matches ComputeIndexCardinality as long as |c| was
+// accurate for |from|.
+func AdjustIndexCardinality(ctx context.Context, idx schema.Index, c IndexCardinality, from, to durable.Index) (IndexCardinality, error) {
+	if !types.IsFormat_DOLT_1(to.Format()) {
+		return IndexCardinality{}, fmt.Errorf("index cardinality is not supported for format %s", to.Format().VersionString())
+	}
+	fromMap, toMap := durable.ProllyMapFromIndex(from), durable.ProllyMapFromIndex(to)
+	kd, _ := toMap.Descriptors()
+	prefixKD := prefixDesc(kd, idx.Count())
+	prefixKB := val.NewTupleBuilder(prefixKD)
+
+	// newValue returns true if |k| is the first entry of its value in |in|,
+	// and its value has no entries in |other|.
+	newValue := func(ctx context.Context, k val.Tuple, in, other prolly.Map) (bool, error) {
+		for i := 0; i < idx.Count(); i++ {
+			prefixKB.PutRaw(i, k.GetField(i))
+		}
+		prefix := prefixKB.BuildPermissive(in.Pool())
+
+		first, err := firstWithPrefix(ctx, in, prefixKD, prefix)
+		if err != nil || !bytes.Equal(first, k) {
+			return false, err
+		}
+		existing, err := firstWithPrefix(ctx, other, prefixKD, prefix)
+		return existing == nil, err
+	}
+
+	err := prolly.DiffMaps(ctx, fromMap, toMap, func(ctx context.Context, diff tree.Diff) error {
+		k := val.Tuple(diff.Key)
+		switch diff.Type {
+		case tree.AddedDiff:
+			c.Entries++
+			ok, err := newValue(ctx, k, toMap, fromMap)
+			if err != nil {
+				return err
+			}
+			if ok {
+				c.Distinct++
+			}
+		case tree.RemovedDiff:
+			c.Entries--
+			ok, err := newValue(ctx, k, fromMap, toMap)
+			if err != nil {
+				return err
+			}
+			if ok {
+				c.Distinct--
+			}
+		}
+		return nil
+	})
+	if err != nil && err != io.EOF {
+		return IndexCardinality{}, err
+	}
+	return c, nil
+}
+
+// firstWithPrefix returns the first key of |m| with the prefix |p|, or nil.
+func firstWithPrefix(ctx context.Context, m rangeIterator, d val.TupleDesc, p val.Tuple) (val.Tuple, error) {
+	itr, err := NewPrefixItrLimit(ctx, p, d, m, 1)
+	if err != nil {
+		return nil, err
+	}
+	k, _, err := itr.Next(ctx)
+	if err == io.EOF {
+		return nil, nil
+	}
+	return k, err
+}
+
+// samePrefix returns true if the first |n| fields of |a| and |b| are equal.
+func samePrefix(a, b val.Tuple, n int) bool {
+	for i := 0; i < n; i++ {
+		if !bytes.Equal(a.GetField(i), b.GetField(i)) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,73 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"context"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/dolthub/dolt/go/libraries/doltcore/schema"
+)
+
+func TestAdjustIndexCardinality(t *testing.T) {
+	ctx := context.Background()
+	vrw := newTestVRW()
+	sch := newTestSchema(t)
+	idx, err := schema.NewIndexCollection(sch.GetAllCols(), sch.GetPKCols()).AddIndexByColNames(
+		"c1_idx", []string{"c1"}, schema.IndexProperties{})
+	require.NoError(t, err)
+
+	rnd := rand.New(rand.NewSource(0))
+	rows := make(map[int]interface{})
+	randomRows := func() [][]interface{} {
+		// insert, update and delete rows with a small range of c1 values so that values gain and lose entries
+		for i := 0; i < 20; i++ {
+			pk := rnd.Intn(100)
+			if rnd.Intn(3) == 0 {
+				delete(rows, pk)
+			} else if c1 := rnd.Intn(12); c1 < 10 {
+				rows[pk] = c1
+			} else {
+				rows[pk] = nil
+			}
+		}
+		var res [][]interface{}
+		for pk, c1 := range rows {
+			res = append(res, []interface{}{pk, c1, "a"})
+		}
+		return res
+	}
+
+	from, err := BuildSecondaryProllyIndex(ctx, vrw, sch, idx, newTestPrimary(t, ctx, vrw, sch, randomRows()), BuildOptions{})
+	require.NoError(t, err)
+	c, err := ComputeIndexCardinality(ctx, idx, from)
+	require.NoError(t, err)
+
+	for i := 0; i < 50; i++ {
+		to, err := BuildSecondaryProllyIndex(ctx, vrw, sch, idx, newTestPrimary(t, ctx, vrw, sch, randomRows()), BuildOptions{})
+		require.NoError(t, err)
+		c, err = AdjustIndexCardinality(ctx, idx, c, from, to)
+		require.NoError(t, err)
+
+		expected, err := ComputeIndexCardinality(ctx, idx, to)
+		require.NoError(t, err)
+		require.Equal(t, expected, c, "cardinality drifted after %d adjustments", i+1)
+		require.Equal(t, uint64(len(rows)), c.Entries)
+		from = to
+	}
+}
@@ -0,0 +1,131 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"context"
+
+	"github.com/dolthub/go-mysql-server/sql"
+
+	"github.com/dolthub/dolt/go/libraries/doltcore/doltdb/durable"
+	"github.com/dolthub/dolt/go/libraries/doltcore/schema"
+	"github.com/dolthub/dolt/go/libraries/doltcore/sqle/index"
+	"github.com/dolthub/dolt/go/store/prolly"
+	"github.com/dolthub/dolt/go/store/prolly/tree"
+	"github.com/dolthub/dolt/go/store/types"
+	"github.com/dolthub/dolt/go/store/val"
+)
+
+// BuildSecondaryProllyIndexWithCheck builds the secondary index |idx| over
+// |primary|, as BuildSecondaryProllyIndex does, while evaluating the CHECK
+// constraint expression |check| against every row in the same scan. |check|
+// must be resolved against the columns of |sch|, in schema order.
+//
+// The index does not depend on the constraint, so violations do not stop the
+// build. The primary keys of the rows for which |check| is false are returned
+// alongside the index. As in MySQL, rows for which |check| is NULL pass.
+func BuildSecondaryProllyIndexWithCheck(
+	ctx *sql.Context,
+	vrw types.ValueReadWriter,
+	sch schema.Schema,
+	idx schema.Index,
+	primary prolly.Map,
+	opts BuildOptions,
+	check sql.Expression,
+) (durable.Index, []val.Tuple, error) {
+	iter, err := primary.IterAll(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	kd, vd := primary.Descriptors()
+	checked := &checkIter{
+		MapIter: iter,
+		sqlCtx:  ctx,
+		check:   check,
+		kd:      kd,
+		vd:      vd,
+		ns:      primary.NodeStore(),
+		row:     make(sql.Row, sch.GetAllCols().Size()),
+	}
+	// the values of keyless rows start with their cardinality
+	valOffset := 0
+	if schema.IsKeyless(sch) {
+		valOffset = 1
+	}
+	_ = sch.GetAllCols().Iter(func(tag uint64, col schema.Column) (stop bool, err error) {
+		if i, ok := sch.GetPKCols().TagToIdx[tag]; ok {
+			checked.fields = append(checked.fields, checkField{key: true, i: i})
+		} else {
+			checked.fields = append(checked.fields, checkField{i: valOffset + sch.GetNonPKCols().TagToIdx[tag]})
+		}
+		return false, nil
+	})
+
+	secondary, err := BuildSecondaryProllyIndexFromIter(ctx, vrw, sch, idx, checked, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	return secondary, checked.violations, nil
+}
+
+// checkIter is a prolly.MapIter that evaluates a CHECK constraint against
+// each primary row it returns.
+type checkIter struct {
+	prolly.MapIter
+	sqlCtx *sql.Context
+	check  sql.Expression
+	kd, vd val.TupleDesc
+	ns     tree.NodeStore
+	// fields are the fields of a row holding each column of the schema
+	fields     []checkField
+	row        sql.Row
+	violations []val.Tuple
+}
+
+// checkField is a field of the key or value tuple of a row.
+type checkField struct {
+	key bool
+	i   int
+}
+
+var _ prolly.MapIter = &checkIter{}
+
+func (itr *checkIter) Next(ctx context.Context) (k, v val.Tuple, err error) {
+	k, v, err = itr.MapIter.Next(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for i, f := range itr.fields {
+		if f.key {
+			itr.row[i], err = index.GetField(ctx, itr.kd, f.i, k, itr.ns)
+		} else {
+			itr.row[i], err = index.GetField(ctx, itr.vd, f.i, v, itr.ns)
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	res, err := sql.EvaluateCondition(itr.sqlCtx, itr.check, itr.row)
+	if err != nil {
+		return nil, nil, err
+	}
+	if sql.IsFalse(res) {
+		itr.violations = append(itr.violations, k)
+	}
+	return k, v, nil
+}
@@ -0,0 +1,64 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"testing"
+
+	"github.com/dolthub/go-mysql-server/sql"
+	"github.com/dolthub/go-mysql-server/sql/expression"
+	"github.com/stretchr/testify/require"
+
+	"github.com/dolthub/dolt/go/libraries/doltcore/doltdb/durable"
+	"github.com/dolthub/dolt/go/libraries/doltcore/schema"
+)
+
+func TestBuildSecondaryProllyIndexWithCheck(t *testing.T) {
+	ctx := sql.NewEmptyContext()
+	vrw := newTestVRW()
+	sch := newTestSchema(t)
+	primary := newTestPrimary(t, ctx, vrw, sch, [][]interface{}{
+		{1, 10, "a"},
+		{2, 20, "b"},
+		{3, nil, "c"},
+		{4, 5, "d"},
+	})
+	idx, err := schema.NewIndexCollection(sch.GetAllCols(), sch.GetPKCols()).AddIndexByColNames(
+		"c2_idx", []string{"c2"}, schema.IndexProperties{IsUserDefined: true})
+	require.NoError(t, err)
+
+	// CHECK (c1 > 8)
+	check := expression.NewGreaterThan(
+		expression.NewGetField(1, sql.Int64, "c1", true),
+		expression.NewLiteral(int64(8), sql.Int64),
+	)
+	secondary, violations, err := BuildSecondaryProllyIndexWithCheck(ctx, vrw, sch, idx, primary, BuildOptions{}, check)
+	require.NoError(t, err)
+	require.Equal(t, uint64(4), secondary.Count())
+	require.Equal(t, []string{"[a,1]", "[b,2]", "[c,3]", "[d,4]"}, collectKeys(t, ctx, durable.ProllyMapFromIndex(secondary)))
+
+	kd, _ := primary.Descriptors()
+	require.Len(t, violations, 1)
+	require.Equal(t, "( 4 )", kd.Format(violations[0]))
+
+	// CHECK (c1 > 0) passes for every row
+	check = expression.NewGreaterThan(
+		expression.NewGetField(1, sql.Int64, "c1", true),
+		expression.NewLiteral(int64(0), sql.Int64),
+	)
+	_, violations, err = BuildSecondaryProllyIndexWithCheck(ctx, vrw, sch, idx, primary, BuildOptions{}, check)
+	require.NoError(t, err)
+	require.Empty(t, violations)
+}
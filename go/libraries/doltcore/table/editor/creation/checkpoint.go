@@ -0,0 +1,333 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/dolthub/dolt/go/libraries/doltcore/doltdb/durable"
+	"github.com/dolthub/dolt/go/libraries/doltcore/schema"
+	"github.com/dolthub/dolt/go/store/hash"
+	"github.com/dolthub/dolt/go/store/prolly"
+	"github.com/dolthub/dolt/go/store/prolly/shim"
+	"github.com/dolthub/dolt/go/store/types"
+	"github.com/dolthub/dolt/go/store/val"
+)
+
+// buildCheckpointer stores the checkpoints of a secondary index build in
+// BuildOptions.IndexBuildCheckpoints. A nil *buildCheckpointer does not
+// checkpoint.
+type buildCheckpointer struct {
+	store   IndexBuildCheckpointStore
+	every   uint64
+	vrw     types.ValueReadWriter
+	idx     schema.Index
+	opts    BuildOptions
+	primary hash.Hash
+	// definition is the hash of the index definition and build options
+	definition hash.Hash
+
+	// start is the index data of the resumed checkpoint, or nil
+	start *prolly.Map
+	// base is the number of rows processed before the build was resumed
+	base uint64
+	// last is the primary key of the last row processed
+	last val.Tuple
+}
+
+// resumeBuild returns the checkpointer of a build of |idx| from |primary|,
+// and an iterator over the primary rows that remain to be indexed. If the
+// build has a checkpoint from an earlier build of the same index definition,
+// with the same options, from the same primary index, the build resumes from
+// it. If the build is not checkpointed, the checkpointer is
+// nil. A checkpoint whose index data can no longer be read, for example
+// because its chunks were lost in a crash before the chunk store persisted
+// them, is ignored.
+func resumeBuild(ctx context.Context, vrw types.ValueReadWriter, sch schema.Schema, idx schema.Index, primary prolly.Map, opts BuildOptions) (*buildCheckpointer, prolly.MapIter, error) {
+	if opts.IndexBuildCheckpoints == nil {
+		iter, err := iterPrimary(ctx, primary, opts)
+		return nil, iter, err
+	}
+	if err := opts.Validate(false); err != nil {
+		return nil, nil, fmt.Errorf("index `%s`: %w", idx.Name(), err)
+	}
+	if _, ok := vrw.(*types.ValueStore); !ok {
+		return nil, nil, fmt.Errorf("index `%s`: cannot checkpoint a build that writes to a %T", idx.Name(), vrw)
+	}
+	def, err := buildDefinitionHash(sch, idx, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	h := primary.HashOf()
+	c := &buildCheckpointer{
+		store:      opts.IndexBuildCheckpoints,
+		every:      opts.IndexBuildCheckpointRows,
+		vrw:        vrw,
+		idx:        idx,
+		opts:       opts,
+		primary:    h,
+		definition: def,
+	}
+
+	cp, ok, err := c.store.LoadCheckpoint(ctx, idx.Name())
+	if err != nil {
+		return nil, nil, err
+	}
+	if ok && cp.Primary == h && cp.Definition == def {
+		v, err := vrw.ReadValue(ctx, cp.Root)
+		if err != nil {
+			return nil, nil, err
+		}
+		if v != nil {
+			empty, err := newSecondaryMap(ctx, vrw, sch, idx, opts)
+			if err != nil {
+				return nil, nil, err
+			}
+			// the stored map is described by the index schema, which lacks
+			// the descriptors of mirrored values and time buckets
+			kd, vd := empty.Descriptors()
+			m := shim.MapFromValue(v, idx.Schema(), vrw)
+			start := prolly.NewMap(m.Node(), m.NodeStore(), kd, vd)
+			c.start, c.base, c.last = &start, cp.RowsProcessed, cp.LastKey
+
+			pkd, _ := primary.Descriptors()
+			iter, err := primary.IterRange(ctx, prolly.GreaterRange(cp.LastKey, pkd))
+			return c, iter, err
+		}
+	}
+
+	iter, err := primary.IterAll(ctx)
+	return c, iter, err
+}
+
+// secondaryMap returns the index data the build starts from.
+func (c *buildCheckpointer) secondaryMap(ctx context.Context, vrw types.ValueReadWriter, sch schema.Schema, idx schema.Index, opts BuildOptions) (prolly.Map, error) {
+	if c != nil && c.start != nil {
+		return *c.start, nil
+	}
+	return newSecondaryMap(ctx, vrw, sch, idx, opts)
+}
+
+// row is called before the primary row with key |k| is processed, when
+// |rows| rows have been processed since the build started or resumed. Every
+// BuildOptions.IndexBuildCheckpointRows rows, the entries of |mut| are
+// written to the build's ValueReadWriter and a checkpoint of them is stored.
+// Returns the map to continue the build with.
+func (c *buildCheckpointer) row(ctx context.Context, mut prolly.MutableMap, k val.Tuple, rows uint64) (prolly.MutableMap, error) {
+	if c == nil {
+		return mut, nil
+	}
+	if rows > 0 && rows%c.every == 0 {
+		cctx, span := startBuildSpan(ctx, c.opts, c.idx, "index.checkpoint")
+		m, err := c.checkpoint(cctx, mut, c.base+rows)
+		if err = span.end(nil, err); err != nil {
+			return mut, err
+		}
+		mut = m.Mutate()
+	}
+	c.last = k
+	return mut, nil
+}
+
+func (c *buildCheckpointer) checkpoint(ctx context.Context, mut prolly.MutableMap, rows uint64) (prolly.Map, error) {
+	m, err := mut.Map(ctx)
+	if err != nil {
+		return prolly.Map{}, err
+	}
+	ref, err := durable.RefFromIndex(ctx, c.vrw, durable.IndexFromProllyMap(m))
+	if err != nil {
+		return prolly.Map{}, err
+	}
+	// the checkpoint must not refer to index data a crash could lose
+	if err = persistValues(ctx, c.vrw.(*types.ValueStore)); err != nil {
+		return prolly.Map{}, err
+	}
+	err = c.store.SaveCheckpoint(ctx, c.idx.Name(), IndexBuildCheckpoint{
+		Primary:       c.primary,
+		Definition:    c.definition,
+		Root:          ref.TargetHash(),
+		LastKey:       c.last,
+		RowsProcessed: rows,
+	})
+	return m, err
+}
+
+// persistValues persists the values written to |vs| in its chunk store,
+// without moving the root of the chunk store.
+func persistValues(ctx context.Context, vs *types.ValueStore) error {
+	for {
+		root, err := vs.Root(ctx)
+		if err != nil {
+			return err
+		}
+		// a commit fails if another writer moved the root, and is retried at the new root
+		ok, err := vs.Commit(ctx, root, root)
+		if err != nil || ok {
+			return err
+		}
+	}
+}
+
+// buildDefinition is the definition of a checkpointed index build: the
+// definition of the index, and the BuildOptions that determine its data, which
+// are its KeyEncodingOptions and ValueLayoutOptions. Of the ExecutionOptions,
+// only whether the build has an IndexRowFilter is recorded, so a build must
+// not be resumed with a different filter.
+type buildDefinition struct {
+	Name       string                 `json:"name"`
+	Columns    []string               `json:"columns"`
+	Properties schema.IndexProperties `json:"properties"`
+
+	Filtered    bool               `json:"filtered"`
+	KeyEncoding KeyEncodingOptions `json:"key_encoding"`
+	ValueLayout ValueLayoutOptions `json:"value_layout"`
+}
+
+// buildDefinitionHash returns the hash of the buildDefinition of building
+// |idx| of |sch| with |opts|.
+func buildDefinitionHash(sch schema.Schema, idx schema.Index, opts BuildOptions) (hash.Hash, error) {
+	def := buildDefinition{
+		Name:        idx.Name(),
+		Properties:  schema.IndexPropertiesOf(idx),
+		Filtered:    opts.IndexRowFilter != nil,
+		KeyEncoding: opts.KeyEncodingOptions,
+		ValueLayout: opts.ValueLayoutOptions,
+	}
+	for _, tag := range idx.IndexedColumnTags() {
+		col, ok := sch.GetAllCols().GetByTag(tag)
+		if !ok {
+			return hash.Hash{}, fmt.Errorf("index `%s`: column with tag %d not found", idx.Name(), tag)
+		}
+		def.Columns = append(def.Columns, fmt.Sprintf("%s %d %s", col.Name, tag, col.TypeInfo.String()))
+	}
+	b, err := json.Marshal(def)
+	if err != nil {
+		return hash.Hash{}, err
+	}
+	return hash.Of(b), nil
+}
+
+// finish clears the checkpoints of a completed build.
+func (c *buildCheckpointer) finish(ctx context.Context) error {
+	if c == nil {
+		return nil
+	}
+	return c.store.ClearCheckpoint(ctx, c.idx.Name())
+}
+
+// FileCheckpointStore is an IndexBuildCheckpointStore that stores the
+// checkpoint of each index in a file of a directory.
+type FileCheckpointStore struct {
+	dir string
+}
+
+var _ IndexBuildCheckpointStore = FileCheckpointStore{}
+
+// NewFileCheckpointStore returns a FileCheckpointStore that stores its
+// checkpoints in |dir|, which must exist.
+func NewFileCheckpointStore(dir string) FileCheckpointStore {
+	return FileCheckpointStore{dir: dir}
+}
+
+type fileCheckpoint struct {
+	Primary       string `json:"primary"`
+	Definition    string `json:"definition"`
+	Root          string `json:"root"`
+	LastKey       []byte `json:"last_key"`
+	RowsProcessed uint64 `json:"rows_processed"`
+}
+
+func (s FileCheckpointStore) path(indexName string) string {
+	return filepath.Join(s.dir, indexName+".checkpoint")
+}
+
+// SaveCheckpoint implements IndexBuildCheckpointStore. The checkpoint
+// is written to a temporary file that is renamed over the previous one, so a
+// crash leaves either checkpoint intact.
+func (s FileCheckpointStore) SaveCheckpoint(_ context.Context, indexName string, cp IndexBuildCheckpoint) error {
+	b, err := json.Marshal(fileCheckpoint{
+		Primary:       cp.Primary.String(),
+		Definition:    cp.Definition.String(),
+		Root:          cp.Root.String(),
+		LastKey:       cp.LastKey,
+		RowsProcessed: cp.RowsProcessed,
+	})
+	if err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(s.dir, indexName+".checkpoint.*")
+	if err != nil {
+		return err
+	}
+	if _, err = f.Write(b); err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), s.path(indexName))
+	}
+	if err != nil {
+		_ = os.Remove(f.Name())
+	}
+	return err
+}
+
+// LoadCheckpoint implements IndexBuildCheckpointStore.
+func (s FileCheckpointStore) LoadCheckpoint(_ context.Context, indexName string) (IndexBuildCheckpoint, bool, error) {
+	b, err := os.ReadFile(s.path(indexName))
+	if os.IsNotExist(err) {
+		return IndexBuildCheckpoint{}, false, nil
+	} else if err != nil {
+		return IndexBuildCheckpoint{}, false, err
+	}
+	var fc fileCheckpoint
+	if err = json.Unmarshal(b, &fc); err != nil {
+		return IndexBuildCheckpoint{}, false, err
+	}
+	primary, ok := hash.MaybeParse(fc.Primary)
+	if !ok {
+		return IndexBuildCheckpoint{}, false, fmt.Errorf("invalid index build checkpoint for index `%s`", indexName)
+	}
+	definition, ok := hash.MaybeParse(fc.Definition)
+	if !ok {
+		return IndexBuildCheckpoint{}, false, fmt.Errorf("invalid index build checkpoint for index `%s`", indexName)
+	}
+	root, ok := hash.MaybeParse(fc.Root)
+	if !ok {
+		return IndexBuildCheckpoint{}, false, fmt.Errorf("invalid index build checkpoint for index `%s`", indexName)
+	}
+	return IndexBuildCheckpoint{
+		Primary:       primary,
+		Definition:    definition,
+		Root:          root,
+		LastKey:       fc.LastKey,
+		RowsProcessed: fc.RowsProcessed,
+	}, true, nil
+}
+
+// ClearCheckpoint implements IndexBuildCheckpointStore.
+func (s FileCheckpointStore) ClearCheckpoint(_ context.Context, indexName string) error {
+	err := os.Remove(s.path(indexName))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
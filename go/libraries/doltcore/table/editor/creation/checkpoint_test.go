@@ -0,0 +1,179 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/dolthub/dolt/go/libraries/doltcore/doltdb/durable"
+	"github.com/dolthub/dolt/go/libraries/doltcore/schema"
+	"github.com/dolthub/dolt/go/store/hash"
+	"github.com/dolthub/dolt/go/store/nbs"
+	"github.com/dolthub/dolt/go/store/prolly"
+	"github.com/dolthub/dolt/go/store/prolly/shim"
+	"github.com/dolthub/dolt/go/store/types"
+)
+
+func TestIndexBuildCheckpoints(t *testing.T) {
+	ctx := context.Background()
+	sch := newTestSchema(t)
+	var rows [][]interface{}
+	for i := 0; i < 10; i++ {
+		rows = append(rows, []interface{}{i, 100 - i, fmt.Sprintf("v%d", i)})
+	}
+	errCrash := errors.New("crash")
+
+	for _, unique := range []bool{false, true} {
+		t.Run(fmt.Sprintf("unique=%t", unique), func(t *testing.T) {
+			idx, err := schema.NewIndexCollection(sch.GetAllCols(), sch.GetPKCols()).AddIndexByColNames(
+				"c1_idx", []string{"c1"}, schema.IndexProperties{IsUnique: unique})
+			require.NoError(t, err)
+
+			dir := t.TempDir()
+			vs := openTestValueStore(t, ctx, dir)
+			primary := persistTestPrimary(t, ctx, vs, sch, rows)
+			cpDir := t.TempDir()
+			store := NewFileCheckpointStore(cpDir)
+			opts := BuildOptions{ExecutionOptions: ExecutionOptions{IndexBuildCheckpoints: store, IndexBuildCheckpointRows: 3}}
+
+			// the build crashes at its second checkpoint, after the first 3 rows were checkpointed
+			crashing := opts
+			crashing.IndexBuildCheckpoints = &crashingCheckpointStore{IndexBuildCheckpointStore: store, crashAt: 2, err: errCrash}
+			_, err = BuildSecondaryProllyIndex(ctx, vs, sch, idx, primary, crashing)
+			require.ErrorIs(t, err, errCrash)
+
+			// the process restarts, losing the chunks that were not persisted
+			require.NoError(t, vs.Close())
+			vs = openTestValueStore(t, ctx, dir)
+			primary = loadTestPrimary(t, ctx, vs, sch, primary.HashOf())
+			store = NewFileCheckpointStore(cpDir)
+			opts.IndexBuildCheckpoints = store
+			cp, ok, err := store.LoadCheckpoint(ctx, idx.Name())
+			require.NoError(t, err)
+			require.True(t, ok)
+			require.Equal(t, uint64(3), cp.RowsProcessed)
+			expected, err := BuildSecondaryProllyIndex(ctx, vs, sch, idx, primary, BuildOptions{})
+			require.NoError(t, err)
+
+			// the next build resumes after the checkpoint, and clears it when it completes
+			stats := &IndexBuildStats{}
+			resumed := opts
+			resumed.IndexBuildStats = stats
+			actual, err := BuildSecondaryProllyIndex(ctx, vs, sch, idx, primary, resumed)
+			require.NoError(t, err)
+			require.Equal(t, uint64(7), stats.RowsScanned)
+			requireSameIndex(t, expected, actual)
+			_, ok, err = store.LoadCheckpoint(ctx, idx.Name())
+			require.NoError(t, err)
+			require.False(t, ok)
+
+			// a checkpoint of different primary rows is not resumed
+			crashing.IndexBuildCheckpoints = &crashingCheckpointStore{IndexBuildCheckpointStore: store, crashAt: 2, err: errCrash}
+			_, err = BuildSecondaryProllyIndex(ctx, vs, sch, idx, primary, crashing)
+			require.ErrorIs(t, err, errCrash)
+			other := newTestPrimary(t, ctx, vs, sch, rows[:9])
+			_, err = BuildSecondaryProllyIndex(ctx, vs, sch, idx, other, resumed)
+			require.NoError(t, err)
+			require.Equal(t, uint64(9), stats.RowsScanned)
+
+			// nor is a checkpoint of an index of the same name over other columns
+			crashing.IndexBuildCheckpoints = &crashingCheckpointStore{IndexBuildCheckpointStore: store, crashAt: 2, err: errCrash}
+			_, err = BuildSecondaryProllyIndex(ctx, vs, sch, idx, primary, crashing)
+			require.ErrorIs(t, err, errCrash)
+			redefined, err := schema.NewIndexCollection(sch.GetAllCols(), sch.GetPKCols()).AddIndexByColNames(
+				"c1_idx", []string{"c2"}, schema.IndexProperties{IsUnique: unique})
+			require.NoError(t, err)
+			expected, err = BuildSecondaryProllyIndex(ctx, vs, sch, redefined, primary, BuildOptions{})
+			require.NoError(t, err)
+			actual, err = BuildSecondaryProllyIndex(ctx, vs, sch, redefined, primary, resumed)
+			require.NoError(t, err)
+			require.Equal(t, uint64(10), stats.RowsScanned)
+			requireSameIndex(t, expected, actual)
+
+			// nor a checkpoint of a build with other options
+			crashing.IndexBuildCheckpoints = &crashingCheckpointStore{IndexBuildCheckpointStore: store, crashAt: 2, err: errCrash}
+			_, err = BuildSecondaryProllyIndex(ctx, vs, sch, idx, primary, crashing)
+			require.ErrorIs(t, err, errCrash)
+			checksummed := resumed
+			checksummed.IndexEntryChecksums = true
+			_, err = BuildSecondaryProllyIndex(ctx, vs, sch, idx, primary, checksummed)
+			require.NoError(t, err)
+			require.Equal(t, uint64(10), stats.RowsScanned)
+			require.NoError(t, vs.Close())
+		})
+	}
+
+	// checkpoints cannot be persisted without a ValueStore
+	vrw := newTestVRW()
+	idx, err := schema.NewIndexCollection(sch.GetAllCols(), sch.GetPKCols()).AddIndexByColNames(
+		"c1_idx", []string{"c1"}, schema.IndexProperties{})
+	require.NoError(t, err)
+	_, err = BuildSecondaryProllyIndex(ctx, noStoreVRW{vrw}, sch, idx, newTestPrimary(t, ctx, vrw, sch, rows), BuildOptions{
+		ExecutionOptions: ExecutionOptions{
+			IndexBuildCheckpoints:    NewFileCheckpointStore(t.TempDir()),
+			IndexBuildCheckpointRows: 3,
+		},
+	})
+	require.Error(t, err)
+}
+
+// openTestValueStore returns a ValueStore of a chunk store in |dir|, which is reopened by opening the same |dir|.
+func openTestValueStore(t *testing.T, ctx context.Context, dir string) *types.ValueStore {
+	cs, err := nbs.NewLocalStore(ctx, types.Format_DOLT_1.VersionString(), dir, 1<<20, nbs.NewUnlimitedMemQuotaProvider())
+	require.NoError(t, err)
+	return types.NewValueStore(cs)
+}
+
+// persistTestPrimary returns a primary index containing |rows|, persisted in the chunk store of |vs|.
+func persistTestPrimary(t *testing.T, ctx context.Context, vs *types.ValueStore, sch schema.Schema, rows [][]interface{}) prolly.Map {
+	primary := newTestPrimary(t, ctx, vs, sch, rows)
+	_, err := durable.RefFromIndex(ctx, vs, durable.IndexFromProllyMap(primary))
+	require.NoError(t, err)
+	require.NoError(t, persistValues(ctx, vs))
+	return primary
+}
+
+// loadTestPrimary returns the primary index persisted by persistTestPrimary with hash |h|.
+func loadTestPrimary(t *testing.T, ctx context.Context, vs *types.ValueStore, sch schema.Schema, h hash.Hash) prolly.Map {
+	v, err := vs.ReadValue(ctx, h)
+	require.NoError(t, err)
+	require.NotNil(t, v)
+	return shim.MapFromValue(v, sch, vs)
+}
+
+// noStoreVRW is a ValueReadWriter that is not a ValueStore.
+type noStoreVRW struct {
+	types.ValueReadWriter
+}
+
+// crashingCheckpointStore is an IndexBuildCheckpointStore that fails to save its |crashAt|th checkpoint.
+type crashingCheckpointStore struct {
+	IndexBuildCheckpointStore
+	crashAt int
+	err     error
+	saves   int
+}
+
+func (s *crashingCheckpointStore) SaveCheckpoint(ctx context.Context, indexName string, cp IndexBuildCheckpoint) error {
+	if s.saves++; s.saves == s.crashAt {
+		return s.err
+	}
+	return s.IndexBuildCheckpointStore.SaveCheckpoint(ctx, indexName, cp)
+}
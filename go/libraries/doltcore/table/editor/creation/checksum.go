@@ -0,0 +1,86 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"context"
+	"fmt"
+	"hash/crc32"
+	"io"
+
+	"github.com/dolthub/dolt/go/libraries/doltcore/doltdb/durable"
+	"github.com/dolthub/dolt/go/libraries/doltcore/schema"
+	"github.com/dolthub/dolt/go/store/pool"
+	"github.com/dolthub/dolt/go/store/val"
+)
+
+// checksumValueDesc is the value descriptor of an index built with
+// BuildOptions.IndexEntryChecksums. The value of an entry is the CRC-32C
+// checksum of the bytes of its key.
+var checksumValueDesc = val.NewTupleDescriptor(val.Type{Enc: val.Uint32Enc})
+
+var checksumTable = crc32.MakeTable(crc32.Castagnoli)
+
+// entryChecksums computes the values of an index built with
+// BuildOptions.IndexEntryChecksums. A nil *entryChecksums computes no
+// checksums.
+type entryChecksums struct {
+	vb *val.TupleBuilder
+}
+
+// newEntryChecksums returns the entryChecksums of a build of |idx|, or nil if
+// |opts| does not set IndexEntryChecksums.
+func newEntryChecksums(idx schema.Index, opts BuildOptions) (*entryChecksums, error) {
+	if !opts.IndexEntryChecksums {
+		return nil, nil
+	}
+	return &entryChecksums{vb: val.NewTupleBuilder(checksumValueDesc)}, nil
+}
+
+// value returns the value of the entry with the key |key|.
+func (c *entryChecksums) value(key val.Tuple, p pool.BuffPool) val.Tuple {
+	c.vb.PutUint32(0, crc32.Checksum(key, checksumTable))
+	return c.vb.Build(p)
+}
+
+// VerifyIndexChecksums recomputes the checksums of the entries of |rows|, the
+// data of an index built with BuildOptions.IndexEntryChecksums, and returns
+// the keys of the entries whose stored checksum does not match their key. A
+// mismatch means that the key or the value of the entry was corrupted in
+// storage, whether or not the primary rows of the index are intact.
+func VerifyIndexChecksums(ctx context.Context, rows durable.Index) ([]val.Tuple, error) {
+	m := durable.ProllyMapFromIndex(rows)
+	_, vd := m.Descriptors()
+	if vd.Count() != 1 || vd.Types[0].Enc != val.Uint32Enc {
+		return nil, fmt.Errorf("index data is not the data of an index with entry checksums")
+	}
+	iter, err := m.IterAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var corrupt []val.Tuple
+	for {
+		k, v, err := iter.Next(ctx)
+		if err == io.EOF {
+			return corrupt, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if sum, ok := vd.GetUint32(0, v); !ok || sum != crc32.Checksum(k, checksumTable) {
+			corrupt = append(corrupt, k)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/dolthub/dolt/go/libraries/doltcore/doltdb/durable"
+	"github.com/dolthub/dolt/go/libraries/doltcore/schema"
+	"github.com/dolthub/dolt/go/store/val"
+)
+
+func TestIndexEntryChecksums(t *testing.T) {
+	ctx := context.Background()
+	vrw := newTestVRW()
+	sch := newTestSchema(t)
+	var rows [][]interface{}
+	for i := 0; i < 100; i++ {
+		rows = append(rows, []interface{}{i, i % 10, "row"})
+	}
+	primary := newTestPrimary(t, ctx, vrw, sch, rows)
+	coll := schema.NewIndexCollection(sch.GetAllCols(), sch.GetPKCols())
+	idx, err := coll.AddIndexByColNames("c1_idx", []string{"c1"}, schema.IndexProperties{})
+	require.NoError(t, err)
+	uniq, err := coll.AddIndexByColNames("pk_uniq", []string{"c2", "pk"}, schema.IndexProperties{IsUnique: true})
+	require.NoError(t, err)
+
+	opts := BuildOptions{ValueLayoutOptions: ValueLayoutOptions{IndexEntryChecksums: true}}
+	for _, i := range []schema.Index{idx, uniq} {
+		rowData, err := BuildSecondaryProllyIndex(ctx, vrw, sch, i, primary, opts)
+		require.NoError(t, err)
+		require.Equal(t, uint64(100), rowData.Count())
+		corrupt, err := VerifyIndexChecksums(ctx, rowData)
+		require.NoError(t, err)
+		require.Empty(t, corrupt)
+
+		// the keys are those of an index without checksums
+		plain, err := BuildSecondaryProllyIndex(ctx, vrw, sch, i, primary, BuildOptions{})
+		require.NoError(t, err)
+		require.Equal(t, collectKeys(t, ctx, durable.ProllyMapFromIndex(plain)), collectKeys(t, ctx, durable.ProllyMapFromIndex(rowData)))
+	}
+
+	rowData, err := BuildSecondaryProllyIndex(ctx, vrw, sch, idx, primary, opts)
+	require.NoError(t, err)
+	m := durable.ProllyMapFromIndex(rowData)
+	kd, vd := m.Descriptors()
+
+	// flip a bit of the key of one entry, and of the value of another
+	var keys, values []val.Tuple
+	iter, err := m.IterAll(ctx)
+	require.NoError(t, err)
+	for i := 0; i < 2; i++ {
+		k, v, err := iter.Next(ctx)
+		require.NoError(t, err)
+		keys, values = append(keys, k), append(values, v)
+	}
+	mut := m.Mutate()
+	require.NoError(t, mut.Delete(ctx, keys[0]))
+	flipped := append(val.Tuple(nil), keys[0]...)
+	flipped[0] ^= 0x40
+	require.NoError(t, mut.Put(ctx, flipped, values[0]))
+	rotten := append(val.Tuple(nil), values[1]...)
+	rotten[0] ^= 0x01
+	require.NoError(t, mut.Put(ctx, keys[1], rotten))
+	m, err = mut.Map(ctx)
+	require.NoError(t, err)
+
+	corrupt, err := VerifyIndexChecksums(ctx, durable.IndexFromProllyMap(m))
+	require.NoError(t, err)
+	var formatted []string
+	for _, k := range corrupt {
+		formatted = append(formatted, kd.Format(k))
+	}
+	require.ElementsMatch(t, []string{kd.Format(flipped), kd.Format(keys[1])}, formatted)
+	require.Equal(t, 1, vd.Count())
+
+	// the values of the index hold the checksums, and nothing else
+	_, err = BuildSecondaryProllyIndex(ctx, vrw, sch, idx, primary, BuildOptions{ValueLayoutOptions: ValueLayoutOptions{IndexEntryChecksums: true, MirrorPrimaryRowInIndex: true}})
+	require.Error(t, err)
+	for _, other := range []BuildOptions{
+		{ValueLayoutOptions: ValueLayoutOptions{IndexEntryChecksums: true, MirrorPrimaryRowInIndex: true}},
+		{ValueLayoutOptions: ValueLayoutOptions{IndexEntryChecksums: true, RecordSourceChunkInIndex: true}},
+		{ValueLayoutOptions: ValueLayoutOptions{IndexEntryChecksums: true, RecordRowLocatorInIndex: true}},
+		{ValueLayoutOptions: ValueLayoutOptions{IndexEntryChecksums: true, IndexIntervalEnd: "c1"}},
+		{ValueLayoutOptions: ValueLayoutOptions{IndexEntryChecksums: true, IndexExpiryColumn: "c1"}},
+	} {
+		_, err = newSecondaryMap(ctx, vrw, sch, idx, other)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "IndexEntryChecksums cannot be combined")
+	}
+	plain, err := BuildSecondaryProllyIndex(ctx, vrw, sch, idx, primary, BuildOptions{})
+	require.NoError(t, err)
+	_, err = VerifyIndexChecksums(ctx, plain)
+	require.Error(t, err)
+
+	tbl := newTestTable(t, ctx, vrw, sch, rows)
+	_, err = CreateIndex(ctx, tbl, "idx", []string{"c1"}, false, true, "", opts)
+	require.Error(t, err)
+}
@@ -0,0 +1,87 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"context"
+	"io"
+
+	"github.com/dolthub/dolt/go/libraries/doltcore/schema"
+	"github.com/dolthub/dolt/go/libraries/doltcore/sqle/index"
+	"github.com/dolthub/dolt/go/store/prolly"
+)
+
+// defaultColumnBatchSize is the number of entries in each batch passed to an
+// IndexColumnSink, if BuildOptions.IndexColumnBatchSize is zero.
+const defaultColumnBatchSize = 1024
+
+// emitIndexColumns passes the indexed values of |secondary|, the data of |idx|
+// built with |opts|, to the IndexColumnSink of |opts|, if any.
+func emitIndexColumns(ctx context.Context, idx schema.Index, secondary prolly.Map, opts BuildOptions) error {
+	if opts.IndexColumnSink == nil {
+		return nil
+	}
+	size := opts.IndexColumnBatchSize
+	if size <= 0 {
+		size = defaultColumnBatchSize
+	}
+	// the indexed fields follow the time bucket or geohash of the key, if any
+	off := 0
+	if idx.TimeBucket() != 0 || opts.IndexGeohashPrecision != 0 {
+		off = 1
+	}
+	kd, _ := secondary.Descriptors()
+	names := idx.ColumnNames()
+
+	batch := IndexColumnBatch{Names: names, Columns: make([][]interface{}, len(names))}
+	n := 0
+	flush := func() error {
+		if n == 0 {
+			return nil
+		}
+		if err := opts.IndexColumnSink.WriteBatch(ctx, batch); err != nil {
+			return err
+		}
+		batch = IndexColumnBatch{Names: names, Columns: make([][]interface{}, len(names))}
+		n = 0
+		return nil
+	}
+
+	iter, err := secondary.IterAll(ctx)
+	if err != nil {
+		return err
+	}
+	for {
+		k, _, err := iter.Next(ctx)
+		if err == io.EOF {
+			return flush()
+		}
+		if err != nil {
+			return err
+		}
+		for i := range names {
+			v, err := index.GetField(ctx, kd, off+i, k, secondary.NodeStore())
+			if err != nil {
+				return err
+			}
+			batch.Columns[i] = append(batch.Columns[i], v)
+		}
+		if n++; n == size {
+			if err = flush(); err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,87 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/dolthub/dolt/go/libraries/doltcore/schema"
+)
+
+type capturingColumnSink struct {
+	batches []IndexColumnBatch
+	err     error
+}
+
+func (s *capturingColumnSink) WriteBatch(_ context.Context, batch IndexColumnBatch) error {
+	s.batches = append(s.batches, batch)
+	return s.err
+}
+
+func TestIndexColumnSink(t *testing.T) {
+	ctx := context.Background()
+	vrw := newTestVRW()
+	sch := newTestSchema(t)
+	var rows [][]interface{}
+	for i := 0; i < 10; i++ {
+		var c2 interface{} = string(rune('j' - i))
+		if i == 4 {
+			c2 = nil
+		}
+		rows = append(rows, []interface{}{i, i * 7 % 10, c2})
+	}
+	primary := newTestPrimary(t, ctx, vrw, sch, rows)
+	coll := schema.NewIndexCollection(sch.GetAllCols(), sch.GetPKCols())
+	idx, err := coll.AddIndexByColNames("c2_c1", []string{"c2", "c1"}, schema.IndexProperties{})
+	require.NoError(t, err)
+	uniq, err := coll.AddIndexByColNames("c1_uniq", []string{"c1"}, schema.IndexProperties{IsUnique: true})
+	require.NoError(t, err)
+
+	sink := &capturingColumnSink{}
+	_, err = BuildSecondaryProllyIndex(ctx, vrw, sch, idx, primary, BuildOptions{ExecutionOptions: ExecutionOptions{IndexColumnSink: sink, IndexColumnBatchSize: 3}})
+	require.NoError(t, err)
+	require.Len(t, sink.batches, 4)
+	var c2s, c1s []interface{}
+	for i, b := range sink.batches {
+		require.Equal(t, []string{"c2", "c1"}, b.Names)
+		require.Len(t, b.Columns, 2)
+		if i < 3 {
+			require.Len(t, b.Columns[0], 3)
+		}
+		c2s, c1s = append(c2s, b.Columns[0]...), append(c1s, b.Columns[1]...)
+	}
+	// the values are in index order, with the NULL last
+	require.Equal(t, []interface{}{"a", "b", "c", "d", "e", "g", "h", "i", "j", nil}, c2s)
+	require.Equal(t, []interface{}{int64(3), int64(6), int64(9), int64(2), int64(5), int64(1), int64(4), int64(7), int64(0), int64(8)}, c1s)
+
+	// unique builds emit the same batches, whether or not they are pipelined
+	for _, workers := range []int{0, 2} {
+		sink := &capturingColumnSink{}
+		_, err = BuildSecondaryProllyIndex(ctx, vrw, sch, uniq, primary, BuildOptions{ExecutionOptions: ExecutionOptions{IndexColumnSink: sink, UniqueIndexCheckWorkers: workers}})
+		require.NoError(t, err)
+		require.Len(t, sink.batches, 1)
+		require.Equal(t, []interface{}{int64(0), int64(1), int64(2), int64(3), int64(4), int64(5), int64(6), int64(7), int64(8), int64(9)}, sink.batches[0].Columns[0])
+	}
+
+	// an error of the sink stops the build
+	failing := &capturingColumnSink{err: errors.New("sink is full")}
+	_, err = BuildSecondaryProllyIndex(ctx, vrw, sch, idx, primary, BuildOptions{ExecutionOptions: ExecutionOptions{IndexColumnSink: failing, IndexColumnBatchSize: 3}})
+	require.ErrorIs(t, err, failing.err)
+	require.Len(t, failing.batches, 1)
+}
@@ -0,0 +1,193 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"context"
+	"io"
+	"sort"
+
+	"github.com/dolthub/dolt/go/libraries/doltcore/doltdb/durable"
+	"github.com/dolthub/dolt/go/libraries/doltcore/schema"
+	"github.com/dolthub/dolt/go/store/prolly"
+	"github.com/dolthub/dolt/go/store/types"
+	"github.com/dolthub/dolt/go/store/val"
+)
+
+// groupCountValueDesc is the value descriptor of a group count index.
+var groupCountValueDesc = val.NewTupleDescriptor(val.Type{Enc: val.Uint64Enc, Nullable: false})
+
+// BuildGroupCountIndex builds a summary of the secondary index |idx| over
+// |primary|, for GROUP BY queries over its indexed columns: an index mapping
+// each distinct value of the indexed columns to the number of rows with that
+// value. Rows with NULL values are grouped together, as GROUP BY groups them.
+// The keys of a time bucketed index are grouped by their bucket too.
+//
+// A scan of |primary| is not in the order of the index, so the counts are
+// aggregated in memory while there are at most |maxGroups| distinct values.
+// Past that, the counts are instead taken from a sorted build of |idx|, which
+// holds one entry per row rather than per value. The index is not maintained
+// by writes to the table, and must be rebuilt when they change the counts.
+func BuildGroupCountIndex(ctx context.Context, vrw types.ValueReadWriter, sch schema.Schema, idx schema.Index, primary prolly.Map, maxGroups int) (durable.Index, error) {
+	secondary, err := newSecondaryMap(ctx, vrw, sch, idx, BuildOptions{})
+	if err != nil {
+		return nil, err
+	}
+	kd, _ := secondary.Descriptors()
+	enc, err := newIndexKeyEncoder(sch, idx, kd, BuildOptions{})
+	if err != nil {
+		return nil, err
+	}
+	c := groupCounter{n: enc.offset() + idx.Count()}
+	c.kd = prefixDesc(kd, c.n)
+	empty, err := prolly.NewMapFromTuples(ctx, secondary.NodeStore(), c.kd, groupCountValueDesc)
+	if err != nil {
+		return nil, err
+	}
+	if c.app, err = newAppendIndexBuilder(ctx, empty); err != nil {
+		return nil, err
+	}
+	// groups are put in key order
+	c.app.strict = true
+	c.kb = val.NewTupleBuilder(c.kd)
+	c.vb = val.NewTupleBuilder(groupCountValueDesc)
+
+	ok, err := c.countInMemory(ctx, idx, enc, primary, maxGroups)
+	if err == nil && !ok {
+		err = c.countSorted(ctx, vrw, sch, idx, primary)
+	}
+	if err != nil {
+		return nil, err
+	}
+	m, err := c.app.build(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return durable.IndexFromProllyMap(m), nil
+}
+
+// GroupCount returns the number of rows whose indexed values are |prefix| in
+// |counts|, a group count index built by BuildGroupCountIndex.
+func GroupCount(ctx context.Context, counts durable.Index, prefix val.Tuple) (n uint64, err error) {
+	err = durable.ProllyMapFromIndex(counts).Get(ctx, prefix, func(_, v val.Tuple) error {
+		if v != nil {
+			n, _ = groupCountValueDesc.GetUint64(0, v)
+		}
+		return nil
+	})
+	return n, err
+}
+
+// groupCounter puts the groups of a group count index, the distinct prefixes
+// of |n| fields of the index keys, and their counts.
+type groupCounter struct {
+	n   int
+	kd  val.TupleDesc
+	kb  *val.TupleBuilder
+	vb  *val.TupleBuilder
+	app *appendIndexBuilder
+}
+
+// countInMemory counts the groups of the index keys encoded by |enc| from a
+// scan of |primary|. It returns false, without putting any groups, if there
+// are more than |maxGroups| of them.
+func (c groupCounter) countInMemory(ctx context.Context, idx schema.Index, enc *indexKeyEncoder, primary prolly.Map, maxGroups int) (bool, error) {
+	iter, err := primary.IterAll(ctx)
+	if err != nil {
+		return false, err
+	}
+	mon := newBuildMonitor(idx, BuildOptions{})
+	counts := make(map[string]uint64)
+	var groups []val.Tuple
+	for {
+		k, v, err := iter.Next(ctx)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return false, err
+		}
+		if err = enc.put(k, v, mon); err != nil {
+			return false, err
+		}
+		g := c.group(enc.kb.Build(primary.Pool()), primary)
+		if _, ok := counts[string(g)]; !ok {
+			if len(groups) == maxGroups {
+				return false, nil
+			}
+			groups = append(groups, g)
+		}
+		counts[string(g)]++
+	}
+
+	sort.Slice(groups, func(i, j int) bool {
+		return c.kd.Compare(groups[i], groups[j]) < 0
+	})
+	for _, g := range groups {
+		if err = c.put(ctx, g, counts[string(g)], primary); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// countSorted counts the groups of |idx| from runs of equal prefixes in a
+// sorted build of the index.
+func (c groupCounter) countSorted(ctx context.Context, vrw types.ValueReadWriter, sch schema.Schema, idx schema.Index, primary prolly.Map) error {
+	rows, err := BuildSecondaryProllyIndex(ctx, vrw, sch, idx, primary, BuildOptions{})
+	if err != nil {
+		return err
+	}
+	iter, err := durable.ProllyMapFromIndex(rows).IterAll(ctx)
+	if err != nil {
+		return err
+	}
+	var prev val.Tuple
+	var n uint64
+	for {
+		k, _, err := iter.Next(ctx)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		if prev != nil && !samePrefix(prev, k, c.n) {
+			if err = c.put(ctx, c.group(prev, primary), n, primary); err != nil {
+				return err
+			}
+			n = 0
+		}
+		prev = k
+		n++
+	}
+	if prev == nil {
+		return nil
+	}
+	return c.put(ctx, c.group(prev, primary), n, primary)
+}
+
+// group returns the group of the index key |k|.
+func (c groupCounter) group(k val.Tuple, m prolly.Map) val.Tuple {
+	for i := 0; i < c.n; i++ {
+		c.kb.PutRaw(i, k.GetField(i))
+	}
+	return c.kb.BuildPermissive(m.Pool())
+}
+
+// put puts the group |g| with the count |n|.
+func (c groupCounter) put(ctx context.Context, g val.Tuple, n uint64, m prolly.Map) error {
+	c.vb.PutUint64(0, n)
+	_, err := c.app.put(ctx, g, c.vb.Build(m.Pool()))
+	return err
+}
@@ -0,0 +1,98 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/dolthub/dolt/go/libraries/doltcore/doltdb/durable"
+	"github.com/dolthub/dolt/go/libraries/doltcore/schema"
+	"github.com/dolthub/dolt/go/store/val"
+)
+
+func TestGroupCountIndex(t *testing.T) {
+	ctx := context.Background()
+	vrw := newTestVRW()
+	sch := newTestSchema(t)
+	// SELECT c1, COUNT(*) FROM t GROUP BY c1
+	expected := make(map[interface{}]uint64)
+	var rows [][]interface{}
+	for i := 0; i < 2000; i++ {
+		var c1 interface{} = i % 13
+		if i%10 == 0 {
+			c1 = nil
+		}
+		rows = append(rows, []interface{}{i, c1, "row"})
+		expected[c1]++
+	}
+	primary := newTestPrimary(t, ctx, vrw, sch, rows)
+	coll := schema.NewIndexCollection(sch.GetAllCols(), sch.GetPKCols())
+	idx, err := coll.AddIndexByColNames("c1_idx", []string{"c1"}, schema.IndexProperties{})
+	require.NoError(t, err)
+
+	// 14 groups are counted in memory, or from a sorted build past 5
+	inMemory, err := BuildGroupCountIndex(ctx, vrw, sch, idx, primary, 100)
+	require.NoError(t, err)
+	sorted, err := BuildGroupCountIndex(ctx, vrw, sch, idx, primary, 5)
+	require.NoError(t, err)
+	requireSameIndex(t, inMemory, sorted)
+	require.Equal(t, uint64(len(expected)), inMemory.Count())
+
+	m := durable.ProllyMapFromIndex(inMemory)
+	kd, vd := m.Descriptors()
+	iter, err := m.IterAll(ctx)
+	require.NoError(t, err)
+	actual := make(map[interface{}]uint64)
+	var order []interface{}
+	for {
+		k, v, err := iter.Next(ctx)
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		var c1 interface{}
+		if n, ok := kd.GetInt64(0, k); ok {
+			c1 = int(n)
+		}
+		actual[c1], _ = vd.GetUint64(0, v)
+		order = append(order, c1)
+	}
+	require.Equal(t, expected, actual)
+	// NULLs sort last
+	require.Equal(t, []interface{}{0, 1, 2}, order[:3])
+	require.Nil(t, order[len(order)-1])
+
+	// SELECT COUNT(*) FROM t WHERE c1 = 7
+	kb := val.NewTupleBuilder(kd)
+	kb.PutInt64(0, 7)
+	n, err := GroupCount(ctx, inMemory, kb.Build(sharePool))
+	require.NoError(t, err)
+	require.Equal(t, expected[7], n)
+	kb.PutInt64(0, 99)
+	n, err = GroupCount(ctx, inMemory, kb.Build(sharePool))
+	require.NoError(t, err)
+	require.Zero(t, n)
+
+	// every row is its own group of an index over its primary key
+	c2pk, err := coll.AddIndexByColNames("c2_pk", []string{"c2", "pk"}, schema.IndexProperties{})
+	require.NoError(t, err)
+	perRow, err := BuildGroupCountIndex(ctx, vrw, sch, c2pk, primary, 10)
+	require.NoError(t, err)
+	require.Equal(t, uint64(len(rows)), perRow.Count())
+}
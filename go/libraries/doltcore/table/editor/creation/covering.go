@@ -0,0 +1,94 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"fmt"
+
+	"github.com/dolthub/dolt/go/libraries/doltcore/schema"
+	"github.com/dolthub/dolt/go/store/pool"
+	"github.com/dolthub/dolt/go/store/prolly/shim"
+	"github.com/dolthub/dolt/go/store/val"
+)
+
+// CoveringRowBuilder reconstructs full primary rows from the entries of a
+// secondary index that covers every column of its table. This allows index
+// scans to drive deletes and updates without reading from the primary index.
+type CoveringRowBuilder struct {
+	// keyMap maps primary key fields to index key fields
+	keyMap val.OrdinalMapping
+	// valMap maps primary value fields to index key fields
+	valMap val.OrdinalMapping
+	kb, vb *val.TupleBuilder
+}
+
+// NewCoveringRowBuilder returns a CoveringRowBuilder for |idx|. It returns an
+// error if |idx| does not cover all columns of |sch|.
+func NewCoveringRowBuilder(sch schema.Schema, idx schema.Index) (*CoveringRowBuilder, error) {
+	if schema.IsKeyless(sch) {
+		return nil, fmt.Errorf("index `%s`: cannot reconstruct rows of a keyless table", idx.Name())
+	}
+
+	pkLen := sch.GetPKCols().Size()
+	idxMap, err := GetIndexKeyMapping(sch, idx)
+	if err != nil {
+		return nil, err
+	}
+
+	// invert the index key mapping
+	keyMap := make(val.OrdinalMapping, pkLen)
+	valMap := make(val.OrdinalMapping, sch.GetNonPKCols().Size())
+	for i := range keyMap {
+		keyMap[i] = -1
+	}
+	for i := range valMap {
+		valMap[i] = -1
+	}
+	for to := range idxMap {
+		from := idxMap.MapOrdinal(to)
+		if from < pkLen {
+			keyMap[from] = to
+		} else {
+			valMap[from-pkLen] = to
+		}
+	}
+
+	for i, j := range valMap {
+		if j < 0 {
+			col := sch.GetNonPKCols().GetByIndex(i)
+			return nil, fmt.Errorf("index `%s` does not cover column `%s`", idx.Name(), col.Name)
+		}
+	}
+
+	kd, vd := shim.MapDescriptorsFromSchema(sch)
+	return &CoveringRowBuilder{
+		keyMap: keyMap,
+		valMap: valMap,
+		kb:     val.NewTupleBuilder(kd),
+		vb:     val.NewTupleBuilder(vd),
+	}, nil
+}
+
+// Build returns the primary key and value of the row stored at |idxKey| in
+// the covering index.
+func (b *CoveringRowBuilder) Build(idxKey val.Tuple, p pool.BuffPool) (k, v val.Tuple) {
+	for to, from := range b.keyMap {
+		b.kb.PutRaw(to, idxKey.GetField(from))
+	}
+	for to, from := range b.valMap {
+		b.vb.PutRaw(to, idxKey.GetField(from))
+	}
+	return b.kb.Build(p), b.vb.Build(p)
+}
@@ -0,0 +1,107 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/dolthub/dolt/go/libraries/doltcore/doltdb/durable"
+	"github.com/dolthub/dolt/go/store/val"
+)
+
+func TestDeleteByCoveringIndex(t *testing.T) {
+	ctx := context.Background()
+	vrw := newTestVRW()
+	sch := newTestSchema(t)
+	tbl := newTestTable(t, ctx, vrw, sch, [][]interface{}{
+		{1, 10, "a"},
+		{2, 20, "b"},
+		{3, 10, "c"},
+		{4, 30, nil},
+	})
+
+	ret, err := CreateIndex(ctx, tbl, "c1c2", []string{"c1", "c2"}, false, true, "", BuildOptions{})
+	require.NoError(t, err)
+	sch = ret.Sch
+
+	rows, err := ret.NewTable.GetRowData(ctx)
+	require.NoError(t, err)
+	primary := durable.ProllyMapFromIndex(rows)
+	idxRows, err := ret.NewTable.GetIndexRowData(ctx, "c1c2")
+	require.NoError(t, err)
+	secondary := durable.ProllyMapFromIndex(idxRows)
+
+	rb, err := NewCoveringRowBuilder(sch, ret.NewIndex)
+	require.NoError(t, err)
+
+	// DELETE FROM t WHERE c1 = 10, driven by the covering index
+	kd, _ := secondary.Descriptors()
+	prefixKD := kd.PrefixDesc(1)
+	pb := val.NewTupleBuilder(prefixKD)
+	pb.PutInt64(0, 10)
+	itr, err := NewPrefixItr(ctx, pb.Build(primary.Pool()), prefixKD, secondary)
+	require.NoError(t, err)
+
+	pMut, sMut := primary.Mutate(), secondary.Mutate()
+	primaryKD, primaryVD := primary.Descriptors()
+	var deleted int
+	for {
+		idxKey, _, err := itr.Next(ctx)
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+
+		k, v := rb.Build(idxKey, primary.Pool())
+		err = primary.Get(ctx, k, func(key, value val.Tuple) error {
+			assert.Equal(t, 0, primaryKD.Compare(k, key))
+			assert.Equal(t, 0, primaryVD.Compare(v, value))
+			return nil
+		})
+		require.NoError(t, err)
+
+		require.NoError(t, pMut.Delete(ctx, k))
+		require.NoError(t, sMut.Delete(ctx, idxKey))
+		deleted++
+	}
+	require.Equal(t, 2, deleted)
+
+	primary, err = pMut.Map(ctx)
+	require.NoError(t, err)
+	secondary, err = sMut.Map(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"[2]", "[4]"}, collectKeys(t, ctx, primary))
+
+	rebuilt, err := BuildSecondaryProllyIndex(ctx, vrw, sch, ret.NewIndex, primary, BuildOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, collectKeys(t, ctx, durable.ProllyMapFromIndex(rebuilt)), collectKeys(t, ctx, secondary))
+}
+
+func TestCoveringRowBuilderRequiresAllColumns(t *testing.T) {
+	ctx := context.Background()
+	vrw := newTestVRW()
+	sch := newTestSchema(t)
+	tbl := newTestTable(t, ctx, vrw, sch, nil)
+
+	ret, err := CreateIndex(ctx, tbl, "c1", []string{"c1"}, false, true, "", BuildOptions{})
+	require.NoError(t, err)
+	_, err = NewCoveringRowBuilder(ret.Sch, ret.NewIndex)
+	require.Error(t, err)
+}
@@ -0,0 +1,156 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"context"
+	"encoding/binary"
+	"hash/fnv"
+	"io"
+
+	"github.com/dolthub/dolt/go/libraries/doltcore/doltdb/durable"
+	"github.com/dolthub/dolt/go/libraries/doltcore/schema"
+	"github.com/dolthub/dolt/go/store/prolly"
+	"github.com/dolthub/dolt/go/store/val"
+)
+
+// dedupHash hashes the encoded fields of a row. Each field is preceded by its
+// length, or by a marker if the field is NULL, so that different splits of the
+// same bytes hash differently.
+var dedupHash = func(fields [][]byte) uint64 {
+	h := fnv.New64a()
+	var buf [binary.MaxVarintLen64]byte
+	for _, f := range fields {
+		n := binary.PutVarint(buf[:], -1)
+		if f != nil {
+			n = binary.PutVarint(buf[:], int64(len(f)))
+		}
+		h.Write(buf[:n])
+		h.Write(f)
+	}
+	return h.Sum64()
+}
+
+// BuildDedupHashIndex builds an index of the rows of |primary| keyed by a hash
+// of their |columns| followed by their primary key. Each entry's value is the
+// primary key of its row. Rows with equal values in |columns| are adjacent in
+// the index, so DedupCandidates can find them with a single scan. Values are
+// compared as encoded, so values equal only under a collation are not
+// duplicates.
+//
+// Unlike a unique index, the index tolerates hash collisions: rows in the same
+// hash group are only candidate duplicates, and the caller must compare their
+// values to confirm them.
+func BuildDedupHashIndex(ctx context.Context, sch schema.Schema, primary prolly.Map, columns []string) (durable.Index, error) {
+	realColNames, err := resolveColumnNames(sch, columns)
+	if err != nil {
+		return nil, err
+	}
+	pkLen := sch.GetPKCols().Size()
+	fieldMap := make(val.OrdinalMapping, len(realColNames))
+	for i, name := range realColNames {
+		col, _ := sch.GetAllCols().GetByName(name)
+		if j, ok := sch.GetPKCols().TagToIdx[col.Tag]; ok {
+			fieldMap[i] = j
+		} else {
+			fieldMap[i] = pkLen + sch.GetNonPKCols().TagToIdx[col.Tag]
+		}
+	}
+
+	pkd, _ := primary.Descriptors()
+	kd := val.NewTupleDescriptor(append([]val.Type{{Enc: val.Uint64Enc}}, pkd.Types...)...)
+	empty, err := prolly.NewMapFromTuples(ctx, primary.NodeStore(), kd, pkd)
+	if err != nil {
+		return nil, err
+	}
+
+	iter, err := primary.IterAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	kb := val.NewTupleBuilder(kd)
+	fields := make([][]byte, len(fieldMap))
+	mut := empty.Mutate()
+	for {
+		k, v, err := iter.Next(ctx)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		for i := range fieldMap {
+			from := fieldMap.MapOrdinal(i)
+			if from < pkLen {
+				fields[i] = k.GetField(from)
+			} else {
+				fields[i] = v.GetField(from - pkLen)
+			}
+		}
+		kb.PutUint64(0, dedupHash(fields))
+		for i := 0; i < k.Count(); i++ {
+			kb.PutRaw(i+1, k.GetField(i))
+		}
+		if err = mut.Put(ctx, kb.Build(primary.Pool()), k); err != nil {
+			return nil, err
+		}
+	}
+
+	m, err := mut.Map(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return durable.IndexFromProllyMap(m), nil
+}
+
+// DedupCandidates scans the dedup hash index |idx| built by BuildDedupHashIndex
+// and passes the primary keys of each group of two or more rows with the same
+// hash to |cb|, in hash order.
+func DedupCandidates(ctx context.Context, idx durable.Index, cb func(ctx context.Context, pks []val.Tuple) error) error {
+	m := durable.ProllyMapFromIndex(idx)
+	kd, _ := m.Descriptors()
+	iter, err := m.IterAll(ctx)
+	if err != nil {
+		return err
+	}
+
+	var group []val.Tuple
+	var groupHash uint64
+	flush := func() error {
+		if len(group) < 2 {
+			return nil
+		}
+		return cb(ctx, group)
+	}
+	for {
+		k, pk, err := iter.Next(ctx)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		h, _ := kd.GetUint64(0, k)
+		if len(group) > 0 && h != groupHash {
+			if err = flush(); err != nil {
+				return err
+			}
+			group = nil
+		}
+		groupHash = h
+		group = append(group, pk)
+	}
+	return flush()
+}
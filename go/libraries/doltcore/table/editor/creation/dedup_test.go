@@ -0,0 +1,71 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"context"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/dolthub/dolt/go/store/val"
+)
+
+func TestDedupHashIndex(t *testing.T) {
+	ctx := context.Background()
+	vrw := newTestVRW()
+	sch := newTestSchema(t)
+	primary := newTestPrimary(t, ctx, vrw, sch, [][]interface{}{
+		{1, 10, "a"},
+		{2, 20, "a"},
+		{3, 10, "a"},
+		{4, 10, nil},
+		{5, 10, nil},
+		{6, 20, "b"},
+		{7, 10, "a"},
+	})
+	pkd, _ := primary.Descriptors()
+
+	candidates := func() [][]int64 {
+		idx, err := BuildDedupHashIndex(ctx, sch, primary, []string{"C1", "c2"})
+		require.NoError(t, err)
+		var groups [][]int64
+		err = DedupCandidates(ctx, idx, func(ctx context.Context, pks []val.Tuple) error {
+			var group []int64
+			for _, pk := range pks {
+				v, _ := pkd.GetInt64(0, pk)
+				group = append(group, v)
+			}
+			groups = append(groups, group)
+			return nil
+		})
+		require.NoError(t, err)
+		sort.Slice(groups, func(i, j int) bool { return groups[i][0] < groups[j][0] })
+		return groups
+	}
+
+	require.Equal(t, [][]int64{{1, 3, 7}, {4, 5}}, candidates())
+
+	// on a collision, rows with different values are reported together and must be verified by the caller
+	defer func(h func([][]byte) uint64) { dedupHash = h }(dedupHash)
+	dedupHash = func(fields [][]byte) uint64 {
+		return 0
+	}
+	require.Equal(t, [][]int64{{1, 2, 3, 4, 5, 6, 7}}, candidates())
+
+	_, err := BuildDedupHashIndex(ctx, sch, primary, []string{"missing"})
+	require.Error(t, err)
+}
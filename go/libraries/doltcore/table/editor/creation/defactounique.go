@@ -0,0 +1,159 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"context"
+	"io"
+
+	"github.com/dolthub/dolt/go/libraries/doltcore/doltdb"
+	"github.com/dolthub/dolt/go/libraries/doltcore/doltdb/durable"
+	"github.com/dolthub/dolt/go/libraries/doltcore/schema"
+	"github.com/dolthub/dolt/go/store/val"
+)
+
+// IndexIsDeFactoUnique returns whether no two entries of |rows|, the data of
+// the non-unique index |idx| of a table with schema |sch| built with |opts|,
+// have equal indexed values, i.e. whether |idx| could have been built as a
+// unique index. Like BuildUniqueProllyIndex, entries with a NULL indexed value
+// never conflict, and strings of PAD SPACE collations that differ only in
+// trailing spaces are equal. Unlike it, a duplicate is reported rather than
+// returned as an error. Entries with equal indexed values are adjacent in the
+// index, so each entry is only compared to the one before it.
+func IndexIsDeFactoUnique(ctx context.Context, sch schema.Schema, idx schema.Index, rows durable.Index, opts BuildOptions) (bool, error) {
+	m := durable.ProllyMapFromIndex(rows)
+	kd, _ := m.Descriptors()
+	encr, err := NewIndexKeyEncrypter(idx, kd, opts.IndexKeyEncryption)
+	if err != nil {
+		return false, err
+	}
+	pads := newPadSpaceKeys(sch, idx, kd, encr)
+
+	iter, err := m.IterAll(ctx)
+	if err != nil {
+		return false, err
+	}
+	n := idx.Count()
+	var prev val.Tuple
+	for {
+		k, _, err := iter.Next(ctx)
+		if err == io.EOF {
+			return true, nil
+		} else if err != nil {
+			return false, err
+		}
+		if hasNullPrefix(k, n) {
+			continue
+		}
+		if prev != nil && equalPrefixes(kd, prev, k, n) {
+			return false, nil
+		}
+		if _, found := pads.find(k); found {
+			return false, nil
+		}
+		pads.add(k)
+		prev = k
+	}
+}
+
+// equalPrefixes returns whether the first |n| fields of |left| and |right|
+// compare as equal.
+func equalPrefixes(kd val.TupleDesc, left, right val.Tuple, n int) bool {
+	for i := 0; i < n; i++ {
+		if kd.CompareField(left.GetField(i), i, right) != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// detectDeFactoUnique redefines the non-unique index |idx| of |tbl|, whose
+// schema is |sch|, with IsDeFactoUnique set to whether its data |rows| is de
+// facto unique, if that changes it.
+func detectDeFactoUnique(ctx context.Context, tbl *doltdb.Table, sch schema.Schema, idx schema.Index, rows durable.Index, opts BuildOptions) (*doltdb.Table, schema.Index, error) {
+	unique, err := IndexIsDeFactoUnique(ctx, sch, idx, rows, opts)
+	if err != nil || unique == idx.IsDeFactoUnique() {
+		return tbl, idx, err
+	}
+	newIdx, err := setDeFactoUnique(sch, idx, unique)
+	if err != nil {
+		return nil, nil, err
+	}
+	tbl, err = tbl.UpdateSchema(ctx, sch)
+	if err != nil {
+		return nil, nil, err
+	}
+	return tbl, newIdx, nil
+}
+
+// ClearChangedDeFactoUnique returns |tbl| with IsDeFactoUnique cleared on each
+// index of |sch|, its schema, whose data differs from its data in |prev|, an
+// earlier version of |tbl|. Writes may add duplicates to a de facto unique
+// index, so the hint only holds for the data it was detected on.
+func ClearChangedDeFactoUnique(ctx context.Context, sch schema.Schema, tbl, prev *doltdb.Table) (*doltdb.Table, error) {
+	var changed []string
+	for _, idx := range sch.Indexes().AllIndexes() {
+		if !idx.IsDeFactoUnique() {
+			continue
+		}
+		rows, err := tbl.GetIndexRowData(ctx, idx.Name())
+		if err != nil {
+			return nil, err
+		}
+		h, err := IndexContentHash(rows)
+		if err != nil {
+			return nil, err
+		}
+		if prevRows, err := prev.GetIndexRowData(ctx, idx.Name()); err == nil {
+			prevH, err := IndexContentHash(prevRows)
+			if err != nil {
+				return nil, err
+			}
+			if h == prevH {
+				continue
+			}
+		}
+		changed = append(changed, idx.Name())
+	}
+	if len(changed) == 0 {
+		return tbl, nil
+	}
+
+	// |sch| may be shared, so the flags are cleared on a copy read from |tbl|
+	sch, err := tbl.GetSchema(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range changed {
+		if _, err = setDeFactoUnique(sch, sch.Indexes().GetByName(name), false); err != nil {
+			return nil, err
+		}
+	}
+	return tbl.UpdateSchema(ctx, sch)
+}
+
+// setDeFactoUnique redefines the non-unique index |idx| of |sch| with
+// IsDeFactoUnique set to |unique|.
+func setDeFactoUnique(sch schema.Schema, idx schema.Index, unique bool) (schema.Index, error) {
+	if _, err := sch.Indexes().RemoveIndex(idx.Name()); err != nil {
+		return nil, err
+	}
+	return sch.Indexes().AddIndexByColTags(idx.Name(), idx.IndexedColumnTags(), schema.IndexProperties{
+		IsUserDefined:   idx.IsUserDefined(),
+		Comment:         idx.Comment(),
+		PkSuffixOrder:   idx.PkSuffixOrder(),
+		IsDeFactoUnique: unique,
+	})
+}
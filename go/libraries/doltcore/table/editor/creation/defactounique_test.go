@@ -0,0 +1,102 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dolthub/go-mysql-server/sql"
+	"github.com/stretchr/testify/require"
+
+	"github.com/dolthub/dolt/go/libraries/doltcore/doltdb/durable"
+	"github.com/dolthub/dolt/go/libraries/doltcore/schema"
+)
+
+func TestDeFactoUniqueIndex(t *testing.T) {
+	ctx := context.Background()
+	vrw := newTestVRW()
+	sch := newCollatedTestSchema(t, sql.Collation_utf8mb4_bin)
+	// c1 is unique in practice, as NULLs never conflict, and c2 only differs in trailing spaces
+	rows := [][]interface{}{
+		{1, 10, "a"},
+		{2, nil, "b"},
+		{3, 30, "a "},
+		{4, nil, "c"},
+		{5, 20, "d"},
+	}
+	opts := BuildOptions{ExecutionOptions: ExecutionOptions{DetectDeFactoUnique: true}}
+
+	res, err := CreateIndexWithProperties(ctx, newTestTable(t, ctx, vrw, sch, rows), "c1_idx", []uint64{c1Tag}, schema.IndexProperties{IsUserDefined: true}, opts)
+	require.NoError(t, err)
+	require.True(t, res.NewIndex.IsDeFactoUnique())
+	require.False(t, res.NewIndex.IsUnique())
+	// the hint is stored in the schema of the table
+	stored, err := res.NewTable.GetSchema(ctx)
+	require.NoError(t, err)
+	require.True(t, stored.Indexes().GetByName("c1_idx").IsDeFactoUnique())
+	rowData, err := res.NewTable.GetIndexRowData(ctx, "c1_idx")
+	require.NoError(t, err)
+	require.Equal(t, []string{"[10,1]", "[20,5]", "[30,3]", "[NULL,2]", "[NULL,4]"}, collectKeys(t, ctx, durable.ProllyMapFromIndex(rowData)))
+
+	// extending the index keeps the hint
+	ext, err := ExtendIndex(ctx, res.NewTable, res.NewIndex, []string{"c1", "pk"}, BuildOptions{})
+	require.NoError(t, err)
+	require.True(t, ext.NewIndex.IsDeFactoUnique())
+
+	// a PAD SPACE collation compares "a" and "a " as equal
+	res, err = CreateIndexWithProperties(ctx, res.NewTable, "c2_idx", []uint64{c2Tag}, schema.IndexProperties{IsUserDefined: true}, opts)
+	require.NoError(t, err)
+	require.False(t, res.NewIndex.IsDeFactoUnique())
+	res, err = CreateIndexWithProperties(ctx, res.NewTable, "c1c2_idx", []uint64{c1Tag, c2Tag}, schema.IndexProperties{IsUserDefined: true}, opts)
+	require.NoError(t, err)
+	require.True(t, res.NewIndex.IsDeFactoUnique())
+
+	// without the option, or for a unique index, nothing is detected
+	res, err = CreateIndexWithProperties(ctx, newTestTable(t, ctx, vrw, sch, rows), "c1_idx", []uint64{c1Tag}, schema.IndexProperties{IsUserDefined: true}, BuildOptions{})
+	require.NoError(t, err)
+	require.False(t, res.NewIndex.IsDeFactoUnique())
+	res, err = CreateIndexWithProperties(ctx, newTestTable(t, ctx, vrw, sch, rows), "c1_uniq", []uint64{c1Tag}, schema.IndexProperties{IsUnique: true, IsUserDefined: true}, opts)
+	require.NoError(t, err)
+	require.False(t, res.NewIndex.IsDeFactoUnique())
+
+	// a duplicate value is found wherever it is
+	dup := append(rows, []interface{}{6, 10, "e"})
+	res, err = CreateIndexWithProperties(ctx, newTestTable(t, ctx, vrw, sch, dup), "c1_idx", []uint64{c1Tag}, schema.IndexProperties{IsUserDefined: true}, opts)
+	require.NoError(t, err)
+	require.False(t, res.NewIndex.IsDeFactoUnique())
+	rowData, err = res.NewTable.GetIndexRowData(ctx, "c1_idx")
+	require.NoError(t, err)
+	unique, err := IndexIsDeFactoUnique(ctx, sch, res.NewIndex, rowData, BuildOptions{})
+	require.NoError(t, err)
+	require.False(t, unique)
+
+	// writing different index data clears the hint, as it may add duplicates
+	hinted, err := CreateIndexWithProperties(ctx, newTestTable(t, ctx, vrw, sch, rows), "c1_idx", []uint64{c1Tag}, schema.IndexProperties{IsUserDefined: true}, opts)
+	require.NoError(t, err)
+	hintedSch, err := hinted.NewTable.GetSchema(ctx)
+	require.NoError(t, err)
+	same, err := ClearChangedDeFactoUnique(ctx, hintedSch, hinted.NewTable, hinted.NewTable)
+	require.NoError(t, err)
+	require.Equal(t, hinted.NewTable, same)
+	written, err := hinted.NewTable.SetIndexRows(ctx, "c1_idx", rowData)
+	require.NoError(t, err)
+	written, err = ClearChangedDeFactoUnique(ctx, hintedSch, written, hinted.NewTable)
+	require.NoError(t, err)
+	stored, err = written.GetSchema(ctx)
+	require.NoError(t, err)
+	require.False(t, stored.Indexes().GetByName("c1_idx").IsDeFactoUnique())
+	require.True(t, hintedSch.Indexes().GetByName("c1_idx").IsDeFactoUnique())
+}
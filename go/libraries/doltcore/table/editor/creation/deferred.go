@@ -0,0 +1,73 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dolthub/dolt/go/libraries/doltcore/doltdb"
+	"github.com/dolthub/dolt/go/libraries/doltcore/schema"
+)
+
+// MaterializeDeferredIndex builds the data of the deferred index |indexName| of |tbl| and marks the index as
+// materialized. Readers call this before the first use of a deferred index, and persist the returned table. If the
+// index is not deferred, |tbl| is returned unchanged.
+//
+// Deferred indexes are not offered to the query planner, and the prolly table writers do not maintain their entries,
+// so the index is always rebuilt from the primary rows.
+func MaterializeDeferredIndex(ctx context.Context, tbl *doltdb.Table, indexName string, opts BuildOptions) (*doltdb.Table, error) {
+	sch, err := tbl.GetSchema(ctx)
+	if err != nil {
+		return nil, err
+	}
+	idx := sch.Indexes().GetByName(indexName)
+	if idx == nil {
+		return nil, fmt.Errorf("index `%s` does not exist", indexName)
+	}
+	if !idx.IsDeferred() {
+		return tbl, nil
+	}
+
+	if _, err = sch.Indexes().RemoveIndex(indexName); err != nil {
+		return nil, err
+	}
+	idx, err = sch.Indexes().AddIndexByColTags(indexName, idx.IndexedColumnTags(), schema.IndexProperties{
+		IsUnique:      idx.IsUnique(),
+		IsUserDefined: idx.IsUserDefined(),
+		Comment:       idx.Comment(),
+		PkSuffixOrder: idx.PkSuffixOrder(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	tbl, err = tbl.UpdateSchema(ctx, sch)
+	if err != nil {
+		return nil, err
+	}
+
+	opts = withHookStats(opts)
+	indexRows, err := BuildSecondaryIndex(ctx, tbl, idx, opts)
+	if err != nil {
+		return nil, err
+	}
+	if tbl, err = tbl.SetIndexRows(ctx, indexName, indexRows); err != nil {
+		return nil, err
+	}
+	if err = callBuildHook(ctx, indexName, indexRows, opts); err != nil {
+		return nil, err
+	}
+	return tbl, nil
+}
@@ -0,0 +1,72 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/dolthub/dolt/go/libraries/doltcore/doltdb/durable"
+	"github.com/dolthub/dolt/go/libraries/doltcore/schema"
+)
+
+func TestMaterializeDeferredIndex(t *testing.T) {
+	ctx := context.Background()
+	vrw := newTestVRW()
+	sch := newTestSchema(t)
+	tbl := newTestTable(t, ctx, vrw, sch, [][]interface{}{
+		{1, 20, "a"},
+		{2, 10, "b"},
+	})
+
+	ret, err := CreateIndexWithProperties(ctx, tbl, "c1_idx", []uint64{c1Tag}, schema.IndexProperties{
+		IsUserDefined: true,
+		IsDeferred:    true,
+	}, BuildOptions{ExecutionOptions: ExecutionOptions{VerifyIndexRowCount: true}})
+	require.NoError(t, err)
+	tbl = ret.NewTable
+
+	sch, err = tbl.GetSchema(ctx)
+	require.NoError(t, err)
+	assert.True(t, sch.Indexes().GetByName("c1_idx").IsDeferred())
+	idx, err := tbl.GetIndexRowData(ctx, "c1_idx")
+	require.NoError(t, err)
+	assert.Equal(t, uint64(0), idx.Count())
+
+	tbl, err = MaterializeDeferredIndex(ctx, tbl, "c1_idx", BuildOptions{})
+	require.NoError(t, err)
+	sch, err = tbl.GetSchema(ctx)
+	require.NoError(t, err)
+	assert.False(t, sch.Indexes().GetByName("c1_idx").IsDeferred())
+	idx, err = tbl.GetIndexRowData(ctx, "c1_idx")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"[10,2]", "[20,1]"}, collectKeys(t, ctx, durable.ProllyMapFromIndex(idx)))
+
+	// materialized indexes are left as they are
+	again, err := MaterializeDeferredIndex(ctx, tbl, "c1_idx", BuildOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, tbl, again)
+
+	_, err = MaterializeDeferredIndex(ctx, tbl, "missing", BuildOptions{})
+	require.Error(t, err)
+	_, err = CreateIndexWithProperties(ctx, tbl, "c2_idx", []uint64{c2Tag}, schema.IndexProperties{
+		IsUnique:   true,
+		IsDeferred: true,
+	}, BuildOptions{})
+	require.Error(t, err)
+}
@@ -0,0 +1,207 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/dolthub/dolt/go/libraries/doltcore/doltdb/durable"
+	"github.com/dolthub/dolt/go/libraries/doltcore/schema"
+	"github.com/dolthub/dolt/go/store/val"
+)
+
+// TestIndexBuildDeterminism checks that every way of building an index produces the same tree, chunk boundaries
+// included, so equal index data is stored once by the content-addressed chunk store.
+func TestIndexBuildDeterminism(t *testing.T) {
+	ctx := context.Background()
+	vrw := newTestVRW()
+	sch := newTestSchema(t)
+
+	// enough rows to span many chunks
+	var rows [][]interface{}
+	for i := 0; i < 5000; i++ {
+		rows = append(rows, []interface{}{i, (i * 7919) % 500, fmt.Sprintf("value-%06d", i)})
+	}
+	primary := newTestPrimary(t, ctx, vrw, sch, rows)
+	var kvs [][2]val.Tuple
+	iter, err := primary.IterAll(ctx)
+	require.NoError(t, err)
+	for {
+		k, v, err := iter.Next(ctx)
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		kvs = append(kvs, [2]val.Tuple{k, v})
+	}
+
+	coll := schema.NewIndexCollection(sch.GetAllCols(), sch.GetPKCols())
+	idx, err := coll.AddIndexByColNames("c1_c2_idx", []string{"c1", "c2"}, schema.IndexProperties{})
+	require.NoError(t, err)
+	prefixIdx, err := coll.AddIndexByColNames("c1_idx", []string{"c1"}, schema.IndexProperties{})
+	require.NoError(t, err)
+
+	var entries bytes.Buffer
+	expected, err := BuildSecondaryProllyIndex(ctx, vrw, sch, idx, primary, BuildOptions{ExecutionOptions: ExecutionOptions{IndexEntryWriter: &entries}})
+	require.NoError(t, err)
+	require.Greater(t, durable.ProllyMapFromIndex(expected).Node().Level(), 0)
+
+	strategies := map[string]func(t *testing.T) durable.Index{
+		"reverse": func(t *testing.T) durable.Index {
+			iter, err := primary.IterAllReverse(ctx)
+			require.NoError(t, err)
+			rows, err := BuildSecondaryProllyIndexFromIter(ctx, vrw, sch, idx, iter, BuildOptions{})
+			require.NoError(t, err)
+			return rows
+		},
+		"shuffled": func(t *testing.T) durable.Index {
+			shuffled := append([][2]val.Tuple(nil), kvs...)
+			rand.New(rand.NewSource(1)).Shuffle(len(shuffled), func(i, j int) {
+				shuffled[i], shuffled[j] = shuffled[j], shuffled[i]
+			})
+			rows, err := BuildSecondaryProllyIndexFromIter(ctx, vrw, sch, idx, &sliceMapIter{kvs: shuffled}, BuildOptions{})
+			require.NoError(t, err)
+			return rows
+		},
+		"checkpointed": func(t *testing.T) durable.Index {
+			rows, err := BuildSecondaryProllyIndex(ctx, vrw, sch, idx, primary, BuildOptions{
+				ExecutionOptions: ExecutionOptions{
+					IndexBuildCheckpoints:    NewFileCheckpointStore(t.TempDir()),
+					IndexBuildCheckpointRows: 997,
+				},
+			})
+			require.NoError(t, err)
+			return rows
+		},
+		"tolerant": func(t *testing.T) durable.Index {
+			rows, _, err := BuildSecondaryProllyIndexTolerant(ctx, vrw, sch, idx, primary, BuildOptions{})
+			require.NoError(t, err)
+			return rows
+		},
+		"prefix sharing": func(t *testing.T) durable.Index {
+			built, err := BuildPrefixSharingIndexes(ctx, vrw, sch, []schema.Index{prefixIdx, idx}, primary, BuildOptions{})
+			require.NoError(t, err)
+			return built[1]
+		},
+		"bulk load": func(t *testing.T) durable.Index {
+			rows, err := LoadIndexEntries(ctx, vrw, idx, bytes.NewReader(entries.Bytes()))
+			require.NoError(t, err)
+			return rows
+		},
+		"concurrent maintainer": func(t *testing.T) durable.Index {
+			empty, err := durable.NewEmptyIndex(ctx, vrw, idx.Schema())
+			require.NoError(t, err)
+			im, err := NewIndexMaintainer(empty, 64)
+			require.NoError(t, err)
+
+			var keys []val.Tuple
+			iter, err := durable.ProllyMapFromIndex(expected).IterAll(ctx)
+			require.NoError(t, err)
+			for {
+				k, _, err := iter.Next(ctx)
+				if err == io.EOF {
+					break
+				}
+				require.NoError(t, err)
+				keys = append(keys, k)
+			}
+			var wg sync.WaitGroup
+			for w := 0; w < 4; w++ {
+				wg.Add(1)
+				go func(w int) {
+					defer wg.Done()
+					for i := w; i < len(keys); i += 4 {
+						assert.NoError(t, im.Insert(ctx, keys[i]))
+					}
+				}(w)
+			}
+			wg.Wait()
+			rows, err := im.Index(ctx)
+			require.NoError(t, err)
+			return rows
+		},
+	}
+	for name, build := range strategies {
+		t.Run(name, func(t *testing.T) {
+			requireSameIndex(t, expected, build(t))
+		})
+	}
+
+	// the strategies of unique indexes agree as well
+	uniqueIdx, err := coll.AddIndexByColNames("c2_idx", []string{"c2"}, schema.IndexProperties{IsUnique: true})
+	require.NoError(t, err)
+	expected, err = BuildSecondaryProllyIndex(ctx, vrw, sch, uniqueIdx, primary, BuildOptions{})
+	require.NoError(t, err)
+	batched, err := BuildUniqueProllyIndexBatched(ctx, vrw, sch, uniqueIdx, primary, BuildOptions{}, 16, func(ctx context.Context, dups []DupEntry) error {
+		return nil
+	})
+	require.NoError(t, err)
+	requireSameIndex(t, expected, batched)
+	iter, err = primary.IterAllReverse(ctx)
+	require.NoError(t, err)
+	reversed, err := BuildUniqueProllyIndexFromIter(ctx, vrw, sch, uniqueIdx, iter, BuildOptions{}, func(ctx context.Context, existingKey, newKey val.Tuple) error {
+		return nil
+	})
+	require.NoError(t, err)
+	requireSameIndex(t, expected, reversed)
+}
+
+func TestIndexContentHash(t *testing.T) {
+	ctx := context.Background()
+	sch := newTestSchema(t)
+	var rows [][]interface{}
+	for i := 0; i < 2000; i++ {
+		rows = append(rows, []interface{}{i, i % 37, fmt.Sprintf("value-%06d", i)})
+	}
+	shuffled := append([][]interface{}(nil), rows...)
+	rand.New(rand.NewSource(7)).Shuffle(len(shuffled), func(i, j int) {
+		shuffled[i], shuffled[j] = shuffled[j], shuffled[i]
+	})
+
+	// equal index data has equal hashes across builds and stores
+	a, err := CreateIndex(ctx, newTestTable(t, ctx, newTestVRW(), sch, rows), "c1_c2", []string{"c1", "c2"}, false, true, "", BuildOptions{})
+	require.NoError(t, err)
+	b, err := CreateIndex(ctx, newTestTable(t, ctx, newTestVRW(), sch, shuffled), "c1_c2", []string{"c1", "c2"}, false, true, "", BuildOptions{})
+	require.NoError(t, err)
+	assert.False(t, a.ContentHash.IsEmpty())
+	assert.Equal(t, a.ContentHash, b.ContentHash)
+	stored, err := a.NewTable.GetIndexRowData(ctx, "c1_c2")
+	require.NoError(t, err)
+	h, err := IndexContentHash(stored)
+	require.NoError(t, err)
+	assert.Equal(t, a.ContentHash, h)
+
+	// an index spliced from a narrower one hashes like one built from scratch
+	c1, err := CreateIndex(ctx, newTestTable(t, ctx, newTestVRW(), sch, rows), "c1_c2", []string{"c1"}, false, true, "", BuildOptions{})
+	require.NoError(t, err)
+	ext, err := ExtendIndex(ctx, c1.NewTable, c1.NewIndex, []string{"c1", "c2"}, BuildOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, a.ContentHash, ext.ContentHash)
+
+	// changed index data has a different hash
+	rows[1000] = []interface{}{1000, 1, "changed"}
+	c, err := CreateIndex(ctx, newTestTable(t, ctx, newTestVRW(), sch, rows), "c1_c2", []string{"c1", "c2"}, false, true, "", BuildOptions{})
+	require.NoError(t, err)
+	assert.NotEqual(t, a.ContentHash, c.ContentHash)
+}
@@ -0,0 +1,40 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"fmt"
+
+	"github.com/dolthub/dolt/go/libraries/doltcore/schema"
+	"github.com/dolthub/dolt/go/store/val"
+)
+
+// validateDistinct returns an error if |idx| cannot be built as a distinct
+// index. The entries of a distinct index stand for any number of rows, so
+// they cannot be unique; BuildOptions.Validate checks the options they cannot
+// be combined with.
+func validateDistinct(idx schema.Index) error {
+	if idx.IsUnique() {
+		return fmt.Errorf("index `%s`: unique indexes cannot be distinct indexes", idx.Name())
+	}
+	return nil
+}
+
+// distinctKeyDesc returns the key descriptor of a distinct index |idx| with
+// the key descriptor |kd|, whose keys are the values of the indexed columns,
+// without the primary key suffix.
+func distinctKeyDesc(idx schema.Index, kd val.TupleDesc) val.TupleDesc {
+	return prefixDesc(kd, idx.Count())
+}
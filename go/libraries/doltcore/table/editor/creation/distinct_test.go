@@ -0,0 +1,99 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/dolthub/dolt/go/libraries/doltcore/doltdb/durable"
+	"github.com/dolthub/dolt/go/libraries/doltcore/schema"
+)
+
+func TestDistinctIndex(t *testing.T) {
+	ctx := context.Background()
+	vrw := newTestVRW()
+	sch := newTestSchema(t)
+	var rows [][]interface{}
+	for i := 0; i < 3000; i++ {
+		var c1 interface{} = (i * 7919) % 17
+		if i%10 == 0 {
+			c1 = nil
+		}
+		rows = append(rows, []interface{}{i, c1, []string{"a", "b", "c"}[i%3]})
+	}
+	primary := newTestPrimary(t, ctx, vrw, sch, rows)
+	coll := schema.NewIndexCollection(sch.GetAllCols(), sch.GetPKCols())
+	c1Idx, err := coll.AddIndexByColNames("c1_idx", []string{"c1"}, schema.IndexProperties{})
+	require.NoError(t, err)
+	opts := BuildOptions{KeyEncodingOptions: KeyEncodingOptions{DistinctIndex: true}}
+
+	// SELECT DISTINCT c1 FROM t ORDER BY c1
+	distinct, err := BuildSecondaryProllyIndex(ctx, vrw, sch, c1Idx, primary, opts)
+	require.NoError(t, err)
+	m := durable.ProllyMapFromIndex(distinct)
+	kd, _ := m.Descriptors()
+	require.Equal(t, 1, kd.Count())
+	iter, err := m.IterAll(ctx)
+	require.NoError(t, err)
+	var values []interface{}
+	for {
+		k, _, err := iter.Next(ctx)
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		if c1, ok := kd.GetInt64(0, k); ok {
+			values = append(values, int(c1))
+		} else {
+			values = append(values, nil)
+		}
+	}
+	require.Equal(t, []interface{}{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, nil}, values)
+
+	// the distinct values of several columns are the groups of a group count index
+	c1c2Idx, err := coll.AddIndexByColNames("c1_c2_idx", []string{"c1", "c2"}, schema.IndexProperties{})
+	require.NoError(t, err)
+	distinct, err = BuildSecondaryProllyIndex(ctx, vrw, sch, c1c2Idx, primary, opts)
+	require.NoError(t, err)
+	counts, err := BuildGroupCountIndex(ctx, vrw, sch, c1c2Idx, primary, 100)
+	require.NoError(t, err)
+	require.Equal(t, collectKeys(t, ctx, durable.ProllyMapFromIndex(counts)), collectKeys(t, ctx, durable.ProllyMapFromIndex(distinct)))
+	tolerant, report, err := BuildSecondaryProllyIndexTolerant(ctx, vrw, sch, c1c2Idx, primary, opts)
+	require.NoError(t, err)
+	require.Zero(t, report.RowsSkipped())
+	requireSameIndex(t, distinct, tolerant)
+
+	// entries appended in scan order stay distinct
+	auto := newAutoIncrementTestSchema(t, true)
+	pkIdx, err := schema.NewIndexCollection(auto.GetAllCols(), auto.GetPKCols()).AddIndexByColNames(
+		"pk_c1", []string{"pk", "c1"}, schema.IndexProperties{})
+	require.NoError(t, err)
+	appended, err := BuildSecondaryProllyIndex(ctx, vrw, auto, pkIdx, newTestPrimary(t, ctx, vrw, auto, rows), opts)
+	require.NoError(t, err)
+	require.Equal(t, uint64(len(rows)), appended.Count())
+
+	uniq, err := coll.AddIndexByColNames("c1_uniq", []string{"c1"}, schema.IndexProperties{IsUnique: true})
+	require.NoError(t, err)
+	_, err = BuildSecondaryProllyIndex(ctx, vrw, sch, uniq, primary, opts)
+	require.Error(t, err)
+	_, err = BuildSecondaryProllyIndex(ctx, vrw, sch, c1Idx, primary, BuildOptions{KeyEncodingOptions: KeyEncodingOptions{DistinctIndex: true}, ValueLayoutOptions: ValueLayoutOptions{MirrorPrimaryRowInIndex: true}})
+	require.Error(t, err)
+	_, err = CreateIndex(ctx, newTestTable(t, ctx, vrw, sch, rows), "c1_distinct", []string{"c1"}, false, true, "", opts)
+	require.Error(t, err)
+}
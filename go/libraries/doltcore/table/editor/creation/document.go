@@ -0,0 +1,77 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"context"
+
+	"github.com/dolthub/dolt/go/libraries/doltcore/schema"
+	"github.com/dolthub/dolt/go/libraries/doltcore/sqle/index"
+	"github.com/dolthub/dolt/go/store/prolly/shim"
+	"github.com/dolthub/dolt/go/store/prolly/tree"
+	"github.com/dolthub/dolt/go/store/val"
+)
+
+// documentFeeder passes the rows indexed by a build to the build's
+// IndexDocumentSink. A nil *documentFeeder feeds nothing.
+type documentFeeder struct {
+	sink IndexDocumentSink
+	// keyMap maps the indexed columns to the fields of the primary row
+	keyMap val.OrdinalMapping
+	pkLen  int
+	pkd    val.TupleDesc
+	pvd    val.TupleDesc
+	ns     tree.NodeStore
+}
+
+// newDocumentFeeder returns the documentFeeder of a build of |idx| whose index
+// data is stored in |ns|, or nil if |opts| has no IndexDocumentSink.
+func newDocumentFeeder(sch schema.Schema, idx schema.Index, ns tree.NodeStore, opts BuildOptions) (*documentFeeder, error) {
+	if opts.IndexDocumentSink == nil {
+		return nil, nil
+	}
+	keyMap, err := GetIndexKeyMapping(sch, idx)
+	if err != nil {
+		return nil, err
+	}
+	pkd, pvd := shim.MapDescriptorsFromSchema(sch)
+	return &documentFeeder{
+		sink:   opts.IndexDocumentSink,
+		keyMap: keyMap[:idx.Count()],
+		pkLen:  sch.GetPKCols().Size(),
+		pkd:    pkd,
+		pvd:    pvd,
+		ns:     ns,
+	}, nil
+}
+
+// feed passes the primary row |k|, |v| to the sink.
+func (f *documentFeeder) feed(ctx context.Context, k, v val.Tuple) (err error) {
+	if f == nil {
+		return nil
+	}
+	values := make([]interface{}, len(f.keyMap))
+	for i, from := range f.keyMap {
+		if from < f.pkLen {
+			values[i], err = index.GetField(ctx, f.pkd, from, k, f.ns)
+		} else {
+			values[i], err = index.GetField(ctx, f.pvd, from-f.pkLen, v, f.ns)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return f.sink.Index(ctx, k, values)
+}
@@ -0,0 +1,108 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/dolthub/dolt/go/libraries/doltcore/schema"
+	"github.com/dolthub/dolt/go/store/prolly/shim"
+	"github.com/dolthub/dolt/go/store/val"
+)
+
+// recordingSink is an IndexDocumentSink that records the documents it
+// receives, keyed by their integer primary key.
+type recordingSink struct {
+	pkd  val.TupleDesc
+	docs map[int64][]interface{}
+	err  error
+}
+
+func (s *recordingSink) Index(_ context.Context, docID val.Tuple, values []interface{}) error {
+	pk, _ := s.pkd.GetInt64(0, docID)
+	s.docs[pk] = values
+	return s.err
+}
+
+func TestIndexDocumentSink(t *testing.T) {
+	ctx := context.Background()
+	vrw := newTestVRW()
+	sch := newTestSchema(t)
+	var rows [][]interface{}
+	for i := 0; i < 1000; i++ {
+		rows = append(rows, []interface{}{i, 1000 - i, fmt.Sprintf("document %d", i)})
+	}
+	rows = append(rows, []interface{}{1000, 0, nil})
+	primary := newTestPrimary(t, ctx, vrw, sch, rows)
+	coll := schema.NewIndexCollection(sch.GetAllCols(), sch.GetPKCols())
+	idx, err := coll.AddIndexByColNames("c2_c1", []string{"c2", "c1"}, schema.IndexProperties{})
+	require.NoError(t, err)
+	uniq, err := coll.AddIndexByColNames("c2_c1_uniq", []string{"c2", "c1"}, schema.IndexProperties{IsUnique: true})
+	require.NoError(t, err)
+	newSink := func() *recordingSink {
+		return &recordingSink{pkd: shim.KeyDescriptorFromSchema(sch), docs: make(map[int64][]interface{})}
+	}
+
+	requireDocs := func(sink *recordingSink) {
+		require.Len(t, sink.docs, len(rows))
+		for i := 0; i < 1000; i++ {
+			require.Equal(t, []interface{}{fmt.Sprintf("document %d", i), int64(1000 - i)}, sink.docs[int64(i)])
+		}
+		require.Equal(t, []interface{}{nil, int64(0)}, sink.docs[1000])
+	}
+
+	// documents are fed from the scan that builds the index
+	sink := newSink()
+	rowData, err := BuildSecondaryProllyIndex(ctx, vrw, sch, idx, primary, BuildOptions{ExecutionOptions: ExecutionOptions{IndexDocumentSink: sink}})
+	require.NoError(t, err)
+	require.Equal(t, uint64(len(rows)), rowData.Count())
+	requireDocs(sink)
+
+	sink = newSink()
+	_, err = BuildSecondaryProllyIndex(ctx, vrw, sch, uniq, primary, BuildOptions{ExecutionOptions: ExecutionOptions{IndexDocumentSink: sink}})
+	require.NoError(t, err)
+	requireDocs(sink)
+
+	sink = newSink()
+	_, _, err = BuildSecondaryProllyIndexTolerant(ctx, vrw, sch, idx, primary, BuildOptions{ExecutionOptions: ExecutionOptions{IndexDocumentSink: sink}})
+	require.NoError(t, err)
+	requireDocs(sink)
+
+	// rows left out of a partial index are not fed
+	sink = newSink()
+	_, err = BuildSecondaryProllyIndex(ctx, vrw, sch, idx, primary, BuildOptions{
+		ExecutionOptions: ExecutionOptions{
+			IndexDocumentSink: sink,
+			IndexRowFilter: func(ctx context.Context, k, v val.Tuple) (bool, error) {
+				pk, _ := sink.pkd.GetInt64(0, k)
+				return pk%2 == 0, nil
+			},
+		},
+	})
+	require.NoError(t, err)
+	require.Len(t, sink.docs, 501)
+
+	// errors of the sink stop the build
+	sink = newSink()
+	sink.err = errors.New("sink is full")
+	_, err = BuildSecondaryProllyIndex(ctx, vrw, sch, idx, primary, BuildOptions{ExecutionOptions: ExecutionOptions{IndexDocumentSink: sink}})
+	require.ErrorIs(t, err, sink.err)
+	require.Len(t, sink.docs, 1)
+}
@@ -0,0 +1,120 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"fmt"
+	"strings"
+
+	"github.com/dolthub/dolt/go/libraries/doltcore/schema"
+	"github.com/dolthub/dolt/go/store/val"
+)
+
+// minIndexKeyMaterial is the minimum length of IndexKeyEncryption.Key.
+const minIndexKeyMaterial = 16
+
+// IndexKeyEncrypter deterministically encrypts the fields of index keys, as configured by IndexKeyEncryption.
+// Each value is encrypted with AES-GCM using a nonce derived from an HMAC of the value, so equal values always
+// produce the same ciphertext.
+type IndexKeyEncrypter struct {
+	// encrypted holds whether each index key field is encrypted
+	encrypted []bool
+	aead      cipher.AEAD
+	macKey    []byte
+}
+
+// NewIndexKeyEncrypter returns an IndexKeyEncrypter for the keys of |idx|, which are encoded by |kd|. It returns nil
+// if |enc| is nil, and an error if a configured column is not a string or binary column of |idx|, or is part of the
+// primary key.
+func NewIndexKeyEncrypter(idx schema.Index, kd val.TupleDesc, enc *IndexKeyEncryption) (*IndexKeyEncrypter, error) {
+	if enc == nil {
+		return nil, nil
+	}
+	if len(enc.Key) < minIndexKeyMaterial {
+		return nil, fmt.Errorf("index `%s`: encryption key must be at least %d bytes", idx.Name(), minIndexKeyMaterial)
+	}
+
+	encrypted := make([]bool, kd.Count())
+	names := idx.ColumnNames()
+	for _, col := range enc.Columns {
+		i := -1
+		for j, name := range names {
+			if strings.EqualFold(name, col) {
+				i = j
+				break
+			}
+		}
+		if i < 0 {
+			return nil, fmt.Errorf("index `%s` does not index column `%s`", idx.Name(), col)
+		}
+		for _, pk := range idx.PrimaryKeyTags() {
+			// the primary key suffix is used to find the indexed row
+			if pk == idx.IndexedColumnTags()[i] {
+				return nil, fmt.Errorf("index `%s`: primary key column `%s` cannot be encrypted", idx.Name(), col)
+			}
+		}
+		if e := kd.Types[i].Enc; e != val.StringEnc && e != val.ByteStringEnc {
+			return nil, fmt.Errorf("index `%s`: column `%s` cannot be encrypted, only string and binary columns are supported", idx.Name(), col)
+		}
+		encrypted[i] = true
+	}
+
+	block, err := aes.NewCipher(deriveKey(enc.Key, "dolt index encryption"))
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &IndexKeyEncrypter{
+		encrypted: encrypted,
+		aead:      aead,
+		macKey:    deriveKey(enc.Key, "dolt index nonce"),
+	}, nil
+}
+
+// EncryptField returns the encrypted form of the encoded field |f| at position |i| of an index key. Fields that are
+// not encrypted, and NULL fields, are returned unchanged. Lookups use this to encrypt the fields of their keys.
+func (e *IndexKeyEncrypter) EncryptField(i int, f []byte) []byte {
+	if e == nil || f == nil || i >= len(e.encrypted) || !e.encrypted[i] {
+		return f
+	}
+	// string and binary fields are null terminated
+	plain := f[:len(f)-1]
+	mac := hmac.New(sha256.New, e.macKey)
+	mac.Write(plain)
+	nonce := mac.Sum(nil)[:e.aead.NonceSize()]
+
+	out := make([]byte, 0, len(nonce)+len(plain)+e.aead.Overhead()+1)
+	out = append(out, nonce...)
+	out = e.aead.Seal(out, nonce, plain, nil)
+	return append(out, 0)
+}
+
+func deriveKey(material []byte, purpose string) []byte {
+	mac := hmac.New(sha256.New, material)
+	mac.Write([]byte(purpose))
+	return mac.Sum(nil)
+}
+
+// encryptsField returns whether the field at position |i| of an index key is encrypted.
+func (e *IndexKeyEncrypter) encryptsField(i int) bool {
+	return e != nil && i < len(e.encrypted) && e.encrypted[i]
+}
@@ -0,0 +1,129 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/dolthub/dolt/go/libraries/doltcore/doltdb/durable"
+	"github.com/dolthub/dolt/go/libraries/doltcore/schema"
+	"github.com/dolthub/dolt/go/store/val"
+)
+
+func TestEncryptedIndexLookup(t *testing.T) {
+	ctx := context.Background()
+	vrw := newTestVRW()
+	sch := newTestSchema(t)
+	tbl := newTestTable(t, ctx, vrw, sch, [][]interface{}{
+		{1, 10, "alice@example.com"},
+		{2, 20, "bob@example.com"},
+		{3, 30, "alice@example.com"},
+		{4, 40, nil},
+	})
+
+	enc := &IndexKeyEncryption{Key: []byte("0123456789abcdef"), Columns: []string{"C2"}}
+	opts := BuildOptions{KeyEncodingOptions: KeyEncodingOptions{IndexKeyEncryption: enc}}
+	rowData, err := tbl.GetRowData(ctx)
+	require.NoError(t, err)
+	primary := durable.ProllyMapFromIndex(rowData)
+	c2Idx, err := sch.Indexes().AddIndexByColNames("c2_idx", []string{"c2"}, schema.IndexProperties{IsUserDefined: true})
+	require.NoError(t, err)
+	idx, err := BuildSecondaryProllyIndex(ctx, vrw, sch, c2Idx, primary, opts)
+	require.NoError(t, err)
+	secondary := durable.ProllyMapFromIndex(idx)
+
+	kd, _ := secondary.Descriptors()
+	encr, err := NewIndexKeyEncrypter(c2Idx, kd, enc)
+	require.NoError(t, err)
+	prefixKD := kd.PrefixDesc(1)
+
+	// lookup builds an encrypted prefix for |email| and returns the primary keys of the matching entries
+	lookup := func(email string) []int64 {
+		pb := val.NewTupleBuilder(prefixKD)
+		pb.PutString(0, email)
+		plain := pb.Build(sharePool)
+		pb.PutRaw(0, encr.EncryptField(0, plain.GetField(0)))
+		itr, err := NewPrefixItr(ctx, pb.Build(sharePool), prefixKD, secondary)
+		require.NoError(t, err)
+
+		var pks []int64
+		for {
+			k, _, err := itr.Next(ctx)
+			if err == io.EOF {
+				return pks
+			}
+			require.NoError(t, err)
+			pk, _ := kd.GetInt64(1, k)
+			pks = append(pks, pk)
+		}
+	}
+	assert.Equal(t, []int64{1, 3}, lookup("alice@example.com"))
+	assert.Equal(t, []int64{2}, lookup("bob@example.com"))
+	assert.Empty(t, lookup("carol@example.com"))
+
+	// values are not stored in plaintext
+	iter, err := secondary.IterAll(ctx)
+	require.NoError(t, err)
+	for {
+		k, _, err := iter.Next(ctx)
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		if s, ok := kd.GetString(0, k); ok {
+			assert.NotContains(t, s, "example.com")
+		}
+	}
+
+	res, err := ValidateImportedIndex(ctx, tbl, c2Idx, idx, opts)
+	require.NoError(t, err)
+	assert.True(t, res.Consistent())
+	res, err = ValidateImportedIndex(ctx, tbl, c2Idx, idx, BuildOptions{KeyEncodingOptions: KeyEncodingOptions{IndexKeyEncryption: enc}, ExecutionOptions: ExecutionOptions{VerifySampleSize: 2}})
+	require.NoError(t, err)
+	assert.True(t, res.Consistent())
+
+	// unique indexes detect duplicate ciphertexts
+	uniq, err := sch.Indexes().AddIndexByColNames("c2_uniq", []string{"c2"}, schema.IndexProperties{IsUnique: true, IsUserDefined: true})
+	require.NoError(t, err)
+	_, err = BuildSecondaryProllyIndex(ctx, vrw, sch, uniq, primary, opts)
+	require.Error(t, err)
+
+	bad, err := sch.Indexes().AddIndexByColNames("bad", []string{"pk", "c1", "c2"}, schema.IndexProperties{IsUserDefined: true})
+	require.NoError(t, err)
+	for _, cols := range [][]string{{"c1"}, {"pk"}, {"missing"}} {
+		_, err = BuildSecondaryProllyIndex(ctx, vrw, sch, bad, primary, BuildOptions{
+			KeyEncodingOptions: KeyEncodingOptions{
+				IndexKeyEncryption: &IndexKeyEncryption{Key: enc.Key, Columns: cols},
+			},
+		})
+		assert.Error(t, err, "%v", cols)
+	}
+	_, err = BuildSecondaryProllyIndex(ctx, vrw, sch, c2Idx, primary, BuildOptions{
+		KeyEncodingOptions: KeyEncodingOptions{
+			IndexKeyEncryption: &IndexKeyEncryption{Key: []byte("short"), Columns: enc.Columns},
+		},
+	})
+	assert.Error(t, err)
+
+	// the encryption is not stored with the index, so DML would write and
+	// look up plaintext keys
+	_, err = CreateIndex(ctx, tbl, "c2_idx", []string{"c2"}, false, true, "", opts)
+	require.Error(t, err)
+}
@@ -0,0 +1,103 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/dolthub/go-mysql-server/sql"
+
+	"github.com/dolthub/dolt/go/libraries/doltcore/schema"
+	"github.com/dolthub/dolt/go/store/val"
+)
+
+// enumLabeler replaces the ordinals of the ENUM and SET fields of index keys
+// with their labels, for builds with BuildOptions.IndexEnumsByLabel. A nil
+// *enumLabeler leaves keys unchanged.
+type enumLabeler struct {
+	// enums holds, for each ENUM field of the key, its labels encoded as
+	// strings and indexed by ordinal
+	enums map[int][][]byte
+	sets  map[int]sql.SetType
+}
+
+// newEnumLabeler returns the enumLabeler of the keys of |idx|, or nil if
+// |opts| does not order enums by label or |idx| keys no ENUM or SET columns.
+func newEnumLabeler(sch schema.Schema, idx schema.Index, opts BuildOptions) *enumLabeler {
+	if !opts.IndexEnumsByLabel {
+		return nil
+	}
+	l := &enumLabeler{enums: make(map[int][][]byte), sets: make(map[int]sql.SetType)}
+	for to, tag := range idx.AllTags() {
+		col, _ := sch.GetAllCols().GetByTag(tag)
+		switch t := col.TypeInfo.ToSqlType().(type) {
+		case sql.EnumType:
+			// ordinal 0 is the empty string MySQL stores for invalid values
+			labels := [][]byte{encodeString("")}
+			for _, label := range t.Values() {
+				labels = append(labels, encodeString(label))
+			}
+			l.enums[to] = labels
+		case sql.SetType:
+			l.sets[to] = t
+		}
+	}
+	if len(l.enums) == 0 && len(l.sets) == 0 {
+		return nil
+	}
+	return l
+}
+
+// encodeString returns |s| encoded as a val.StringEnc field.
+func encodeString(s string) []byte {
+	return append([]byte(s), 0)
+}
+
+// enumLabelKeyDesc returns |kd|, the key descriptor of an index, with its
+// ENUM and SET fields replaced by string fields holding their labels.
+func enumLabelKeyDesc(kd val.TupleDesc) val.TupleDesc {
+	types := make([]val.Type, len(kd.Types))
+	for i, typ := range kd.Types {
+		if typ.Enc == val.EnumEnc || typ.Enc == val.SetEnc {
+			typ.Enc = val.StringEnc
+		}
+		types[i] = typ
+	}
+	return val.NewTupleDescriptor(types...)
+}
+
+// LabelField returns the label of |f| if the key field |to| is an ENUM or SET
+// field, or |f| otherwise.
+func (l *enumLabeler) LabelField(to int, f []byte) ([]byte, error) {
+	if l == nil || f == nil {
+		return f, nil
+	}
+	if labels, ok := l.enums[to]; ok {
+		ord := int(binary.LittleEndian.Uint16(f))
+		if ord >= len(labels) {
+			return nil, fmt.Errorf("invalid enum ordinal %d", ord)
+		}
+		return labels[ord], nil
+	}
+	if t, ok := l.sets[to]; ok {
+		s, err := t.BitsToString(binary.LittleEndian.Uint64(f))
+		if err != nil {
+			return nil, err
+		}
+		return encodeString(s), nil
+	}
+	return f, nil
+}
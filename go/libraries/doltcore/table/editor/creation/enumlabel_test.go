@@ -0,0 +1,108 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/dolthub/go-mysql-server/sql"
+	"github.com/stretchr/testify/require"
+
+	"github.com/dolthub/dolt/go/libraries/doltcore/doltdb/durable"
+	"github.com/dolthub/dolt/go/libraries/doltcore/schema"
+	"github.com/dolthub/dolt/go/libraries/doltcore/schema/typeinfo"
+	"github.com/dolthub/dolt/go/libraries/doltcore/sqle/index"
+	"github.com/dolthub/dolt/go/store/types"
+)
+
+func TestIndexEnumsByLabel(t *testing.T) {
+	ctx := context.Background()
+	vrw := newTestVRW()
+	enumTI, err := typeinfo.FromSqlType(sql.MustCreateEnumType([]string{"zebra", "apple", "mango"}, sql.Collation_Default))
+	require.NoError(t, err)
+	setTI, err := typeinfo.FromSqlType(sql.MustCreateSetType([]string{"red", "green", "blue"}, sql.Collation_Default))
+	require.NoError(t, err)
+	animal, err := schema.NewColumnWithTypeInfo("animal", 2, enumTI, false, "", false, "")
+	require.NoError(t, err)
+	colors, err := schema.NewColumnWithTypeInfo("colors", 3, setTI, false, "", false, "")
+	require.NoError(t, err)
+	sch, err := schema.SchemaFromCols(schema.NewColCollection(
+		schema.NewColumn("pk", 1, types.IntKind, true, schema.NotNullConstraint{}),
+		animal,
+		colors,
+	))
+	require.NoError(t, err)
+
+	rows := [][]interface{}{
+		{1, uint16(2), uint64(4)},
+		{2, uint16(3), uint64(3)},
+		{3, uint16(1), uint64(1)},
+		{4, nil, nil},
+		{5, uint16(2), uint64(2)},
+	}
+	primary := newTestPrimary(t, ctx, vrw, sch, rows)
+	coll := schema.NewIndexCollection(sch.GetAllCols(), sch.GetPKCols())
+
+	// values returns the first key field of the entries of |rowData| in order,
+	// as returned by the SQL engine
+	values := func(rowData durable.Index) (vals []interface{}) {
+		m := durable.ProllyMapFromIndex(rowData)
+		kd, _ := m.Descriptors()
+		iter, err := m.IterAll(ctx)
+		require.NoError(t, err)
+		for {
+			k, _, err := iter.Next(ctx)
+			if err == io.EOF {
+				return vals
+			}
+			require.NoError(t, err)
+			v, err := index.GetField(ctx, kd, 0, k, m.NodeStore())
+			require.NoError(t, err)
+			vals = append(vals, v)
+		}
+	}
+
+	// by default, keys are ordered by ordinal, like MySQL's ORDER BY
+	byAnimal, err := coll.AddIndexByColNames("animal_idx", []string{"animal"}, schema.IndexProperties{})
+	require.NoError(t, err)
+	rowData, err := BuildSecondaryProllyIndex(ctx, vrw, sch, byAnimal, primary, BuildOptions{})
+	require.NoError(t, err)
+	require.Equal(t, []interface{}{uint16(1), uint16(2), uint16(2), uint16(3), nil}, values(rowData))
+	byColors, err := coll.AddIndexByColNames("colors_idx", []string{"colors"}, schema.IndexProperties{})
+	require.NoError(t, err)
+	rowData, err = BuildSecondaryProllyIndex(ctx, vrw, sch, byColors, primary, BuildOptions{})
+	require.NoError(t, err)
+	require.Equal(t, []interface{}{uint64(1), uint64(2), uint64(3), uint64(4), nil}, values(rowData))
+
+	// by label, keys are ordered alphabetically
+	opts := BuildOptions{KeyEncodingOptions: KeyEncodingOptions{IndexEnumsByLabel: true}}
+	rowData, err = BuildSecondaryProllyIndex(ctx, vrw, sch, byAnimal, primary, opts)
+	require.NoError(t, err)
+	require.Equal(t, []interface{}{"apple", "apple", "mango", "zebra", nil}, values(rowData))
+	rowData, err = BuildSecondaryProllyIndex(ctx, vrw, sch, byColors, primary, opts)
+	require.NoError(t, err)
+	require.Equal(t, []interface{}{"blue", "green", "red", "red,green", nil}, values(rowData))
+
+	uniq, err := coll.AddIndexByColNames("animal_colors_uniq", []string{"animal", "colors"}, schema.IndexProperties{IsUnique: true})
+	require.NoError(t, err)
+	rowData, err = BuildSecondaryProllyIndex(ctx, vrw, sch, uniq, primary, opts)
+	require.NoError(t, err)
+	require.Equal(t, []interface{}{"apple", "apple", "mango", "zebra", nil}, values(rowData))
+
+	_, err = CreateIndex(ctx, newTestTable(t, ctx, vrw, sch, rows), "animal_idx", []string{"animal"}, false, true, "", opts)
+	require.Error(t, err)
+}
@@ -0,0 +1,158 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/dolthub/dolt/go/libraries/doltcore/doltdb/durable"
+)
+
+// ErrIndexRowCountMismatch is returned when a newly built secondary index does not contain one entry per primary row.
+var ErrIndexRowCountMismatch = errors.New("secondary index row count does not match primary row count")
+
+// ErrPrefixLimitReached is returned by a PrefixItr created with NewPrefixItrLimit once it has returned its limit of
+// matching entries.
+var ErrPrefixLimitReached = errors.New("prefix iterator reached its limit")
+
+// ErrIndexKeyCollision is returned when two primary rows produce the same full secondary index key. Since the key of
+// a non-unique index includes the primary key, this indicates duplicate primary keys.
+var ErrIndexKeyCollision = errors.New("secondary index key collision")
+
+// ErrJSONNotObject is returned by BuildJSONKeysIndex with RejectNonObjectJSON when an indexed JSON value is not an
+// object.
+var ErrJSONNotObject = errors.New("JSON value is not an object")
+
+// ErrJSONArrayShape is returned by BuildJSONArrayIndex when an indexed JSON value is not an array with the indexed
+// number of elements and element types.
+var ErrJSONArrayShape = errors.New("JSON value is not an array of the indexed shape")
+
+// ErrIndexFieldTooLarge is returned, wrapped in an ErrIndexEncode, when an indexed field of a primary row is larger
+// than BuildOptions.MaxIndexFieldSize, and BuildOptions.OversizedIndexFieldPolicy does not truncate or skip it.
+var ErrIndexFieldTooLarge = errors.New("indexed field exceeds the maximum field size")
+
+// ErrIndexKeyOrder is returned when a build that writes the entries of an index in key order is given a key that is
+// not greater than the key before it, with BuildOptions.AssertIndexKeyOrder.
+var ErrIndexKeyOrder = errors.New("index keys out of order")
+
+// ErrIndexKeyNotOrdered is returned when an indexed column has a type without an order-preserving encoding, so that
+// the index could not be range scanned, and the index is not built with BuildOptions.EqualityOnlyIndex.
+var ErrIndexKeyNotOrdered = errors.New("index key type has no order-preserving encoding")
+
+// ErrIndexKeyNaN is returned, wrapped in an ErrIndexEncode, when an indexed float field of a primary row is NaN, which
+// has no order relative to other floats and so cannot be stored in an index key.
+var ErrIndexKeyNaN = errors.New("indexed float value is NaN")
+
+// ErrIndexDefinitionChanged is returned by SwapIndexRows when the definition of an index changed after its new data
+// was built.
+var ErrIndexDefinitionChanged = errors.New("index definition changed")
+
+// ErrIndexSchemaMismatch is returned by BuildSecondaryIndexWithSchema when an index was defined against a schema that
+// is not the schema of its table.
+var ErrIndexSchemaMismatch = errors.New("index schema does not match table schema")
+
+// ErrIndexBuildTimeout is returned when an index build exceeds BuildOptions.MaxIndexBuildDuration.
+type ErrIndexBuildTimeout struct {
+	IndexName     string
+	Timeout       time.Duration
+	RowsProcessed uint64
+}
+
+func (e ErrIndexBuildTimeout) Error() string {
+	return fmt.Sprintf("building index `%s` exceeded the maximum build duration of %s after processing %d rows",
+		e.IndexName, e.Timeout, e.RowsProcessed)
+}
+
+// ErrIndexCardinalityExceeded is returned when a secondary index has more distinct values of its indexed columns than
+// BuildOptions.MaxIndexDistinctValues allows.
+type ErrIndexCardinalityExceeded struct {
+	IndexName     string
+	MaxDistinct   uint64
+	RowsProcessed uint64
+}
+
+func (e ErrIndexCardinalityExceeded) Error() string {
+	return fmt.Sprintf("index `%s` has more than %d distinct values after processing %d rows",
+		e.IndexName, e.MaxDistinct, e.RowsProcessed)
+}
+
+// ErrForeignKeyViolation is returned by CreateIndexForForeignKey when a row of the child table of a foreign key
+// references no row of its parent table. PrimaryKey is the formatted primary key of the child row.
+type ErrForeignKeyViolation struct {
+	ForeignKey  string
+	ParentTable string
+	PrimaryKey  string
+}
+
+func (e ErrForeignKeyViolation) Error() string {
+	return fmt.Sprintf("row %s violates foreign key `%s`: it references no row of table `%s`",
+		e.PrimaryKey, e.ForeignKey, e.ParentTable)
+}
+
+// ErrPartialIndexBuild is returned by a canceled index build when BuildOptions.FlushPartialIndexOnCancel is set.
+// Partial holds the entries built before the build was canceled. It is incomplete and must only be used for debugging,
+// never as the data of the index.
+type ErrPartialIndexBuild struct {
+	IndexName     string
+	RowsProcessed uint64
+	Partial       durable.Index
+	Err           error
+}
+
+func (e ErrPartialIndexBuild) Error() string {
+	return fmt.Sprintf("building index `%s` was stopped after processing %d rows: %s", e.IndexName, e.RowsProcessed, e.Err)
+}
+
+func (e ErrPartialIndexBuild) Unwrap() error {
+	return e.Err
+}
+
+// ErrIndexBuildIO is returned when reading the primary rows of a table, or writing the entries of a secondary index,
+// fails while building the index. PrimaryKey is the formatted primary key of the row being indexed, if any.
+type ErrIndexBuildIO struct {
+	IndexName  string
+	PrimaryKey string
+	Err        error
+}
+
+func (e ErrIndexBuildIO) Error() string {
+	if e.PrimaryKey != "" {
+		return fmt.Sprintf("building index `%s` failed at row %s: %s", e.IndexName, e.PrimaryKey, e.Err)
+	}
+	return fmt.Sprintf("building index `%s` failed: %s", e.IndexName, e.Err)
+}
+
+func (e ErrIndexBuildIO) Unwrap() error {
+	return e.Err
+}
+
+// ErrIndexEncode is returned when a primary row cannot be encoded as a key of a secondary index, e.g. because one of
+// its primary key columns is NULL, which can only happen if the primary index is corrupt. PrimaryKey is the formatted
+// primary key of the row.
+type ErrIndexEncode struct {
+	IndexName  string
+	PrimaryKey string
+	Err        error
+}
+
+func (e ErrIndexEncode) Error() string {
+	return fmt.Sprintf("row %s cannot be encoded for index `%s`: %s", e.PrimaryKey, e.IndexName, e.Err)
+}
+
+func (e ErrIndexEncode) Unwrap() error {
+	return e.Err
+}
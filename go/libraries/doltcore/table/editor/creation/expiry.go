@@ -0,0 +1,144 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/dolthub/dolt/go/libraries/doltcore/doltdb/durable"
+	"github.com/dolthub/dolt/go/libraries/doltcore/schema"
+	"github.com/dolthub/dolt/go/store/pool"
+	"github.com/dolthub/dolt/go/store/prolly/shim"
+	"github.com/dolthub/dolt/go/store/val"
+)
+
+// expiryValueDesc is the value descriptor of an index built with
+// BuildOptions.IndexExpiryColumn. The value of an entry is the time its row
+// expires, or NULL if it never expires.
+var expiryValueDesc = val.NewTupleDescriptor(val.Type{Enc: val.DatetimeEnc, Nullable: true})
+
+// expiryValues computes the values of an index built with
+// BuildOptions.IndexExpiryColumn. A nil *expiryValues computes no expiries.
+type expiryValues struct {
+	ttl time.Duration
+	// from is the field of the primary row holding the expiry column,
+	// numbered like the fields of a val.OrdinalMapping from GetIndexKeyMapping
+	from  int
+	pkLen int
+	pkd   val.TupleDesc
+	pvd   val.TupleDesc
+	vb    *val.TupleBuilder
+}
+
+// validateExpiry returns an error if |idx| cannot be built with the expiry
+// column of |opts|.
+func validateExpiry(sch schema.Schema, idx schema.Index, opts BuildOptions) (schema.Column, error) {
+	col, ok := sch.GetAllCols().GetByNameCaseInsensitive(opts.IndexExpiryColumn)
+	if !ok {
+		return schema.Column{}, fmt.Errorf("index `%s`: expiry column `%s` does not exist", idx.Name(), opts.IndexExpiryColumn)
+	}
+	if !schema.IsColTimeBucketType(col) {
+		return schema.Column{}, fmt.Errorf("index `%s`: expiry column `%s` is not a datetime or timestamp column", idx.Name(), col.Name)
+	}
+	return col, nil
+}
+
+// newExpiryValues returns the expiryValues of a build of |idx|, or nil if
+// |opts| has no expiry column.
+func newExpiryValues(sch schema.Schema, idx schema.Index, opts BuildOptions) (*expiryValues, error) {
+	if opts.IndexExpiryColumn == "" {
+		return nil, nil
+	}
+	col, err := validateExpiry(sch, idx, opts)
+	if err != nil {
+		return nil, err
+	}
+	pkd, pvd := shim.MapDescriptorsFromSchema(sch)
+	e := &expiryValues{
+		ttl:   opts.IndexExpiryTTL,
+		pkLen: sch.GetPKCols().Size(),
+		pkd:   pkd,
+		pvd:   pvd,
+		vb:    val.NewTupleBuilder(expiryValueDesc),
+	}
+	if i, ok := sch.GetPKCols().TagToIdx[col.Tag]; ok {
+		e.from = i
+	} else {
+		e.from = e.pkLen + sch.GetNonPKCols().TagToIdx[col.Tag]
+	}
+	return e, nil
+}
+
+// value returns the value of the entry of the primary row |k|, |v|: the time
+// of its expiry column plus the TTL, or NULL if the column is NULL.
+func (e *expiryValues) value(k, v val.Tuple, p pool.BuffPool) val.Tuple {
+	var t time.Time
+	var ok bool
+	if e.from < e.pkLen {
+		t, ok = e.pkd.GetDatetime(e.from, k)
+	} else {
+		t, ok = e.pvd.GetDatetime(e.from-e.pkLen, v)
+	}
+	if !ok {
+		e.vb.PutRaw(0, nil)
+	} else {
+		e.vb.PutDatetime(0, t.Add(e.ttl))
+	}
+	return e.vb.Build(p)
+}
+
+// CompactExpiredIndexEntries returns |rows|, the data of an index built with
+// BuildOptions.IndexExpiryColumn, without the entries that expired before
+// |now|, and the number of entries it removed. Entries that never expire are
+// kept.
+func CompactExpiredIndexEntries(ctx context.Context, rows durable.Index, now time.Time) (durable.Index, uint64, error) {
+	m := durable.ProllyMapFromIndex(rows)
+	_, vd := m.Descriptors()
+	if vd.Count() != 1 || vd.Types[0].Enc != val.DatetimeEnc {
+		return nil, 0, fmt.Errorf("index data is not the data of an index with expiries")
+	}
+	iter, err := m.IterAll(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	mut := m.Mutate()
+	var removed uint64
+	for {
+		k, v, err := iter.Next(ctx)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		if t, ok := vd.GetDatetime(0, v); ok && t.Before(now) {
+			if err = mut.Delete(ctx, k); err != nil {
+				return nil, 0, err
+			}
+			removed++
+		}
+	}
+	if removed == 0 {
+		return rows, 0, nil
+	}
+	m, err = mut.Map(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	return durable.IndexFromProllyMap(m), removed, nil
+}
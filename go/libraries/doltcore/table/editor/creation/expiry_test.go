@@ -0,0 +1,148 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/dolthub/dolt/go/libraries/doltcore/doltdb/durable"
+	"github.com/dolthub/dolt/go/libraries/doltcore/schema"
+	"github.com/dolthub/dolt/go/store/types"
+)
+
+func TestIndexExpiry(t *testing.T) {
+	ctx := context.Background()
+	vrw := newTestVRW()
+	sch, err := schema.SchemaFromCols(schema.NewColCollection(
+		schema.NewColumn("pk", 1, types.IntKind, true, schema.NotNullConstraint{}),
+		schema.NewColumn("c1", 2, types.IntKind, false),
+		schema.NewColumn("created", 3, types.TimestampKind, false),
+	))
+	require.NoError(t, err)
+	now := time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)
+	rows := [][]interface{}{
+		{1, 10, now.Add(-3 * time.Hour)},
+		{2, 20, now.Add(-30 * time.Minute)},
+		{3, 30, now.Add(-2 * time.Hour)},
+		{4, 40, nil},
+		{5, 50, now},
+	}
+	primary := newTestPrimary(t, ctx, vrw, sch, rows)
+	coll := schema.NewIndexCollection(sch.GetAllCols(), sch.GetPKCols())
+	idx, err := coll.AddIndexByColNames("c1_idx", []string{"c1"}, schema.IndexProperties{})
+	require.NoError(t, err)
+	uniq, err := coll.AddIndexByColNames("c1_uniq", []string{"c1"}, schema.IndexProperties{IsUnique: true})
+	require.NoError(t, err)
+
+	// pks returns the primary keys of the entries of |rowData| in order
+	pks := func(rowData durable.Index) (pks []int64) {
+		m := durable.ProllyMapFromIndex(rowData)
+		kd, _ := m.Descriptors()
+		iter, err := m.IterAll(ctx)
+		require.NoError(t, err)
+		for {
+			k, _, err := iter.Next(ctx)
+			if err == io.EOF {
+				return pks
+			}
+			require.NoError(t, err)
+			pk, _ := kd.GetInt64(1, k)
+			pks = append(pks, pk)
+		}
+	}
+
+	// rows expire an hour after they were created
+	opts := BuildOptions{ValueLayoutOptions: ValueLayoutOptions{IndexExpiryColumn: "created", IndexExpiryTTL: time.Hour}}
+	for _, i := range []schema.Index{idx, uniq} {
+		rowData, err := BuildSecondaryProllyIndex(ctx, vrw, sch, i, primary, opts)
+		require.NoError(t, err)
+		m := durable.ProllyMapFromIndex(rowData)
+		kd, vd := m.Descriptors()
+		iter, err := m.IterAll(ctx)
+		require.NoError(t, err)
+		for _, row := range rows {
+			k, v, err := iter.Next(ctx)
+			require.NoError(t, err)
+			pk, _ := kd.GetInt64(1, k)
+			require.Equal(t, int64(row[0].(int)), pk)
+			exp, ok := vd.GetDatetime(0, v)
+			if row[2] == nil {
+				require.False(t, ok)
+			} else {
+				require.True(t, ok)
+				require.True(t, row[2].(time.Time).Add(time.Hour).Equal(exp))
+			}
+		}
+
+		compacted, removed, err := CompactExpiredIndexEntries(ctx, rowData, now)
+		require.NoError(t, err)
+		require.Equal(t, uint64(2), removed)
+		require.Equal(t, []int64{2, 4, 5}, pks(compacted))
+
+		// compacting again removes nothing until more entries expire
+		again, removed, err := CompactExpiredIndexEntries(ctx, compacted, now)
+		require.NoError(t, err)
+		require.Zero(t, removed)
+		require.Equal(t, durable.ProllyMapFromIndex(compacted).HashOf(), durable.ProllyMapFromIndex(again).HashOf())
+		later, removed, err := CompactExpiredIndexEntries(ctx, compacted, now.Add(2*time.Hour))
+		require.NoError(t, err)
+		require.Equal(t, uint64(2), removed)
+		require.Equal(t, []int64{4}, pks(later))
+	}
+
+	// pipelined unique builds compute the same expiries
+	expected, err := BuildSecondaryProllyIndex(ctx, vrw, sch, uniq, primary, opts)
+	require.NoError(t, err)
+	pipelined := opts
+	pipelined.UniqueIndexCheckWorkers = 2
+	actual, err := BuildSecondaryProllyIndex(ctx, vrw, sch, uniq, primary, pipelined)
+	require.NoError(t, err)
+	requireSameIndex(t, expected, actual)
+
+	// the data of an index without expiries cannot be compacted
+	plain, err := BuildSecondaryProllyIndex(ctx, vrw, sch, idx, primary, BuildOptions{})
+	require.NoError(t, err)
+	_, _, err = CompactExpiredIndexEntries(ctx, plain, now)
+	require.Error(t, err)
+
+	for _, bad := range []BuildOptions{
+		{ValueLayoutOptions: ValueLayoutOptions{IndexExpiryColumn: "missing"}},
+		{ValueLayoutOptions: ValueLayoutOptions{IndexExpiryColumn: "c1"}},
+		{ValueLayoutOptions: ValueLayoutOptions{IndexExpiryColumn: "created", IndexExpiryTTL: -time.Hour}},
+		{ValueLayoutOptions: ValueLayoutOptions{IndexExpiryColumn: "created", MirrorPrimaryRowInIndex: true}},
+	} {
+		_, err = BuildSecondaryProllyIndex(ctx, vrw, sch, idx, primary, bad)
+		require.Error(t, err, "%+v", bad)
+	}
+
+	// the values of indexes with expiries cannot hold anything else
+	for _, other := range []BuildOptions{
+		{ValueLayoutOptions: ValueLayoutOptions{IndexExpiryColumn: "created", MirrorPrimaryRowInIndex: true}},
+		{ValueLayoutOptions: ValueLayoutOptions{IndexExpiryColumn: "created", RecordSourceChunkInIndex: true}},
+		{ValueLayoutOptions: ValueLayoutOptions{IndexExpiryColumn: "created", RecordRowLocatorInIndex: true}},
+		{ValueLayoutOptions: ValueLayoutOptions{IndexExpiryColumn: "created", IndexIntervalEnd: "created"}},
+	} {
+		_, err = newSecondaryMap(ctx, vrw, sch, idx, other)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "IndexExpiryColumn cannot be combined")
+	}
+	_, err = CreateIndex(ctx, newTestTable(t, ctx, vrw, sch, rows), "c1_idx", []string{"c1"}, false, true, "", opts)
+	require.Error(t, err)
+}
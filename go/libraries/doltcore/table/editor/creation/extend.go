@@ -0,0 +1,213 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/dolthub/dolt/go/libraries/doltcore/doltdb"
+	"github.com/dolthub/dolt/go/libraries/doltcore/doltdb/durable"
+	"github.com/dolthub/dolt/go/libraries/doltcore/schema"
+	"github.com/dolthub/dolt/go/store/types"
+	"github.com/dolthub/dolt/go/store/val"
+)
+
+// ExtendIndex redefines the index |oldIdx| of |tbl| over |columns|, keeping its name and properties. If |columns|
+// strictly extends the columns of |oldIdx| on the right, e.g. from (a, b) to (a, b, c), the new index is spliced
+// from the entries of the existing index, reading only the added columns from the primary rows. Otherwise, the new
+// index is built from scratch.
+func ExtendIndex(ctx context.Context, tbl *doltdb.Table, oldIdx schema.Index, columns []string, opts BuildOptions) (*CreateIndexReturn, error) {
+	sch, err := tbl.GetSchema(ctx)
+	if err != nil {
+		return nil, err
+	}
+	realColNames, err := resolveColumnNames(sch, columns)
+	if err != nil {
+		return nil, err
+	}
+	tags := make([]uint64, len(realColNames))
+	for i, name := range realColNames {
+		col, _ := sch.GetAllCols().GetByName(name)
+		tags[i] = col.Tag
+	}
+
+	if _, err = sch.Indexes().RemoveIndex(oldIdx.Name()); err != nil {
+		return nil, err
+	}
+	// an index that extends a de facto unique index on the right is de facto unique too
+	newIdx, err := sch.Indexes().AddIndexByColTags(oldIdx.Name(), tags, schema.IndexProperties{
+		IsUnique:        oldIdx.IsUnique(),
+		IsUserDefined:   oldIdx.IsUserDefined(),
+		Comment:         oldIdx.Comment(),
+		IsDeFactoUnique: oldIdx.IsDeFactoUnique() && extendsTags(oldIdx.IndexedColumnTags(), tags),
+	})
+	if err != nil {
+		return nil, err
+	}
+	newTable, err := tbl.UpdateSchema(ctx, sch)
+	if err != nil {
+		return nil, err
+	}
+
+	var indexRows durable.Index
+	opts = withHookStats(opts)
+	if canSpliceIndex(tbl.Format(), oldIdx, newIdx, opts) {
+		indexRows, err = spliceIndex(ctx, tbl, sch, oldIdx, newIdx)
+	} else {
+		indexRows, err = BuildSecondaryIndex(ctx, newTable, newIdx, opts)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	newTable, err = newTable.SetIndexRows(ctx, newIdx.Name(), indexRows)
+	if err != nil {
+		return nil, err
+	}
+	if err = callBuildHook(ctx, newIdx.Name(), indexRows, opts); err != nil {
+		return nil, err
+	}
+	contentHash, err := IndexContentHash(indexRows)
+	if err != nil {
+		return nil, err
+	}
+	return &CreateIndexReturn{
+		NewTable:    newTable,
+		Sch:         sch,
+		OldIndex:    oldIdx,
+		NewIndex:    newIdx,
+		ContentHash: contentHash,
+	}, nil
+}
+
+// canSpliceIndex returns whether |newIdx| can be spliced from the complete data of |oldIdx|.
+func canSpliceIndex(nbf *types.NomsBinFormat, oldIdx, newIdx schema.Index, opts BuildOptions) bool {
+	if !types.IsFormat_DOLT_1(nbf) || oldIdx.IsDeferred() || opts.IndexRowFilter != nil || opts.IndexKeyEncryption != nil {
+		return false
+	}
+	return extendsTags(oldIdx.IndexedColumnTags(), newIdx.IndexedColumnTags())
+}
+
+// extendsTags returns whether |newTags| strictly extends |oldTags| on the right.
+func extendsTags(oldTags, newTags []uint64) bool {
+	if len(newTags) <= len(oldTags) {
+		return false
+	}
+	for i := range oldTags {
+		if oldTags[i] != newTags[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// spliceIndex builds the data of |newIdx| from the entries of |oldIdx|, whose indexed columns are a prefix of
+// those of |newIdx|. Indexed fields of |oldIdx| are copied from its entries, and the remaining fields of |newIdx|
+// are read from the primary row of each entry. A unique |oldIdx| implies that |newIdx| is unique, so no duplicate
+// checks are needed.
+func spliceIndex(ctx context.Context, tbl *doltdb.Table, sch schema.Schema, oldIdx, newIdx schema.Index) (durable.Index, error) {
+	m, err := tbl.GetRowData(ctx)
+	if err != nil {
+		return nil, err
+	}
+	primary := durable.ProllyMapFromIndex(m)
+	s, err := tbl.GetIndexRowData(ctx, oldIdx.Name())
+	if err != nil {
+		return nil, err
+	}
+	existing := durable.ProllyMapFromIndex(s)
+
+	empty, err := durable.NewEmptyIndex(ctx, tbl.ValueReadWriter(), newIdx.Schema())
+	if err != nil {
+		return nil, err
+	}
+	secondary := durable.ProllyMapFromIndex(empty)
+	kd, _ := secondary.Descriptors()
+	keyBld := val.NewTupleBuilder(kd)
+
+	pkLen := sch.GetPKCols().Size()
+	keyMap, err := GetIndexKeyMapping(sch, newIdx)
+	if err != nil {
+		return nil, err
+	}
+
+	// the primary key of an entry of |oldIdx| is found at the fields that map to primary key fields
+	oldKeyMap, err := GetIndexKeyMapping(sch, oldIdx)
+	if err != nil {
+		return nil, err
+	}
+	pkd, _ := primary.Descriptors()
+	pkBld := val.NewTupleBuilder(pkd)
+	pkMap := make(val.OrdinalMapping, pkLen)
+	for to := range oldKeyMap {
+		if from := oldKeyMap.MapOrdinal(to); from < pkLen {
+			pkMap[from] = to
+		}
+	}
+
+	iter, err := existing.IterAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	mut := secondary.Mutate()
+	for {
+		oldKey, _, err := iter.Next(ctx)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		for to, from := range pkMap {
+			pkBld.PutRaw(to, oldKey.GetField(from))
+		}
+		var k, v val.Tuple
+		err = primary.Get(ctx, pkBld.Build(primary.Pool()), func(key, value val.Tuple) error {
+			k, v = key, value
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		if k == nil {
+			return nil, fmt.Errorf("index `%s` has an entry for a row that does not exist", oldIdx.Name())
+		}
+
+		for to := range keyMap {
+			if to < oldIdx.Count() {
+				keyBld.PutRaw(to, oldKey.GetField(to))
+				continue
+			}
+			from := keyMap.MapOrdinal(to)
+			if from < pkLen {
+				keyBld.PutRaw(to, k.GetField(from))
+			} else {
+				keyBld.PutRaw(to, v.GetField(from-pkLen))
+			}
+		}
+		if err = mut.Put(ctx, keyBld.Build(secondary.Pool()), val.EmptyTuple); err != nil {
+			return nil, err
+		}
+	}
+
+	secondary, err = mut.Map(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return durable.IndexFromProllyMap(secondary), nil
+}
@@ -0,0 +1,77 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/dolthub/dolt/go/libraries/doltcore/doltdb/durable"
+	"github.com/dolthub/dolt/go/libraries/doltcore/schema"
+)
+
+func TestExtendIndex(t *testing.T) {
+	ctx := context.Background()
+	vrw := newTestVRW()
+	sch := newTestSchema(t)
+	var rows [][]interface{}
+	for i := 0; i < 200; i++ {
+		var c2 interface{} = string(rune('a' + i%5))
+		if i%7 == 0 {
+			c2 = nil
+		}
+		rows = append(rows, []interface{}{i, i % 3, c2})
+	}
+	tbl := newTestTable(t, ctx, vrw, sch, rows)
+
+	tests := []struct {
+		name    string
+		old     []string
+		new     []string
+		splices bool
+	}{
+		{name: "extend", old: []string{"c1"}, new: []string{"c1", "c2"}, splices: true},
+		{name: "extend with primary key", old: []string{"c1"}, new: []string{"c1", "c2", "pk"}, splices: true},
+		{name: "reorder", old: []string{"c1", "c2"}, new: []string{"c2", "c1"}},
+		{name: "shrink", old: []string{"c1", "c2"}, new: []string{"c1"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ret, err := CreateIndex(ctx, tbl, "idx", test.old, false, true, "comment", BuildOptions{})
+			require.NoError(t, err)
+			assert.Equal(t, test.splices, canSpliceIndex(vrw.Format(), ret.NewIndex, mustCandidateIndex(t, ret.Sch, test.new), BuildOptions{}))
+
+			ext, err := ExtendIndex(ctx, ret.NewTable, ret.NewIndex, test.new, BuildOptions{})
+			require.NoError(t, err)
+			assert.Equal(t, test.new, ext.NewIndex.ColumnNames())
+			assert.Equal(t, "comment", ext.NewIndex.Comment())
+
+			expected, err := BuildSecondaryIndex(ctx, ext.NewTable, ext.NewIndex, BuildOptions{})
+			require.NoError(t, err)
+			actual, err := ext.NewTable.GetIndexRowData(ctx, "idx")
+			require.NoError(t, err)
+			assert.Equal(t, durable.ProllyMapFromIndex(expected).HashOf(), durable.ProllyMapFromIndex(actual).HashOf())
+		})
+	}
+}
+
+func mustCandidateIndex(t *testing.T, sch schema.Schema, cols []string) schema.Index {
+	idx, err := candidateIndex(sch, cols)
+	require.NoError(t, err)
+	return idx
+}
@@ -0,0 +1,79 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"errors"
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/dolthub/dolt/go/libraries/doltcore/schema"
+	"github.com/dolthub/dolt/go/store/val"
+)
+
+// fieldSizeLimit applies BuildOptions.MaxIndexFieldSize to the indexed
+// fields of index keys. A nil *fieldSizeLimit leaves keys unchanged.
+type fieldSizeLimit struct {
+	max    int
+	policy OversizedIndexFieldPolicy
+	// n is the number of indexed fields, which precede the primary key fields
+	// of the key
+	n  int
+	kd val.TupleDesc
+}
+
+// newFieldSizeLimit returns the fieldSizeLimit of the keys of |idx|, encoded
+// by |kd|, or nil if |opts| has no MaxIndexFieldSize.
+func newFieldSizeLimit(idx schema.Index, kd val.TupleDesc, opts BuildOptions) (*fieldSizeLimit, error) {
+	if opts.MaxIndexFieldSize == 0 {
+		return nil, nil
+	}
+	return &fieldSizeLimit{max: opts.MaxIndexFieldSize, policy: opts.OversizedIndexFieldPolicy, n: idx.Count(), kd: kd}, nil
+}
+
+// LimitField returns the index key field |f| at position |to|, which is at
+// position |at| of the key, truncated if it is an indexed field larger than
+// the limit and the policy truncates fields. Fields that are not truncated
+// fail with an ErrIndexFieldTooLarge.
+func (l *fieldSizeLimit) LimitField(to, at int, f []byte) ([]byte, error) {
+	if l == nil || to >= l.n || len(f) <= l.max {
+		return f, nil
+	}
+	err := fmt.Errorf("%w: %d bytes, more than %d", ErrIndexFieldTooLarge, len(f), l.max)
+	if l.policy != OversizedIndexFieldTruncate {
+		return nil, err
+	}
+	switch l.kd.Types[at].Enc {
+	case val.StringEnc:
+		// strings are null terminated, and are cut before the character at the limit
+		end := l.max - 1
+		for end > 0 && !utf8.RuneStart(f[end]) {
+			end--
+		}
+		return append(f[:end:end], 0), nil
+	case val.ByteStringEnc:
+		return f[:l.max], nil
+	default:
+		return nil, err
+	}
+}
+
+// skipsOversizedRow returns whether |err|, the error of encoding the index key
+// of a primary row, leaves the row out of the index rather than failing the
+// build, as it does for an ErrIndexFieldTooLarge with
+// OversizedIndexFieldSkipRow.
+func skipsOversizedRow(err error, opts BuildOptions) bool {
+	return opts.OversizedIndexFieldPolicy == OversizedIndexFieldSkipRow && errors.Is(err, ErrIndexFieldTooLarge)
+}
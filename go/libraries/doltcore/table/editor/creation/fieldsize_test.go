@@ -0,0 +1,133 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/dolthub/dolt/go/libraries/doltcore/doltdb/durable"
+	"github.com/dolthub/dolt/go/libraries/doltcore/schema"
+)
+
+func TestOversizedIndexFields(t *testing.T) {
+	ctx := context.Background()
+	vrw := newTestVRW()
+	sch := newTestSchema(t)
+	rows := [][]interface{}{
+		{1, 10, "short"},
+		{2, 20, strings.Repeat("x", 100)},
+		{3, 30, "ééééééé"},
+		{4, 40, nil},
+		{5, 50, "exactly10b"},
+	}
+	primary := newTestPrimary(t, ctx, vrw, sch, rows)
+	coll := schema.NewIndexCollection(sch.GetAllCols(), sch.GetPKCols())
+	idx, err := coll.AddIndexByColNames("c2_idx", []string{"c2"}, schema.IndexProperties{})
+	require.NoError(t, err)
+	uniq, err := coll.AddIndexByColNames("c2_uniq", []string{"c2"}, schema.IndexProperties{IsUnique: true})
+	require.NoError(t, err)
+
+	// strings are null terminated, so a field of 11 bytes holds 10 bytes of a string
+	limit := BuildOptions{KeyEncodingOptions: KeyEncodingOptions{MaxIndexFieldSize: 11}}
+
+	t.Run("error", func(t *testing.T) {
+		for _, i := range []schema.Index{idx, uniq} {
+			_, err := BuildSecondaryProllyIndex(ctx, vrw, sch, i, primary, limit)
+			require.True(t, errors.Is(err, ErrIndexFieldTooLarge), "%v", err)
+			var encErr ErrIndexEncode
+			require.True(t, errors.As(err, &encErr))
+			require.Equal(t, "( 2 )", encErr.PrimaryKey)
+		}
+		// without a limit, the same rows are indexed
+		_, err := BuildSecondaryProllyIndex(ctx, vrw, sch, idx, primary, BuildOptions{})
+		require.NoError(t, err)
+	})
+
+	t.Run("truncate", func(t *testing.T) {
+		opts := limit
+		opts.OversizedIndexFieldPolicy = OversizedIndexFieldTruncate
+		for _, i := range []schema.Index{idx, uniq} {
+			rowData, err := BuildSecondaryProllyIndex(ctx, vrw, sch, i, primary, opts)
+			require.NoError(t, err)
+			// é is two bytes, so the string is cut before the character that would exceed the limit
+			require.Equal(t, []string{"[exactly10b,5]", "[short,1]", "[xxxxxxxxxx,2]", "[ééééé,3]", "[NULL,4]"}, collectKeys(t, ctx, durable.ProllyMapFromIndex(rowData)))
+		}
+
+		// values that are equal once truncated are duplicates of a unique index
+		dup := newTestPrimary(t, ctx, vrw, sch, append(rows, []interface{}{6, 60, strings.Repeat("x", 50)}))
+		_, err = BuildSecondaryProllyIndex(ctx, vrw, sch, uniq, dup, opts)
+		require.Error(t, err)
+		rowData, err := BuildSecondaryProllyIndex(ctx, vrw, sch, idx, dup, opts)
+		require.NoError(t, err)
+		require.Equal(t, uint64(6), uint64(durable.ProllyMapFromIndex(rowData).Count()))
+
+		// truncated keys are not the keys of their rows
+		_, err = CreateIndex(ctx, newTestTable(t, ctx, vrw, sch, rows), "c2_idx", []string{"c2"}, false, true, "", opts)
+		require.Error(t, err)
+	})
+
+	t.Run("skip row", func(t *testing.T) {
+		opts := limit
+		opts.OversizedIndexFieldPolicy = OversizedIndexFieldSkipRow
+		expected := []string{"[exactly10b,5]", "[short,1]", "[NULL,4]"}
+		for _, i := range []schema.Index{idx, uniq} {
+			stats := &IndexBuildStats{}
+			opts.IndexBuildStats = stats
+			rowData, err := BuildSecondaryProllyIndex(ctx, vrw, sch, i, primary, opts)
+			require.NoError(t, err)
+			require.Equal(t, expected, collectKeys(t, ctx, durable.ProllyMapFromIndex(rowData)))
+			require.Equal(t, []string{"( 2 )", "( 3 )"}, stats.OversizedRows)
+			require.Equal(t, uint64(5), stats.RowsScanned)
+			require.Equal(t, uint64(3), stats.RowsIndexed)
+		}
+
+		// pipelined unique builds skip the same rows
+		pipelined := opts
+		pipelined.IndexBuildStats = nil
+		pipelined.UniqueIndexCheckWorkers = 2
+		rowData, err := BuildSecondaryProllyIndex(ctx, vrw, sch, uniq, primary, pipelined)
+		require.NoError(t, err)
+		require.Equal(t, expected, collectKeys(t, ctx, durable.ProllyMapFromIndex(rowData)))
+
+		// verification expects the rows to be skipped
+		opts.IndexBuildStats = nil
+		res, err := CreateIndex(ctx, newTestTable(t, ctx, vrw, sch, rows), "c2_idx", []string{"c2"}, false, true, "", opts)
+		require.NoError(t, err)
+		stored, err := res.NewTable.GetIndexRowData(ctx, "c2_idx")
+		require.NoError(t, err)
+		require.Equal(t, expected, collectKeys(t, ctx, durable.ProllyMapFromIndex(stored)))
+	})
+
+	t.Run("tolerant", func(t *testing.T) {
+		_, report, err := BuildSecondaryProllyIndexTolerant(ctx, vrw, sch, idx, primary, limit)
+		require.NoError(t, err)
+		require.Len(t, report.Skipped[SkipReasonOversized], 2)
+		require.Equal(t, "( 2 )", report.Skipped[SkipReasonOversized][0].PrimaryKey)
+		require.Empty(t, report.Skipped[SkipReasonEncode])
+	})
+
+	for _, bad := range []BuildOptions{
+		{KeyEncodingOptions: KeyEncodingOptions{MaxIndexFieldSize: -1}},
+		{KeyEncodingOptions: KeyEncodingOptions{MaxIndexFieldSize: 11, OversizedIndexFieldPolicy: 3}},
+	} {
+		_, err = BuildSecondaryProllyIndex(ctx, vrw, sch, idx, primary, bad)
+		require.Error(t, err, "%+v", bad)
+	}
+}
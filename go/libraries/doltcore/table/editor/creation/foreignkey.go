@@ -0,0 +1,268 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/dolthub/dolt/go/libraries/doltcore/doltdb"
+	"github.com/dolthub/dolt/go/libraries/doltcore/doltdb/durable"
+	"github.com/dolthub/dolt/go/libraries/doltcore/schema"
+	"github.com/dolthub/dolt/go/store/prolly"
+	"github.com/dolthub/dolt/go/store/types"
+	"github.com/dolthub/dolt/go/store/val"
+)
+
+// ForeignKeyIndexReturn is the result of CreateIndexForForeignKey.
+type ForeignKeyIndexReturn struct {
+	// NewChild and NewParent are the child and parent tables with the backing indexes of the foreign key. They are
+	// the same table for a self-referential foreign key.
+	NewChild  *doltdb.Table
+	NewParent *doltdb.Table
+	// ForeignKey is the foreign key with the names of its backing indexes.
+	ForeignKey doltdb.ForeignKey
+}
+
+// CreateIndexForForeignKey builds the backing indexes of the resolved foreign key |fk| of |childTbl|, which references
+// |parentTbl|, that do not exist yet, and checks that every row of |childTbl| references a row of |parentTbl|. An
+// index backs a side of |fk| if its leading columns are the columns of that side, in any order. For a
+// self-referential |fk|, |parentTbl| is ignored and both sides are indexes of |childTbl|.
+//
+// A missing parent index is built first, as the child rows are looked up in it. The child rows are then checked by a
+// scan of |childTbl|, before a missing child index is built. Like MySQL,
+// rows with a NULL in any column of |fk| are not checked. The first row that references no parent row is returned
+// as an ErrForeignKeyViolation.
+func CreateIndexForForeignKey(ctx context.Context, childTbl, parentTbl *doltdb.Table, fk doltdb.ForeignKey, opts BuildOptions) (*ForeignKeyIndexReturn, error) {
+	if !fk.IsResolved() {
+		return nil, fmt.Errorf("foreign key `%s` is not resolved", fk.Name)
+	}
+	if len(fk.TableColumns) != len(fk.ReferencedTableColumns) {
+		return nil, fmt.Errorf("foreign key `%s` has %d columns but references %d columns",
+			fk.Name, len(fk.TableColumns), len(fk.ReferencedTableColumns))
+	}
+	if !types.IsFormat_DOLT_1(childTbl.Format()) {
+		return nil, fmt.Errorf("foreign key index builds are not supported for format %s", childTbl.Format().VersionString())
+	}
+	if opts.IndexRowFilter != nil {
+		return nil, fmt.Errorf("foreign key `%s`: backing indexes cannot be partial", fk.Name)
+	}
+	if fk.IsSelfReferential() {
+		parentTbl = childTbl
+	}
+
+	parentSch, err := parentTbl.GetSchema(ctx)
+	if err != nil {
+		return nil, err
+	}
+	parentIdx, ok := foreignKeyBackingIndex(parentSch, fk.ReferencedTableIndex, fk.ReferencedTableColumns)
+	if !ok {
+		// the parent index is only unique if it indexes the whole primary key
+		ret, err := CreateIndexByTags(ctx, parentTbl, "", fk.ReferencedTableColumns,
+			sameTags(parentSch.GetPKCols().Tags, fk.ReferencedTableColumns), false, "", opts)
+		if err != nil {
+			return nil, err
+		}
+		parentTbl, parentIdx = ret.NewTable, ret.NewIndex
+		if fk.IsSelfReferential() {
+			childTbl = parentTbl
+		}
+	}
+	fk.ReferencedTableIndex = parentIdx.Name()
+
+	childSch, err := childTbl.GetSchema(ctx)
+	if err != nil {
+		return nil, err
+	}
+	childRows, err := childTbl.GetRowData(ctx)
+	if err != nil {
+		return nil, err
+	}
+	parentRows, err := parentTbl.GetIndexRowData(ctx, parentIdx.Name())
+	if err != nil {
+		return nil, err
+	}
+	chk, err := newForeignKeyChecker(childSch, durable.ProllyMapFromIndex(childRows), parentIdx, durable.ProllyMapFromIndex(parentRows), fk)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = chk.checkAll(ctx, durable.ProllyMapFromIndex(childRows)); err != nil {
+		return nil, err
+	}
+
+	childIdx, ok := foreignKeyBackingIndex(childSch, fk.TableIndex, fk.TableColumns)
+	if !ok {
+		ret, err := CreateIndexByTags(ctx, childTbl, "", fk.TableColumns, false, false, "", opts)
+		if err != nil {
+			return nil, err
+		}
+		childTbl, childIdx = ret.NewTable, ret.NewIndex
+		if fk.IsSelfReferential() {
+			parentTbl = childTbl
+		}
+	}
+	fk.TableIndex = childIdx.Name()
+
+	return &ForeignKeyIndexReturn{
+		NewChild:   childTbl,
+		NewParent:  parentTbl,
+		ForeignKey: fk,
+	}, nil
+}
+
+// foreignKeyBackingIndex returns the index of |sch| named |name|, or if |name| is empty, the index with the fewest
+// columns whose leading columns are |tags| in any order. Deferred indexes have no data, so they back no foreign keys.
+func foreignKeyBackingIndex(sch schema.Schema, name string, tags []uint64) (schema.Index, bool) {
+	if name != "" {
+		idx := sch.Indexes().GetByName(name)
+		if idx != nil && !idx.IsDeferred() && leadingTags(idx, tags) {
+			return idx, true
+		}
+		return nil, false
+	}
+	var best schema.Index
+	for _, idx := range sch.Indexes().AllIndexes() {
+		if idx.IsDeferred() || !leadingTags(idx, tags) {
+			continue
+		}
+		if best == nil || idx.Count() < best.Count() {
+			best = idx
+		}
+	}
+	return best, best != nil
+}
+
+// leadingTags returns whether the leading indexed columns of |idx| are |tags|, in any order.
+func leadingTags(idx schema.Index, tags []uint64) bool {
+	indexed := idx.IndexedColumnTags()
+	return len(indexed) >= len(tags) && sameTags(indexed[:len(tags)], tags)
+}
+
+// sameTags returns whether |a| and |b| hold the same tags, in any order.
+func sameTags(a, b []uint64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	seen := make(map[uint64]bool, len(a))
+	for _, t := range a {
+		seen[t] = true
+	}
+	for _, t := range b {
+		if !seen[t] {
+			return false
+		}
+	}
+	return true
+}
+
+// foreignKeyChecker looks up the rows of the child table of a foreign key in the backing index of its parent table.
+type foreignKeyChecker struct {
+	fk     doltdb.ForeignKey
+	parent prolly.Map
+	pkd    val.TupleDesc
+	pd     val.TupleDesc
+	pb     *val.TupleBuilder
+	pkLen  int
+	// fields maps the fields of the prefix of the parent index to the fields of a child row, where the fields of a
+	// row are its key fields followed by its value fields.
+	fields val.OrdinalMapping
+}
+
+func newForeignKeyChecker(childSch schema.Schema, child prolly.Map, parentIdx schema.Index, parent prolly.Map, fk doltdb.ForeignKey) (*foreignKeyChecker, error) {
+	kd, _ := parent.Descriptors()
+	pd := prefixDesc(kd, len(fk.TableColumns))
+	fields := make(val.OrdinalMapping, len(fk.TableColumns))
+	pkLen := childSch.GetPKCols().Size()
+	for to, parentTag := range parentIdx.IndexedColumnTags()[:len(fields)] {
+		var childTag uint64
+		for i, t := range fk.ReferencedTableColumns {
+			if t == parentTag {
+				childTag = fk.TableColumns[i]
+			}
+		}
+		j, ok := childSch.GetPKCols().TagToIdx[childTag]
+		if !ok {
+			j, ok = childSch.GetNonPKCols().TagToIdx[childTag]
+			if !ok {
+				return nil, fmt.Errorf("foreign key `%s` references column with tag %d which does not exist in table `%s`",
+					fk.Name, childTag, fk.TableName)
+			}
+			j += pkLen
+		}
+		fields[to] = j
+	}
+	pkd, _ := child.Descriptors()
+	return &foreignKeyChecker{
+		fk:     fk,
+		parent: parent,
+		pkd:    pkd,
+		pd:     pd,
+		pb:     val.NewTupleBuilder(pd),
+		pkLen:  pkLen,
+		fields: fields,
+	}, nil
+}
+
+// check returns an ErrForeignKeyViolation if the child row |k|, |v| has no NULL foreign key fields and references no
+// row of the parent table.
+func (c *foreignKeyChecker) check(ctx context.Context, k, v val.Tuple) error {
+	for to, from := range c.fields {
+		var f []byte
+		if from < c.pkLen {
+			f = k.GetField(from)
+		} else {
+			f = v.GetField(from - c.pkLen)
+		}
+		if f == nil {
+			return nil
+		}
+		c.pb.PutRaw(to, f)
+	}
+	itr, err := NewPrefixItr(ctx, c.pb.Build(c.parent.Pool()), c.pd, c.parent)
+	if err != nil {
+		return err
+	}
+	_, _, err = itr.Next(ctx)
+	if err == io.EOF {
+		return ErrForeignKeyViolation{
+			ForeignKey:  c.fk.Name,
+			ParentTable: c.fk.ReferencedTableName,
+			PrimaryKey:  c.pkd.Format(k),
+		}
+	}
+	return err
+}
+
+// checkAll checks every row of |child|.
+func (c *foreignKeyChecker) checkAll(ctx context.Context, child prolly.Map) error {
+	iter, err := child.IterAll(ctx)
+	if err != nil {
+		return err
+	}
+	for {
+		k, v, err := iter.Next(ctx)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err = c.check(ctx, k, v); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,133 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/dolthub/dolt/go/libraries/doltcore/doltdb"
+	"github.com/dolthub/dolt/go/libraries/doltcore/doltdb/durable"
+	"github.com/dolthub/dolt/go/libraries/doltcore/schema"
+	"github.com/dolthub/dolt/go/store/types"
+	"github.com/dolthub/dolt/go/store/val"
+)
+
+func TestCreateIndexForForeignKey(t *testing.T) {
+	ctx := context.Background()
+	vrw := newTestVRW()
+	sch := newTestSchema(t)
+	const idTag, nameTag = 100, 101
+	parentSch, err := schema.SchemaFromCols(schema.NewColCollection(
+		schema.NewColumn("id", idTag, types.IntKind, true, schema.NotNullConstraint{}),
+		schema.NewColumn("name", nameTag, types.StringKind, false),
+	))
+	require.NoError(t, err)
+	parent := newTestTable(t, ctx, vrw, parentSch, [][]interface{}{{1, "a"}, {2, "b"}, {3, "c"}})
+	fk := doltdb.ForeignKey{
+		Name:                   "fk",
+		TableName:              "child",
+		TableColumns:           []uint64{c1Tag},
+		ReferencedTableName:    "parent",
+		ReferencedTableColumns: []uint64{idTag},
+	}
+
+	// requireBuilt checks that |tbl| has the data of its index |name|
+	requireBuilt := func(t *testing.T, tbl *doltdb.Table, name string) {
+		sch, err := tbl.GetSchema(ctx)
+		require.NoError(t, err)
+		expected, err := BuildSecondaryIndex(ctx, tbl, sch.Indexes().GetByName(name), BuildOptions{})
+		require.NoError(t, err)
+		actual, err := tbl.GetIndexRowData(ctx, name)
+		require.NoError(t, err)
+		assert.Equal(t, durable.ProllyMapFromIndex(expected).HashOf(), durable.ProllyMapFromIndex(actual).HashOf())
+	}
+
+	t.Run("missing indexes", func(t *testing.T) {
+		child := newTestTable(t, ctx, vrw, sch, [][]interface{}{{1, 1, "x"}, {2, 3, "y"}, {3, nil, "z"}, {4, 1, nil}})
+		ret, err := CreateIndexForForeignKey(ctx, child, parent, fk, BuildOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, "c1", ret.ForeignKey.TableIndex)
+		assert.Equal(t, "id", ret.ForeignKey.ReferencedTableIndex)
+		requireBuilt(t, ret.NewChild, "c1")
+		requireBuilt(t, ret.NewParent, "id")
+
+		// the parent index covers the whole primary key, so it is unique
+		parentSch, err := ret.NewParent.GetSchema(ctx)
+		require.NoError(t, err)
+		assert.True(t, parentSch.Indexes().GetByName("id").IsUnique())
+
+		// the backing indexes are found on both sides the second time
+		again, err := CreateIndexForForeignKey(ctx, ret.NewChild, ret.NewParent, fk, BuildOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, ret.ForeignKey, again.ForeignKey)
+		assert.Equal(t, ret.NewChild, again.NewChild)
+		assert.Equal(t, ret.NewParent, again.NewParent)
+	})
+
+	t.Run("orphan child rows", func(t *testing.T) {
+		child := newTestTable(t, ctx, vrw, sch, [][]interface{}{{1, 1, "x"}, {2, 4, "y"}, {3, 5, "z"}})
+		_, err := CreateIndexForForeignKey(ctx, child, parent, fk, BuildOptions{})
+		var violation ErrForeignKeyViolation
+		require.ErrorAs(t, err, &violation)
+		assert.Equal(t, ErrForeignKeyViolation{ForeignKey: "fk", ParentTable: "parent", PrimaryKey: "( 2 )"}, violation)
+
+		// an existing child index is reused, and the child rows are still checked
+		ret, err := CreateIndexByTags(ctx, child, "by_c1", []uint64{c1Tag, c2Tag}, false, true, "", BuildOptions{})
+		require.NoError(t, err)
+		_, err = CreateIndexForForeignKey(ctx, ret.NewTable, parent, fk, BuildOptions{})
+		require.ErrorAs(t, err, &violation)
+
+		child = newTestTable(t, ctx, vrw, sch, [][]interface{}{{1, 1, "x"}, {2, 2, "y"}})
+		ret, err = CreateIndexByTags(ctx, child, "by_c1", []uint64{c1Tag, c2Tag}, false, true, "", BuildOptions{})
+		require.NoError(t, err)
+		fkRet, err := CreateIndexForForeignKey(ctx, ret.NewTable, parent, fk, BuildOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, "by_c1", fkRet.ForeignKey.TableIndex)
+	})
+
+	t.Run("self-referential", func(t *testing.T) {
+		selfFk := doltdb.ForeignKey{
+			Name:                   "parent_fk",
+			TableName:              "tree",
+			TableColumns:           []uint64{c1Tag},
+			ReferencedTableName:    "tree",
+			ReferencedTableColumns: []uint64{pkTag},
+		}
+		tree := newTestTable(t, ctx, vrw, sch, [][]interface{}{{1, nil, "root"}, {2, 1, "a"}, {3, 2, "b"}})
+		ret, err := CreateIndexForForeignKey(ctx, tree, tree, selfFk, BuildOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, ret.NewChild, ret.NewParent)
+		requireBuilt(t, ret.NewChild, "c1")
+		requireBuilt(t, ret.NewChild, "pk")
+
+		tree = newTestTable(t, ctx, vrw, sch, [][]interface{}{{1, nil, "root"}, {2, 7, "a"}})
+		_, err = CreateIndexForForeignKey(ctx, tree, tree, selfFk, BuildOptions{})
+		require.ErrorAs(t, err, &ErrForeignKeyViolation{})
+	})
+
+	child := newTestTable(t, ctx, vrw, sch, nil)
+	_, err = CreateIndexForForeignKey(ctx, child, parent, doltdb.ForeignKey{Name: "unresolved"}, BuildOptions{})
+	require.Error(t, err)
+	_, err = CreateIndexForForeignKey(ctx, child, parent, fk, BuildOptions{
+		ExecutionOptions: ExecutionOptions{
+			IndexRowFilter: func(context.Context, val.Tuple, val.Tuple) (bool, error) { return true, nil },
+		},
+	})
+	require.Error(t, err)
+}
@@ -0,0 +1,210 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/dolthub/dolt/go/libraries/doltcore/doltdb/durable"
+	"github.com/dolthub/dolt/go/libraries/doltcore/schema"
+	"github.com/dolthub/dolt/go/store/prolly"
+	"github.com/dolthub/dolt/go/store/prolly/shim"
+	"github.com/dolthub/dolt/go/store/val"
+)
+
+// geohashAlphabet is the base32 alphabet of geohashes. Its characters are in
+// ascending byte order, so geohashes sort like the cells they name.
+const geohashAlphabet = "0123456789bcdefghjkmnpqrstuvwxyz"
+
+// maxGeohashPrecision is the longest geohash an index key can be prefixed
+// with. A cell of 12 characters is a few centimeters wide.
+const maxGeohashPrecision = 12
+
+// EncodeGeohash returns the geohash of |precision| characters of the location
+// at latitude |lat| and longitude |lon|, in degrees. Each character halves the
+// cell of the previous one five times, alternating between longitude and
+// latitude, so the geohashes of nearby locations usually share a prefix.
+func EncodeGeohash(lat, lon float64, precision int) string {
+	latLo, latHi := -90.0, 90.0
+	lonLo, lonHi := -180.0, 180.0
+	var sb strings.Builder
+	sb.Grow(precision)
+	even := true
+	for sb.Len() < precision {
+		var c int
+		for bit := 0; bit < 5; bit++ {
+			c <<= 1
+			if even {
+				mid := (lonLo + lonHi) / 2
+				if lon >= mid {
+					c |= 1
+					lonLo = mid
+				} else {
+					lonHi = mid
+				}
+			} else {
+				mid := (latLo + latHi) / 2
+				if lat >= mid {
+					c |= 1
+					latLo = mid
+				} else {
+					latHi = mid
+				}
+			}
+			even = !even
+		}
+		sb.WriteByte(geohashAlphabet[c])
+	}
+	return sb.String()
+}
+
+// geohashing computes the geohash that prefixes the keys of an index built
+// with BuildOptions.IndexGeohashPrecision.
+type geohashing struct {
+	precision int
+	// lat and lon are the fields of the primary row holding the location,
+	// numbered like the fields of a val.OrdinalMapping from GetIndexKeyMapping
+	lat, lon int
+	pkLen    int
+	pkd      val.TupleDesc
+	pvd      val.TupleDesc
+}
+
+// newGeohashing returns the geohashing of the keys of |idx|, or nil if the
+// build has no geohash precision. |keyMap| is the key mapping of |idx|.
+func newGeohashing(sch schema.Schema, idx schema.Index, keyMap val.OrdinalMapping, opts BuildOptions) (*geohashing, error) {
+	if opts.IndexGeohashPrecision == 0 {
+		return nil, nil
+	}
+	if err := validateGeohash(idx, opts); err != nil {
+		return nil, err
+	}
+	if idx.Count() < 2 {
+		return nil, fmt.Errorf("index `%s`: geohash indexes must index a latitude and a longitude column", idx.Name())
+	}
+	pkd, pvd := shim.MapDescriptorsFromSchema(sch)
+	g := &geohashing{
+		precision: opts.IndexGeohashPrecision,
+		lat:       keyMap.MapOrdinal(0),
+		lon:       keyMap.MapOrdinal(1),
+		pkLen:     sch.GetPKCols().Size(),
+		pkd:       pkd,
+		pvd:       pvd,
+	}
+	for i, from := range []int{g.lat, g.lon} {
+		if enc := g.desc(from).Types[g.field(from)].Enc; enc != val.Float32Enc && enc != val.Float64Enc {
+			col := idx.ColumnNames()[i]
+			return nil, fmt.Errorf("index `%s`: geohash column `%s` is not a floating point column", idx.Name(), col)
+		}
+	}
+	return g, nil
+}
+
+// validateGeohash returns an error if |idx| cannot be built with the geohash
+// precision of |opts|.
+func validateGeohash(idx schema.Index, opts BuildOptions) error {
+	if idx.IsUnique() {
+		return fmt.Errorf("index `%s`: geohash indexes cannot be unique", idx.Name())
+	}
+	if idx.TimeBucket() != 0 {
+		return fmt.Errorf("index `%s`: geohash indexes cannot have a time bucket", idx.Name())
+	}
+	return nil
+}
+
+func (g *geohashing) desc(from int) val.TupleDesc {
+	if from < g.pkLen {
+		return g.pkd
+	}
+	return g.pvd
+}
+
+func (g *geohashing) field(from int) int {
+	if from < g.pkLen {
+		return from
+	}
+	return from - g.pkLen
+}
+
+func (g *geohashing) degrees(from int, k, v val.Tuple) (float64, bool) {
+	tup := v
+	if from < g.pkLen {
+		tup = k
+	}
+	desc, i := g.desc(from), g.field(from)
+	if desc.Types[i].Enc == val.Float32Enc {
+		f, ok := desc.GetFloat32(i, tup)
+		return float64(f), ok
+	}
+	return desc.GetFloat64(i, tup)
+}
+
+// put writes the geohash of the location of the primary row |k|, |v| to the
+// first field of |kb|. The geohash of a location with a NULL coordinate is
+// NULL.
+func (g *geohashing) put(kb *val.TupleBuilder, k, v val.Tuple) {
+	lat, ok := g.degrees(g.lat, k, v)
+	if !ok {
+		kb.PutRaw(0, nil)
+		return
+	}
+	lon, ok := g.degrees(g.lon, k, v)
+	if !ok {
+		kb.PutRaw(0, nil)
+		return
+	}
+	kb.PutString(0, EncodeGeohash(lat, lon, g.precision))
+}
+
+// geohashKeyDesc returns the key descriptor of a geohash index whose keys are
+// otherwise described by |kd|.
+func geohashKeyDesc(kd val.TupleDesc) val.TupleDesc {
+	types := make([]val.Type, 0, kd.Count()+1)
+	types = append(types, val.Type{Enc: val.StringEnc, Nullable: true})
+	types = append(types, kd.Types...)
+	return val.NewTupleDescriptor(types...)
+}
+
+// IterIndexGeohashPrefix returns an iterator over the entries of |rows|, index
+// data built with BuildOptions.IndexGeohashPrecision, whose locations have a
+// geohash starting with |prefix|. As the entries of a geohash cell are stored
+// together, this is a single range scan. The cell of a prefix |p| characters
+// long is about as wide as the cells of an index built with a precision of |p|.
+func IterIndexGeohashPrefix(ctx context.Context, rows durable.Index, prefix string) (prolly.MapIter, error) {
+	if len(prefix) == 0 || len(prefix) > maxGeohashPrecision {
+		return nil, fmt.Errorf("invalid geohash prefix '%s'", prefix)
+	}
+	for i := 0; i < len(prefix); i++ {
+		if strings.IndexByte(geohashAlphabet, prefix[i]) < 0 {
+			return nil, fmt.Errorf("invalid geohash prefix '%s'", prefix)
+		}
+	}
+
+	m := durable.ProllyMapFromIndex(rows)
+	kd, _ := m.Descriptors()
+	if kd.Count() == 0 || kd.Types[0].Enc != val.StringEnc {
+		return nil, fmt.Errorf("index data is not keyed by a geohash")
+	}
+	pd := prefixDesc(kd, 1)
+	start := val.NewTupleBuilder(pd)
+	start.PutString(0, prefix)
+	// the geohashes starting with |prefix| are less than |prefix| with its last
+	// character incremented, which need not be a geohash character
+	stop := val.NewTupleBuilder(pd)
+	stop.PutString(0, prefix[:len(prefix)-1]+string(prefix[len(prefix)-1]+1))
+	return m.IterRange(ctx, prolly.OpenStopRange(start.Build(m.Pool()), stop.Build(m.Pool()), pd))
+}
@@ -0,0 +1,139 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/dolthub/dolt/go/libraries/doltcore/doltdb/durable"
+	"github.com/dolthub/dolt/go/libraries/doltcore/schema"
+	"github.com/dolthub/dolt/go/store/types"
+)
+
+func TestEncodeGeohash(t *testing.T) {
+	require.Equal(t, "u4pruydqqvj", EncodeGeohash(57.64911, 10.40744, 11))
+	require.Equal(t, "9q8yy", EncodeGeohash(37.7749, -122.4194, 5))
+	require.Equal(t, "s", EncodeGeohash(0, 0, 1))
+	for p := 1; p <= maxGeohashPrecision; p++ {
+		require.True(t, strings.HasPrefix(EncodeGeohash(40.7128, -74.0060, maxGeohashPrecision), EncodeGeohash(40.7128, -74.0060, p)))
+	}
+}
+
+func TestGeohashIndex(t *testing.T) {
+	ctx := context.Background()
+	vrw := newTestVRW()
+	sch, err := schema.SchemaFromCols(schema.NewColCollection(
+		schema.NewColumn("pk", 1, types.IntKind, true, schema.NotNullConstraint{}),
+		schema.NewColumn("lat", 2, types.FloatKind, false),
+		schema.NewColumn("lon", 3, types.FloatKind, false),
+		schema.NewColumn("name", 4, types.StringKind, false),
+	))
+	require.NoError(t, err)
+
+	// points a few hundred meters apart in San Francisco and in New York,
+	// interleaved by primary key, and a point without a location
+	var rows [][]interface{}
+	for i := 0; i < 20; i++ {
+		d := float64(i) * 0.0002
+		rows = append(rows,
+			[]interface{}{2 * i, 37.7749 + d, -122.4194 + d, "sf"},
+			[]interface{}{2*i + 1, 40.7128 + d, -74.0060 + d, "nyc"})
+	}
+	rows = append(rows, []interface{}{40, nil, -74.0060, "nowhere"})
+	primary := newTestPrimary(t, ctx, vrw, sch, rows)
+
+	coll := schema.NewIndexCollection(sch.GetAllCols(), sch.GetPKCols())
+	idx, err := coll.AddIndexByColNames("loc", []string{"lat", "lon"}, schema.IndexProperties{})
+	require.NoError(t, err)
+	opts := BuildOptions{KeyEncodingOptions: KeyEncodingOptions{IndexGeohashPrecision: 6}}
+	rowData, err := BuildSecondaryProllyIndex(ctx, vrw, sch, idx, primary, opts)
+	require.NoError(t, err)
+	require.Equal(t, uint64(len(rows)), rowData.Count())
+
+	// nearby points share geohash prefixes, so the points of a city are stored
+	// together, followed by the point without a location
+	m := durable.ProllyMapFromIndex(rowData)
+	kd, _ := m.Descriptors()
+	iter, err := m.IterAll(ctx)
+	require.NoError(t, err)
+	var pks []int64
+	var hashes []string
+	for {
+		k, _, err := iter.Next(ctx)
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		pk, _ := kd.GetInt64(3, k)
+		pks = append(pks, pk)
+		h, ok := kd.GetString(0, k)
+		if ok {
+			require.Len(t, h, 6)
+			hashes = append(hashes, h)
+		}
+	}
+	require.Len(t, hashes, 40)
+	for i := 0; i < 20; i++ {
+		require.True(t, strings.HasPrefix(hashes[i], "9q8yy"), hashes[i])
+		require.Equal(t, int64(0), pks[i]%2)
+		require.True(t, strings.HasPrefix(hashes[20+i], "dr5r"), hashes[20+i])
+		require.Equal(t, int64(1), pks[20+i]%2)
+	}
+	require.Equal(t, int64(40), pks[40])
+
+	// a proximity query is a prefix scan of the geohash of its location
+	near := func(lat, lon float64, precision int) (pks []int64) {
+		iter, err := IterIndexGeohashPrefix(ctx, rowData, EncodeGeohash(lat, lon, precision))
+		require.NoError(t, err)
+		for {
+			k, _, err := iter.Next(ctx)
+			if err == io.EOF {
+				return pks
+			}
+			require.NoError(t, err)
+			pk, _ := kd.GetInt64(3, k)
+			pks = append(pks, pk)
+		}
+	}
+	sf := near(37.7760, -122.4180, 5)
+	require.Len(t, sf, 20)
+	for _, pk := range sf {
+		require.Equal(t, int64(0), pk%2)
+	}
+	require.Len(t, near(40.7130, -74.0055, 4), 20)
+	require.Empty(t, near(51.5074, -0.1278, 3))
+	_, err = IterIndexGeohashPrefix(ctx, rowData, "9qa")
+	require.Error(t, err)
+
+	// a location needs two floating point columns, and geohash indexes are
+	// neither unique nor stored in tables
+	byName, err := coll.AddIndexByColNames("name_lat", []string{"name", "lat"}, schema.IndexProperties{})
+	require.NoError(t, err)
+	_, err = BuildSecondaryProllyIndex(ctx, vrw, sch, byName, primary, opts)
+	require.Error(t, err)
+	uniq, err := coll.AddIndexByColNames("loc_uniq", []string{"lat", "lon"}, schema.IndexProperties{IsUnique: true})
+	require.NoError(t, err)
+	_, err = BuildSecondaryProllyIndex(ctx, vrw, sch, uniq, primary, opts)
+	require.Error(t, err)
+	_, err = BuildSecondaryProllyIndex(ctx, vrw, sch, idx, primary, BuildOptions{KeyEncodingOptions: KeyEncodingOptions{IndexGeohashPrecision: 13}})
+	require.Error(t, err)
+	_, err = CreateIndex(ctx, newTestTable(t, ctx, vrw, sch, rows), "loc", []string{"lat", "lon"}, false, true, "", opts)
+	require.Error(t, err)
+}
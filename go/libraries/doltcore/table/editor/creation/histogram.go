@@ -0,0 +1,88 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/dolthub/dolt/go/libraries/doltcore/doltdb/durable"
+	"github.com/dolthub/dolt/go/libraries/doltcore/schema"
+	"github.com/dolthub/dolt/go/store/prolly"
+	"github.com/dolthub/dolt/go/store/types"
+	"github.com/dolthub/dolt/go/store/val"
+)
+
+// BuildIndexHistogram builds an equi-depth histogram of at most |numBuckets|
+// buckets of the indexed values of the index |idx| with the data |rows|. The
+// entries of the index are sorted, so a walk of its leaves closes a bucket
+// once it holds its share of the entries, at the end of the entries of its
+// last value. A value with more entries than a bucket's share gets a bucket
+// of its own, so the histogram may have fewer buckets than |numBuckets|.
+func BuildIndexHistogram(ctx context.Context, idx schema.Index, rows durable.Index, numBuckets int) (IndexHistogram, error) {
+	if !types.IsFormat_DOLT_1(rows.Format()) {
+		return IndexHistogram{}, fmt.Errorf("index histograms are not supported for format %s", rows.Format().VersionString())
+	}
+	if numBuckets <= 0 {
+		return IndexHistogram{}, fmt.Errorf("index `%s`: invalid histogram of %d buckets", idx.Name(), numBuckets)
+	}
+	return indexHistogram(ctx, idx, durable.ProllyMapFromIndex(rows), numBuckets)
+}
+
+func indexHistogram(ctx context.Context, idx schema.Index, m prolly.Map, numBuckets int) (IndexHistogram, error) {
+	kd, _ := m.Descriptors()
+	n := idx.Count()
+	h := IndexHistogram{Desc: prefixDesc(kd, n), Entries: uint64(m.Count())}
+	// each bucket holds at least its share of the entries, rounded up
+	share := (h.Entries + uint64(numBuckets) - 1) / uint64(numBuckets)
+	pb := val.NewTupleBuilder(h.Desc)
+
+	iter, err := m.IterAll(ctx)
+	if err != nil {
+		return IndexHistogram{}, err
+	}
+	var bucket IndexHistogramBucket
+	var prev val.Tuple
+	// closeBucket appends the current bucket, whose last entry is |prev|
+	closeBucket := func() {
+		for i := 0; i < n; i++ {
+			pb.PutRaw(i, prev.GetField(i))
+		}
+		bucket.UpperBound = pb.BuildPermissive(m.Pool())
+		h.Buckets = append(h.Buckets, bucket)
+		bucket = IndexHistogramBucket{}
+	}
+	for {
+		k, _, err := iter.Next(ctx)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return IndexHistogram{}, err
+		}
+		if prev == nil || !samePrefix(prev, k, n) {
+			if bucket.Count >= share {
+				closeBucket()
+			}
+			bucket.Distinct++
+		}
+		bucket.Count++
+		prev = k
+	}
+	if bucket.Count > 0 {
+		closeBucket()
+	}
+	return h, nil
+}
@@ -0,0 +1,100 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/dolthub/dolt/go/libraries/doltcore/schema"
+)
+
+func TestBuildIndexHistogram(t *testing.T) {
+	ctx := context.Background()
+	vrw := newTestVRW()
+	sch := newTestSchema(t)
+	var rows [][]interface{}
+	for i := 0; i < 1000; i++ {
+		switch {
+		case i%4 == 0:
+			// a quarter of the rows share a value
+			rows = append(rows, []interface{}{i, 7, "row"})
+		case i%25 == 1:
+			rows = append(rows, []interface{}{i, nil, "row"})
+		default:
+			rows = append(rows, []interface{}{i, i % 97, "row"})
+		}
+	}
+	primary := newTestPrimary(t, ctx, vrw, sch, rows)
+	idx, err := schema.NewIndexCollection(sch.GetAllCols(), sch.GetPKCols()).AddIndexByColNames(
+		"c1_idx", []string{"c1"}, schema.IndexProperties{})
+	require.NoError(t, err)
+	built, err := BuildSecondaryProllyIndex(ctx, vrw, sch, idx, primary, BuildOptions{})
+	require.NoError(t, err)
+	card, err := ComputeIndexCardinality(ctx, idx, built)
+	require.NoError(t, err)
+
+	for _, numBuckets := range []int{1, 4, 10, 2000} {
+		h, err := BuildIndexHistogram(ctx, idx, built, numBuckets)
+		require.NoError(t, err)
+		require.Equal(t, uint64(len(rows)), h.Entries)
+		require.LessOrEqual(t, len(h.Buckets), numBuckets)
+
+		// bucket counts sum to the total and boundaries are monotonic
+		var entries, distinct uint64
+		for i, b := range h.Buckets {
+			entries += b.Count
+			distinct += b.Distinct
+			require.NotZero(t, b.Count)
+			if i > 0 {
+				require.Less(t, h.Desc.Compare(h.Buckets[i-1].UpperBound, b.UpperBound), 0)
+			}
+		}
+		require.Equal(t, h.Entries, entries)
+		require.Equal(t, card.Distinct, distinct)
+
+		// NULLs sort last, so they bound the last bucket
+		_, ok := h.Desc.GetInt64(0, h.Buckets[len(h.Buckets)-1].UpperBound)
+		require.False(t, ok)
+	}
+
+	// the entries of the shared value are all in one bucket
+	h, err := BuildIndexHistogram(ctx, idx, built, 10)
+	require.NoError(t, err)
+	for _, b := range h.Buckets {
+		if v, ok := h.Desc.GetInt64(0, b.UpperBound); ok && v == 7 {
+			require.GreaterOrEqual(t, b.Count, uint64(250))
+		}
+	}
+
+	// builds report the histogram of the index they build
+	var stats IndexBuildStats
+	_, err = BuildSecondaryProllyIndex(ctx, vrw, sch, idx, primary, BuildOptions{ExecutionOptions: ExecutionOptions{IndexBuildStats: &stats, IndexHistogramBuckets: 10}})
+	require.NoError(t, err)
+	require.NotNil(t, stats.Histogram)
+	require.Equal(t, h, *stats.Histogram)
+
+	empty, err := BuildSecondaryProllyIndex(ctx, vrw, sch, idx, newTestPrimary(t, ctx, vrw, sch, nil), BuildOptions{})
+	require.NoError(t, err)
+	h, err = BuildIndexHistogram(ctx, idx, empty, 10)
+	require.NoError(t, err)
+	require.Empty(t, h.Buckets)
+	require.Zero(t, h.Entries)
+
+	_, err = BuildIndexHistogram(ctx, idx, built, 0)
+	require.Error(t, err)
+}
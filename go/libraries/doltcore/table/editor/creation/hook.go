@@ -0,0 +1,50 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"context"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/dolthub/dolt/go/libraries/doltcore/doltdb/durable"
+)
+
+// withHookStats returns |opts| with IndexBuildStats set, if it has an
+// IndexBuildHook, so that the statistics of the build can be passed to it.
+func withHookStats(opts BuildOptions) BuildOptions {
+	if opts.IndexBuildHook != nil && opts.IndexBuildStats == nil {
+		opts.IndexBuildStats = &IndexBuildStats{}
+	}
+	return opts
+}
+
+// callBuildHook calls the IndexBuildHook of |opts|, if any, with the data
+// |rows| of the index |indexName|, built with withHookStats(|opts|).
+func callBuildHook(ctx context.Context, indexName string, rows durable.Index, opts BuildOptions) error {
+	if opts.IndexBuildHook == nil {
+		return nil
+	}
+	var stats IndexBuildStats
+	if opts.IndexBuildStats != nil {
+		stats = *opts.IndexBuildStats
+	}
+	err := opts.IndexBuildHook(ctx, indexName, rows, stats)
+	if err != nil && opts.LogIndexBuildHookErrors {
+		logrus.Warnf("index build hook of index `%s` failed: %s", indexName, err)
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,87 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/dolthub/dolt/go/libraries/doltcore/doltdb/durable"
+	"github.com/dolthub/dolt/go/libraries/doltcore/schema"
+)
+
+func TestIndexBuildHook(t *testing.T) {
+	ctx := context.Background()
+	vrw := newTestVRW()
+	sch := newTestSchema(t)
+	tbl := newTestTable(t, ctx, vrw, sch, [][]interface{}{
+		{1, 20, "a"},
+		{2, 10, nil},
+		{3, 30, "c"},
+	})
+
+	type call struct {
+		name  string
+		rows  durable.Index
+		stats IndexBuildStats
+	}
+	var calls []call
+	opts := BuildOptions{
+		ExecutionOptions: ExecutionOptions{
+			IndexBuildHook: func(ctx context.Context, indexName string, rows durable.Index, stats IndexBuildStats) error {
+				calls = append(calls, call{name: indexName, rows: rows, stats: stats})
+				return nil
+			},
+		},
+	}
+
+	ret, err := CreateIndex(ctx, tbl, "c2_idx", []string{"c2"}, false, true, "", opts)
+	require.NoError(t, err)
+	require.Len(t, calls, 1)
+	require.Equal(t, "c2_idx", calls[0].name)
+	stored, err := ret.NewTable.GetIndexRowData(ctx, "c2_idx")
+	require.NoError(t, err)
+	requireSameIndex(t, stored, calls[0].rows)
+	require.Equal(t, uint64(3), calls[0].stats.RowsIndexed)
+	require.Equal(t, map[string]uint64{"c2": 1}, calls[0].stats.NullCounts)
+
+	// deferred indexes are not built until they are materialized
+	ret, err = CreateIndexWithProperties(ctx, ret.NewTable, "c1_idx", []uint64{c1Tag}, schema.IndexProperties{
+		IsUserDefined: true,
+		IsDeferred:    true,
+	}, opts)
+	require.NoError(t, err)
+	require.Len(t, calls, 1)
+	tbl, err = MaterializeDeferredIndex(ctx, ret.NewTable, "c1_idx", opts)
+	require.NoError(t, err)
+	require.Len(t, calls, 2)
+	require.Equal(t, "c1_idx", calls[1].name)
+	require.Equal(t, uint64(3), calls[1].stats.RowsScanned)
+
+	// hook errors fail the index creation unless they are logged
+	hookErr := errors.New("cache is down")
+	opts.IndexBuildHook = func(ctx context.Context, indexName string, rows durable.Index, stats IndexBuildStats) error {
+		return hookErr
+	}
+	_, err = CreateIndex(ctx, tbl, "c1_c2_idx", []string{"c1", "c2"}, false, true, "", opts)
+	require.ErrorIs(t, err, hookErr)
+	opts.LogIndexBuildHookErrors = true
+	ret, err = CreateIndex(ctx, tbl, "c1_c2_idx", []string{"c1", "c2"}, false, true, "", opts)
+	require.NoError(t, err)
+	require.NotNil(t, ret.NewIndex)
+}
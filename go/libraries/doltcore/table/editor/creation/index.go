@@ -15,11 +15,14 @@
 package creation
 
 import (
-	"bytes"
 	"context"
+	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
+	"math"
 	"strings"
+	"time"
 
 	"github.com/dolthub/go-mysql-server/sql"
 
@@ -27,8 +30,11 @@ import (
 	"github.com/dolthub/dolt/go/libraries/doltcore/doltdb/durable"
 	"github.com/dolthub/dolt/go/libraries/doltcore/schema"
 	"github.com/dolthub/dolt/go/libraries/doltcore/table/editor"
+	"github.com/dolthub/dolt/go/store/hash"
+	"github.com/dolthub/dolt/go/store/pool"
 	"github.com/dolthub/dolt/go/store/prolly"
 	"github.com/dolthub/dolt/go/store/prolly/shim"
+	"github.com/dolthub/dolt/go/store/prolly/tree"
 	"github.com/dolthub/dolt/go/store/types"
 	"github.com/dolthub/dolt/go/store/val"
 )
@@ -38,6 +44,15 @@ type CreateIndexReturn struct {
 	Sch      schema.Schema
 	OldIndex schema.Index
 	NewIndex schema.Index
+	// ContentHash is the IndexContentHash of the data of NewIndex.
+	ContentHash hash.Hash
+}
+
+// IndexContentHash returns the hash of the index data |rows|, the root hash of its tree. Index builds are
+// deterministic, so equal index data has equal hashes however, and whenever, it was built, and callers can compare
+// the hashes of an index across commits to detect whether it changed.
+func IndexContentHash(rows durable.Index) (hash.Hash, error) {
+	return rows.HashOf()
 }
 
 // CreateIndex creates the given index on the given table with the given schema. Returns the updated table, updated schema, and created index.
@@ -49,22 +64,86 @@ func CreateIndex(
 	isUnique bool,
 	isUserDefined bool,
 	comment string,
-	opts editor.Options,
+	opts BuildOptions,
 ) (*CreateIndexReturn, error) {
 	sch, err := table.GetSchema(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	// get the real column names as CREATE INDEX columns are case-insensitive
-	var realColNames []string
-	allTableCols := sch.GetAllCols()
-	for _, indexCol := range columns {
-		tableCol, ok := allTableCols.GetByNameCaseInsensitive(indexCol)
+	realColNames, err := resolveColumnNames(sch, columns)
+	if err != nil {
+		return nil, err
+	}
+	tags := make([]uint64, len(realColNames))
+	for i, name := range realColNames {
+		col, _ := sch.GetAllCols().GetByName(name)
+		tags[i] = col.Tag
+	}
+
+	return CreateIndexByTags(ctx, table, indexName, tags, isUnique, isUserDefined, comment, opts)
+}
+
+// CreateIndexByTags creates an index over the columns with the given |tags|, in index order, as CreateIndex does.
+// It is intended for callers that have already resolved the index columns, and skips column name resolution.
+func CreateIndexByTags(
+	ctx context.Context,
+	table *doltdb.Table,
+	indexName string,
+	tags []uint64,
+	isUnique bool,
+	isUserDefined bool,
+	comment string,
+	opts BuildOptions,
+) (*CreateIndexReturn, error) {
+	return CreateIndexWithProperties(ctx, table, indexName, tags, schema.IndexProperties{
+		IsUnique:      isUnique,
+		IsUserDefined: isUserDefined,
+		Comment:       comment,
+	}, opts)
+}
+
+// CreateIndexWithProperties creates an index over the columns with the given |tags|, in index order, with the
+// index properties |props|.
+func CreateIndexWithProperties(
+	ctx context.Context,
+	table *doltdb.Table,
+	indexName string,
+	tags []uint64,
+	props schema.IndexProperties,
+	opts BuildOptions,
+) (*CreateIndexReturn, error) {
+	if props.IsDeferred && props.IsUnique {
+		return nil, fmt.Errorf("index `%s`: unique indexes cannot be deferred", indexName)
+	}
+	if err := opts.Validate(true); err != nil {
+		return nil, fmt.Errorf("index `%s`: %w", indexName, err)
+	}
+	if props.TimeBucket != 0 {
+		return nil, fmt.Errorf("index `%s`: time bucketed indexes cannot be stored in a table", indexName)
+	}
+	if props.ReverseStrings {
+		return nil, fmt.Errorf("index `%s`: reversed string indexes cannot be stored in a table", indexName)
+	}
+	if props.Normalization != "" {
+		return nil, fmt.Errorf("index `%s`: normalized indexes cannot be stored in a table", indexName)
+	}
+	if props.SamplePercent != 0 {
+		return nil, fmt.Errorf("index `%s`: sampled indexes cannot be stored in a table", indexName)
+	}
+
+	sch, err := table.GetSchema(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	realColNames := make([]string, len(tags))
+	for i, tag := range tags {
+		col, ok := sch.GetAllCols().GetByTag(tag)
 		if !ok {
-			return nil, fmt.Errorf("column `%s` does not exist for the table", indexCol)
+			return nil, fmt.Errorf("column with tag %d does not exist for the table", tag)
 		}
-		realColNames = append(realColNames, tableCol.Name)
+		realColNames[i] = col.Name
 	}
 
 	if indexName == "" {
@@ -81,8 +160,12 @@ func CreateIndex(
 		return nil, fmt.Errorf("invalid index name `%s` as they must match the regular expression %s", indexName, doltdb.IndexNameRegexStr)
 	}
 
+	if opts.RejectRedundantIndexes && IsRedundantWithPrimaryKey(sch, tags, props) {
+		return nil, fmt.Errorf("index `%s` is redundant with the primary key, whose prefix (%s) it indexes", indexName, strings.Join(realColNames, ","))
+	}
+
 	// if an index was already created for the column set but was not generated by the user then we replace it
-	existingIndex, ok := sch.Indexes().GetIndexByColumnNames(realColNames...)
+	existingIndex, ok := sch.Indexes().GetIndexByTags(tags...)
 	if ok && !existingIndex.IsUserDefined() {
 		_, err = sch.Indexes().RemoveIndex(existingIndex.Name())
 		if err != nil {
@@ -95,15 +178,7 @@ func CreateIndex(
 	}
 
 	// create the index metadata, will error if index names are taken or an index with the same columns in the same order exists
-	index, err := sch.Indexes().AddIndexByColNames(
-		indexName,
-		realColNames,
-		schema.IndexProperties{
-			IsUnique:      isUnique,
-			IsUserDefined: isUserDefined,
-			Comment:       comment,
-		},
-	)
+	index, err := sch.Indexes().AddIndexByColTags(indexName, tags, props)
 	if err != nil {
 		return nil, err
 	}
@@ -114,30 +189,125 @@ func CreateIndex(
 		return nil, err
 	}
 
-	// TODO: in the case that we're replacing an implicit index with one the user specified, we could do this more
-	//  cheaply in some cases by just renaming it, rather than building it from scratch. But that's harder to get right.
-	indexRows, err := BuildSecondaryIndex(ctx, newTable, index, opts)
-	if err != nil {
-		return nil, err
+	var indexRows durable.Index
+	if index.IsDeferred() {
+		// the index is built on first use by MaterializeDeferredIndex
+		indexRows, err = durable.NewEmptyIndex(ctx, newTable.ValueReadWriter(), index.Schema())
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		// TODO: in the case that we're replacing an implicit index with one the user specified, we could do this more
+		//  cheaply in some cases by just renaming it, rather than building it from scratch. But that's harder to get right.
+		opts = withHookStats(opts)
+		indexRows, err = BuildSecondaryIndex(ctx, newTable, index, opts)
+		if err != nil {
+			return nil, err
+		}
+		if opts.DetectDeFactoUnique && !index.IsUnique() && types.IsFormat_DOLT_1(newTable.Format()) {
+			newTable, index, err = detectDeFactoUnique(ctx, newTable, sch, index, indexRows, opts)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if opts.VerifyIndexRowCount && !index.IsDeferred() {
+		if err = verifyIndexRowCount(ctx, newTable, index, indexRows, opts); err != nil {
+			return nil, err
+		}
 	}
 
 	newTable, err = newTable.SetIndexRows(ctx, index.Name(), indexRows)
 	if err != nil {
 		return nil, err
 	}
+	contentHash, err := IndexContentHash(indexRows)
+	if err != nil {
+		return nil, err
+	}
+	if !index.IsDeferred() {
+		if err = callBuildHook(ctx, index.Name(), indexRows, opts); err != nil {
+			return nil, err
+		}
+	}
 
 	return &CreateIndexReturn{
-		NewTable: newTable,
-		Sch:      sch,
-		OldIndex: existingIndex,
-		NewIndex: index,
+		NewTable:    newTable,
+		Sch:         sch,
+		OldIndex:    existingIndex,
+		NewIndex:    index,
+		ContentHash: contentHash,
 	}, nil
 }
 
-func BuildSecondaryIndex(ctx context.Context, tbl *doltdb.Table, idx schema.Index, opts editor.Options) (durable.Index, error) {
+// IsRedundantWithPrimaryKey returns true if an index over the columns with the given |tags|, with the properties
+// |props|, would duplicate the primary index of |sch|: its columns are the leading primary key columns in order and
+// it keeps the remaining primary key columns in order, so the index is ordered like the primary index and a primary
+// key range scan serves any lookup on it. A unique index is only redundant if it covers the whole primary key, since
+// it otherwise constrains the prefix.
+func IsRedundantWithPrimaryKey(sch schema.Schema, tags []uint64, props schema.IndexProperties) bool {
+	pkTags := sch.GetPKCols().Tags
+	if schema.IsKeyless(sch) || len(tags)+len(props.PkSuffixOrder) > len(pkTags) {
+		return false
+	}
+	if props.IsUnique && len(tags) < len(pkTags) {
+		return false
+	}
+	for i, tag := range tags {
+		if pkTags[i] != tag {
+			return false
+		}
+	}
+	// the primary key suffix of the index key must also be in primary key order
+	for i, tag := range props.PkSuffixOrder {
+		if pkTags[len(tags)+i] != tag {
+			return false
+		}
+	}
+	return true
+}
+
+// verifyIndexRowCount checks that |indexRows| contains exactly one entry for every row of |tbl|. The check only
+// applies to non-unique, non-partial indexes on tables with a primary key.
+func verifyIndexRowCount(ctx context.Context, tbl *doltdb.Table, idx schema.Index, indexRows durable.Index, opts BuildOptions) error {
+	sch, err := tbl.GetSchema(ctx)
+	if err != nil {
+		return err
+	}
+	if idx.IsUnique() || opts.IndexRowFilter != nil || schema.IsKeyless(sch) {
+		return nil
+	}
+
+	rows, err := tbl.GetRowData(ctx)
+	if err != nil {
+		return err
+	}
+	if rows.Count() != indexRows.Count() {
+		return fmt.Errorf("%w: index `%s` has %d entries, table has %d rows",
+			ErrIndexRowCountMismatch, idx.Name(), indexRows.Count(), rows.Count())
+	}
+	return nil
+}
+
+// resolveColumnNames returns the real column names for |columns|, as CREATE INDEX columns are case-insensitive.
+func resolveColumnNames(sch schema.Schema, columns []string) ([]string, error) {
+	var realColNames []string
+	allTableCols := sch.GetAllCols()
+	for _, indexCol := range columns {
+		tableCol, ok := allTableCols.GetByNameCaseInsensitive(indexCol)
+		if !ok {
+			return nil, fmt.Errorf("column `%s` does not exist for the table", indexCol)
+		}
+		realColNames = append(realColNames, tableCol.Name)
+	}
+	return realColNames, nil
+}
+
+func BuildSecondaryIndex(ctx context.Context, tbl *doltdb.Table, idx schema.Index, opts BuildOptions) (durable.Index, error) {
 	switch tbl.Format() {
 	case types.Format_LD_1, types.Format_DOLT_DEV:
-		m, err := editor.RebuildIndex(ctx, tbl, idx.Name(), opts)
+		m, err := editor.RebuildIndex(ctx, tbl, idx.Name(), opts.EditOptions)
 		if err != nil {
 			return nil, err
 		}
@@ -153,7 +323,7 @@ func BuildSecondaryIndex(ctx context.Context, tbl *doltdb.Table, idx schema.Inde
 			return nil, err
 		}
 		primary := durable.ProllyMapFromIndex(m)
-		return BuildSecondaryProllyIndex(ctx, tbl.ValueReadWriter(), sch, idx, primary)
+		return BuildSecondaryProllyIndex(ctx, tbl.ValueReadWriter(), sch, idx, primary, opts)
 
 	default:
 		return nil, fmt.Errorf("unknown NomsBinFormat")
@@ -161,99 +331,616 @@ func BuildSecondaryIndex(ctx context.Context, tbl *doltdb.Table, idx schema.Inde
 }
 
 // BuildSecondaryProllyIndex builds secondary index data for the given primary
-// index row data |primary|. |sch| is the current schema of the table.
-func BuildSecondaryProllyIndex(ctx context.Context, vrw types.ValueReadWriter, sch schema.Schema, idx schema.Index, primary prolly.Map) (durable.Index, error) {
+// index row data |primary|. |sch| is the current schema of the table. If
+// |opts| has an IndexRowFilter, only rows accepted by the filter are indexed,
+// and if |idx| is sampled, only the rows it samples are. Indexed float fields
+// may hold -Inf and +Inf, which sort first and last, but rows with a NaN
+// indexed float are an ErrIndexKeyNaN, since NaN has no place in key order.
+func BuildSecondaryProllyIndex(ctx context.Context, vrw types.ValueReadWriter, sch schema.Schema, idx schema.Index, primary prolly.Map, opts BuildOptions) (durable.Index, error) {
+	ckpt, iter, err := resumeBuild(ctx, vrw, sch, idx, primary, opts)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := buildSecondaryProllyIndexFromIter(ctx, vrw, sch, idx, iter, opts, ckpt)
+	if err != nil {
+		return nil, err
+	}
+	return rows, ckpt.finish(ctx)
+}
+
+// BuildSecondaryProllyIndexFromIter builds secondary index data from the
+// primary rows returned by |iter|, which are encoded according to |sch|.
+func BuildSecondaryProllyIndexFromIter(ctx context.Context, vrw types.ValueReadWriter, sch schema.Schema, idx schema.Index, iter prolly.MapIter, opts BuildOptions) (durable.Index, error) {
+	return buildSecondaryProllyIndexFromIter(ctx, vrw, sch, idx, iter, opts, nil)
+}
+
+func buildSecondaryProllyIndexFromIter(ctx context.Context, vrw types.ValueReadWriter, sch schema.Schema, idx schema.Index, iter prolly.MapIter, opts BuildOptions, ckpt *buildCheckpointer) (durable.Index, error) {
+	opts = withIndexSample(idx, opts)
 	if idx.IsUnique() {
 		kd := shim.KeyDescriptorFromSchema(idx.Schema())
-		return BuildUniqueProllyIndex(ctx, vrw, sch, idx, primary, func(ctx context.Context, existingKey, newKey val.Tuple) error {
+		return buildUniqueProllyIndexFromIter(ctx, vrw, sch, idx, iter, opts, func(ctx context.Context, existingKey, newKey val.Tuple) error {
 			return sql.ErrDuplicateEntry.Wrap(&prollyUniqueKeyErr{k: newKey, kd: kd, IndexName: idx.Name()}, idx.Name())
-		})
+		}, ckpt)
 	}
 
-	empty, err := durable.NewEmptyIndex(ctx, vrw, idx.Schema())
+	ctx, span := startBuildSpan(ctx, opts, idx, "index.build")
+	rows, err := buildNonUniqueProllyIndex(ctx, vrw, sch, idx, iter, opts, ckpt)
+	return rows, span.end(rows, err)
+}
+
+func buildNonUniqueProllyIndex(ctx context.Context, vrw types.ValueReadWriter, sch schema.Schema, idx schema.Index, iter prolly.MapIter, opts BuildOptions, ckpt *buildCheckpointer) (durable.Index, error) {
+	secondary, err := ckpt.secondaryMap(ctx, vrw, sch, idx, opts)
 	if err != nil {
 		return nil, err
 	}
-	secondary := durable.ProllyMapFromIndex(empty)
 
-	iter, err := primary.IterAll(ctx)
+	kd, _ := secondary.Descriptors()
+	enc, err := newIndexKeyEncoder(sch, idx, kd, opts)
+	if err != nil {
+		return nil, err
+	}
+	pkd := enc.pkd
+	p := tuplePool(secondary, opts)
+	mon := newBuildMonitor(idx, opts)
+	docs, err := newDocumentFeeder(sch, idx, secondary.NodeStore(), opts)
+	if err != nil {
+		return nil, err
+	}
+	spill, err := newRunSpiller(idx, kd, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer spill.abort()
+	ends, err := newIntervalEnds(sch, idx, opts)
+	if err != nil {
+		return nil, err
+	}
+	exp, err := newExpiryValues(sch, idx, opts)
+	if err != nil {
+		return nil, err
+	}
+	sums, err := newEntryChecksums(idx, opts)
 	if err != nil {
 		return nil, err
 	}
-	pkLen := sch.GetPKCols().Size()
-
-	// create a key builder for index key tuples
-	kd, _ := secondary.Descriptors()
-	keyBld := val.NewTupleBuilder(kd)
-	keyMap := GetIndexKeyMapping(sch, idx)
 
 	mut := secondary.Mutate()
+	// the entries of an index that leads with an auto increment key are in
+	// scan order, so they are appended until an entry is out of order
+	var app *appendIndexBuilder
+	if ckpt == nil && canAppendIndex(sch, idx, opts) {
+		if app, err = newAppendIndexBuilder(ctx, secondary); err != nil {
+			return nil, err
+		}
+		app.strict = opts.AssertIndexKeyOrder
+	}
 	for {
 		k, v, err := iter.Next(ctx)
 		if err == io.EOF {
 			break
 		}
 		if err != nil {
-			return nil, err
+			return nil, mon.partial(ioErr(idx, pkd, nil, err), mut)
+		}
+		if mut, err = ckpt.row(ctx, mut, k, mon.rows); err != nil {
+			return nil, ioErr(idx, pkd, k, err)
+		}
+		if err = mon.row(ctx); err != nil {
+			return nil, mon.partial(err, mut)
 		}
 
-		for to := range keyMap {
-			from := keyMap.MapOrdinal(to)
-			if from < pkLen {
-				keyBld.PutRaw(to, k.GetField(from))
-			} else {
-				from -= pkLen
-				keyBld.PutRaw(to, v.GetField(from))
+		if opts.IndexRowFilter != nil {
+			ok, err := opts.IndexRowFilter(ctx, k, v)
+			if err != nil {
+				return nil, err
 			}
+			if !ok {
+				continue
+			}
+		}
+
+		if err = enc.put(k, v, mon); err != nil {
+			if skipsOversizedRow(err, opts) {
+				enc.kb.Recycle()
+				mon.oversized(pkd, k)
+				continue
+			}
+			return nil, err
 		}
 
 		// todo(andy): build permissive?
-		idxKey := keyBld.Build(primary.Pool())
-		idxVal := val.EmptyTuple
+		idxKey := enc.kb.Build(p)
+		idxVal, err := indexValue(v, iter, p, opts)
+		if err != nil {
+			return nil, err
+		}
+		if ends != nil {
+			idxVal = ends.value(k, v, p)
+		}
+		if exp != nil {
+			idxVal = exp.value(k, v, p)
+		}
+		if sums != nil {
+			idxVal = sums.value(idxKey, p)
+		}
+		if err = mon.value(idxKey); err != nil {
+			return nil, err
+		}
 
-		// todo(andy): periodic flushing
-		if err = mut.Put(ctx, idxKey, idxVal); err != nil {
+		appended := false
+		if app != nil {
+			if appended, err = app.put(ctx, idxKey, idxVal); err != nil {
+				return nil, ioErr(idx, pkd, k, err)
+			}
+			if !appended {
+				m, err := app.build(ctx)
+				if err != nil {
+					return nil, ioErr(idx, pkd, k, err)
+				}
+				mut, app = m.Mutate(), nil
+			}
+		}
+		if !appended {
+			if opts.DetectIndexKeyCollisions {
+				ok, err := mut.Has(ctx, idxKey)
+				if err != nil {
+					return nil, ioErr(idx, pkd, k, err)
+				}
+				if ok {
+					keyStr, _ := formatKey(idxKey, kd)
+					return nil, fmt.Errorf("%w: index `%s` has multiple rows with key %s", ErrIndexKeyCollision, idx.Name(), keyStr)
+				}
+			}
+			if err = mut.Put(ctx, idxKey, idxVal); err != nil {
+				return nil, ioErr(idx, pkd, k, err)
+			}
+		}
+		if opts.IndexEntryWriter != nil {
+			if err = WriteIndexEntry(opts.IndexEntryWriter, idxKey, idxVal); err != nil {
+				return nil, ioErr(idx, pkd, k, err)
+			}
+		}
+		if err = spill.put(idxKey, idxVal); err != nil {
+			return nil, ioErr(idx, pkd, k, err)
+		}
+		if err = docs.feed(ctx, k, v); err != nil {
 			return nil, err
 		}
+		mon.indexed()
 	}
 
-	secondary, err = mut.Map(ctx)
+	fctx, span := startBuildSpan(ctx, opts, idx, "index.flush")
+	if app != nil {
+		secondary, err = app.build(fctx)
+	} else {
+		secondary, err = mut.Map(fctx)
+	}
+	if err == nil {
+		secondary, err = ends.finish(fctx, secondary)
+	}
+	span.end(nil, err)
 	if err != nil {
+		return nil, ioErr(idx, pkd, nil, err)
+	}
+	if err = spill.finish(); err != nil {
+		return nil, ioErr(idx, pkd, nil, err)
+	}
+	if err = mon.finish(ctx, secondary); err != nil {
+		return nil, err
+	}
+	if err = emitIndexColumns(ctx, idx, secondary, opts); err != nil {
 		return nil, err
 	}
 
 	return durable.IndexFromProllyMap(secondary), nil
 }
 
+// keyFieldTransform transforms the index key field |f| at position |to|,
+// which is at position |at| of the key.
+type keyFieldTransform func(to, at int, f []byte) ([]byte, error)
+
+// keyFieldEncoder reads the indexed fields of primary rows and transforms
+// them into the fields of index keys.
+type keyFieldEncoder struct {
+	sch    schema.Schema
+	idx    schema.Index
+	keyMap val.OrdinalMapping
+	pkLen  int
+	pkd    val.TupleDesc
+	kd     val.TupleDesc
+	encr   *IndexKeyEncrypter
+	// steps are the transforms configured for the index, in the order they
+	// are applied. It is empty if no transforms are configured.
+	steps []keyFieldTransform
+}
+
+func newKeyFieldEncoder(sch schema.Schema, idx schema.Index, kd val.TupleDesc, opts BuildOptions) (keyFieldEncoder, error) {
+	if err := opts.Validate(false); err != nil {
+		return keyFieldEncoder{}, fmt.Errorf("index `%s`: %w", idx.Name(), err)
+	}
+	keyMap, err := GetIndexKeyMapping(sch, idx)
+	if err != nil {
+		return keyFieldEncoder{}, err
+	}
+	nulls, err := newNullCanonicalizer(sch, idx, kd, opts)
+	if err != nil {
+		return keyFieldEncoder{}, err
+	}
+	trans, err := newFieldTranscoder(sch, idx, kd, opts)
+	if err != nil {
+		return keyFieldEncoder{}, err
+	}
+	norm, err := newFieldNormalizer(idx)
+	if err != nil {
+		return keyFieldEncoder{}, err
+	}
+	encr, err := NewIndexKeyEncrypter(idx, kd, opts.IndexKeyEncryption)
+	if err != nil {
+		return keyFieldEncoder{}, err
+	}
+	limit, err := newFieldSizeLimit(idx, kd, opts)
+	if err != nil {
+		return keyFieldEncoder{}, err
+	}
+	reverse := newStringReversal(idx)
+	labels := newEnumLabeler(sch, idx, opts)
+
+	var steps []keyFieldTransform
+	if nulls != nil {
+		steps = append(steps, func(to, _ int, f []byte) ([]byte, error) {
+			return nulls.CanonicalizeField(to, f), nil
+		})
+	}
+	if trans != nil {
+		steps = append(steps, func(to, _ int, f []byte) ([]byte, error) {
+			return trans.TranscodeField(to, f)
+		})
+	}
+	if norm != nil {
+		steps = append(steps, func(to, _ int, f []byte) ([]byte, error) {
+			return norm.NormalizeField(to, f), nil
+		})
+	}
+	if reverse != nil {
+		steps = append(steps, func(to, _ int, f []byte) ([]byte, error) {
+			return reverse.ReverseField(to, f), nil
+		})
+	}
+	if encr != nil {
+		steps = append(steps, func(to, _ int, f []byte) ([]byte, error) {
+			return encr.EncryptField(to, f), nil
+		})
+	}
+	if labels != nil {
+		steps = append(steps, func(to, _ int, f []byte) ([]byte, error) {
+			return labels.LabelField(to, f)
+		})
+	}
+	if limit != nil {
+		steps = append(steps, limit.LimitField)
+	}
+
+	return keyFieldEncoder{
+		sch:    sch,
+		idx:    idx,
+		keyMap: keyMap,
+		pkLen:  sch.GetPKCols().Size(),
+		pkd:    shim.KeyDescriptorFromSchema(sch),
+		kd:     kd,
+		encr:   encr,
+		steps:  steps,
+	}, nil
+}
+
+// field returns the index key field |to|, which is at position |at| of the
+// key, of the primary row |k|, |v|.
+func (e *keyFieldEncoder) field(k, v val.Tuple, to, at int) ([]byte, error) {
+	from := e.keyMap.MapOrdinal(to)
+	var f []byte
+	if from < e.pkLen {
+		f = k.GetField(from)
+		if f == nil && !e.pkd.Types[from].Nullable {
+			return nil, encodeErr(e.sch, e.idx, e.pkd, k, to)
+		}
+	} else {
+		f = v.GetField(from - e.pkLen)
+	}
+	var err error
+	for _, step := range e.steps {
+		if f, err = step(to, at, f); err != nil {
+			return nil, encodeFieldErr(e.sch, e.idx, e.pkd, k, to, err)
+		}
+	}
+	if err = checkNaN(e.kd.Types[at], f); err != nil {
+		return nil, encodeFieldErr(e.sch, e.idx, e.pkd, k, to, err)
+	}
+	return f, nil
+}
+
+// indexKeyEncoder encodes the secondary index keys of primary rows.
+type indexKeyEncoder struct {
+	keyFieldEncoder
+	kb *val.TupleBuilder
+	// bucket is the time bucketing of the key, or nil
+	bucket *timeBucketing
+	// geohash is the geohashing of the key, or nil
+	geohash *geohashing
+}
+
+func newIndexKeyEncoder(sch schema.Schema, idx schema.Index, kd val.TupleDesc, opts BuildOptions) (*indexKeyEncoder, error) {
+	fields, err := newKeyFieldEncoder(sch, idx, kd, opts)
+	if err != nil {
+		return nil, err
+	}
+	bucket, err := newTimeBucketing(sch, idx, fields.keyMap, opts)
+	if err != nil {
+		return nil, err
+	}
+	geohash, err := newGeohashing(sch, idx, fields.keyMap, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &indexKeyEncoder{
+		keyFieldEncoder: fields,
+		kb:              val.NewTupleBuilder(kd),
+		bucket:          bucket,
+		geohash:         geohash,
+	}, nil
+}
+
+// put writes the index key fields of the primary row |k|, |v| to the key
+// builder and records NULL fields in |mon|.
+func (e *indexKeyEncoder) put(k, v val.Tuple, mon *buildMonitor) error {
+	if e.bucket != nil {
+		e.bucket.put(e.kb, k, v)
+	}
+	if e.geohash != nil {
+		e.geohash.put(e.kb, k, v)
+	}
+	off := e.offset()
+	for to := range e.keyMap {
+		if to+off == e.kb.Desc.Count() {
+			// the keys of distinct indexes end with the indexed columns
+			break
+		}
+		f, err := e.field(k, v, to, to+off)
+		if err != nil {
+			return err
+		}
+		e.kb.PutRaw(to+off, f)
+		if f == nil {
+			mon.null(to)
+		}
+	}
+	return nil
+}
+
+// offset returns the position of the first index key field in the keys built
+// by the encoder, which follows the time bucket or geohash of the key, if any.
+func (e *indexKeyEncoder) offset() int {
+	if e.bucket != nil || e.geohash != nil {
+		return 1
+	}
+	return 0
+}
+
+// uniqueKeyEncoder encodes the keys of a unique index, along with their
+// indexed values, the prefix of the keys that is checked for duplicates.
+type uniqueKeyEncoder struct {
+	keyFieldEncoder
+	kb       *val.TupleBuilder
+	prefixKD val.TupleDesc
+	prefixKB *val.TupleBuilder
+}
+
+func newUniqueKeyEncoder(sch schema.Schema, idx schema.Index, kd val.TupleDesc, opts BuildOptions) (*uniqueKeyEncoder, error) {
+	fields, err := newKeyFieldEncoder(sch, idx, kd, opts)
+	if err != nil {
+		return nil, err
+	}
+	prefixKD := prefixDesc(kd, idx.Count())
+	return &uniqueKeyEncoder{
+		keyFieldEncoder: fields,
+		kb:              val.NewTupleBuilder(kd),
+		prefixKD:        prefixKD,
+		prefixKB:        val.NewTupleBuilder(prefixKD),
+	}, nil
+}
+
+// put puts the index key of the primary row |k|, |v| to |e.kb|, and its
+// indexed values to |e.prefixKB|, returning true if any of them is NULL.
+func (e *uniqueKeyEncoder) put(k, v val.Tuple, mon *buildMonitor) (bool, error) {
+	nullPrefix := false
+	e.prefixKB.Recycle()
+	for to := range e.keyMap {
+		f, err := e.field(k, v, to, to)
+		if err != nil {
+			return false, err
+		}
+		e.kb.PutRaw(to, f)
+		if to < e.prefixKD.Count() {
+			if f == nil {
+				nullPrefix = true
+				mon.null(to)
+			} else {
+				e.prefixKB.PutRaw(to, f)
+			}
+		}
+	}
+	return nullPrefix, nil
+}
+
 // DupEntryCb receives duplicate unique index entries.
 type DupEntryCb func(ctx context.Context, existingKey, newKey val.Tuple) error
 
 // BuildUniqueProllyIndex builds a unique index based on the given |primary| row
 // data. If any duplicate entries are found, they are passed to |cb|. If |cb|
-// returns a non-nil error then the process is stopped.
-func BuildUniqueProllyIndex(ctx context.Context, vrw types.ValueReadWriter, sch schema.Schema, idx schema.Index, primary prolly.Map, cb DupEntryCb) (durable.Index, error) {
-	empty, err := durable.NewEmptyIndex(ctx, vrw, idx.Schema())
+// returns a non-nil error then the process is stopped. If |opts| has an
+// IndexRowFilter, the index is a partial unique index: rows rejected by the
+// filter are neither indexed nor checked for duplicates, so only the accepted
+// rows must be unique. As in MySQL, strings of a column with a PAD SPACE
+// collation that differ only in trailing spaces are duplicates, and so are -0
+// and 0. As for other indexes, rows with a NaN indexed float are an
+// ErrIndexKeyNaN.
+func BuildUniqueProllyIndex(ctx context.Context, vrw types.ValueReadWriter, sch schema.Schema, idx schema.Index, primary prolly.Map, opts BuildOptions, cb DupEntryCb) (durable.Index, error) {
+	ckpt, iter, err := resumeBuild(ctx, vrw, sch, idx, primary, opts)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := buildUniqueProllyIndexFromIter(ctx, vrw, sch, idx, iter, opts, cb, ckpt)
 	if err != nil {
 		return nil, err
 	}
-	secondary := durable.ProllyMapFromIndex(empty)
+	return rows, ckpt.finish(ctx)
+}
+
+// DupEntry is a duplicate unique index entry.
+type DupEntry struct {
+	ExistingKey, NewKey val.Tuple
+}
+
+// DupEntryBatchCb receives batches of duplicate unique index entries.
+type DupEntryBatchCb func(ctx context.Context, dups []DupEntry) error
+
+// BuildUniqueProllyIndexBatched builds a unique index like BuildUniqueProllyIndex,
+// but accumulates duplicate entries and passes them to |cb| in batches of
+// |batchSize|. Any remaining duplicates are passed to |cb| once the scan is
+// complete, before the index is returned.
+func BuildUniqueProllyIndexBatched(ctx context.Context, vrw types.ValueReadWriter, sch schema.Schema, idx schema.Index, primary prolly.Map, opts BuildOptions, batchSize int, cb DupEntryBatchCb) (durable.Index, error) {
+	if batchSize <= 0 {
+		return nil, fmt.Errorf("invalid duplicate entry batch size %d", batchSize)
+	}
 
-	iter, err := primary.IterAll(ctx)
+	batch := make([]DupEntry, 0, batchSize)
+	s, err := BuildUniqueProllyIndex(ctx, vrw, sch, idx, primary, opts, func(ctx context.Context, existingKey, newKey val.Tuple) error {
+		batch = append(batch, DupEntry{ExistingKey: existingKey, NewKey: newKey})
+		if len(batch) < batchSize {
+			return nil
+		}
+		err := cb(ctx, batch)
+		batch = batch[:0]
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
+	if len(batch) > 0 {
+		if err = cb(ctx, batch); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// DupRowCb receives the primary rows of duplicate unique index entries, so
+// that errors can report the conflicting rows' non-indexed columns.
+type DupRowCb func(ctx context.Context, existingKey, existingVal, newKey, newVal val.Tuple) error
+
+// BuildUniqueProllyIndexWithRows builds a unique index like BuildUniqueProllyIndex,
+// but passes the primary key and value tuples of both conflicting rows to |cb|.
+// The rows are looked up in |primary| by the primary key suffix of each index
+// key, which costs two point lookups per duplicate.
+func BuildUniqueProllyIndexWithRows(ctx context.Context, vrw types.ValueReadWriter, sch schema.Schema, idx schema.Index, primary prolly.Map, opts BuildOptions, cb DupRowCb) (durable.Index, error) {
+	if schema.IsKeyless(sch) {
+		return nil, fmt.Errorf("index `%s`: duplicate rows cannot be looked up for keyless tables", idx.Name())
+	}
+	keyMap, err := GetIndexKeyMapping(sch, idx)
+	if err != nil {
+		return nil, err
+	}
+
+	// pkMap maps the fields of a primary key to the fields of an index key
 	pkLen := sch.GetPKCols().Size()
+	pkMap := make(val.OrdinalMapping, pkLen)
+	for to := idx.Count(); to < len(keyMap); to++ {
+		pkMap[keyMap.MapOrdinal(to)] = to
+	}
+
+	kd, _ := primary.Descriptors()
+	pkBld := val.NewTupleBuilder(kd)
+	getRow := func(ctx context.Context, idxKey val.Tuple) (k, v val.Tuple, err error) {
+		for to := range pkMap {
+			pkBld.PutRaw(to, idxKey.GetField(pkMap.MapOrdinal(to)))
+		}
+		err = primary.Get(ctx, pkBld.Build(primary.Pool()), func(key, value val.Tuple) error {
+			k, v = key, value
+			return nil
+		})
+		if err == nil && k == nil {
+			err = fmt.Errorf("index `%s` entry has no primary row", idx.Name())
+		}
+		return k, v, err
+	}
+
+	return BuildUniqueProllyIndex(ctx, vrw, sch, idx, primary, opts, func(ctx context.Context, existingKey, newKey val.Tuple) error {
+		ek, ev, err := getRow(ctx, existingKey)
+		if err != nil {
+			return err
+		}
+		nk, nv, err := getRow(ctx, newKey)
+		if err != nil {
+			return err
+		}
+		return cb(ctx, ek, ev, nk, nv)
+	})
+}
+
+// BuildUniqueProllyIndexFromIter builds a unique index from the primary rows
+// returned by |iter|. Duplicate entries are handled as in BuildUniqueProllyIndex.
+func BuildUniqueProllyIndexFromIter(ctx context.Context, vrw types.ValueReadWriter, sch schema.Schema, idx schema.Index, iter prolly.MapIter, opts BuildOptions, cb DupEntryCb) (durable.Index, error) {
+	return buildUniqueProllyIndexFromIter(ctx, vrw, sch, idx, iter, opts, cb, nil)
+}
+
+func buildUniqueProllyIndexFromIter(ctx context.Context, vrw types.ValueReadWriter, sch schema.Schema, idx schema.Index, iter prolly.MapIter, opts BuildOptions, cb DupEntryCb, ckpt *buildCheckpointer) (durable.Index, error) {
+	ctx, span := startBuildSpan(ctx, opts, idx, "index.build")
+	rows, err := buildUniqueProllyIndex(ctx, vrw, sch, idx, iter, opts, cb, ckpt)
+	return rows, span.end(rows, err)
+}
+
+func buildUniqueProllyIndex(ctx context.Context, vrw types.ValueReadWriter, sch schema.Schema, idx schema.Index, iter prolly.MapIter, opts BuildOptions, cb DupEntryCb, ckpt *buildCheckpointer) (durable.Index, error) {
+	if canPipelineUniqueBuild(opts, ckpt) {
+		return buildUniqueProllyIndexPipelined(ctx, vrw, sch, idx, iter, opts, cb, uniquePipeline{
+			workers:       opts.UniqueIndexCheckWorkers,
+			batchSize:     uniqueBatchSize,
+			snapshotEvery: uniqueSnapshotEntries,
+		})
+	}
+	secondary, err := ckpt.secondaryMap(ctx, vrw, sch, idx, opts)
+	if err != nil {
+		return nil, err
+	}
 
-	// create a key builder for index key tuples
 	kd, _ := secondary.Descriptors()
-	keyBld := val.NewTupleBuilder(kd)
-	keyMap := GetIndexKeyMapping(sch, idx)
+	enc, err := newUniqueKeyEncoder(sch, idx, kd, opts)
+	if err != nil {
+		return nil, err
+	}
 
-	// key builder for the indexed columns only which is a prefix of the index key
-	prefixKD := kd.PrefixDesc(idx.Count())
-	prefixKB := val.NewTupleBuilder(prefixKD)
+	pads := newPadSpaceKeys(sch, idx, kd, enc.encr)
+	if err = pads.seed(ctx, secondary); err != nil {
+		return nil, err
+	}
 
-	p := primary.Pool()
+	pkd := enc.pkd
+	p := tuplePool(secondary, opts)
+	mon := newBuildMonitor(idx, opts)
+	docs, err := newDocumentFeeder(sch, idx, secondary.NodeStore(), opts)
+	if err != nil {
+		return nil, err
+	}
+	spill, err := newRunSpiller(idx, kd, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer spill.abort()
+	exp, err := newExpiryValues(sch, idx, opts)
+	if err != nil {
+		return nil, err
+	}
+	sums, err := newEntryChecksums(idx, opts)
+	if err != nil {
+		return nil, err
+	}
 
 	mut := secondary.Mutate()
 	for {
@@ -262,72 +949,545 @@ func BuildUniqueProllyIndex(ctx context.Context, vrw types.ValueReadWriter, sch
 			break
 		}
 		if err != nil {
-			return nil, err
+			return nil, mon.partial(ioErr(idx, pkd, nil, err), mut)
+		}
+		if mut, err = ckpt.row(ctx, mut, k, mon.rows); err != nil {
+			return nil, ioErr(idx, pkd, k, err)
+		}
+		if err = mon.row(ctx); err != nil {
+			return nil, mon.partial(err, mut)
 		}
 
-		foundNullPrefix := false
-		prefixKB.Recycle()
-		for to := range keyMap {
-			from := keyMap.MapOrdinal(to)
-			var f []byte
-			if from < pkLen {
-				f = k.GetField(from)
-			} else {
-				from -= pkLen
-				f = v.GetField(from)
+		if opts.IndexRowFilter != nil {
+			ok, err := opts.IndexRowFilter(ctx, k, v)
+			if err != nil {
+				return nil, err
 			}
-			keyBld.PutRaw(to, f)
-			if to < prefixKD.Count() {
-				if f == nil {
-					foundNullPrefix = true
-				} else {
-					prefixKB.PutRaw(to, f)
-				}
+			if !ok {
+				continue
 			}
 		}
 
-		idxKey := keyBld.Build(p)
-		idxVal := val.EmptyTuple
+		foundNullPrefix, err := enc.put(k, v, mon)
+		if err != nil {
+			if skipsOversizedRow(err, opts) {
+				enc.kb.Recycle()
+				mon.oversized(pkd, k)
+				continue
+			}
+			return nil, err
+		}
 
+		idxKey := enc.kb.Build(p)
+		idxVal, err := indexValue(v, iter, p, opts)
+		if err != nil {
+			return nil, err
+		}
+		if exp != nil {
+			idxVal = exp.value(k, v, p)
+		}
+		if sums != nil {
+			idxVal = sums.value(idxKey, p)
+		}
+		if err = mon.value(idxKey); err != nil {
+			return nil, err
+		}
+
+		// like MySQL, an entry with a NULL in any unique column never conflicts
 		if !foundNullPrefix {
-			prefixKey := prefixKB.Build(p)
+			prefixKey := enc.prefixKB.Build(p)
 
-			itr, err := NewPrefixItr(ctx, prefixKey, prefixKD, mut)
+			itr, err := NewPrefixItr(ctx, prefixKey, enc.prefixKD, mut)
 			if err != nil {
-				return nil, err
+				return nil, ioErr(idx, pkd, k, err)
 			}
 
-			k, _, err = itr.Next(ctx)
+			existing, _, err := itr.Next(ctx)
 			if err != nil && err != io.EOF {
-				return nil, err
+				return nil, ioErr(idx, pkd, k, err)
 			}
-			if err == nil {
+			found := err == nil
+			if !found {
+				existing, found = pads.find(idxKey)
+			}
+			pads.add(idxKey)
+			if found {
 				// We found a duplicate entry so delegate behavior to callback.
-				if err = cb(ctx, k, idxKey); err != nil {
+				dctx, span := startBuildSpan(ctx, opts, idx, "index.duplicate")
+				err = cb(dctx, existing, idxKey)
+				if err = span.end(nil, err); err != nil {
 					return nil, err
 				}
 			}
 		}
 
 		if err = mut.Put(ctx, idxKey, idxVal); err != nil {
+			return nil, ioErr(idx, pkd, k, err)
+		}
+		if opts.IndexEntryWriter != nil {
+			if err = WriteIndexEntry(opts.IndexEntryWriter, idxKey, idxVal); err != nil {
+				return nil, ioErr(idx, pkd, k, err)
+			}
+		}
+		if err = spill.put(idxKey, idxVal); err != nil {
+			return nil, ioErr(idx, pkd, k, err)
+		}
+		if err = docs.feed(ctx, k, v); err != nil {
 			return nil, err
 		}
+		mon.indexed()
 	}
 
-	secondary, err = mut.Map(ctx)
+	fctx, span := startBuildSpan(ctx, opts, idx, "index.flush")
+	secondary, err = mut.Map(fctx)
+	span.end(nil, err)
 	if err != nil {
+		return nil, ioErr(idx, pkd, nil, err)
+	}
+	if err = spill.finish(); err != nil {
+		return nil, ioErr(idx, pkd, nil, err)
+	}
+	if err = mon.finish(ctx, secondary); err != nil {
+		return nil, err
+	}
+	if err = emitIndexColumns(ctx, idx, secondary, opts); err != nil {
 		return nil, err
 	}
 
 	return durable.IndexFromProllyMap(secondary), nil
 }
 
+// ioErr wraps |err|, returned while reading the primary rows or writing the
+// entries of |idx|, in an ErrIndexBuildIO. |k| is the primary key, encoded
+// with |pkd|, of the row being indexed, or nil. Context errors and errors that
+// are already wrapped are returned unchanged.
+func ioErr(idx schema.Index, pkd val.TupleDesc, k val.Tuple, err error) error {
+	var ioe ErrIndexBuildIO
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) || errors.As(err, &ioe) {
+		return err
+	}
+	ioe = ErrIndexBuildIO{IndexName: idx.Name(), Err: err}
+	if k != nil {
+		ioe.PrimaryKey = pkd.Format(k)
+	}
+	return ioe
+}
+
+// encodeErr returns an ErrIndexEncode for the primary row with key |k|, whose
+// value for the index key field |to| of |idx| is a NULL primary key field.
+func encodeErr(sch schema.Schema, idx schema.Index, pkd val.TupleDesc, k val.Tuple, to int) error {
+	col, _ := sch.GetAllCols().GetByTag(idx.AllTags()[to])
+	return ErrIndexEncode{
+		IndexName:  idx.Name(),
+		PrimaryKey: pkd.Format(k),
+		Err:        fmt.Errorf("primary key column `%s` is NULL", col.Name),
+	}
+}
+
+// encodeFieldErr returns an ErrIndexEncode for the primary row with key |k|,
+// whose value for the index key field |to| of |idx| cannot be encoded.
+func encodeFieldErr(sch schema.Schema, idx schema.Index, pkd val.TupleDesc, k val.Tuple, to int, err error) error {
+	col, _ := sch.GetAllCols().GetByTag(idx.AllTags()[to])
+	return ErrIndexEncode{
+		IndexName:  idx.Name(),
+		PrimaryKey: pkd.Format(k),
+		Err:        fmt.Errorf("column `%s`: %w", col.Name, err),
+	}
+}
+
+// checkNaN returns ErrIndexKeyNaN if |f| is a NaN float field of type |typ|.
+// The comparator of keys orders no float before or after NaN, so the place of
+// a NaN key in an index would depend on the keys it is compared to.
+func checkNaN(typ val.Type, f []byte) error {
+	switch {
+	case f == nil:
+		return nil
+	case typ.Enc == val.Float32Enc && len(f) == 4:
+		if v := math.Float32frombits(binary.LittleEndian.Uint32(f)); v != v {
+			return ErrIndexKeyNaN
+		}
+	case typ.Enc == val.Float64Enc && len(f) == 8:
+		if math.IsNaN(math.Float64frombits(binary.LittleEndian.Uint64(f))) {
+			return ErrIndexKeyNaN
+		}
+	}
+	return nil
+}
+
+// newSecondaryMap returns an empty map for the data of the secondary index
+// |idx| of |sch|. If BuildOptions.MirrorPrimaryRowInIndex is set, its values
+// are encoded like the values of the primary index, if
+// BuildOptions.RecordSourceChunkInIndex is set, they are encoded by
+// sourceChunkValueDesc, if BuildOptions.RecordRowLocatorInIndex is set, they
+// are encoded by rowLocatorValueDesc, if BuildOptions.IndexIntervalEnd is set, they are
+// encoded by intervalValueDesc, if BuildOptions.IndexExpiryColumn is set,
+// they are encoded by expiryValueDesc, and if
+// BuildOptions.IndexEntryChecksums is set, they are encoded by
+// checksumValueDesc. If |idx| has a time bucket, its keys are
+// prefixed with the bucket, if BuildOptions.IndexGeohashPrecision is set,
+// they are prefixed with a geohash, and if BuildOptions.ReverseIndexOrder is
+// set, they are in reverse order. If BuildOptions.IndexEnumsByLabel is set,
+// their ENUM and SET fields are encoded by enumLabelKeyDesc. Key fields
+// without an order-preserving encoding are an error, unless
+// BuildOptions.EqualityOnlyIndex is set and they are ordered by their bytes.
+func newSecondaryMap(ctx context.Context, vrw types.ValueReadWriter, sch schema.Schema, idx schema.Index, opts BuildOptions) (prolly.Map, error) {
+	if err := opts.Validate(false); err != nil {
+		return prolly.Map{}, fmt.Errorf("index `%s`: %w", idx.Name(), err)
+	}
+	if opts.IndexGeohashPrecision != 0 {
+		if err := validateGeohash(idx, opts); err != nil {
+			return prolly.Map{}, err
+		}
+	}
+	if opts.DistinctIndex {
+		if err := validateDistinct(idx); err != nil {
+			return prolly.Map{}, err
+		}
+	}
+	empty, err := durable.NewEmptyIndex(ctx, vrw, idx.Schema())
+	if err != nil {
+		return prolly.Map{}, err
+	}
+	m := durable.ProllyMapFromIndex(empty)
+	kd, vd := m.Descriptors()
+	if err = validateKeyOrder(idx, kd, opts); err != nil {
+		return prolly.Map{}, err
+	}
+	keySteps := keyDescTransforms(idx, opts)
+	customVD, ok, err := indexValueDesc(sch, idx, opts)
+	if err != nil {
+		return prolly.Map{}, err
+	}
+	if len(keySteps) == 0 && !ok {
+		return m, nil
+	}
+	for _, step := range keySteps {
+		kd = step(kd)
+	}
+	if ok {
+		vd = customVD
+	}
+	return prolly.NewMapFromTuples(ctx, m.NodeStore(), kd, vd)
+}
+
+// keyDescTransforms returns the transforms of the key descriptor of |idx|
+// configured by |opts|, in the order they are applied. It is empty if the
+// keys of |idx| are plain secondary index keys.
+func keyDescTransforms(idx schema.Index, opts BuildOptions) []func(val.TupleDesc) val.TupleDesc {
+	var steps []func(val.TupleDesc) val.TupleDesc
+	if opts.IndexEnumsByLabel {
+		steps = append(steps, enumLabelKeyDesc)
+	}
+	if opts.DistinctIndex {
+		steps = append(steps, func(kd val.TupleDesc) val.TupleDesc {
+			return distinctKeyDesc(idx, kd)
+		})
+	}
+	if idx.TimeBucket() != 0 {
+		steps = append(steps, timeBucketKeyDesc)
+	}
+	if opts.IndexGeohashPrecision != 0 {
+		steps = append(steps, geohashKeyDesc)
+	}
+	if opts.EqualityOnlyIndex {
+		steps = append(steps, equalityKeyDesc)
+	}
+	if opts.ReverseIndexOrder {
+		steps = append(steps, reverseKeyDesc)
+	}
+	return steps
+}
+
+// indexValueDesc returns the descriptor of the values of |idx| built with
+// |opts|, and false if they are the empty values of a plain secondary index.
+func indexValueDesc(sch schema.Schema, idx schema.Index, opts BuildOptions) (vd val.TupleDesc, ok bool, err error) {
+	if opts.MirrorPrimaryRowInIndex {
+		_, vd = shim.MapDescriptorsFromSchema(sch)
+		ok = true
+	}
+	if opts.RecordSourceChunkInIndex {
+		vd, ok = sourceChunkValueDesc, true
+	}
+	if opts.RecordRowLocatorInIndex {
+		vd, ok = rowLocatorValueDesc, true
+	}
+	if opts.IndexIntervalEnd != "" {
+		_, typ, err := validateInterval(sch, idx, opts)
+		if err != nil {
+			return val.TupleDesc{}, false, err
+		}
+		vd, ok = intervalValueDesc(typ), true
+	}
+	if opts.IndexExpiryColumn != "" {
+		if _, err := validateExpiry(sch, idx, opts); err != nil {
+			return val.TupleDesc{}, false, err
+		}
+		vd, ok = expiryValueDesc, true
+	}
+	if opts.IndexEntryChecksums {
+		vd, ok = checksumValueDesc, true
+	}
+	return vd, ok, nil
+}
+
+// indexValue returns the secondary index value of the primary row with the
+// value |v|, the current row of |iter|.
+func indexValue(v val.Tuple, iter prolly.MapIter, p pool.BuffPool, opts BuildOptions) (val.Tuple, error) {
+	if opts.RecordSourceChunkInIndex || opts.RecordRowLocatorInIndex {
+		sc, ok := iter.(*sourceChunkIter)
+		if !ok {
+			return nil, errNoSourceChunks
+		}
+		return sc.value(p), nil
+	}
+	if opts.MirrorPrimaryRowInIndex {
+		return v, nil
+	}
+	return val.EmptyTuple, nil
+}
+
+// buildSpan is a span traced by BuildOptions.IndexBuildTracer, or a no-op
+// if the build is not traced.
+type buildSpan struct {
+	span IndexBuildSpan
+}
+
+// startBuildSpan starts a span named |name| for a step of building |idx|.
+func startBuildSpan(ctx context.Context, opts BuildOptions, idx schema.Index, name string) (context.Context, buildSpan) {
+	if opts.IndexBuildTracer == nil {
+		return ctx, buildSpan{}
+	}
+	ctx, span := opts.IndexBuildTracer.StartSpan(ctx, name)
+	span.SetAttribute("index", idx.Name())
+	return ctx, buildSpan{span: span}
+}
+
+// end ends the span with the error |err|, which it returns. If the step
+// produced the index data |rows|, its entry count is recorded.
+func (s buildSpan) end(rows durable.Index, err error) error {
+	if s.span == nil {
+		return err
+	}
+	if rows != nil {
+		s.span.SetAttribute("entries", rows.Count())
+	}
+	s.span.End(err)
+	return err
+}
+
+// tuplePool returns the pool used to build index tuples for |m|.
+func tuplePool(m prolly.Map, opts BuildOptions) pool.BuffPool {
+	if opts.IndexTuplePool != nil {
+		return opts.IndexTuplePool
+	}
+	return m.Pool()
+}
+
+// buildMonitorInterval is the number of rows processed between checks of the build's elapsed time, and between
+// waits on BuildOptions.IndexBuildLimiter.
+const buildMonitorInterval = 256
+
+// buildMonitor tracks the progress of an index build and enforces the limits
+// configured in BuildOptions.
+type buildMonitor struct {
+	idx     schema.Index
+	timeout time.Duration
+	start   time.Time
+	rows    uint64
+	indexes uint64
+	nulls   []uint64
+	stats   *IndexBuildStats
+	limiter IndexBuildLimiter
+	// flushPartial is true if a canceled build returns its partial index
+	flushPartial bool
+	// distinct holds the hashes of the distinct values seen so far, if the
+	// build's distinct values are capped at maxDistinct
+	maxDistinct uint64
+	distinct    map[uint64]struct{}
+	fields      [][]byte
+	// geohash is true if the keys of the build are prefixed with a geohash
+	geohash bool
+	// skipped are the formatted primary keys of the rows skipped for an
+	// oversized field
+	skipped []string
+	// histBuckets is the number of buckets of the histogram of the index, or
+	// zero if no histogram is built
+	histBuckets int
+}
+
+func newBuildMonitor(idx schema.Index, opts BuildOptions) *buildMonitor {
+	return &buildMonitor{
+		idx:          idx,
+		timeout:      opts.MaxIndexBuildDuration,
+		start:        time.Now(),
+		nulls:        make([]uint64, idx.Count()),
+		stats:        opts.IndexBuildStats,
+		limiter:      opts.IndexBuildLimiter,
+		flushPartial: opts.FlushPartialIndexOnCancel,
+		maxDistinct:  opts.MaxIndexDistinctValues,
+		geohash:      opts.IndexGeohashPrecision != 0,
+		histBuckets:  opts.IndexHistogramBuckets,
+	}
+}
+
+// row records that a primary row has been read, returning an
+// ErrIndexBuildTimeout if the build has exceeded its maximum duration. If the
+// build is throttled, row waits on the limiter once per interval of rows.
+func (m *buildMonitor) row(ctx context.Context) error {
+	if m.rows%buildMonitorInterval == 0 {
+		if m.timeout > 0 && m.rows > 0 && time.Since(m.start) > m.timeout {
+			return ErrIndexBuildTimeout{IndexName: m.idx.Name(), Timeout: m.timeout, RowsProcessed: m.rows}
+		}
+		if m.limiter != nil {
+			if err := m.limiter.WaitN(ctx, buildMonitorInterval); err != nil {
+				return err
+			}
+		}
+		if m.flushPartial && ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+	m.rows++
+	return nil
+}
+
+// partial returns the error |err| that stopped a build. If the build was
+// canceled and BuildOptions.FlushPartialIndexOnCancel is set, the entries
+// in |mut| are flushed and returned in an ErrPartialIndexBuild wrapping |err|.
+func (m *buildMonitor) partial(err error, mut prolly.MutableMap) error {
+	if !m.flushPartial || !(errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)) {
+		return err
+	}
+	// the build's context is done, so the flush cannot use it
+	pm, ferr := mut.Map(context.Background())
+	if ferr != nil {
+		return err
+	}
+	return ErrPartialIndexBuild{
+		IndexName:     m.idx.Name(),
+		RowsProcessed: m.rows,
+		Partial:       durable.IndexFromProllyMap(pm),
+		Err:           err,
+	}
+}
+
+// oversized records that the primary row |k| was skipped for an oversized
+// field.
+func (m *buildMonitor) oversized(pkd val.TupleDesc, k val.Tuple) {
+	if m.stats != nil {
+		m.skipped = append(m.skipped, pkd.Format(k))
+	}
+}
+
+// null records a NULL value for the index key field |i|. Fields of the
+// appended primary key are ignored.
+func (m *buildMonitor) null(i int) {
+	if i < len(m.nulls) {
+		m.nulls[i]++
+	}
+}
+
+// value records the value of the indexed columns of |key|, returning an
+// ErrIndexCardinalityExceeded if the build's distinct values are capped and
+// the cap is exceeded. Values are counted by hash, so a hash collision could
+// undercount them, and NULL is counted as a value.
+func (m *buildMonitor) value(key val.Tuple) error {
+	if m.maxDistinct == 0 {
+		return nil
+	}
+	if m.distinct == nil {
+		m.distinct = make(map[uint64]struct{})
+		// the time bucket or geohash of a key is derived from its indexed values
+		n := m.idx.Count()
+		if m.idx.TimeBucket() != 0 || m.geohash {
+			n++
+		}
+		m.fields = make([][]byte, n)
+	}
+	for i := range m.fields {
+		m.fields[i] = key.GetField(i)
+	}
+	m.distinct[dedupHash(m.fields)] = struct{}{}
+	if uint64(len(m.distinct)) > m.maxDistinct {
+		return ErrIndexCardinalityExceeded{IndexName: m.idx.Name(), MaxDistinct: m.maxDistinct, RowsProcessed: m.rows}
+	}
+	return nil
+}
+
+// indexed records that an entry was written to the index.
+func (m *buildMonitor) indexed() {
+	m.indexes++
+}
+
+// finish reports the build's statistics to BuildOptions.IndexBuildStats.
+// |secondary| is the index data that was built, whose histogram is built if
+// BuildOptions.IndexHistogramBuckets is set.
+func (m *buildMonitor) finish(ctx context.Context, secondary prolly.Map) error {
+	if m.stats == nil {
+		return nil
+	}
+	m.stats.RowsScanned = m.rows
+	m.stats.RowsIndexed = m.indexes
+	m.stats.OversizedRows = m.skipped
+	m.stats.Histogram = nil
+	if m.histBuckets > 0 {
+		h, err := indexHistogram(ctx, m.idx, secondary, m.histBuckets)
+		if err != nil {
+			return err
+		}
+		m.stats.Histogram = &h
+	}
+	m.stats.NullCounts = make(map[string]uint64, len(m.nulls))
+	for i, name := range m.idx.ColumnNames() {
+		m.stats.NullCounts[name] = m.nulls[i]
+	}
+
+	logical, stored, err := indexBytes(ctx, secondary)
+	if err != nil {
+		return err
+	}
+	m.stats.LogicalBytes, m.stats.StoredBytes = logical, stored
+	m.stats.WriteAmplification = 0
+	if logical > 0 {
+		m.stats.WriteAmplification = float64(stored) / float64(logical)
+	}
+	return nil
+}
+
+// indexBytes returns the total size of the keys and values of the entries of
+// |m|, and the total size of the nodes of its tree. The chunk store does not
+// count the bytes written to it, so the size of the tree is the size of the
+// chunks as they are written, before the chunk store compresses them.
+func indexBytes(ctx context.Context, m prolly.Map) (logical, stored uint64, err error) {
+	iter, err := m.IterAll(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+	for {
+		k, v, err := iter.Next(ctx)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return 0, 0, err
+		}
+		logical += uint64(len(k) + len(v))
+	}
+	err = m.WalkNodes(ctx, func(ctx context.Context, nd tree.Node) error {
+		stored += uint64(nd.Size())
+		return nil
+	})
+	return logical, stored, err
+}
+
 // PrefixItr iterates all keys of a given prefix |p| and its descriptor |d| in
 // map |m|.
 type PrefixItr struct {
 	itr prolly.MapIter
 	p   val.Tuple
 	d   val.TupleDesc
+	// limit is the maximum number of matches returned, or zero for no limit
+	limit int
+	count int
 }
 
 func NewPrefixItr(ctx context.Context, p val.Tuple, d val.TupleDesc, m rangeIterator) (PrefixItr, error) {
@@ -339,7 +1499,26 @@ func NewPrefixItr(ctx context.Context, p val.Tuple, d val.TupleDesc, m rangeIter
 	return PrefixItr{p: p, d: d, itr: itr}, nil
 }
 
-func (itr PrefixItr) Next(ctx context.Context) (k, v val.Tuple, err error) {
+// NewPrefixItrLimit returns a PrefixItr that returns at most |limit| matches.
+// Once |limit| matches have been returned, Next returns ErrPrefixLimitReached
+// without reading further from |m|.
+func NewPrefixItrLimit(ctx context.Context, p val.Tuple, d val.TupleDesc, m rangeIterator, limit int) (PrefixItr, error) {
+	if limit <= 0 {
+		return PrefixItr{}, fmt.Errorf("invalid prefix iterator limit %d", limit)
+	}
+	itr, err := NewPrefixItr(ctx, p, d, m)
+	if err != nil {
+		return PrefixItr{}, err
+	}
+	itr.limit = limit
+	return itr, nil
+}
+
+func (itr *PrefixItr) Next(ctx context.Context) (k, v val.Tuple, err error) {
+	if itr.limit > 0 && itr.count >= itr.limit {
+		return nil, nil, ErrPrefixLimitReached
+	}
+
 OUTER:
 	for {
 		k, v, err = itr.itr.Next(ctx)
@@ -348,16 +1527,18 @@ OUTER:
 		}
 
 		// check if p is a prefix of k
-		// range iteration currently can return keys not in the range
+		// range iteration currently can return keys not in the range.
+		// fields can be equal without being byte-equal, e.g. -0 and 0
 		for i := 0; i < itr.p.Count(); i++ {
 			f1 := itr.p.GetField(i)
 			f2 := k.GetField(i)
-			if bytes.Compare(f1, f2) != 0 {
+			if itr.d.Comparator().CompareValues(f1, f2, itr.d.Types[i]) != 0 {
 				// if a field in the prefix does not match |k|, go to the next row
 				continue OUTER
 			}
 		}
 
+		itr.count++
 		return k, v, nil
 	}
 }
@@ -366,19 +1547,25 @@ type rangeIterator interface {
 	IterRange(ctx context.Context, rng prolly.Range) (prolly.MapIter, error)
 }
 
-func GetIndexKeyMapping(sch schema.Schema, idx schema.Index) (m val.OrdinalMapping) {
-	m = make(val.OrdinalMapping, len(idx.AllTags()))
+// GetIndexKeyMapping returns a mapping from the fields of the index key of |idx| to the fields of a row of |sch|,
+// where the fields of a row are its key fields followed by its value fields. It returns an error if a column of |idx|
+// does not exist in |sch|, e.g. if it was dropped before the index was.
+func GetIndexKeyMapping(sch schema.Schema, idx schema.Index) (val.OrdinalMapping, error) {
+	m := make(val.OrdinalMapping, len(idx.AllTags()))
 
 	for i, tag := range idx.AllTags() {
 		j, ok := sch.GetPKCols().TagToIdx[tag]
 		if !ok {
-			j = sch.GetNonPKCols().TagToIdx[tag]
+			j, ok = sch.GetNonPKCols().TagToIdx[tag]
+			if !ok {
+				return nil, fmt.Errorf("index `%s` references column with tag %d which does not exist in the table", idx.Name(), tag)
+			}
 			j += sch.GetPKCols().Size()
 		}
 		m[i] = j
 	}
 
-	return
+	return m, nil
 }
 
 var _ error = (*prollyUniqueKeyErr)(nil)
@@ -0,0 +1,1130 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/dolthub/dolt/go/libraries/doltcore/doltdb"
+	"github.com/dolthub/dolt/go/libraries/doltcore/doltdb/durable"
+	"github.com/dolthub/dolt/go/libraries/doltcore/schema"
+	"github.com/dolthub/dolt/go/store/chunks"
+	"github.com/dolthub/dolt/go/store/hash"
+	"github.com/dolthub/dolt/go/store/pool"
+	"github.com/dolthub/dolt/go/store/prolly"
+	"github.com/dolthub/dolt/go/store/prolly/shim"
+	"github.com/dolthub/dolt/go/store/prolly/tree"
+	"github.com/dolthub/dolt/go/store/types"
+	"github.com/dolthub/dolt/go/store/val"
+)
+
+const (
+	pkTag uint64 = iota
+	c1Tag
+	c2Tag
+)
+
+var sharePool = pool.NewBuffPool()
+
+func newTestVRW() types.ValueReadWriter {
+	ts := &chunks.TestStorage{}
+	return types.NewValueStore(ts.NewViewWithFormat(types.Format_DOLT_1.VersionString()))
+}
+
+// newTestSchema returns a schema of (pk int primary key, c1 int, c2 varchar).
+func newTestSchema(t testing.TB) schema.Schema {
+	sch, err := schema.SchemaFromCols(schema.NewColCollection(
+		schema.NewColumn("pk", pkTag, types.IntKind, true, schema.NotNullConstraint{}),
+		schema.NewColumn("c1", c1Tag, types.IntKind, false),
+		schema.NewColumn("c2", c2Tag, types.StringKind, false),
+	))
+	require.NoError(t, err)
+	return sch
+}
+
+// newTestTable returns a table with schema |sch| containing |rows|. Each row holds the primary key values followed
+// by the non-primary key values, as int, string, []byte, float64, time.Time, json.RawMessage, uint16 enum ordinals,
+// uint64 set bits, hash.Hash blob addresses or nil.
+func newTestTable(t *testing.T, ctx context.Context, vrw types.ValueReadWriter, sch schema.Schema, rows [][]interface{}) *doltdb.Table {
+	primary := newTestPrimary(t, ctx, vrw, sch, rows)
+	indexes, err := durable.NewIndexSetWithEmptyIndexes(ctx, vrw, sch)
+	require.NoError(t, err)
+	tbl, err := doltdb.NewTable(ctx, vrw, sch, durable.IndexFromProllyMap(primary), indexes, nil)
+	require.NoError(t, err)
+	return tbl
+}
+
+func newTestPrimary(t testing.TB, ctx context.Context, vrw types.ValueReadWriter, sch schema.Schema, rows [][]interface{}) prolly.Map {
+	empty, err := durable.NewEmptyIndex(ctx, vrw, sch)
+	require.NoError(t, err)
+	m := durable.ProllyMapFromIndex(empty)
+
+	kd, vd := shim.MapDescriptorsFromSchema(sch)
+	kb, vb := val.NewTupleBuilder(kd), val.NewTupleBuilder(vd)
+	mut := m.Mutate()
+	for _, row := range rows {
+		for i := 0; i < kd.Count(); i++ {
+			putTestField(kb, i, row[i])
+		}
+		for i := 0; i < vd.Count(); i++ {
+			putTestField(vb, i, row[kd.Count()+i])
+		}
+		require.NoError(t, mut.Put(ctx, kb.Build(m.Pool()), vb.Build(m.Pool())))
+	}
+	m, err = mut.Map(ctx)
+	require.NoError(t, err)
+	return m
+}
+
+func putTestField(tb *val.TupleBuilder, i int, v interface{}) {
+	switch v := v.(type) {
+	case nil:
+	case int:
+		tb.PutInt64(i, int64(v))
+	case string:
+		tb.PutString(i, v)
+	case []byte:
+		tb.PutByteString(i, v)
+	case float64:
+		tb.PutFloat64(i, v)
+	case time.Time:
+		tb.PutDatetime(i, v)
+	case json.RawMessage:
+		tb.PutJSON(i, v)
+	case uint16:
+		tb.PutEnum(i, v)
+	case uint64:
+		tb.PutSet(i, v)
+	case hash.Hash:
+		tb.PutBytesAddr(i, v)
+	default:
+		panic("unsupported test value")
+	}
+}
+
+// collectKeys returns the formatted keys of |m| in order.
+func collectKeys(t *testing.T, ctx context.Context, m prolly.Map) []string {
+	kd, _ := m.Descriptors()
+	iter, err := m.IterAll(ctx)
+	require.NoError(t, err)
+	var keys []string
+	for {
+		k, _, err := iter.Next(ctx)
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		s, err := formatKey(k, kd)
+		require.NoError(t, err)
+		keys = append(keys, s)
+	}
+	return keys
+}
+
+func TestAnalyzeIndexCandidate(t *testing.T) {
+	ctx := context.Background()
+	vrw := newTestVRW()
+	sch := newTestSchema(t)
+
+	var rows [][]interface{}
+	for i := 0; i < 1000; i++ {
+		rows = append(rows, []interface{}{i, i % 2, "row"})
+	}
+	tbl := newTestTable(t, ctx, vrw, sch, rows)
+
+	report, err := AnalyzeIndexCandidate(ctx, tbl, []string{"PK"}, BuildOptions{})
+	require.NoError(t, err)
+	require.Equal(t, []string{"pk"}, report.Columns)
+	require.Equal(t, uint64(1000), report.SampledRows)
+	require.Equal(t, uint64(1000), report.EstimatedDistinct)
+	require.Equal(t, 1.0, report.Confidence)
+	require.True(t, report.LikelyUseful)
+
+	report, err = AnalyzeIndexCandidate(ctx, tbl, []string{"c1"}, BuildOptions{})
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), report.EstimatedDistinct)
+	require.False(t, report.LikelyUseful)
+
+	report, err = AnalyzeIndexCandidate(ctx, tbl, []string{"c1", "c2"}, BuildOptions{})
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), report.EstimatedDistinct)
+
+	_, err = AnalyzeIndexCandidate(ctx, tbl, []string{"missing"}, BuildOptions{})
+	require.Error(t, err)
+
+	after, err := tbl.GetSchema(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 0, after.Indexes().Count())
+}
+
+func TestAnalyzeIndexCandidateSampled(t *testing.T) {
+	ctx := context.Background()
+	vrw := newTestVRW()
+	sch := newTestSchema(t)
+
+	var rows [][]interface{}
+	for i := 0; i < 3*candidateSampleSize; i++ {
+		rows = append(rows, []interface{}{i, i, "row"})
+	}
+	tbl := newTestTable(t, ctx, vrw, sch, rows)
+
+	report, err := AnalyzeIndexCandidate(ctx, tbl, []string{"c1"}, BuildOptions{})
+	require.NoError(t, err)
+	require.Equal(t, uint64(candidateSampleSize), report.SampledRows)
+	require.Less(t, report.Confidence, 1.0)
+	require.InDelta(t, float64(report.TotalRows), float64(report.EstimatedDistinct), float64(report.TotalRows)*0.1)
+	require.True(t, report.LikelyUseful)
+}
+
+func TestAnalyzeIndexCandidateSeeded(t *testing.T) {
+	ctx := context.Background()
+	vrw := newTestVRW()
+	sch := newTestSchema(t)
+
+	// c1 is distinct in the first block of each stride of the sample, and constant in the rest of it
+	stride := 3 * candidateSampleBlock
+	var rows [][]interface{}
+	for i := 0; i < 3*candidateSampleSize; i++ {
+		c1 := -1
+		if i%stride < candidateSampleBlock {
+			c1 = i
+		}
+		rows = append(rows, []interface{}{i, c1, "row"})
+	}
+	tbl := newTestTable(t, ctx, vrw, sch, rows)
+
+	unseeded, err := AnalyzeIndexCandidate(ctx, tbl, []string{"c1"}, BuildOptions{})
+	require.NoError(t, err)
+	seeded, err := AnalyzeIndexCandidate(ctx, tbl, []string{"c1"}, BuildOptions{ExecutionOptions: ExecutionOptions{IndexSampleSeed: 42}})
+	require.NoError(t, err)
+	require.Equal(t, uint64(candidateSampleSize), seeded.SampledRows)
+	require.Less(t, seeded.EstimatedDistinct, unseeded.EstimatedDistinct)
+
+	// the same seed samples the same rows
+	for i := 0; i < 3; i++ {
+		again, err := AnalyzeIndexCandidate(ctx, tbl, []string{"c1"}, BuildOptions{ExecutionOptions: ExecutionOptions{IndexSampleSeed: 42}})
+		require.NoError(t, err)
+		require.Equal(t, seeded, again)
+	}
+	other, err := AnalyzeIndexCandidate(ctx, tbl, []string{"c1"}, BuildOptions{ExecutionOptions: ExecutionOptions{IndexSampleSeed: 7}})
+	require.NoError(t, err)
+	require.NotEqual(t, seeded.EstimatedDistinct, other.EstimatedDistinct)
+
+	// sampleBlocks reads the same rows for the same seed, as sampled verification does
+	m, err := tbl.GetRowData(ctx)
+	require.NoError(t, err)
+	primary := durable.ProllyMapFromIndex(m)
+	sample := func(seed int64) (keys []string) {
+		kd, _ := primary.Descriptors()
+		_, err := sampleBlocks(ctx, primary, 1000, seed, func(k, _ val.Tuple) error {
+			keys = append(keys, kd.Format(k))
+			return nil
+		})
+		require.NoError(t, err)
+		return keys
+	}
+	require.Len(t, sample(42), 1000)
+	require.Equal(t, sample(42), sample(42))
+	require.NotEqual(t, sample(42), sample(7))
+	require.NotEqual(t, sample(0), sample(42))
+}
+
+func TestVerifyIndexRowCount(t *testing.T) {
+	ctx := context.Background()
+	vrw := newTestVRW()
+	sch := newTestSchema(t)
+	tbl := newTestTable(t, ctx, vrw, sch, [][]interface{}{
+		{1, 10, "a"},
+		{2, 10, "b"},
+		{3, nil, "c"},
+	})
+
+	opts := BuildOptions{ExecutionOptions: ExecutionOptions{VerifyIndexRowCount: true}}
+	ret, err := CreateIndex(ctx, tbl, "c1_idx", []string{"c1"}, false, true, "", opts)
+	require.NoError(t, err)
+
+	empty, err := durable.NewEmptyIndex(ctx, vrw, ret.NewIndex.Schema())
+	require.NoError(t, err)
+	err = verifyIndexRowCount(ctx, ret.NewTable, ret.NewIndex, empty, opts)
+	require.ErrorIs(t, err, ErrIndexRowCountMismatch)
+
+	// partial indexes are not checked
+	opts.IndexRowFilter = func(ctx context.Context, k, v val.Tuple) (bool, error) {
+		return false, nil
+	}
+	err = verifyIndexRowCount(ctx, ret.NewTable, ret.NewIndex, empty, opts)
+	require.NoError(t, err)
+}
+
+func TestMaxIndexBuildDuration(t *testing.T) {
+	ctx := context.Background()
+	vrw := newTestVRW()
+	sch := newTestSchema(t)
+
+	var rows [][]interface{}
+	for i := 0; i < 1000; i++ {
+		rows = append(rows, []interface{}{i, i, "row"})
+	}
+	tbl := newTestTable(t, ctx, vrw, sch, rows)
+
+	for _, unique := range []bool{false, true} {
+		_, err := CreateIndex(ctx, tbl, "c1_idx", []string{"c1"}, unique, true, "", BuildOptions{
+			ExecutionOptions: ExecutionOptions{
+				MaxIndexBuildDuration: time.Nanosecond,
+			},
+		})
+		var timeoutErr ErrIndexBuildTimeout
+		require.ErrorAs(t, err, &timeoutErr)
+		require.Equal(t, "c1_idx", timeoutErr.IndexName)
+		require.Equal(t, uint64(buildMonitorInterval), timeoutErr.RowsProcessed)
+	}
+
+	existing, err := tbl.GetSchema(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 0, existing.Indexes().Count())
+
+	_, err = CreateIndex(ctx, tbl, "c1_idx", []string{"c1"}, false, true, "", BuildOptions{
+		ExecutionOptions: ExecutionOptions{
+			MaxIndexBuildDuration: time.Hour,
+		},
+	})
+	require.NoError(t, err)
+}
+
+// sleepLimiter is an IndexBuildLimiter that sleeps for |delay| on every wait.
+type sleepLimiter struct {
+	delay  time.Duration
+	tokens int
+}
+
+func (l *sleepLimiter) WaitN(ctx context.Context, n int) error {
+	l.tokens += n
+	time.Sleep(l.delay)
+	return ctx.Err()
+}
+
+func TestIndexBuildLimiter(t *testing.T) {
+	ctx := context.Background()
+	vrw := newTestVRW()
+	sch := newTestSchema(t)
+
+	var rows [][]interface{}
+	for i := 0; i < 1000; i++ {
+		rows = append(rows, []interface{}{i, i, "row"})
+	}
+	tbl := newTestTable(t, ctx, vrw, sch, rows)
+
+	for _, unique := range []bool{false, true} {
+		limiter := &sleepLimiter{delay: 10 * time.Millisecond}
+		start := time.Now()
+		_, err := CreateIndex(ctx, tbl, "c1_idx", []string{"c1"}, unique, true, "", BuildOptions{
+			ExecutionOptions: ExecutionOptions{
+				IndexBuildLimiter: limiter,
+			},
+		})
+		require.NoError(t, err)
+		// every row must be paid for before it is read
+		require.GreaterOrEqual(t, limiter.tokens, len(rows))
+		require.GreaterOrEqual(t, time.Since(start), 4*limiter.delay)
+	}
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err := CreateIndex(canceled, tbl, "c1_idx", []string{"c1"}, false, true, "", BuildOptions{
+		ExecutionOptions: ExecutionOptions{
+			IndexBuildLimiter: &sleepLimiter{},
+		},
+	})
+	require.ErrorIs(t, err, context.Canceled)
+}
+
+func TestIndexTuplePool(t *testing.T) {
+	ctx := context.Background()
+	vrw := newTestVRW()
+	sch := newTestSchema(t)
+
+	var rows [][]interface{}
+	for i := 0; i < 1000; i++ {
+		rows = append(rows, []interface{}{i, i % 7, "row"})
+	}
+	tbl := newTestTable(t, ctx, vrw, sch, rows)
+
+	for _, unique := range []bool{false, true} {
+		var hashes []hash.Hash
+		for _, opts := range []BuildOptions{{}, {ExecutionOptions: ExecutionOptions{IndexTuplePool: pool.NewSlabBuffPool(4096)}}} {
+			ret, err := CreateIndex(ctx, tbl, "c2_pk_idx", []string{"c2", "pk"}, unique, true, "", opts)
+			require.NoError(t, err)
+			idx, err := ret.NewTable.GetIndexRowData(ctx, "c2_pk_idx")
+			require.NoError(t, err)
+			hashes = append(hashes, durable.ProllyMapFromIndex(idx).HashOf())
+		}
+		require.Equal(t, hashes[0], hashes[1])
+	}
+}
+
+func BenchmarkIndexTuplePool(b *testing.B) {
+	ctx := context.Background()
+	vrw := newTestVRW()
+	sch := newTestSchema(b)
+	var rows [][]interface{}
+	for i := 0; i < 10_000; i++ {
+		rows = append(rows, []interface{}{i, i % 100, "row"})
+	}
+	primary := newTestPrimary(b, ctx, vrw, sch, rows)
+	idx, err := schema.NewIndexCollection(sch.GetAllCols(), sch.GetPKCols()).AddIndexByColNames(
+		"c1_idx", []string{"c1"}, schema.IndexProperties{})
+	require.NoError(b, err)
+
+	b.Run("default", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_, err := BuildSecondaryProllyIndex(ctx, vrw, sch, idx, primary, BuildOptions{})
+			require.NoError(b, err)
+		}
+	})
+	b.Run("slab", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			opts := BuildOptions{ExecutionOptions: ExecutionOptions{IndexTuplePool: pool.NewSlabBuffPool(1 << 16)}}
+			_, err := BuildSecondaryProllyIndex(ctx, vrw, sch, idx, primary, opts)
+			require.NoError(b, err)
+		}
+	})
+}
+
+func TestPrefixItrLimit(t *testing.T) {
+	ctx := context.Background()
+	vrw := newTestVRW()
+	sch := newTestSchema(t)
+
+	var rows [][]interface{}
+	for i := 0; i < 20; i++ {
+		rows = append(rows, []interface{}{i, i % 2, "row"})
+	}
+	tbl := newTestTable(t, ctx, vrw, sch, rows)
+	ret, err := CreateIndex(ctx, tbl, "c1_idx", []string{"c1"}, false, true, "", BuildOptions{})
+	require.NoError(t, err)
+	idx, err := ret.NewTable.GetIndexRowData(ctx, "c1_idx")
+	require.NoError(t, err)
+	secondary := durable.ProllyMapFromIndex(idx)
+
+	kd, _ := secondary.Descriptors()
+	prefixKD := kd.PrefixDesc(1)
+	pb := val.NewTupleBuilder(prefixKD)
+	pb.PutInt64(0, 1)
+	prefix := pb.Build(secondary.Pool())
+
+	countMatches := func(itr PrefixItr) (int, error) {
+		n := 0
+		for {
+			_, _, err := itr.Next(ctx)
+			if err != nil {
+				return n, err
+			}
+			n++
+		}
+	}
+
+	itr, err := NewPrefixItrLimit(ctx, prefix, prefixKD, secondary, 3)
+	require.NoError(t, err)
+	n, err := countMatches(itr)
+	require.ErrorIs(t, err, ErrPrefixLimitReached)
+	require.Equal(t, 3, n)
+
+	itr, err = NewPrefixItrLimit(ctx, prefix, prefixKD, secondary, 10)
+	require.NoError(t, err)
+	n, err = countMatches(itr)
+	require.ErrorIs(t, err, ErrPrefixLimitReached)
+	require.Equal(t, 10, n)
+
+	itr, err = NewPrefixItrLimit(ctx, prefix, prefixKD, secondary, 11)
+	require.NoError(t, err)
+	n, err = countMatches(itr)
+	require.Equal(t, io.EOF, err)
+	require.Equal(t, 10, n)
+
+	itr, err = NewPrefixItr(ctx, prefix, prefixKD, secondary)
+	require.NoError(t, err)
+	n, err = countMatches(itr)
+	require.Equal(t, io.EOF, err)
+	require.Equal(t, 10, n)
+
+	_, err = NewPrefixItrLimit(ctx, prefix, prefixKD, secondary, 0)
+	require.Error(t, err)
+}
+
+func TestIndexBuildStats(t *testing.T) {
+	ctx := context.Background()
+	vrw := newTestVRW()
+	sch := newTestSchema(t)
+	tbl := newTestTable(t, ctx, vrw, sch, [][]interface{}{
+		{1, 10, "a"},
+		{2, nil, "b"},
+		{3, nil, nil},
+		{4, 40, nil},
+		{5, 50, nil},
+	})
+
+	for _, unique := range []bool{false, true} {
+		var stats IndexBuildStats
+		_, err := CreateIndex(ctx, tbl, "c1c2", []string{"c1", "c2"}, unique, true, "", BuildOptions{
+			ExecutionOptions: ExecutionOptions{
+				IndexBuildStats: &stats,
+			},
+		})
+		require.NoError(t, err)
+		require.Equal(t, uint64(5), stats.RowsScanned)
+		require.Equal(t, uint64(5), stats.RowsIndexed)
+		require.Equal(t, map[string]uint64{"c1": 2, "c2": 3}, stats.NullCounts)
+	}
+}
+
+func TestIndexBuildWriteAmplification(t *testing.T) {
+	ctx := context.Background()
+	vrw := newTestVRW()
+	sch := newTestSchema(t)
+	idx, err := schema.NewIndexCollection(sch.GetAllCols(), sch.GetPKCols()).AddIndexByColNames(
+		"c1_idx", []string{"c1"}, schema.IndexProperties{})
+	require.NoError(t, err)
+
+	var stats IndexBuildStats
+	_, err = BuildSecondaryProllyIndex(ctx, vrw, sch, idx, newTestPrimary(t, ctx, vrw, sch, nil), BuildOptions{ExecutionOptions: ExecutionOptions{IndexBuildStats: &stats}})
+	require.NoError(t, err)
+	require.Zero(t, stats.LogicalBytes)
+	require.Zero(t, stats.WriteAmplification)
+
+	var rows [][]interface{}
+	for i := 0; i < 5000; i++ {
+		rows = append(rows, []interface{}{i, i % 100, nil})
+	}
+	built, err := BuildSecondaryProllyIndex(ctx, vrw, sch, idx, newTestPrimary(t, ctx, vrw, sch, rows), BuildOptions{ExecutionOptions: ExecutionOptions{IndexBuildStats: &stats}})
+	require.NoError(t, err)
+
+	// every key holds two int64 fields, and every value is an empty tuple
+	kd, _ := durable.ProllyMapFromIndex(built).Descriptors()
+	kb := val.NewTupleBuilder(kd)
+	kb.PutInt64(0, 0)
+	kb.PutInt64(1, 0)
+	require.Equal(t, uint64(5000*(len(kb.Build(sharePool))+len(val.EmptyTuple))), stats.LogicalBytes)
+
+	var stored uint64
+	var nodes int
+	require.NoError(t, durable.ProllyMapFromIndex(built).WalkNodes(ctx, func(ctx context.Context, nd tree.Node) error {
+		stored += uint64(nd.Size())
+		nodes++
+		return nil
+	}))
+	require.Greater(t, nodes, 1)
+	require.Equal(t, stored, stats.StoredBytes)
+	require.Greater(t, stats.WriteAmplification, 1.0)
+	require.InDelta(t, float64(stats.StoredBytes)/float64(stats.LogicalBytes), stats.WriteAmplification, 1e-9)
+}
+
+func TestBuildUniqueProllyIndexBatched(t *testing.T) {
+	ctx := context.Background()
+	vrw := newTestVRW()
+	sch := newTestSchema(t)
+
+	var rows [][]interface{}
+	for i := 0; i < 12; i++ {
+		rows = append(rows, []interface{}{i, i % 2, "row"})
+	}
+	primary := newTestPrimary(t, ctx, vrw, sch, rows)
+	idx, err := schema.NewIndexCollection(sch.GetAllCols(), sch.GetPKCols()).AddIndexByColNames(
+		"c1_uniq", []string{"c1"}, schema.IndexProperties{IsUnique: true})
+	require.NoError(t, err)
+
+	var batches []int
+	var dups int
+	_, err = BuildUniqueProllyIndexBatched(ctx, vrw, sch, idx, primary, BuildOptions{}, 4, func(ctx context.Context, batch []DupEntry) error {
+		batches = append(batches, len(batch))
+		for _, d := range batch {
+			require.Equal(t, d.ExistingKey.GetField(0), d.NewKey.GetField(0))
+		}
+		dups += len(batch)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, []int{4, 4, 2}, batches)
+	require.Equal(t, 10, dups)
+
+	_, err = BuildUniqueProllyIndexBatched(ctx, vrw, sch, idx, primary, BuildOptions{}, 0, nil)
+	require.Error(t, err)
+}
+
+func TestBuildUniqueProllyIndexWithRows(t *testing.T) {
+	ctx := context.Background()
+	vrw := newTestVRW()
+	sch := newTestSchema(t)
+	primary := newTestPrimary(t, ctx, vrw, sch, [][]interface{}{
+		{1, 10, "first"},
+		{2, 20, "other"},
+		{3, 10, "second"},
+	})
+	idx, err := schema.NewIndexCollection(sch.GetAllCols(), sch.GetPKCols()).AddIndexByColNames(
+		"c1_uniq", []string{"c1"}, schema.IndexProperties{IsUnique: true})
+	require.NoError(t, err)
+
+	kd, vd := primary.Descriptors()
+	var dups [][2]string
+	_, err = BuildUniqueProllyIndexWithRows(ctx, vrw, sch, idx, primary, BuildOptions{}, func(ctx context.Context, existingKey, existingVal, newKey, newVal val.Tuple) error {
+		dups = append(dups, [2]string{kd.Format(existingKey) + vd.Format(existingVal), kd.Format(newKey) + vd.Format(newVal)})
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, [][2]string{{"( 1 )( 10, first )", "( 3 )( 10, second )"}}, dups)
+}
+
+func TestCreateIndexByTags(t *testing.T) {
+	ctx := context.Background()
+	vrw := newTestVRW()
+	sch := newTestSchema(t)
+	tbl := newTestTable(t, ctx, vrw, sch, [][]interface{}{
+		{1, 10, "b"},
+		{2, 20, "a"},
+	})
+
+	ret, err := CreateIndexByTags(ctx, tbl, "", []uint64{c2Tag, c1Tag}, false, true, "", BuildOptions{})
+	require.NoError(t, err)
+	require.Equal(t, "c2c1", ret.NewIndex.Name())
+	require.Equal(t, []string{"c2", "c1"}, ret.NewIndex.ColumnNames())
+
+	idx, err := ret.NewTable.GetIndexRowData(ctx, "c2c1")
+	require.NoError(t, err)
+	require.Equal(t, []string{"[a,20,2]", "[b,10,1]"}, collectKeys(t, ctx, durable.ProllyMapFromIndex(idx)))
+
+	_, err = CreateIndexByTags(ctx, tbl, "bad", []uint64{c1Tag, 1234}, false, true, "", BuildOptions{})
+	require.Error(t, err)
+}
+
+func TestPkSuffixOrder(t *testing.T) {
+	ctx := context.Background()
+	vrw := newTestVRW()
+	sch, err := schema.SchemaFromCols(schema.NewColCollection(
+		schema.NewColumn("a", 1, types.IntKind, true, schema.NotNullConstraint{}),
+		schema.NewColumn("b", 2, types.IntKind, true, schema.NotNullConstraint{}),
+		schema.NewColumn("c", 3, types.IntKind, false),
+	))
+	require.NoError(t, err)
+	tbl := newTestTable(t, ctx, vrw, sch, [][]interface{}{
+		{1, 2, 10},
+		{2, 1, 10},
+		{3, 0, 10},
+	})
+
+	ret, err := CreateIndexWithProperties(ctx, tbl, "c_ba", []uint64{3}, schema.IndexProperties{
+		IsUserDefined: true,
+		PkSuffixOrder: []uint64{2, 1},
+	}, BuildOptions{})
+	require.NoError(t, err)
+	require.Equal(t, []uint64{3, 2, 1}, ret.NewIndex.AllTags())
+
+	idx, err := ret.NewTable.GetIndexRowData(ctx, "c_ba")
+	require.NoError(t, err)
+	secondary := durable.ProllyMapFromIndex(idx)
+	require.Equal(t, []string{"[10,0,3]", "[10,1,2]", "[10,2,1]"}, collectKeys(t, ctx, secondary))
+
+	// index entries map back to their primary rows
+	rows, err := ret.NewTable.GetRowData(ctx)
+	require.NoError(t, err)
+	primary := durable.ProllyMapFromIndex(rows)
+	rb, err := NewCoveringRowBuilder(ret.Sch, ret.NewIndex)
+	require.NoError(t, err)
+	iter, err := secondary.IterAll(ctx)
+	require.NoError(t, err)
+	for {
+		idxKey, _, err := iter.Next(ctx)
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		k, _ := rb.Build(idxKey, sharePool)
+		ok, err := primary.Has(ctx, k)
+		require.NoError(t, err)
+		require.True(t, ok)
+	}
+
+	res, err := VerifySecondaryIndex(ctx, ret.NewTable, ret.NewIndex, BuildOptions{ExecutionOptions: ExecutionOptions{VerifySampleSize: 1}})
+	require.NoError(t, err)
+	require.True(t, res.Consistent())
+}
+
+func TestGetIndexKeyMappingMissingColumn(t *testing.T) {
+	ctx := context.Background()
+	sch := newTestSchema(t)
+	idx, err := sch.Indexes().AddIndexByColNames("c1_idx", []string{"c1"}, schema.IndexProperties{})
+	require.NoError(t, err)
+
+	m, err := GetIndexKeyMapping(sch, idx)
+	require.NoError(t, err)
+	require.Equal(t, val.OrdinalMapping{1, 0}, m)
+
+	// c1 is dropped before the index referencing it
+	dropped, err := schema.SchemaFromCols(schema.NewColCollection(
+		schema.NewColumn("pk", pkTag, types.IntKind, true, schema.NotNullConstraint{}),
+		schema.NewColumn("c2", c2Tag, types.StringKind, false),
+	))
+	require.NoError(t, err)
+	_, err = GetIndexKeyMapping(dropped, idx)
+	require.Error(t, err)
+
+	primary := newTestPrimary(t, ctx, newTestVRW(), dropped, [][]interface{}{{1, "a"}})
+	_, err = BuildSecondaryProllyIndex(ctx, newTestVRW(), dropped, idx, primary, BuildOptions{})
+	require.Error(t, err)
+}
+
+// sliceMapIter is a prolly.MapIter over a slice of key-value pairs, which need not be ordered or distinct.
+type sliceMapIter struct {
+	kvs [][2]val.Tuple
+}
+
+func (itr *sliceMapIter) Next(ctx context.Context) (k, v val.Tuple, err error) {
+	if len(itr.kvs) == 0 {
+		return nil, nil, io.EOF
+	}
+	kv := itr.kvs[0]
+	itr.kvs = itr.kvs[1:]
+	return kv[0], kv[1], nil
+}
+
+func TestDetectIndexKeyCollisions(t *testing.T) {
+	ctx := context.Background()
+	vrw := newTestVRW()
+	sch := newTestSchema(t)
+	idx, err := sch.Indexes().AddIndexByColNames("c1_idx", []string{"c1"}, schema.IndexProperties{})
+	require.NoError(t, err)
+
+	primary := newTestPrimary(t, ctx, vrw, sch, [][]interface{}{
+		{1, 10, "a"},
+		{2, 10, "b"},
+	})
+	var kvs [][2]val.Tuple
+	iter, err := primary.IterAll(ctx)
+	require.NoError(t, err)
+	for {
+		k, v, err := iter.Next(ctx)
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		kvs = append(kvs, [2]val.Tuple{k, v})
+	}
+	// a corrupt primary index that repeats a row
+	corrupt := append(kvs, kvs[0])
+
+	opts := BuildOptions{ExecutionOptions: ExecutionOptions{DetectIndexKeyCollisions: true}}
+	_, err = BuildSecondaryProllyIndexFromIter(ctx, vrw, sch, idx, &sliceMapIter{kvs: kvs}, opts)
+	require.NoError(t, err)
+	_, err = BuildSecondaryProllyIndexFromIter(ctx, vrw, sch, idx, &sliceMapIter{kvs: corrupt}, opts)
+	require.ErrorIs(t, err, ErrIndexKeyCollision)
+	_, err = BuildSecondaryProllyIndexFromIter(ctx, vrw, sch, idx, &sliceMapIter{kvs: corrupt}, BuildOptions{})
+	require.NoError(t, err)
+}
+
+// TestCompositeUniqueIndexNulls checks that a two-column unique index follows MySQL semantics: an entry with a NULL in
+// any indexed column never conflicts with another entry, and entries without NULLs must be distinct.
+func TestCompositeUniqueIndexNulls(t *testing.T) {
+	ctx := context.Background()
+	vrw := newTestVRW()
+	sch := newTestSchema(t)
+
+	tests := []struct {
+		name string
+		rows [][]interface{}
+		ok   bool
+	}{
+		{"both null", [][]interface{}{{1, nil, nil}, {2, nil, nil}}, true},
+		{"first null", [][]interface{}{{1, nil, "a"}, {2, nil, "a"}}, true},
+		{"second null", [][]interface{}{{1, 10, nil}, {2, 10, nil}}, true},
+		{"null and non-null", [][]interface{}{{1, 10, "a"}, {2, 10, nil}, {3, nil, "a"}, {4, nil, nil}}, true},
+		{"distinct non-null", [][]interface{}{{1, 10, "a"}, {2, 10, "b"}, {3, 20, "a"}}, true},
+		{"duplicate non-null", [][]interface{}{{1, 10, "a"}, {2, 10, nil}, {3, 10, "a"}}, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tbl := newTestTable(t, ctx, vrw, sch, test.rows)
+			ret, err := CreateIndex(ctx, tbl, "c1_c2_uniq", []string{"c1", "c2"}, true, true, "", BuildOptions{})
+			if !test.ok {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			rows, err := ret.NewTable.GetIndexRowData(ctx, "c1_c2_uniq")
+			require.NoError(t, err)
+			require.Equal(t, uint64(len(test.rows)), rows.Count())
+		})
+	}
+}
+
+func TestFloatIndexSpecialValues(t *testing.T) {
+	ctx := context.Background()
+	vrw := newTestVRW()
+	sch, err := schema.SchemaFromCols(schema.NewColCollection(
+		schema.NewColumn("pk", pkTag, types.IntKind, true, schema.NotNullConstraint{}),
+		schema.NewColumn("f", c1Tag, types.FloatKind, false),
+	))
+	require.NoError(t, err)
+	negZero := math.Copysign(0, -1)
+
+	// -Inf sorts first and +Inf last, before NULL
+	tbl := newTestTable(t, ctx, vrw, sch, [][]interface{}{
+		{2, math.Inf(1)}, {3, 1.5}, {4, nil}, {5, math.Inf(-1)}, {6, negZero}, {8, -1.5},
+	})
+	ret, err := CreateIndex(ctx, tbl, "f_idx", []string{"f"}, false, true, "", BuildOptions{})
+	require.NoError(t, err)
+	rows, err := ret.NewTable.GetIndexRowData(ctx, "f_idx")
+	require.NoError(t, err)
+	m := durable.ProllyMapFromIndex(rows)
+	kd, _ := m.Descriptors()
+	iter, err := m.IterAll(ctx)
+	require.NoError(t, err)
+	var pks []int64
+	for {
+		k, _, err := iter.Next(ctx)
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		pk, _ := kd.GetInt64(1, k)
+		pks = append(pks, pk)
+	}
+	require.Equal(t, []int64{5, 8, 6, 3, 2, 4}, pks)
+
+	// NaN cannot be indexed, and -0 and 0 are duplicates in unique indexes
+	tests := []struct {
+		name   string
+		rows   [][]interface{}
+		unique bool
+		ok     bool
+		err    error
+	}{
+		{"distinct", [][]interface{}{{1, math.Inf(1)}, {2, math.Inf(-1)}, {3, 0.0}, {4, nil}, {5, nil}}, true, true, nil},
+		{"inf", [][]interface{}{{1, math.Inf(1)}, {2, math.Inf(1)}}, true, false, nil},
+		{"zero", [][]interface{}{{1, negZero}, {2, 0.0}}, true, false, nil},
+		{"nan", [][]interface{}{{1, 1.0}, {2, math.NaN()}}, false, false, ErrIndexKeyNaN},
+		{"unique nan", [][]interface{}{{1, 1.0}, {2, math.NaN()}}, true, false, ErrIndexKeyNaN},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tbl := newTestTable(t, ctx, vrw, sch, test.rows)
+			_, err := CreateIndex(ctx, tbl, "f_uniq", []string{"f"}, test.unique, true, "", BuildOptions{})
+			if test.ok {
+				require.NoError(t, err)
+			} else if test.err != nil {
+				require.ErrorIs(t, err, test.err)
+			} else {
+				require.Error(t, err)
+			}
+		})
+	}
+}
+
+// cancelingIter wraps a prolly.MapIter and calls |cancel| once |after| rows have been read.
+type cancelingIter struct {
+	prolly.MapIter
+	after  int
+	cancel context.CancelFunc
+}
+
+func (itr *cancelingIter) Next(ctx context.Context) (k, v val.Tuple, err error) {
+	if itr.after--; itr.after == 0 {
+		itr.cancel()
+	}
+	return itr.MapIter.Next(ctx)
+}
+
+func TestFlushPartialIndexOnCancel(t *testing.T) {
+	vrw := newTestVRW()
+	sch := newTestSchema(t)
+
+	var rows [][]interface{}
+	for i := 0; i < 2000; i++ {
+		rows = append(rows, []interface{}{i, i, "row"})
+	}
+	primary := newTestPrimary(t, context.Background(), vrw, sch, rows)
+
+	for _, unique := range []bool{false, true} {
+		idx, err := schema.NewIndexCollection(sch.GetAllCols(), sch.GetPKCols()).AddIndexByColNames(
+			"c1_idx", []string{"c1"}, schema.IndexProperties{IsUnique: unique})
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		iter, err := primary.IterAll(ctx)
+		require.NoError(t, err)
+		iter = &cancelingIter{MapIter: iter, after: 300, cancel: cancel}
+
+		_, err = BuildSecondaryProllyIndexFromIter(ctx, vrw, sch, idx, iter, BuildOptions{ExecutionOptions: ExecutionOptions{FlushPartialIndexOnCancel: true}})
+		require.ErrorIs(t, err, context.Canceled)
+		var partialErr ErrPartialIndexBuild
+		require.ErrorAs(t, err, &partialErr)
+		require.Equal(t, "c1_idx", partialErr.IndexName)
+		require.Equal(t, uint64(2*buildMonitorInterval), partialErr.RowsProcessed)
+		require.Equal(t, partialErr.RowsProcessed, partialErr.Partial.Count())
+	}
+}
+
+// errMapIter is a prolly.MapIter that returns |err|.
+type errMapIter struct {
+	err error
+}
+
+func (itr errMapIter) Next(ctx context.Context) (k, v val.Tuple, err error) {
+	return nil, nil, itr.err
+}
+
+func TestIndexBuildErrors(t *testing.T) {
+	ctx := context.Background()
+	vrw := newTestVRW()
+	sch := newTestSchema(t)
+
+	for _, unique := range []bool{false, true} {
+		idx, err := schema.NewIndexCollection(sch.GetAllCols(), sch.GetPKCols()).AddIndexByColNames(
+			"c1_idx", []string{"c1"}, schema.IndexProperties{IsUnique: unique})
+		require.NoError(t, err)
+
+		readErr := errors.New("read failed")
+		_, err = BuildSecondaryProllyIndexFromIter(ctx, vrw, sch, idx, errMapIter{err: readErr}, BuildOptions{})
+		var ioErr ErrIndexBuildIO
+		require.ErrorAs(t, err, &ioErr)
+		require.ErrorIs(t, err, readErr)
+		require.Equal(t, "c1_idx", ioErr.IndexName)
+
+		_, err = BuildSecondaryProllyIndexFromIter(ctx, vrw, sch, idx, errMapIter{err: context.Canceled}, BuildOptions{})
+		require.Equal(t, context.Canceled, err)
+
+		// a NULL primary key can only come from a corrupt or mismatched row
+		kd, vd := shim.MapDescriptorsFromSchema(sch)
+		kb, vb := val.NewTupleBuilder(kd), val.NewTupleBuilder(vd)
+		vb.PutInt64(0, 7)
+		iter := &sliceMapIter{kvs: [][2]val.Tuple{{kb.BuildPermissive(sharePool), vb.Build(sharePool)}}}
+		_, err = BuildSecondaryProllyIndexFromIter(ctx, vrw, sch, idx, iter, BuildOptions{})
+		var encErr ErrIndexEncode
+		require.ErrorAs(t, err, &encErr)
+		require.Equal(t, "c1_idx", encErr.IndexName)
+		require.Equal(t, "( NULL )", encErr.PrimaryKey)
+	}
+}
+
+func TestMaxIndexDistinctValues(t *testing.T) {
+	ctx := context.Background()
+	vrw := newTestVRW()
+	sch := newTestSchema(t)
+	primary := newTestPrimary(t, ctx, vrw, sch, [][]interface{}{
+		{1, 10, "active"},
+		{2, 20, "inactive"},
+		{3, 30, "active"},
+		{4, 40, nil},
+		{5, 50, "inactive"},
+	})
+
+	for _, unique := range []bool{false, true} {
+		idx, err := schema.NewIndexCollection(sch.GetAllCols(), sch.GetPKCols()).AddIndexByColNames(
+			"c2_idx", []string{"c2"}, schema.IndexProperties{IsUnique: unique})
+		require.NoError(t, err)
+		build := func(opts BuildOptions) error {
+			if unique {
+				_, err := BuildUniqueProllyIndex(ctx, vrw, sch, idx, primary, opts, func(ctx context.Context, existingKey, newKey val.Tuple) error {
+					return nil
+				})
+				return err
+			}
+			_, err := BuildSecondaryProllyIndex(ctx, vrw, sch, idx, primary, opts)
+			return err
+		}
+
+		require.NoError(t, build(BuildOptions{ExecutionOptions: ExecutionOptions{MaxIndexDistinctValues: 3}}))
+
+		err = build(BuildOptions{ExecutionOptions: ExecutionOptions{MaxIndexDistinctValues: 2}})
+		var capErr ErrIndexCardinalityExceeded
+		require.True(t, errors.As(err, &capErr))
+		require.Equal(t, "c2_idx", capErr.IndexName)
+		require.Equal(t, uint64(4), capErr.RowsProcessed)
+	}
+}
+
+func TestMirrorPrimaryRowInIndex(t *testing.T) {
+	ctx := context.Background()
+	vrw := newTestVRW()
+	sch := newTestSchema(t)
+	primary := newTestPrimary(t, ctx, vrw, sch, [][]interface{}{
+		{1, 30, "a"},
+		{2, 10, nil},
+		{3, 20, "c"},
+	})
+	pkd, pvd := primary.Descriptors()
+
+	for _, unique := range []bool{false, true} {
+		idx, err := schema.NewIndexCollection(sch.GetAllCols(), sch.GetPKCols()).AddIndexByColNames(
+			"c1_idx", []string{"c1"}, schema.IndexProperties{IsUnique: unique})
+		require.NoError(t, err)
+		rows, err := BuildSecondaryProllyIndex(ctx, vrw, sch, idx, primary, BuildOptions{ValueLayoutOptions: ValueLayoutOptions{MirrorPrimaryRowInIndex: true}})
+		require.NoError(t, err)
+		m := durable.ProllyMapFromIndex(rows)
+		_, vd := m.Descriptors()
+		require.True(t, vd.Equals(pvd))
+
+		// every row of the table can be read from the index alone
+		iter, err := m.IterAll(ctx)
+		require.NoError(t, err)
+		pkb := val.NewTupleBuilder(pkd)
+		var rowStrs []string
+		for {
+			k, v, err := iter.Next(ctx)
+			if err == io.EOF {
+				break
+			}
+			require.NoError(t, err)
+			pkb.PutRaw(0, k.GetField(1))
+			pk := pkb.Build(sharePool)
+			rowStrs = append(rowStrs, pkd.Format(pk)+pvd.Format(v))
+
+			require.NoError(t, primary.Get(ctx, pk, func(pk, pv val.Tuple) error {
+				require.Equal(t, pv, v)
+				return nil
+			}))
+		}
+		require.Equal(t, []string{"( 2 )( 10, NULL )", "( 3 )( 20, c )", "( 1 )( 30, a )"}, rowStrs)
+	}
+
+	// the value layout is not stored with the index, so DML would write the
+	// values of an ordinary index
+	tbl := newTestTable(t, ctx, vrw, newTestSchema(t), [][]interface{}{{1, 30, "a"}})
+	_, err := CreateIndex(ctx, tbl, "c1_idx", []string{"c1"}, false, true, "", BuildOptions{ValueLayoutOptions: ValueLayoutOptions{MirrorPrimaryRowInIndex: true}})
+	require.Error(t, err)
+}
+
+func TestRejectRedundantIndexes(t *testing.T) {
+	ctx := context.Background()
+	vrw := newTestVRW()
+	sch, err := schema.SchemaFromCols(schema.NewColCollection(
+		schema.NewColumn("a", pkTag, types.IntKind, true, schema.NotNullConstraint{}),
+		schema.NewColumn("b", c1Tag, types.IntKind, true, schema.NotNullConstraint{}),
+		schema.NewColumn("c", c2Tag, types.StringKind, false),
+	))
+	require.NoError(t, err)
+	tbl := newTestTable(t, ctx, vrw, sch, [][]interface{}{{1, 2, "x"}})
+
+	tests := []struct {
+		columns   []string
+		unique    bool
+		redundant bool
+	}{
+		{[]string{"a"}, false, true},
+		{[]string{"a", "b"}, false, true},
+		{[]string{"a", "b"}, true, true},
+		{[]string{"a"}, true, false},
+		{[]string{"b"}, false, false},
+		{[]string{"b", "a"}, false, false},
+		{[]string{"a", "c"}, false, false},
+		{[]string{"c"}, false, false},
+	}
+	for _, test := range tests {
+		opts := BuildOptions{ExecutionOptions: ExecutionOptions{RejectRedundantIndexes: true}}
+		_, err := CreateIndex(ctx, tbl, "idx", test.columns, test.unique, true, "", opts)
+		if test.redundant {
+			require.Error(t, err, "%v", test.columns)
+			require.Contains(t, err.Error(), "redundant with the primary key")
+		} else {
+			require.NoError(t, err, "%v", test.columns)
+		}
+
+		// redundant indexes are allowed by default
+		_, err = CreateIndex(ctx, tbl, "idx", test.columns, test.unique, true, "", BuildOptions{})
+		require.NoError(t, err)
+	}
+
+	// the only suffix order of an index on a is the primary key order
+	props := schema.IndexProperties{PkSuffixOrder: []uint64{c1Tag}}
+	require.True(t, IsRedundantWithPrimaryKey(sch, []uint64{pkTag}, props))
+}
+
+type recordedSpan struct {
+	name  string
+	attrs map[string]interface{}
+	err   error
+	ended bool
+}
+
+func (s *recordedSpan) SetAttribute(key string, value interface{}) {
+	s.attrs[key] = value
+}
+
+func (s *recordedSpan) End(err error) {
+	s.err = err
+	s.ended = true
+}
+
+type recordingTracer struct {
+	spans []*recordedSpan
+}
+
+func (t *recordingTracer) StartSpan(ctx context.Context, name string) (context.Context, IndexBuildSpan) {
+	s := &recordedSpan{name: name, attrs: make(map[string]interface{})}
+	t.spans = append(t.spans, s)
+	return ctx, s
+}
+
+func (t *recordingTracer) names() (names []string) {
+	for _, s := range t.spans {
+		names = append(names, s.name)
+	}
+	return names
+}
+
+func TestIndexBuildTracer(t *testing.T) {
+	ctx := context.Background()
+	vrw := newTestVRW()
+	sch := newTestSchema(t)
+	primary := newTestPrimary(t, ctx, vrw, sch, [][]interface{}{
+		{1, 10, "a"},
+		{2, 10, "b"},
+		{3, 20, "c"},
+	})
+
+	idx, err := schema.NewIndexCollection(sch.GetAllCols(), sch.GetPKCols()).AddIndexByColNames(
+		"c1_idx", []string{"c1"}, schema.IndexProperties{})
+	require.NoError(t, err)
+	tracer := &recordingTracer{}
+	_, err = BuildSecondaryProllyIndex(ctx, vrw, sch, idx, primary, BuildOptions{ExecutionOptions: ExecutionOptions{IndexBuildTracer: tracer}})
+	require.NoError(t, err)
+	require.Equal(t, []string{"index.build", "index.flush"}, tracer.names())
+	for _, s := range tracer.spans {
+		require.True(t, s.ended)
+		require.NoError(t, s.err)
+		require.Equal(t, "c1_idx", s.attrs["index"])
+	}
+	require.Equal(t, uint64(3), tracer.spans[0].attrs["entries"])
+
+	// a failed unique build ends its spans with the duplicate entry error
+	idx, err = schema.NewIndexCollection(sch.GetAllCols(), sch.GetPKCols()).AddIndexByColNames(
+		"c1_uidx", []string{"c1"}, schema.IndexProperties{IsUnique: true})
+	require.NoError(t, err)
+	tracer = &recordingTracer{}
+	_, err = BuildSecondaryProllyIndex(ctx, vrw, sch, idx, primary, BuildOptions{ExecutionOptions: ExecutionOptions{IndexBuildTracer: tracer}})
+	require.Error(t, err)
+	require.Equal(t, []string{"index.build", "index.duplicate"}, tracer.names())
+	for _, s := range tracer.spans {
+		require.True(t, s.ended)
+		require.Error(t, s.err)
+	}
+}
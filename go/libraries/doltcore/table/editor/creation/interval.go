@@ -0,0 +1,238 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/dolthub/dolt/go/libraries/doltcore/doltdb/durable"
+	"github.com/dolthub/dolt/go/libraries/doltcore/schema"
+	"github.com/dolthub/dolt/go/libraries/doltcore/sqle/index"
+	"github.com/dolthub/dolt/go/store/pool"
+	"github.com/dolthub/dolt/go/store/prolly"
+	"github.com/dolthub/dolt/go/store/prolly/shim"
+	"github.com/dolthub/dolt/go/store/val"
+)
+
+// intervalEnds computes the values of an interval index, built with
+// BuildOptions.IndexIntervalEnd. The value of an entry is the end of its
+// range followed by the largest end of the ranges at or before it in key
+// order. A nil *intervalEnds builds no interval index.
+type intervalEnds struct {
+	// from is the field of the primary row holding the end, numbered like the
+	// fields of a val.OrdinalMapping from GetIndexKeyMapping
+	from  int
+	pkLen int
+	pkd   val.TupleDesc
+	pvd   val.TupleDesc
+	vb    *val.TupleBuilder
+}
+
+// validateInterval returns the type of the ends of the ranges of the interval
+// index |idx|, or an error if |idx| cannot be built with the interval end of
+// |opts|.
+func validateInterval(sch schema.Schema, idx schema.Index, opts BuildOptions) (int, val.Type, error) {
+	if schema.IsKeyless(sch) {
+		return 0, val.Type{}, fmt.Errorf("index `%s`: interval indexes cannot be built over keyless tables", idx.Name())
+	}
+	if idx.Count() != 1 {
+		return 0, val.Type{}, fmt.Errorf("index `%s`: interval indexes must index the start column of their ranges only", idx.Name())
+	}
+	if idx.IsUnique() || idx.TimeBucket() != 0 {
+		return 0, val.Type{}, fmt.Errorf("index `%s`: interval indexes must be non-unique indexes without key prefixes", idx.Name())
+	}
+
+	col, ok := sch.GetAllCols().GetByNameCaseInsensitive(opts.IndexIntervalEnd)
+	if !ok {
+		return 0, val.Type{}, fmt.Errorf("index `%s`: interval end column `%s` does not exist", idx.Name(), opts.IndexIntervalEnd)
+	}
+	pkd, pvd := shim.MapDescriptorsFromSchema(sch)
+	var from int
+	var typ val.Type
+	if i, ok := sch.GetPKCols().TagToIdx[col.Tag]; ok {
+		from, typ = i, pkd.Types[i]
+	} else {
+		i = sch.GetNonPKCols().TagToIdx[col.Tag]
+		from, typ = sch.GetPKCols().Size()+i, pvd.Types[i]
+	}
+	start, _ := sch.GetAllCols().GetByTag(idx.IndexedColumnTags()[0])
+	if !start.TypeInfo.Equals(col.TypeInfo) {
+		return 0, val.Type{}, fmt.Errorf("index `%s`: interval end column `%s` is not of the type of start column `%s`", idx.Name(), col.Name, start.Name)
+	}
+	typ.Nullable = true
+	return from, typ, nil
+}
+
+// intervalValueDesc returns the value descriptor of an interval index whose
+// range ends are of type |typ|.
+func intervalValueDesc(typ val.Type) val.TupleDesc {
+	return val.NewTupleDescriptor(typ, typ)
+}
+
+// newIntervalEnds returns the intervalEnds of a build of |idx|, or nil if
+// |opts| has no interval end.
+func newIntervalEnds(sch schema.Schema, idx schema.Index, opts BuildOptions) (*intervalEnds, error) {
+	if opts.IndexIntervalEnd == "" {
+		return nil, nil
+	}
+	from, typ, err := validateInterval(sch, idx, opts)
+	if err != nil {
+		return nil, err
+	}
+	pkd, pvd := shim.MapDescriptorsFromSchema(sch)
+	return &intervalEnds{
+		from:  from,
+		pkLen: sch.GetPKCols().Size(),
+		pkd:   pkd,
+		pvd:   pvd,
+		vb:    val.NewTupleBuilder(intervalValueDesc(typ)),
+	}, nil
+}
+
+// value returns the value of the entry of the primary row |k|, |v|, whose
+// largest end is the row's own end until the ends are finished.
+func (e *intervalEnds) value(k, v val.Tuple, p pool.BuffPool) val.Tuple {
+	var end []byte
+	if e.from < e.pkLen {
+		end = k.GetField(e.from)
+	} else {
+		end = v.GetField(e.from - e.pkLen)
+	}
+	e.vb.PutRaw(0, end)
+	e.vb.PutRaw(1, end)
+	return e.vb.Build(p)
+}
+
+// finish returns |m|, index data built with the values of e.value, with the
+// largest ends of its entries. As the largest end of an entry depends on the
+// entries before it, the ends are computed once all entries are written, and
+// the data is rewritten in a single pass. If |e| is nil, |m| is returned.
+func (e *intervalEnds) finish(ctx context.Context, m prolly.Map) (prolly.Map, error) {
+	if e == nil {
+		return m, nil
+	}
+	_, vd := m.Descriptors()
+	iter, err := m.IterAll(ctx)
+	if err != nil {
+		return prolly.Map{}, err
+	}
+	app, err := newAppendIndexBuilder(ctx, m)
+	if err != nil {
+		return prolly.Map{}, err
+	}
+	// the entries of |m| are rewritten in key order
+	app.strict = true
+	var largest []byte
+	for {
+		k, v, err := iter.Next(ctx)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return prolly.Map{}, err
+		}
+		// NULL ends contain nothing, and compare greater than every end
+		end := v.GetField(0)
+		if end != nil && (largest == nil || vd.Comparator().CompareValues(end, largest, vd.Types[0]) > 0) {
+			largest = end
+		}
+		e.vb.PutRaw(0, end)
+		e.vb.PutRaw(1, largest)
+		if _, err = app.put(ctx, k, e.vb.Build(m.Pool())); err != nil {
+			return prolly.Map{}, err
+		}
+	}
+	return app.build(ctx)
+}
+
+// IterIntervalsContaining returns an iterator over the entries of |rows|, the
+// data of an interval index built with BuildOptions.IndexIntervalEnd, whose
+// ranges contain |value|, i.e. whose start is at most |value| and whose end is
+// at least |value|. Ranges with a NULL start or end contain nothing.
+//
+// The largest ends of the entries never decrease in key order, so the entries
+// before the first one whose largest end reaches |value| end before it. That
+// entry is found by a binary search, and the scan stops at the first start past
+// |value|, so only the entries of overlapping ranges are read.
+func IterIntervalsContaining(ctx context.Context, rows durable.Index, value interface{}) (prolly.MapIter, error) {
+	m := durable.ProllyMapFromIndex(rows)
+	kd, vd := m.Descriptors()
+	if vd.Count() != 2 || vd.Types[0].Enc != kd.Types[0].Enc {
+		return nil, fmt.Errorf("index data is not interval index data")
+	}
+	vb := val.NewTupleBuilder(val.NewTupleDescriptor(kd.Types[0]))
+	if err := index.PutField(ctx, m.NodeStore(), vb, 0, value); err != nil {
+		return nil, err
+	}
+	point := vb.Build(m.Pool()).GetField(0)
+	if point == nil {
+		return nil, fmt.Errorf("cannot find the intervals containing NULL")
+	}
+
+	// find the first entry whose largest end is at least |point|
+	lo, hi := uint64(0), uint64(m.Count())
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		iter, err := m.IterOrdinalRange(ctx, mid, mid+1)
+		if err != nil {
+			return nil, err
+		}
+		_, v, err := iter.Next(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if largest := v.GetField(1); largest == nil || vd.CompareField(point, 1, v) > 0 {
+			lo = mid + 1
+		} else {
+			hi = mid
+		}
+	}
+
+	iter, err := m.IterOrdinalRange(ctx, lo, uint64(m.Count()))
+	if err != nil {
+		return nil, err
+	}
+	return &containingIter{iter: iter, kd: kd, vd: vd, point: point}, nil
+}
+
+// containingIter filters the entries of an interval index to those whose
+// ranges contain |point|.
+type containingIter struct {
+	iter  prolly.MapIter
+	kd    val.TupleDesc
+	vd    val.TupleDesc
+	point []byte
+}
+
+var _ prolly.MapIter = &containingIter{}
+
+// Next implements prolly.MapIter.
+func (it *containingIter) Next(ctx context.Context) (val.Tuple, val.Tuple, error) {
+	for {
+		k, v, err := it.iter.Next(ctx)
+		if err != nil {
+			return nil, nil, err
+		}
+		// NULL starts are ordered last
+		if k.GetField(0) == nil || it.kd.CompareField(it.point, 0, k) < 0 {
+			return nil, nil, io.EOF
+		}
+		if v.GetField(0) != nil && it.vd.CompareField(it.point, 0, v) <= 0 {
+			return k, v, nil
+		}
+	}
+}
@@ -0,0 +1,128 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"context"
+	"io"
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/dolthub/dolt/go/libraries/doltcore/doltdb/durable"
+	"github.com/dolthub/dolt/go/libraries/doltcore/schema"
+	"github.com/dolthub/dolt/go/store/types"
+)
+
+// ipv4 returns the IPv4 address a.b.c.d as an integer.
+func ipv4(a, b, c, d int) int {
+	return a<<24 | b<<16 | c<<8 | d
+}
+
+func TestIntervalIndex(t *testing.T) {
+	ctx := context.Background()
+	vrw := newTestVRW()
+	sch, err := schema.SchemaFromCols(schema.NewColCollection(
+		schema.NewColumn("pk", 1, types.IntKind, true, schema.NotNullConstraint{}),
+		schema.NewColumn("ip_start", 2, types.IntKind, false),
+		schema.NewColumn("ip_end", 3, types.IntKind, false),
+		schema.NewColumn("name", 4, types.StringKind, false),
+	))
+	require.NoError(t, err)
+
+	// nested and overlapping networks, a range without an end, and many small
+	// disjoint ranges
+	rows := [][]interface{}{
+		{0, ipv4(10, 0, 0, 0), ipv4(10, 255, 255, 255), "10/8"},
+		{1, ipv4(10, 1, 0, 0), ipv4(10, 1, 255, 255), "10.1/16"},
+		{2, ipv4(10, 1, 2, 0), ipv4(10, 1, 2, 255), "10.1.2/24"},
+		{3, ipv4(10, 1, 2, 128), ipv4(10, 2, 0, 0), "overlap"},
+		{4, ipv4(192, 168, 0, 0), ipv4(192, 168, 255, 255), "192.168/16"},
+		{5, ipv4(192, 168, 1, 0), nil, "no end"},
+	}
+	for i := 0; i < 2000; i++ {
+		start := ipv4(172, 16+i/256, i%256, 0)
+		rows = append(rows, []interface{}{len(rows), start, start + 99, "small"})
+	}
+	primary := newTestPrimary(t, ctx, vrw, sch, rows)
+	coll := schema.NewIndexCollection(sch.GetAllCols(), sch.GetPKCols())
+	idx, err := coll.AddIndexByColNames("ip_range", []string{"ip_start"}, schema.IndexProperties{})
+	require.NoError(t, err)
+	opts := BuildOptions{ValueLayoutOptions: ValueLayoutOptions{IndexIntervalEnd: "ip_end"}}
+	rowData, err := BuildSecondaryProllyIndex(ctx, vrw, sch, idx, primary, opts)
+	require.NoError(t, err)
+	require.Equal(t, uint64(len(rows)), rowData.Count())
+
+	containing := func(ip int) (pks []int64) {
+		iter, err := IterIntervalsContaining(ctx, rowData, int64(ip))
+		require.NoError(t, err)
+		kd, _ := durable.ProllyMapFromIndex(rowData).Descriptors()
+		for {
+			k, _, err := iter.Next(ctx)
+			if err == io.EOF {
+				break
+			}
+			require.NoError(t, err)
+			pk, _ := kd.GetInt64(1, k)
+			pks = append(pks, pk)
+		}
+		sort.Slice(pks, func(i, j int) bool { return pks[i] < pks[j] })
+		return pks
+	}
+	require.Equal(t, []int64{0, 1, 2, 3}, containing(ipv4(10, 1, 2, 200)))
+	require.Equal(t, []int64{0, 3}, containing(ipv4(10, 1, 255, 255)+1))
+	require.Equal(t, []int64{0}, containing(ipv4(10, 200, 0, 0)))
+	require.Equal(t, []int64{4}, containing(ipv4(192, 168, 1, 1)))
+	require.Empty(t, containing(ipv4(8, 8, 8, 8)))
+
+	// containment queries agree with a scan of every range
+	rnd := rand.New(rand.NewSource(0))
+	for i := 0; i < 200; i++ {
+		ip := ipv4(10, 0, 0, 0) + rnd.Intn(ipv4(173, 0, 0, 0)-ipv4(10, 0, 0, 0))
+		if i%2 == 0 {
+			ip = ipv4(172, 16, 0, 0) + rnd.Intn(2000*256)
+		}
+		var expected []int64
+		for _, row := range rows {
+			if end, ok := row[2].(int); ok && row[1].(int) <= ip && ip <= end {
+				expected = append(expected, int64(row[0].(int)))
+			}
+		}
+		require.Equal(t, expected, containing(ip), "ip %d", ip)
+	}
+
+	// interval indexes index a single start column of the end's type, and are
+	// not stored in tables
+	byName, err := coll.AddIndexByColNames("name_idx", []string{"name"}, schema.IndexProperties{})
+	require.NoError(t, err)
+	_, err = BuildSecondaryProllyIndex(ctx, vrw, sch, byName, primary, opts)
+	require.Error(t, err)
+	_, err = BuildSecondaryProllyIndex(ctx, vrw, sch, idx, primary, BuildOptions{ValueLayoutOptions: ValueLayoutOptions{IndexIntervalEnd: "missing"}})
+	require.Error(t, err)
+	_, err = CreateIndex(ctx, newTestTable(t, ctx, vrw, sch, rows), "ip_range", []string{"ip_start"}, false, true, "", opts)
+	require.Error(t, err)
+	// the values of interval indexes cannot hold anything else
+	for _, other := range []BuildOptions{
+		{ValueLayoutOptions: ValueLayoutOptions{IndexIntervalEnd: "ip_end", MirrorPrimaryRowInIndex: true}},
+		{ValueLayoutOptions: ValueLayoutOptions{IndexIntervalEnd: "ip_end", RecordSourceChunkInIndex: true}},
+		{ValueLayoutOptions: ValueLayoutOptions{IndexIntervalEnd: "ip_end", RecordRowLocatorInIndex: true}},
+	} {
+		_, err = newSecondaryMap(ctx, vrw, sch, idx, other)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "IndexIntervalEnd cannot be combined")
+	}
+}
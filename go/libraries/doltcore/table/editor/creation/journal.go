@@ -0,0 +1,194 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/dolthub/dolt/go/store/hash"
+)
+
+// JournalCheckpointStore is an IndexBuildCheckpointStore that appends
+// the checkpoints of each index, the watermarks of its build, to a journal
+// file in a directory.
+//
+// Each record of a journal is the length of its payload and the CRC-32C of its
+// payload, both 4 byte big endian integers, followed by the payload: the
+// primary hash, the definition hash, the root hash, the number of rows
+// processed as an 8 byte big endian integer, and the last primary key
+// processed. Records are fsync'd
+// every syncEvery records, so a crash can lose the last records of a journal,
+// or leave the last of them torn. Loading a journal stops at its first torn or
+// corrupt record, so a build resumes from its last intact checkpoint. The index
+// data of a record is persisted in the chunk store of the build before the
+// record is appended, so the chunk store of an intact record survives the
+// crash with it.
+type JournalCheckpointStore struct {
+	dir       string
+	syncEvery int
+
+	mu sync.Mutex
+	// unsynced is the number of records appended to each journal since it
+	// was last fsync'd, for the journals appended to by this store
+	unsynced map[string]int
+}
+
+var _ IndexBuildCheckpointStore = (*JournalCheckpointStore)(nil)
+
+// journalHeaderSize is the size of the length and checksum of a journal record.
+const journalHeaderSize = 8
+
+// journalFixedSize is the size of the payload of a journal record before its
+// last key.
+const journalFixedSize = 3*hash.ByteLen + 8
+
+var journalCRC = crc32.MakeTable(crc32.Castagnoli)
+
+// NewJournalCheckpointStore returns a JournalCheckpointStore that stores its
+// journals in |dir|, which must exist, and fsyncs them every |syncEvery|
+// records.
+func NewJournalCheckpointStore(dir string, syncEvery int) (*JournalCheckpointStore, error) {
+	if syncEvery <= 0 {
+		return nil, fmt.Errorf("invalid index build journal sync interval of %d records", syncEvery)
+	}
+	return &JournalCheckpointStore{dir: dir, syncEvery: syncEvery, unsynced: make(map[string]int)}, nil
+}
+
+func (s *JournalCheckpointStore) path(indexName string) string {
+	return filepath.Join(s.dir, indexName+".journal")
+}
+
+// SaveCheckpoint implements IndexBuildCheckpointStore. The first
+// checkpoint this store saves for an index truncates the torn or corrupt
+// records at the end of its journal, so that the records appended after them
+// can be read.
+func (s *JournalCheckpointStore) SaveCheckpoint(_ context.Context, indexName string, cp IndexBuildCheckpoint) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	path := s.path(indexName)
+	n, opened := s.unsynced[indexName]
+	if !opened {
+		_, valid, err := readJournal(path)
+		if err != nil {
+			return err
+		}
+		if err = os.Truncate(path, valid); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	payload := make([]byte, journalFixedSize, journalFixedSize+len(cp.LastKey))
+	copy(payload, cp.Primary[:])
+	copy(payload[hash.ByteLen:], cp.Definition[:])
+	copy(payload[2*hash.ByteLen:], cp.Root[:])
+	binary.BigEndian.PutUint64(payload[3*hash.ByteLen:], cp.RowsProcessed)
+	payload = append(payload, cp.LastKey...)
+	rec := make([]byte, journalHeaderSize, journalHeaderSize+len(payload))
+	binary.BigEndian.PutUint32(rec, uint32(len(payload)))
+	binary.BigEndian.PutUint32(rec[4:], crc32.Checksum(payload, journalCRC))
+	rec = append(rec, payload...)
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err = f.Write(rec); err == nil {
+		if n++; n >= s.syncEvery {
+			err, n = f.Sync(), 0
+		}
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	s.unsynced[indexName] = n
+	return nil
+}
+
+// LoadCheckpoint implements IndexBuildCheckpointStore. It returns the
+// last intact record of the journal of |indexName|.
+func (s *JournalCheckpointStore) LoadCheckpoint(_ context.Context, indexName string) (IndexBuildCheckpoint, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cp, _, err := readJournal(s.path(indexName))
+	if err != nil || cp == nil {
+		return IndexBuildCheckpoint{}, false, err
+	}
+	return *cp, true, nil
+}
+
+// ClearCheckpoint implements IndexBuildCheckpointStore.
+func (s *JournalCheckpointStore) ClearCheckpoint(_ context.Context, indexName string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.unsynced, indexName)
+	err := os.Remove(s.path(indexName))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// readJournal returns the last intact record of the journal at |path|, or nil
+// if it has none, and the length of its intact records.
+func readJournal(path string) (*IndexBuildCheckpoint, int64, error) {
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, 0, nil
+	} else if err != nil {
+		return nil, 0, err
+	}
+
+	var last *IndexBuildCheckpoint
+	r := bytes.NewReader(b)
+	var valid int64
+	for {
+		var hdr [journalHeaderSize]byte
+		if _, err = io.ReadFull(r, hdr[:]); err != nil {
+			// a missing or torn header ends the journal
+			return last, valid, nil
+		}
+		n := binary.BigEndian.Uint32(hdr[:])
+		if n < journalFixedSize || int64(n) > int64(r.Len()) {
+			return last, valid, nil
+		}
+		payload := make([]byte, n)
+		_, _ = io.ReadFull(r, payload)
+		if crc32.Checksum(payload, journalCRC) != binary.BigEndian.Uint32(hdr[4:]) {
+			return last, valid, nil
+		}
+
+		cp := IndexBuildCheckpoint{
+			RowsProcessed: binary.BigEndian.Uint64(payload[3*hash.ByteLen:]),
+			LastKey:       payload[journalFixedSize:],
+		}
+		copy(cp.Primary[:], payload)
+		copy(cp.Definition[:], payload[hash.ByteLen:])
+		copy(cp.Root[:], payload[2*hash.ByteLen:])
+		last = &cp
+		valid += journalHeaderSize + int64(n)
+	}
+}
@@ -0,0 +1,162 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/dolthub/dolt/go/libraries/doltcore/schema"
+)
+
+func TestJournalCheckpointStore(t *testing.T) {
+	ctx := context.Background()
+	vrw := newTestVRW()
+	sch := newTestSchema(t)
+	var rows [][]interface{}
+	for i := 0; i < 10; i++ {
+		rows = append(rows, []interface{}{i, 100 - i, fmt.Sprintf("v%d", i)})
+	}
+	primary := newTestPrimary(t, ctx, vrw, sch, rows)
+	idx, err := schema.NewIndexCollection(sch.GetAllCols(), sch.GetPKCols()).AddIndexByColNames(
+		"c1_idx", []string{"c1"}, schema.IndexProperties{})
+	require.NoError(t, err)
+	expected, err := BuildSecondaryProllyIndex(ctx, vrw, sch, idx, primary, BuildOptions{})
+	require.NoError(t, err)
+	errCrash := errors.New("crash")
+
+	dir := t.TempDir()
+	store, err := NewJournalCheckpointStore(dir, 2)
+	require.NoError(t, err)
+	opts := BuildOptions{ExecutionOptions: ExecutionOptions{IndexBuildCheckpoints: store, IndexBuildCheckpointRows: 3}}
+
+	// the build crashes at its third checkpoint, after journaling the first 6 rows
+	crashing := opts
+	crashing.IndexBuildCheckpoints = &crashingCheckpointStore{IndexBuildCheckpointStore: store, crashAt: 3, err: errCrash}
+	_, err = BuildSecondaryProllyIndex(ctx, vrw, sch, idx, primary, crashing)
+	require.ErrorIs(t, err, errCrash)
+	cp, ok, err := store.LoadCheckpoint(ctx, idx.Name())
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, uint64(6), cp.RowsProcessed)
+
+	// the crash tore the last record, so a new store loads the record before it
+	path := store.path(idx.Name())
+	fi, err := os.Stat(path)
+	require.NoError(t, err)
+	require.NoError(t, os.Truncate(path, fi.Size()-3))
+	store, err = NewJournalCheckpointStore(dir, 2)
+	require.NoError(t, err)
+	cp, ok, err = store.LoadCheckpoint(ctx, idx.Name())
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, uint64(3), cp.RowsProcessed)
+
+	// the resumed build truncates the torn record before appending its own
+	crashing = opts
+	crashing.IndexBuildCheckpoints = &crashingCheckpointStore{IndexBuildCheckpointStore: store, crashAt: 2, err: errCrash}
+	_, err = BuildSecondaryProllyIndex(ctx, vrw, sch, idx, primary, crashing)
+	require.ErrorIs(t, err, errCrash)
+	cp, ok, err = store.LoadCheckpoint(ctx, idx.Name())
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, uint64(6), cp.RowsProcessed)
+
+	// a corrupt record is ignored like a torn one
+	b, err := os.ReadFile(path)
+	require.NoError(t, err)
+	b[len(b)-1] ^= 0xff
+	require.NoError(t, os.WriteFile(path, b, 0644))
+	cp, ok, err = store.LoadCheckpoint(ctx, idx.Name())
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, uint64(3), cp.RowsProcessed)
+
+	// the next build resumes from the last intact record, and removes the journal when it completes
+	stats := &IndexBuildStats{}
+	resumed := opts
+	resumed.IndexBuildStats = stats
+	actual, err := BuildSecondaryProllyIndex(ctx, vrw, sch, idx, primary, resumed)
+	require.NoError(t, err)
+	require.Equal(t, uint64(7), stats.RowsScanned)
+	requireSameIndex(t, expected, actual)
+	_, err = os.Stat(path)
+	require.True(t, os.IsNotExist(err))
+
+	_, err = NewJournalCheckpointStore(dir, 0)
+	require.Error(t, err)
+}
+
+func TestJournalCheckpointStoreReopen(t *testing.T) {
+	ctx := context.Background()
+	sch := newTestSchema(t)
+	var rows [][]interface{}
+	for i := 0; i < 10; i++ {
+		rows = append(rows, []interface{}{i, 100 - i, fmt.Sprintf("v%d", i)})
+	}
+	idx, err := schema.NewIndexCollection(sch.GetAllCols(), sch.GetPKCols()).AddIndexByColNames(
+		"c1_idx", []string{"c1"}, schema.IndexProperties{})
+	require.NoError(t, err)
+	errCrash := errors.New("crash")
+
+	dir := t.TempDir()
+	vs := openTestValueStore(t, ctx, dir)
+	primary := persistTestPrimary(t, ctx, vs, sch, rows)
+	journalDir := t.TempDir()
+	store, err := NewJournalCheckpointStore(journalDir, 1)
+	require.NoError(t, err)
+
+	// the build crashes at its third checkpoint, after journaling the first 6 rows
+	crashing := BuildOptions{
+		ExecutionOptions: ExecutionOptions{
+			IndexBuildCheckpoints:    &crashingCheckpointStore{IndexBuildCheckpointStore: store, crashAt: 3, err: errCrash},
+			IndexBuildCheckpointRows: 3,
+		},
+	}
+	_, err = BuildSecondaryProllyIndex(ctx, vs, sch, idx, primary, crashing)
+	require.ErrorIs(t, err, errCrash)
+
+	// the process restarts, reopening the chunk store and the journal
+	require.NoError(t, vs.Close())
+	vs = openTestValueStore(t, ctx, dir)
+	defer vs.Close()
+	primary = loadTestPrimary(t, ctx, vs, sch, primary.HashOf())
+	store, err = NewJournalCheckpointStore(journalDir, 1)
+	require.NoError(t, err)
+	cp, ok, err := store.LoadCheckpoint(ctx, idx.Name())
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, uint64(6), cp.RowsProcessed)
+	expected, err := BuildSecondaryProllyIndex(ctx, vs, sch, idx, primary, BuildOptions{})
+	require.NoError(t, err)
+
+	// the build resumes from the journaled index data
+	stats := &IndexBuildStats{}
+	actual, err := BuildSecondaryProllyIndex(ctx, vs, sch, idx, primary, BuildOptions{
+		ExecutionOptions: ExecutionOptions{
+			IndexBuildCheckpoints:    store,
+			IndexBuildCheckpointRows: 3,
+			IndexBuildStats:          stats,
+		},
+	})
+	require.NoError(t, err)
+	require.Equal(t, uint64(4), stats.RowsScanned)
+	requireSameIndex(t, expected, actual)
+}
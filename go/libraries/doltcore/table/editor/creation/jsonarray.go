@@ -0,0 +1,209 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/dolthub/dolt/go/libraries/doltcore/doltdb/durable"
+	"github.com/dolthub/dolt/go/libraries/doltcore/schema"
+	"github.com/dolthub/dolt/go/libraries/doltcore/sqle/index"
+	"github.com/dolthub/dolt/go/store/prolly"
+	"github.com/dolthub/dolt/go/store/val"
+)
+
+// JSONArrayLayout selects how BuildJSONArrayIndex indexes the elements of JSON arrays.
+type JSONArrayLayout int
+
+const (
+	// CompositeJSONArray indexes arrays of a fixed length, with a key component
+	// per element, like a composite index over a column per element. Each row
+	// has one entry, keyed by the elements of its array followed by the
+	// primary key, and a NULL array indexes as NULL elements.
+	CompositeJSONArray JSONArrayLayout = iota
+	// MultiValuedJSONArray indexes arrays of any length, with an entry per
+	// distinct element, keyed by the element followed by the primary key, like
+	// BuildJSONKeysIndex indexes the keys of objects. NULL and empty arrays
+	// have no entries.
+	MultiValuedJSONArray
+)
+
+// BuildJSONArrayIndex builds an index of the elements of the JSON arrays in
+// the column |colName| of |primary|, laid out as selected by |layout|, with
+// empty values. |elems| are the encodings of the elements, one per element of
+// a CompositeJSONArray and a single one for a MultiValuedJSONArray, each one of
+// val.Int64Enc, val.Float64Enc or val.StringEnc. JSON nulls index as NULL. A
+// value that is not an array, or whose elements do not have these encodings,
+// fails the build with an ErrJSONArrayShape.
+func BuildJSONArrayIndex(ctx context.Context, sch schema.Schema, primary prolly.Map, colName string, layout JSONArrayLayout, elems ...val.Encoding) (durable.Index, error) {
+	col, ok := sch.GetAllCols().GetByNameCaseInsensitive(colName)
+	if !ok {
+		return nil, fmt.Errorf("column `%s` does not exist for the table", colName)
+	}
+	pkd, vd := primary.Descriptors()
+	i, ok := sch.GetNonPKCols().TagToIdx[col.Tag]
+	if !ok || vd.Types[i].Enc != val.JSONEnc {
+		return nil, fmt.Errorf("column `%s` is not a JSON column", col.Name)
+	}
+	if len(elems) == 0 || (layout == MultiValuedJSONArray && len(elems) != 1) {
+		return nil, fmt.Errorf("invalid number of JSON array elements %d", len(elems))
+	}
+	types := make([]val.Type, 0, len(elems)+pkd.Count())
+	for _, enc := range elems {
+		if enc != val.Int64Enc && enc != val.Float64Enc && enc != val.StringEnc {
+			return nil, fmt.Errorf("JSON array elements cannot be indexed with encoding %d", enc)
+		}
+		types = append(types, val.Type{Enc: enc, Nullable: true})
+	}
+	kd := val.NewTupleDescriptor(append(types, pkd.Types...)...)
+	empty, err := prolly.NewMapFromTuples(ctx, primary.NodeStore(), kd, val.NewTupleDescriptor())
+	if err != nil {
+		return nil, err
+	}
+
+	iter, err := primary.IterAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	mut := empty.Mutate()
+	kb := val.NewTupleBuilder(kd)
+	n := len(elems)
+	// put stores the entry of the elements written to |kb| for the row |k|
+	put := func(k val.Tuple) error {
+		for j := 0; j < k.Count(); j++ {
+			kb.PutRaw(n+j, k.GetField(j))
+		}
+		return mut.Put(ctx, kb.Build(primary.Pool()), val.EmptyTuple)
+	}
+	for {
+		k, v, err := iter.Next(ctx)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		var arr []interface{}
+		if doc, ok := vd.GetJSON(i, v); ok {
+			if arr, err = jsonArray(doc); err != nil {
+				return nil, fmt.Errorf("%w: column `%s` of row %s", err, col.Name, pkd.Format(k))
+			}
+		} else if layout == CompositeJSONArray {
+			arr = make([]interface{}, n)
+		}
+
+		switch layout {
+		case CompositeJSONArray:
+			if len(arr) != n {
+				return nil, fmt.Errorf("%w: column `%s` of row %s has %d elements, not %d", ErrJSONArrayShape, col.Name, pkd.Format(k), len(arr), n)
+			}
+			for j, e := range arr {
+				if err = putJSONArrayElement(kb, j, e); err != nil {
+					return nil, fmt.Errorf("%w: column `%s` of row %s", err, col.Name, pkd.Format(k))
+				}
+			}
+			if err = put(k); err != nil {
+				return nil, err
+			}
+		case MultiValuedJSONArray:
+			// repeated elements of an array have the same entry
+			for _, e := range arr {
+				if err = putJSONArrayElement(kb, 0, e); err != nil {
+					return nil, fmt.Errorf("%w: column `%s` of row %s", err, col.Name, pkd.Format(k))
+				}
+				if err = put(k); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	m, err := mut.Map(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return durable.IndexFromProllyMap(m), nil
+}
+
+// jsonArray returns the elements of the JSON array |doc|, with numbers as
+// json.Numbers, or ErrJSONArrayShape if |doc| is not an array.
+func jsonArray(doc []byte) ([]interface{}, error) {
+	if trimmed := bytes.TrimLeft(doc, " \t\r\n"); len(trimmed) == 0 || trimmed[0] != '[' {
+		return nil, ErrJSONArrayShape
+	}
+	dec := json.NewDecoder(bytes.NewReader(doc))
+	dec.UseNumber()
+	var arr []interface{}
+	if err := dec.Decode(&arr); err != nil {
+		return nil, err
+	}
+	return arr, nil
+}
+
+// putJSONArrayElement writes the JSON array element |e| to field |i| of |kb|,
+// or returns ErrJSONArrayShape if it does not have the field's encoding.
+func putJSONArrayElement(kb *val.TupleBuilder, i int, e interface{}) error {
+	if e == nil {
+		kb.PutRaw(i, nil)
+		return nil
+	}
+	enc := kb.Desc.Types[i].Enc
+	switch e := e.(type) {
+	case json.Number:
+		if enc == val.Int64Enc {
+			if n, err := e.Int64(); err == nil {
+				kb.PutInt64(i, n)
+				return nil
+			}
+		} else if enc == val.Float64Enc {
+			if f, err := e.Float64(); err == nil {
+				kb.PutFloat64(i, f)
+				return nil
+			}
+		}
+	case string:
+		if enc == val.StringEnc {
+			kb.PutString(i, e)
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: element %v cannot be indexed with encoding %d", ErrJSONArrayShape, e, enc)
+}
+
+// IterJSONArrayRows returns an iterator over the entries of |idx|, an index
+// built by BuildJSONArrayIndex, whose leading key components are |elems|. For
+// a MultiValuedJSONArray index, a single element finds the rows whose array
+// contains it.
+func IterJSONArrayRows(ctx context.Context, idx durable.Index, elems ...interface{}) (prolly.MapIter, error) {
+	m := durable.ProllyMapFromIndex(idx)
+	kd, _ := m.Descriptors()
+	pd := prefixDesc(kd, len(elems))
+	pb := val.NewTupleBuilder(pd)
+	for i, e := range elems {
+		if err := index.PutField(ctx, m.NodeStore(), pb, i, e); err != nil {
+			return nil, err
+		}
+	}
+	// the range of a prefix is only bound by its first field
+	itr, err := NewPrefixItr(ctx, pb.Build(m.Pool()), pd, m)
+	if err != nil {
+		return nil, err
+	}
+	return &itr, nil
+}
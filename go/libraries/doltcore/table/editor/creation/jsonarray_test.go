@@ -0,0 +1,113 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/dolthub/dolt/go/libraries/doltcore/doltdb/durable"
+	"github.com/dolthub/dolt/go/libraries/doltcore/schema"
+	"github.com/dolthub/dolt/go/store/prolly"
+	"github.com/dolthub/dolt/go/store/types"
+	"github.com/dolthub/dolt/go/store/val"
+)
+
+func TestBuildJSONArrayIndex(t *testing.T) {
+	ctx := context.Background()
+	vrw := newTestVRW()
+	sch, err := schema.SchemaFromCols(schema.NewColCollection(
+		schema.NewColumn("pk", pkTag, types.IntKind, true, schema.NotNullConstraint{}),
+		schema.NewColumn("doc", c1Tag, types.JSONKind, false),
+	))
+	require.NoError(t, err)
+
+	// pks collects the primary keys of the entries of |iter|, the last key
+	// component of an index with |n| element components
+	pks := func(iter prolly.MapIter, n int) (pks []int64) {
+		for {
+			k, _, err := iter.Next(ctx)
+			if err == io.EOF {
+				return pks
+			}
+			require.NoError(t, err)
+			pk, ok := val.NewTupleDescriptor(val.Type{Enc: val.Int64Enc}).GetInt64(0, val.NewTuple(sharePool, k.GetField(n)))
+			require.True(t, ok)
+			pks = append(pks, pk)
+		}
+	}
+
+	t.Run("composite", func(t *testing.T) {
+		primary := newTestPrimary(t, ctx, vrw, sch, [][]interface{}{
+			{1, json.RawMessage(`[2, "b"]`)},
+			{2, json.RawMessage(`[1, "z"]`)},
+			{3, json.RawMessage(`[2, "a"]`)},
+			{4, json.RawMessage(`[null, "a"]`)},
+			{5, nil},
+			{6, json.RawMessage(` [1, "a"]`)},
+		})
+		rowData, err := BuildJSONArrayIndex(ctx, sch, primary, "doc", CompositeJSONArray, val.Int64Enc, val.StringEnc)
+		require.NoError(t, err)
+		require.Equal(t, []string{"[1,a,6]", "[1,z,2]", "[2,a,3]", "[2,b,1]", "[NULL,a,4]", "[NULL,NULL,5]"},
+			collectKeys(t, ctx, durable.ProllyMapFromIndex(rowData)))
+
+		// the elements are key components, so a prefix of them is a range scan
+		iter, err := IterJSONArrayRows(ctx, rowData, int64(2))
+		require.NoError(t, err)
+		require.Equal(t, []int64{3, 1}, pks(iter, 2))
+		iter, err = IterJSONArrayRows(ctx, rowData, int64(2), "b")
+		require.NoError(t, err)
+		require.Equal(t, []int64{1}, pks(iter, 2))
+
+		for _, doc := range []string{`[1]`, `[1, "a", 3]`, `{"a": 1}`, `[1.5, "a"]`, `["1", "a"]`} {
+			bad := newTestPrimary(t, ctx, vrw, sch, [][]interface{}{{1, json.RawMessage(doc)}})
+			_, err = BuildJSONArrayIndex(ctx, sch, bad, "doc", CompositeJSONArray, val.Int64Enc, val.StringEnc)
+			require.ErrorIs(t, err, ErrJSONArrayShape, doc)
+		}
+	})
+
+	t.Run("multi-valued", func(t *testing.T) {
+		primary := newTestPrimary(t, ctx, vrw, sch, [][]interface{}{
+			{1, json.RawMessage(`[1.5, 2, 3]`)},
+			{2, json.RawMessage(`[]`)},
+			{3, json.RawMessage(`[2, 2, null]`)},
+			{4, nil},
+		})
+		rowData, err := BuildJSONArrayIndex(ctx, sch, primary, "doc", MultiValuedJSONArray, val.Float64Enc)
+		require.NoError(t, err)
+		require.Equal(t, []string{"[1.500000,1]", "[2.000000,1]", "[2.000000,3]", "[3.000000,1]", "[NULL,3]"},
+			collectKeys(t, ctx, durable.ProllyMapFromIndex(rowData)))
+
+		// the rows whose array contains an element
+		iter, err := IterJSONArrayRows(ctx, rowData, float64(2))
+		require.NoError(t, err)
+		require.Equal(t, []int64{1, 3}, pks(iter, 1))
+
+		_, err = BuildJSONArrayIndex(ctx, sch, primary, "doc", MultiValuedJSONArray, val.Float64Enc, val.Float64Enc)
+		require.Error(t, err)
+	})
+
+	primary := newTestPrimary(t, ctx, vrw, sch, nil)
+	_, err = BuildJSONArrayIndex(ctx, sch, primary, "pk", CompositeJSONArray, val.Int64Enc)
+	require.Error(t, err)
+	_, err = BuildJSONArrayIndex(ctx, sch, primary, "doc", CompositeJSONArray, val.JSONEnc)
+	require.Error(t, err)
+	_, err = BuildJSONArrayIndex(ctx, sch, primary, "doc", CompositeJSONArray)
+	require.Error(t, err)
+}
@@ -0,0 +1,132 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/dolthub/dolt/go/libraries/doltcore/doltdb/durable"
+	"github.com/dolthub/dolt/go/libraries/doltcore/schema"
+	"github.com/dolthub/dolt/go/store/prolly"
+	"github.com/dolthub/dolt/go/store/val"
+)
+
+// NonObjectJSON selects how BuildJSONKeysIndex handles JSON values that are not objects.
+type NonObjectJSON int
+
+const (
+	// SkipNonObjectJSON indexes no keys for JSON values that are not objects.
+	SkipNonObjectJSON NonObjectJSON = iota
+	// RejectNonObjectJSON fails the build with an ErrJSONNotObject.
+	RejectNonObjectJSON
+)
+
+// BuildJSONKeysIndex builds an index of the top-level keys of the JSON objects
+// in the column |colName| of |primary|, as needed to find the rows whose
+// object has a given key. Each row has an entry per key of its object, keyed
+// by the object key followed by the primary key, with an empty value. Keys of
+// nested objects are not indexed. NULL values have no keys, and values that
+// are not objects are handled as selected by |nonObject|.
+func BuildJSONKeysIndex(ctx context.Context, sch schema.Schema, primary prolly.Map, colName string, nonObject NonObjectJSON) (durable.Index, error) {
+	col, ok := sch.GetAllCols().GetByNameCaseInsensitive(colName)
+	if !ok {
+		return nil, fmt.Errorf("column `%s` does not exist for the table", colName)
+	}
+	pkd, vd := primary.Descriptors()
+	i, ok := sch.GetNonPKCols().TagToIdx[col.Tag]
+	if !ok || vd.Types[i].Enc != val.JSONEnc {
+		return nil, fmt.Errorf("column `%s` is not a JSON column", col.Name)
+	}
+
+	kd := val.NewTupleDescriptor(append([]val.Type{{Enc: val.StringEnc}}, pkd.Types...)...)
+	empty, err := prolly.NewMapFromTuples(ctx, primary.NodeStore(), kd, val.NewTupleDescriptor())
+	if err != nil {
+		return nil, err
+	}
+
+	iter, err := primary.IterAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	kb := val.NewTupleBuilder(kd)
+	mut := empty.Mutate()
+	for {
+		k, v, err := iter.Next(ctx)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		doc, ok := vd.GetJSON(i, v)
+		if !ok {
+			continue
+		}
+		keys, err := jsonObjectKeys(doc)
+		if err == ErrJSONNotObject && nonObject == SkipNonObjectJSON {
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("%w: column `%s` of row %s", err, col.Name, pkd.Format(k))
+		}
+		for _, key := range keys {
+			kb.PutString(0, key)
+			for j := 0; j < k.Count(); j++ {
+				kb.PutRaw(j+1, k.GetField(j))
+			}
+			if err = mut.Put(ctx, kb.Build(primary.Pool()), val.EmptyTuple); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	m, err := mut.Map(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return durable.IndexFromProllyMap(m), nil
+}
+
+// jsonObjectKeys returns the top-level keys of the JSON object |doc|, or
+// ErrJSONNotObject if |doc| is not an object.
+func jsonObjectKeys(doc []byte) ([]string, error) {
+	if trimmed := bytes.TrimLeft(doc, " \t\r\n"); len(trimmed) == 0 || trimmed[0] != '{' {
+		return nil, ErrJSONNotObject
+	}
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(doc, &obj); err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0, len(obj))
+	for key := range obj {
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// IterJSONKeyRows returns an iterator over the entries of |idx|, an index
+// built by BuildJSONKeysIndex, of the rows whose object has the key |key|.
+func IterJSONKeyRows(ctx context.Context, idx durable.Index, key string) (prolly.MapIter, error) {
+	m := durable.ProllyMapFromIndex(idx)
+	kd, _ := m.Descriptors()
+	pd := prefixDesc(kd, 1)
+	pb := val.NewTupleBuilder(pd)
+	pb.PutString(0, key)
+	p := pb.Build(m.Pool())
+	return m.IterRange(ctx, prolly.ClosedRange(p, p, pd))
+}
@@ -0,0 +1,79 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/dolthub/dolt/go/libraries/doltcore/doltdb/durable"
+	"github.com/dolthub/dolt/go/libraries/doltcore/schema"
+	"github.com/dolthub/dolt/go/store/types"
+)
+
+func TestBuildJSONKeysIndex(t *testing.T) {
+	ctx := context.Background()
+	vrw := newTestVRW()
+	sch, err := schema.SchemaFromCols(schema.NewColCollection(
+		schema.NewColumn("pk", pkTag, types.IntKind, true, schema.NotNullConstraint{}),
+		schema.NewColumn("doc", c1Tag, types.JSONKind, false),
+	))
+	require.NoError(t, err)
+	rows := [][]interface{}{
+		{1, json.RawMessage(`{"a": 1, "b": {"c": 2}}`)},
+		{2, json.RawMessage(`{"b": [1, 2], "d": null}`)},
+		{3, json.RawMessage(`{}`)},
+		{4, nil},
+		{5, json.RawMessage(` {"c": {"a": true}}`)},
+		{6, json.RawMessage(`[{"a": 1}]`)},
+		{7, json.RawMessage(`"a"`)},
+	}
+	primary := newTestPrimary(t, ctx, vrw, sch, rows)
+
+	idx, err := BuildJSONKeysIndex(ctx, sch, primary, "DOC", SkipNonObjectJSON)
+	require.NoError(t, err)
+	require.Equal(t, []string{"[a,1]", "[b,1]", "[b,2]", "[c,5]", "[d,2]"}, collectKeys(t, ctx, durable.ProllyMapFromIndex(idx)))
+
+	// nested keys are not indexed
+	rowsWithKey := func(key string) (pks []int64) {
+		iter, err := IterJSONKeyRows(ctx, idx, key)
+		require.NoError(t, err)
+		kd, _ := durable.ProllyMapFromIndex(idx).Descriptors()
+		for {
+			k, _, err := iter.Next(ctx)
+			if err == io.EOF {
+				return pks
+			}
+			require.NoError(t, err)
+			pk, _ := kd.GetInt64(1, k)
+			pks = append(pks, pk)
+		}
+	}
+	require.Equal(t, []int64{1}, rowsWithKey("a"))
+	require.Equal(t, []int64{1, 2}, rowsWithKey("b"))
+	require.Equal(t, []int64{5}, rowsWithKey("c"))
+	require.Empty(t, rowsWithKey("e"))
+
+	_, err = BuildJSONKeysIndex(ctx, sch, primary, "doc", RejectNonObjectJSON)
+	require.ErrorIs(t, err, ErrJSONNotObject)
+	_, err = BuildJSONKeysIndex(ctx, sch, primary, "pk", SkipNonObjectJSON)
+	require.Error(t, err)
+	_, err = BuildJSONKeysIndex(ctx, sch, primary, "missing", SkipNonObjectJSON)
+	require.Error(t, err)
+}
@@ -0,0 +1,94 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"context"
+	"io"
+
+	"github.com/dolthub/dolt/go/libraries/doltcore/doltdb/durable"
+	"github.com/dolthub/dolt/go/libraries/doltcore/schema"
+	"github.com/dolthub/dolt/go/store/prolly"
+	"github.com/dolthub/dolt/go/store/types"
+	"github.com/dolthub/dolt/go/store/val"
+)
+
+// BuildLatestProllyIndex builds the index |idx| like a unique index, but
+// resolves duplicate entries instead of reporting them: of the entries with
+// the same indexed values, only the entry with the greatest primary key is
+// kept. For example, with a primary key of (id, version), an index on id keeps
+// the latest version of each id. As in unique indexes, entries with a NULL
+// indexed value never conflict, so all of them are kept.
+func BuildLatestProllyIndex(ctx context.Context, vrw types.ValueReadWriter, sch schema.Schema, idx schema.Index, primary prolly.Map, opts BuildOptions) (durable.Index, error) {
+	iter, err := primary.IterAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := BuildUniqueProllyIndexFromIter(ctx, vrw, sch, idx, iter, opts, func(ctx context.Context, existingKey, newKey val.Tuple) error {
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	m := durable.ProllyMapFromIndex(rows)
+	all, err := m.IterAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// the index keys of duplicate entries differ only in their primary key
+	// suffix, so the entry to keep is the last of its duplicates in the index
+	var superseded []val.Tuple
+	var prev val.Tuple
+	for {
+		k, _, err := all.Next(ctx)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		if prev != nil && !hasNullPrefix(k, idx.Count()) && samePrefix(prev, k, idx.Count()) {
+			superseded = append(superseded, prev)
+		}
+		prev = k
+	}
+	if len(superseded) == 0 {
+		return rows, nil
+	}
+
+	mut := m.Mutate()
+	for _, k := range superseded {
+		if err = mut.Delete(ctx, k); err != nil {
+			return nil, err
+		}
+	}
+	m, err = mut.Map(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return durable.IndexFromProllyMap(m), nil
+}
+
+// hasNullPrefix returns true if any of the first |n| fields of |k| is NULL.
+func hasNullPrefix(k val.Tuple, n int) bool {
+	for i := 0; i < n; i++ {
+		if k.FieldIsNull(i) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,63 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/dolthub/dolt/go/libraries/doltcore/doltdb/durable"
+	"github.com/dolthub/dolt/go/libraries/doltcore/schema"
+	"github.com/dolthub/dolt/go/store/types"
+)
+
+func TestBuildLatestProllyIndex(t *testing.T) {
+	ctx := context.Background()
+	vrw := newTestVRW()
+	// versioned rows of (id, version) with a value
+	sch, err := schema.SchemaFromCols(schema.NewColCollection(
+		schema.NewColumn("id", pkTag, types.StringKind, true, schema.NotNullConstraint{}),
+		schema.NewColumn("version", c1Tag, types.IntKind, true, schema.NotNullConstraint{}),
+		schema.NewColumn("v", c2Tag, types.StringKind, false),
+	))
+	require.NoError(t, err)
+	primary := newTestPrimary(t, ctx, vrw, sch, [][]interface{}{
+		{"a", 1, "a1"},
+		{"a", 3, "a3"},
+		{"a", 2, "a2"},
+		{"b", 1, "b1"},
+		{"c", 5, nil},
+		{"c", 7, nil},
+		{"c", 6, "c6"},
+	})
+
+	idx, err := schema.NewIndexCollection(sch.GetAllCols(), sch.GetPKCols()).AddIndexByColNames(
+		"id_idx", []string{"id"}, schema.IndexProperties{IsUnique: true})
+	require.NoError(t, err)
+	rows, err := BuildLatestProllyIndex(ctx, vrw, sch, idx, primary, BuildOptions{})
+	require.NoError(t, err)
+	require.Equal(t, []string{"[a,3]", "[b,1]", "[c,7]"}, collectKeys(t, ctx, durable.ProllyMapFromIndex(rows)))
+
+	// entries with a NULL indexed value are all kept
+	idx, err = schema.NewIndexCollection(sch.GetAllCols(), sch.GetPKCols()).AddIndexByColNames(
+		"v_idx", []string{"v"}, schema.IndexProperties{IsUnique: true})
+	require.NoError(t, err)
+	rows, err = BuildLatestProllyIndex(ctx, vrw, sch, idx, primary, BuildOptions{})
+	require.NoError(t, err)
+	require.Equal(t, []string{"[a1,a,1]", "[a2,a,2]", "[a3,a,3]", "[b1,b,1]", "[c6,c,6]", "[NULL,c,5]", "[NULL,c,7]"},
+		collectKeys(t, ctx, durable.ProllyMapFromIndex(rows)))
+}
@@ -0,0 +1,147 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/dolthub/dolt/go/libraries/doltcore/doltdb/durable"
+	"github.com/dolthub/dolt/go/store/prolly"
+	"github.com/dolthub/dolt/go/store/val"
+)
+
+// indexOp is a buffered insert or delete of a secondary index entry.
+type indexOp struct {
+	key    val.Tuple
+	delete bool
+}
+
+// IndexMaintainer applies concurrent inserts and deletes of entries to
+// secondary index data. Updates are buffered and applied to the index in
+// sorted batches, so writers only contend on appending to the buffer rather
+// than on mutating the tree. Updates to the same entry are applied in the
+// order they were made.
+//
+// The buffer is not lock-free: it is guarded by a mutex, which writers hold
+// only to append an update and a flush holds only to swap the buffer out.
+// Entries are inserted with empty values, so only index data without value
+// columns can be maintained.
+//
+// Keys are retained until they are flushed, so they must not be built from a
+// pool that recycles buffers.
+type IndexMaintainer struct {
+	kd        val.TupleDesc
+	flushSize int
+
+	// mu guards buf
+	mu  sync.Mutex
+	buf []indexOp
+
+	// flushMu guards m, and orders flushes so that batches are applied in
+	// the order they were buffered
+	flushMu sync.Mutex
+	m       prolly.Map
+}
+
+// NewIndexMaintainer returns an IndexMaintainer for the index data |rows|,
+// which must have no value columns, that flushes its buffer whenever it holds
+// |flushSize| updates.
+func NewIndexMaintainer(rows durable.Index, flushSize int) (*IndexMaintainer, error) {
+	if flushSize < 1 {
+		return nil, fmt.Errorf("invalid index maintainer flush size %d", flushSize)
+	}
+	m := durable.ProllyMapFromIndex(rows)
+	kd, vd := m.Descriptors()
+	if vd.Count() != 0 {
+		return nil, fmt.Errorf("cannot maintain index data with %d value columns", vd.Count())
+	}
+	return &IndexMaintainer{
+		kd:        kd,
+		flushSize: flushSize,
+		buf:       make([]indexOp, 0, flushSize),
+		m:         m,
+	}, nil
+}
+
+// Insert adds the entry |key| to the index.
+func (im *IndexMaintainer) Insert(ctx context.Context, key val.Tuple) error {
+	return im.add(ctx, indexOp{key: key})
+}
+
+// Delete removes the entry |key| from the index.
+func (im *IndexMaintainer) Delete(ctx context.Context, key val.Tuple) error {
+	return im.add(ctx, indexOp{key: key, delete: true})
+}
+
+func (im *IndexMaintainer) add(ctx context.Context, op indexOp) error {
+	im.mu.Lock()
+	im.buf = append(im.buf, op)
+	full := len(im.buf) >= im.flushSize
+	im.mu.Unlock()
+	if full {
+		return im.Flush(ctx)
+	}
+	return nil
+}
+
+// Flush applies all buffered updates to the index.
+func (im *IndexMaintainer) Flush(ctx context.Context) error {
+	im.flushMu.Lock()
+	defer im.flushMu.Unlock()
+
+	im.mu.Lock()
+	batch := im.buf
+	im.buf = make([]indexOp, 0, im.flushSize)
+	im.mu.Unlock()
+	if len(batch) == 0 {
+		return nil
+	}
+
+	// a stable sort keeps updates to the same entry in order
+	sort.SliceStable(batch, func(i, j int) bool {
+		return im.kd.Compare(batch[i].key, batch[j].key) < 0
+	})
+	mut := im.m.Mutate()
+	for _, op := range batch {
+		var err error
+		if op.delete {
+			err = mut.Delete(ctx, op.key)
+		} else {
+			err = mut.Put(ctx, op.key, val.EmptyTuple)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	m, err := mut.Map(ctx)
+	if err != nil {
+		return err
+	}
+	im.m = m
+	return nil
+}
+
+// Index flushes all buffered updates and returns the index data.
+func (im *IndexMaintainer) Index(ctx context.Context) (durable.Index, error) {
+	if err := im.Flush(ctx); err != nil {
+		return nil, err
+	}
+	im.flushMu.Lock()
+	defer im.flushMu.Unlock()
+	return durable.IndexFromProllyMap(im.m), nil
+}
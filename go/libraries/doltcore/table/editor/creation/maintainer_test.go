@@ -0,0 +1,88 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/dolthub/dolt/go/libraries/doltcore/doltdb/durable"
+	"github.com/dolthub/dolt/go/libraries/doltcore/schema"
+	"github.com/dolthub/dolt/go/store/prolly/shim"
+	"github.com/dolthub/dolt/go/store/val"
+)
+
+func TestIndexMaintainer(t *testing.T) {
+	ctx := context.Background()
+	vrw := newTestVRW()
+	sch := newTestSchema(t)
+	idx, err := schema.NewIndexCollection(sch.GetAllCols(), sch.GetPKCols()).AddIndexByColNames(
+		"c1_idx", []string{"c1"}, schema.IndexProperties{})
+	require.NoError(t, err)
+	empty, err := durable.NewEmptyIndex(ctx, vrw, idx.Schema())
+	require.NoError(t, err)
+
+	_, err = NewIndexMaintainer(empty, 0)
+	require.Error(t, err)
+	// the index entries are inserted without values
+	mirrored, err := newSecondaryMap(ctx, vrw, sch, idx, BuildOptions{ValueLayoutOptions: ValueLayoutOptions{MirrorPrimaryRowInIndex: true}})
+	require.NoError(t, err)
+	_, err = NewIndexMaintainer(durable.IndexFromProllyMap(mirrored), 16)
+	require.Error(t, err)
+	im, err := NewIndexMaintainer(empty, 16)
+	require.NoError(t, err)
+
+	kd := shim.KeyDescriptorFromSchema(idx.Schema())
+	key := func(i int) val.Tuple {
+		kb := val.NewTupleBuilder(kd)
+		kb.PutInt64(0, int64(i%10))
+		kb.PutInt64(1, int64(i))
+		return kb.Build(sharePool)
+	}
+
+	const writers, perWriter = 8, 100
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := w * perWriter; i < (w+1)*perWriter; i++ {
+				assert.NoError(t, im.Insert(ctx, key(i)))
+				// deleting after inserting depends on updates to an entry being applied in order
+				if i%3 == 0 {
+					assert.NoError(t, im.Delete(ctx, key(i)))
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	rows, err := im.Index(ctx)
+	require.NoError(t, err)
+	var expected []string
+	for c1 := 0; c1 < 10; c1++ {
+		for i := c1; i < writers*perWriter; i += 10 {
+			if i%3 != 0 {
+				expected = append(expected, fmt.Sprintf("[%d,%d]", c1, i))
+			}
+		}
+	}
+	require.Equal(t, expected, collectKeys(t, ctx, durable.ProllyMapFromIndex(rows)))
+}
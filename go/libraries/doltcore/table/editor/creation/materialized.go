@@ -0,0 +1,147 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dolthub/dolt/go/libraries/doltcore/doltdb/durable"
+	"github.com/dolthub/dolt/go/libraries/doltcore/schema"
+	"github.com/dolthub/dolt/go/store/pool"
+	"github.com/dolthub/dolt/go/store/prolly"
+	"github.com/dolthub/dolt/go/store/types"
+	"github.com/dolthub/dolt/go/store/val"
+)
+
+// MaterializedRowIdColumn is the name of the synthetic primary key column added
+// to the schema of a materialized result.
+const MaterializedRowIdColumn = "__dolt_row_id"
+
+// ResultIter iterates the rows of a materialized result. Each row is a value
+// tuple encoded with the value descriptor of the result's columns.
+type ResultIter interface {
+	// Next returns the next row, or io.EOF when the result is exhausted.
+	Next(ctx context.Context) (val.Tuple, error)
+}
+
+// MaterializedIndexReturn is the result of CreateIndexForMaterializedResult.
+type MaterializedIndexReturn struct {
+	// Sch is the synthetic schema of the result, keyed by MaterializedRowIdColumn.
+	Sch schema.Schema
+	// Index is the index definition within |Sch|.
+	Index schema.Index
+	// IndexRows is the built index data.
+	IndexRows durable.Index
+}
+
+// CreateIndexForMaterializedResult builds a secondary index over |columns| of a
+// materialized result, such as a materialized view. The result rows, read from
+// |rows|, have the columns |resultCols| and no primary key. Each row is
+// assigned a row id equal to its position in |rows|, which is appended to its
+// index key in place of a primary key. Row ids are stable as long as the result
+// is produced in a deterministic order.
+func CreateIndexForMaterializedResult(
+	ctx context.Context,
+	vrw types.ValueReadWriter,
+	resultCols *schema.ColCollection,
+	rows ResultIter,
+	indexName string,
+	columns []string,
+	isUnique bool,
+	opts BuildOptions,
+) (*MaterializedIndexReturn, error) {
+	if !types.IsFormat_DOLT_1(vrw.Format()) {
+		return nil, fmt.Errorf("materialized result indexes are not supported for format %s", vrw.Format().VersionString())
+	}
+
+	sch, err := materializedResultSchema(resultCols)
+	if err != nil {
+		return nil, err
+	}
+	realColNames, err := resolveColumnNames(sch, columns)
+	if err != nil {
+		return nil, err
+	}
+	idx, err := sch.Indexes().AddIndexByColNames(indexName, realColNames, schema.IndexProperties{
+		IsUnique:      isUnique,
+		IsUserDefined: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	empty, err := durable.NewEmptyIndex(ctx, vrw, sch)
+	if err != nil {
+		return nil, err
+	}
+	m := durable.ProllyMapFromIndex(empty)
+	kd, _ := m.Descriptors()
+
+	iter := &rowIdIter{rows: rows, kb: val.NewTupleBuilder(kd), pool: m.Pool()}
+	indexRows, err := BuildSecondaryProllyIndexFromIter(ctx, vrw, sch, idx, iter, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return &MaterializedIndexReturn{
+		Sch:       sch,
+		Index:     idx,
+		IndexRows: indexRows,
+	}, nil
+}
+
+// materializedResultSchema returns a schema with the non-primary key columns
+// |resultCols| and a synthetic row id primary key.
+func materializedResultSchema(resultCols *schema.ColCollection) (schema.Schema, error) {
+	cols := []schema.Column{
+		schema.NewColumn(MaterializedRowIdColumn, schema.KeylessRowIdTag, types.UintKind, true, schema.NotNullConstraint{}),
+	}
+	err := resultCols.Iter(func(tag uint64, col schema.Column) (stop bool, err error) {
+		if col.IsPartOfPK {
+			return true, fmt.Errorf("materialized result column `%s` cannot be part of a primary key", col.Name)
+		}
+		if tag == schema.KeylessRowIdTag || col.Name == MaterializedRowIdColumn {
+			return true, fmt.Errorf("materialized result column `%s` conflicts with the synthetic row id", col.Name)
+		}
+		cols = append(cols, col)
+		return false, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return schema.SchemaFromCols(schema.NewColCollection(cols...))
+}
+
+// rowIdIter adapts a ResultIter to a prolly.MapIter by synthesizing a row id
+// key for each row.
+type rowIdIter struct {
+	rows  ResultIter
+	kb    *val.TupleBuilder
+	pool  pool.BuffPool
+	rowId uint64
+}
+
+var _ prolly.MapIter = &rowIdIter{}
+
+func (itr *rowIdIter) Next(ctx context.Context) (k, v val.Tuple, err error) {
+	v, err = itr.rows.Next(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	itr.kb.PutUint64(0, itr.rowId)
+	itr.rowId++
+	return itr.kb.Build(itr.pool), v, nil
+}
@@ -0,0 +1,83 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/dolthub/dolt/go/libraries/doltcore/doltdb/durable"
+	"github.com/dolthub/dolt/go/libraries/doltcore/schema"
+	"github.com/dolthub/dolt/go/store/prolly/shim"
+	"github.com/dolthub/dolt/go/store/types"
+	"github.com/dolthub/dolt/go/store/val"
+)
+
+type sliceResultIter struct {
+	rows []val.Tuple
+}
+
+func (itr *sliceResultIter) Next(ctx context.Context) (val.Tuple, error) {
+	if len(itr.rows) == 0 {
+		return nil, io.EOF
+	}
+	row := itr.rows[0]
+	itr.rows = itr.rows[1:]
+	return row, nil
+}
+
+func TestCreateIndexForMaterializedResult(t *testing.T) {
+	ctx := context.Background()
+	vrw := newTestVRW()
+
+	resultCols := schema.NewColCollection(
+		schema.NewColumn("name", 1, types.StringKind, false),
+		schema.NewColumn("total", 2, types.IntKind, false),
+	)
+	sch, err := materializedResultSchema(resultCols)
+	require.NoError(t, err)
+	vd := shim.ValueDescriptorFromSchema(sch)
+	vb := val.NewTupleBuilder(vd)
+
+	newResult := func() *sliceResultIter {
+		var rows []val.Tuple
+		for _, r := range [][]interface{}{{"b", 20}, {"a", 10}, {"b", 20}, {"c", nil}} {
+			putTestField(vb, 0, r[0])
+			putTestField(vb, 1, r[1])
+			rows = append(rows, vb.Build(sharePool))
+		}
+		return &sliceResultIter{rows: rows}
+	}
+
+	ret, err := CreateIndexForMaterializedResult(ctx, vrw, resultCols, newResult(), "total_idx", []string{"TOTAL"}, false, BuildOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{MaterializedRowIdColumn}, ret.Sch.GetPKCols().GetColumnNames())
+	assert.Equal(t, []string{"total"}, ret.Index.ColumnNames())
+	assert.Equal(t, []string{"[10,1]", "[20,0]", "[20,2]", "[NULL,3]"},
+		collectKeys(t, ctx, durable.ProllyMapFromIndex(ret.IndexRows)))
+
+	// duplicate rows have distinct row ids, but still violate a unique index
+	_, err = CreateIndexForMaterializedResult(ctx, vrw, resultCols, newResult(), "name_idx", []string{"name"}, true, BuildOptions{})
+	require.Error(t, err)
+
+	_, err = CreateIndexForMaterializedResult(ctx, vrw, schema.NewColCollection(
+		schema.NewColumn(MaterializedRowIdColumn, 1, types.IntKind, false),
+	), newResult(), "idx", []string{MaterializedRowIdColumn}, false, BuildOptions{})
+	require.Error(t, err)
+}
@@ -0,0 +1,266 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/dolthub/dolt/go/libraries/doltcore/doltdb/durable"
+	"github.com/dolthub/dolt/go/libraries/doltcore/schema"
+	"github.com/dolthub/dolt/go/store/pool"
+	"github.com/dolthub/dolt/go/store/prolly"
+	"github.com/dolthub/dolt/go/store/types"
+	"github.com/dolthub/dolt/go/store/val"
+)
+
+// UniqueViolation is a row whose indexed values in a unique index equal those
+// of a row scanned before it.
+type UniqueViolation struct {
+	IndexName string
+	// PrimaryKey is the formatted primary key of the row, and
+	// ExistingPrimaryKey that of the row scanned before it.
+	PrimaryKey         string
+	ExistingPrimaryKey string
+	// NewKey is the index key of the row, and ExistingKey that of the row
+	// scanned before it.
+	NewKey, ExistingKey val.Tuple
+}
+
+// UniqueViolationReport holds the violations found by BuildUniqueProllyIndexes.
+type UniqueViolationReport struct {
+	// Violations are the violations of each index, by index name, in scan
+	// order. Indexes without violations have no entry.
+	Violations map[string][]UniqueViolation
+}
+
+// HasViolations returns whether any index has a violation.
+func (r UniqueViolationReport) HasViolations() bool {
+	return len(r.Violations) != 0
+}
+
+// ViolationsByRow returns the names of the indexes violated by each row, by
+// the formatted primary key of the row. A row violates an index if its
+// indexed values equal those of a row scanned before it, which is not itself
+// a violation.
+func (r UniqueViolationReport) ViolationsByRow() map[string][]string {
+	rows := make(map[string][]string)
+	for name, violations := range r.Violations {
+		for _, v := range violations {
+			rows[v.PrimaryKey] = append(rows[v.PrimaryKey], name)
+		}
+	}
+	for _, names := range rows {
+		sort.Strings(names)
+	}
+	return rows
+}
+
+// uniqueIndexBuild is the build of one of the indexes of
+// BuildUniqueProllyIndexes.
+type uniqueIndexBuild struct {
+	idx  schema.Index
+	kd   val.TupleDesc
+	enc  *uniqueKeyEncoder
+	pads *padSpaceKeys
+	mut  *prolly.MutableMap
+	exp  *expiryValues
+	sums *entryChecksums
+	// mon records the NULLs of the index
+	mon *buildMonitor
+	// pkMap maps the fields of a primary key to the fields of an index key
+	pkMap val.OrdinalMapping
+	pkBld *val.TupleBuilder
+}
+
+// BuildUniqueProllyIndexes builds the unique indexes |idxs| in a single scan
+// of |primary|, and reports every violation of each index, rather than
+// stopping at the first. Duplicates are found as by BuildUniqueProllyIndex: an
+// entry with a NULL indexed value never conflicts, and strings of PAD SPACE
+// collations that differ only in trailing spaces are duplicates. Of the rows
+// with equal indexed values, the first one scanned is indexed, and the others
+// are reported as violations, so the returned index data, in the same order
+// as |idxs|, is only valid if the report has no violations. Builds with
+// |opts| whose indexes need more than the primary scan, such as interval
+// indexes or checkpointed builds, are not supported, nor is IndexBuildStats.
+func BuildUniqueProllyIndexes(ctx context.Context, vrw types.ValueReadWriter, sch schema.Schema, idxs []schema.Index, primary prolly.Map, opts BuildOptions) ([]durable.Index, UniqueViolationReport, error) {
+	report := UniqueViolationReport{Violations: make(map[string][]UniqueViolation)}
+	if len(idxs) == 0 {
+		return nil, report, nil
+	}
+	if opts.IndexIntervalEnd != "" || opts.IndexBuildCheckpoints != nil || opts.IndexDocumentSink != nil || opts.IndexEntryWriter != nil || opts.IndexSortedRunDir != "" {
+		return nil, report, fmt.Errorf("unique indexes built together cannot have intervals, checkpoints, documents, entry writers or sorted runs")
+	}
+	opts.IndexBuildStats = nil
+
+	pkd, _ := primary.Descriptors()
+	pkLen := sch.GetPKCols().Size()
+	builds := make([]*uniqueIndexBuild, len(idxs))
+	for i, idx := range idxs {
+		if !idx.IsUnique() {
+			return nil, report, fmt.Errorf("index `%s` is not unique", idx.Name())
+		}
+		secondary, err := newSecondaryMap(ctx, vrw, sch, idx, opts)
+		if err != nil {
+			return nil, report, err
+		}
+		kd, _ := secondary.Descriptors()
+		enc, err := newUniqueKeyEncoder(sch, idx, kd, opts)
+		if err != nil {
+			return nil, report, err
+		}
+		exp, err := newExpiryValues(sch, idx, opts)
+		if err != nil {
+			return nil, report, err
+		}
+		sums, err := newEntryChecksums(idx, opts)
+		if err != nil {
+			return nil, report, err
+		}
+		keyMap, err := GetIndexKeyMapping(sch, idx)
+		if err != nil {
+			return nil, report, err
+		}
+		pkMap := make(val.OrdinalMapping, pkLen)
+		for to := idx.Count(); to < len(keyMap); to++ {
+			pkMap[keyMap.MapOrdinal(to)] = to
+		}
+		mut := secondary.Mutate()
+		builds[i] = &uniqueIndexBuild{
+			idx:   idx,
+			kd:    kd,
+			enc:   enc,
+			pads:  newPadSpaceKeys(sch, idx, kd, enc.encr),
+			mut:   &mut,
+			exp:   exp,
+			sums:  sums,
+			mon:   newBuildMonitor(idx, BuildOptions{}),
+			pkMap: pkMap,
+			pkBld: val.NewTupleBuilder(pkd),
+		}
+	}
+
+	iter, err := iterPrimary(ctx, primary, opts)
+	if err != nil {
+		return nil, report, err
+	}
+	p := tuplePool(primary, opts)
+	mon := newBuildMonitor(idxs[0], opts)
+	for {
+		k, v, err := iter.Next(ctx)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, report, err
+		}
+		if err = mon.row(ctx); err != nil {
+			return nil, report, err
+		}
+
+		if opts.IndexRowFilter != nil {
+			ok, err := opts.IndexRowFilter(ctx, k, v)
+			if err != nil {
+				return nil, report, err
+			}
+			if !ok {
+				continue
+			}
+		}
+
+		for _, b := range builds {
+			idxKey, existing, err := b.put(ctx, k, v, iter, p, opts)
+			if err != nil {
+				return nil, report, err
+			}
+			if existing != nil {
+				name := b.idx.Name()
+				report.Violations[name] = append(report.Violations[name], UniqueViolation{
+					IndexName:          name,
+					PrimaryKey:         pkd.Format(k),
+					ExistingPrimaryKey: pkd.Format(b.primaryKey(existing, p)),
+					NewKey:             idxKey,
+					ExistingKey:        existing,
+				})
+			}
+		}
+	}
+
+	indexes := make([]durable.Index, len(builds))
+	for i, b := range builds {
+		m, err := b.mut.Map(ctx)
+		if err != nil {
+			return nil, report, ioErr(b.idx, pkd, nil, err)
+		}
+		if err = emitIndexColumns(ctx, b.idx, m, opts); err != nil {
+			return nil, report, err
+		}
+		indexes[i] = durable.IndexFromProllyMap(m)
+	}
+	return indexes, report, nil
+}
+
+// put indexes the primary row |k|, |v| and returns its index key, unless its
+// indexed values equal those of an existing entry, which is returned too.
+func (b *uniqueIndexBuild) put(ctx context.Context, k, v val.Tuple, iter prolly.MapIter, p pool.BuffPool, opts BuildOptions) (idxKey, existing val.Tuple, err error) {
+	nullPrefix, err := b.enc.put(k, v, b.mon)
+	if err != nil {
+		if skipsOversizedRow(err, opts) {
+			b.enc.kb.Recycle()
+			return nil, nil, nil
+		}
+		return nil, nil, err
+	}
+	idxKey = b.enc.kb.Build(p)
+
+	// like MySQL, an entry with a NULL in any unique column never conflicts
+	if !nullPrefix {
+		existing, err = firstWithPrefix(ctx, b.mut, b.enc.prefixKD, b.enc.prefixKB.Build(p))
+		if err != nil {
+			return nil, nil, ioErr(b.idx, b.enc.pkd, k, err)
+		}
+		if existing == nil {
+			existing, _ = b.pads.find(idxKey)
+		}
+		if existing != nil {
+			return idxKey, existing, nil
+		}
+		b.pads.add(idxKey)
+	}
+
+	idxVal, err := indexValue(v, iter, p, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	if b.exp != nil {
+		idxVal = b.exp.value(k, v, p)
+	}
+	if b.sums != nil {
+		idxVal = b.sums.value(idxKey, p)
+	}
+	if err = b.mut.Put(ctx, idxKey, idxVal); err != nil {
+		return nil, nil, ioErr(b.idx, b.enc.pkd, k, err)
+	}
+	return idxKey, nil, nil
+}
+
+// primaryKey returns the primary key held by the index key |idxKey|.
+func (b *uniqueIndexBuild) primaryKey(idxKey val.Tuple, p pool.BuffPool) val.Tuple {
+	for to := range b.pkMap {
+		b.pkBld.PutRaw(to, idxKey.GetField(b.pkMap.MapOrdinal(to)))
+	}
+	return b.pkBld.Build(p)
+}
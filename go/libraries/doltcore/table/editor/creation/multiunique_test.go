@@ -0,0 +1,93 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/dolthub/dolt/go/libraries/doltcore/doltdb/durable"
+	"github.com/dolthub/dolt/go/libraries/doltcore/schema"
+)
+
+func TestBuildUniqueProllyIndexes(t *testing.T) {
+	ctx := context.Background()
+	vrw := newTestVRW()
+	sch := newTestSchema(t)
+	rows := [][]interface{}{
+		{1, 10, "a"},
+		{2, 10, "b"},
+		{3, 20, "a"},
+		{4, 10, "a"},
+		{5, nil, "c"},
+		{6, nil, "d"},
+	}
+	primary := newTestPrimary(t, ctx, vrw, sch, rows)
+	coll := schema.NewIndexCollection(sch.GetAllCols(), sch.GetPKCols())
+	c1, err := coll.AddIndexByColNames("c1_uniq", []string{"c1"}, schema.IndexProperties{IsUnique: true})
+	require.NoError(t, err)
+	c2, err := coll.AddIndexByColNames("c2_uniq", []string{"c2"}, schema.IndexProperties{IsUnique: true})
+	require.NoError(t, err)
+
+	indexes, report, err := BuildUniqueProllyIndexes(ctx, vrw, sch, []schema.Index{c1, c2}, primary, BuildOptions{})
+	require.NoError(t, err)
+	require.True(t, report.HasViolations())
+
+	// every violation of both indexes is reported, against the first row with the same values
+	pks := func(violations []UniqueViolation) (pks [][2]string) {
+		for _, v := range violations {
+			pks = append(pks, [2]string{v.PrimaryKey, v.ExistingPrimaryKey})
+		}
+		return pks
+	}
+	require.Equal(t, [][2]string{{"( 2 )", "( 1 )"}, {"( 4 )", "( 1 )"}}, pks(report.Violations["c1_uniq"]))
+	require.Equal(t, [][2]string{{"( 3 )", "( 1 )"}, {"( 4 )", "( 1 )"}}, pks(report.Violations["c2_uniq"]))
+	require.Equal(t, map[string][]string{
+		"( 2 )": {"c1_uniq"},
+		"( 3 )": {"c2_uniq"},
+		"( 4 )": {"c1_uniq", "c2_uniq"},
+	}, report.ViolationsByRow())
+	kd, _ := durable.ProllyMapFromIndex(indexes[1]).Descriptors()
+	v := report.Violations["c2_uniq"][1]
+	newKey, err := formatKey(v.NewKey, kd)
+	require.NoError(t, err)
+	existingKey, err := formatKey(v.ExistingKey, kd)
+	require.NoError(t, err)
+	require.Equal(t, "[a,4]", newKey)
+	require.Equal(t, "[a,1]", existingKey)
+
+	// the first row with each value is indexed, and NULLs never conflict
+	require.Equal(t, []string{"[10,1]", "[20,3]", "[NULL,5]", "[NULL,6]"}, collectKeys(t, ctx, durable.ProllyMapFromIndex(indexes[0])))
+	require.Equal(t, []string{"[a,1]", "[b,2]", "[c,5]", "[d,6]"}, collectKeys(t, ctx, durable.ProllyMapFromIndex(indexes[1])))
+
+	// without violations, the indexes are those built one at a time
+	clean := newTestPrimary(t, ctx, vrw, sch, [][]interface{}{{1, 10, "a"}, {2, 20, "b"}, {3, nil, "c"}})
+	indexes, report, err = BuildUniqueProllyIndexes(ctx, vrw, sch, []schema.Index{c1, c2}, clean, BuildOptions{})
+	require.NoError(t, err)
+	require.False(t, report.HasViolations())
+	require.Empty(t, report.ViolationsByRow())
+	for i, idx := range []schema.Index{c1, c2} {
+		expected, err := BuildSecondaryProllyIndex(ctx, vrw, sch, idx, clean, BuildOptions{})
+		require.NoError(t, err)
+		requireSameIndex(t, expected, indexes[i])
+	}
+
+	plain, err := coll.AddIndexByColNames("c1_idx", []string{"c1"}, schema.IndexProperties{})
+	require.NoError(t, err)
+	_, _, err = BuildUniqueProllyIndexes(ctx, vrw, sch, []schema.Index{c1, plain}, primary, BuildOptions{})
+	require.Error(t, err)
+}
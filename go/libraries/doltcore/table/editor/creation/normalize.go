@@ -0,0 +1,188 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"golang.org/x/text/cases"
+
+	"github.com/dolthub/dolt/go/libraries/doltcore/doltdb/durable"
+	"github.com/dolthub/dolt/go/libraries/doltcore/schema"
+	"github.com/dolthub/dolt/go/libraries/doltcore/sqle/index"
+	"github.com/dolthub/dolt/go/store/prolly"
+	"github.com/dolthub/dolt/go/store/val"
+)
+
+// IndexNormalizer normalizes the values of the char and varchar fields of the
+// keys of an index whose schema.IndexProperties.Normalization is its name. It
+// must be deterministic, and return values it cannot normalize unchanged.
+type IndexNormalizer func(s string) string
+
+const (
+	// E164PhoneNormalization is the normalization of phone numbers by
+	// NormalizeE164Phone.
+	E164PhoneNormalization = "e164_phone"
+	// EmailNormalization is the normalization of email addresses by
+	// NormalizeEmail.
+	EmailNormalization = "email"
+)
+
+var normalizersMu sync.RWMutex
+
+// indexNormalizers are the registered normalizations, by name.
+var indexNormalizers = map[string]IndexNormalizer{
+	E164PhoneNormalization: NormalizeE164Phone,
+	EmailNormalization:     NormalizeEmail,
+}
+
+// RegisterIndexNormalizer registers |n| as the normalization named |name|, so
+// that indexes with that normalization can be built and read. As the entries
+// of a built index are normalized by it, the normalization of a name must not
+// change once registered.
+func RegisterIndexNormalizer(name string, n IndexNormalizer) error {
+	normalizersMu.Lock()
+	defer normalizersMu.Unlock()
+	if name == "" {
+		return fmt.Errorf("an index normalization must have a name")
+	}
+	if _, ok := indexNormalizers[name]; ok {
+		return fmt.Errorf("index normalization `%s` is already registered", name)
+	}
+	indexNormalizers[name] = n
+	return nil
+}
+
+// NormalizeE164Phone returns the phone number |s| in E.164 format, a "+"
+// followed by at most 15 digits of country code and subscriber number, e.g.
+// "+15551234567" for "+1 (555) 123-4567". Spaces, dots, dashes, slashes and
+// parentheses are ignored, and a leading "00" international call prefix reads
+// as a "+". A number without either prefix is read as starting with its
+// country code. A value that is not such a number, e.g. because it has letters
+// or an extension, is returned unchanged.
+func NormalizeE164Phone(s string) string {
+	digits := make([]byte, 0, len(s))
+	plus := false
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c >= '0' && c <= '9':
+			digits = append(digits, c)
+		case c == '+' && !plus && len(digits) == 0:
+			plus = true
+		case c == ' ' || c == '.' || c == '-' || c == '/' || c == '(' || c == ')' || c == '\t':
+		default:
+			return s
+		}
+	}
+	if !plus && len(digits) > 2 && digits[0] == '0' && digits[1] == '0' {
+		digits = digits[2:]
+	}
+	if len(digits) == 0 || len(digits) > 15 || digits[0] == '0' {
+		return s
+	}
+	return "+" + string(digits)
+}
+
+// NormalizeEmail returns the email address |s| with surrounding spaces removed,
+// its local part case folded and its domain lowercased, e.g.
+// "john.doe@example.com" for " John.Doe@Example.COM". A value without a local
+// part and a domain around its last "@" is returned unchanged.
+func NormalizeEmail(s string) string {
+	addr := strings.TrimSpace(s)
+	at := strings.LastIndexByte(addr, '@')
+	if at <= 0 || at == len(addr)-1 {
+		return s
+	}
+	return cases.Fold().String(addr[:at]) + "@" + strings.ToLower(addr[at+1:])
+}
+
+// fieldNormalizer normalizes the char and varchar fields of the keys of an
+// index with a normalization. A nil *fieldNormalizer leaves keys unchanged.
+type fieldNormalizer struct {
+	norm IndexNormalizer
+	// fields holds the positions of the normalized fields
+	fields map[int]bool
+}
+
+// newFieldNormalizer returns the fieldNormalizer of the keys of |idx|, or nil
+// if |idx| has no normalization.
+func newFieldNormalizer(idx schema.Index) (*fieldNormalizer, error) {
+	if idx.Normalization() == "" {
+		return nil, nil
+	}
+	normalizersMu.RLock()
+	norm, ok := indexNormalizers[idx.Normalization()]
+	normalizersMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("index `%s`: unknown normalization `%s`", idx.Name(), idx.Normalization())
+	}
+	n := &fieldNormalizer{norm: norm, fields: make(map[int]bool)}
+	for i, tag := range idx.IndexedColumnTags() {
+		col, ok := idx.GetColumn(tag)
+		if ok && schema.IsColNormalizedStringType(col) {
+			n.fields[i] = true
+		}
+	}
+	return n, nil
+}
+
+// NormalizeField returns the index key field |f| at position |to| normalized,
+// if it is a normalized field.
+func (n *fieldNormalizer) NormalizeField(to int, f []byte) []byte {
+	if n == nil || f == nil || !n.fields[to] {
+		return f
+	}
+	// strings are null terminated
+	return append([]byte(n.norm(string(f[:len(f)-1]))), 0)
+}
+
+// IterNormalizedIndexRows returns an iterator over the entries of |rows|, the
+// data of |idx|, whose leading indexed values equal |values| once normalized
+// by the normalization of |idx|, so that e.g. "(555) 123-4567" finds the rows
+// of "555.123.4567". The values of indexes with encrypted keys must be
+// encrypted by the caller.
+func IterNormalizedIndexRows(ctx context.Context, idx schema.Index, rows durable.Index, values ...interface{}) (prolly.MapIter, error) {
+	if idx.TimeBucket() != 0 {
+		return nil, fmt.Errorf("index `%s`: normalized lookups of time bucketed indexes are not supported", idx.Name())
+	}
+	norm, err := newFieldNormalizer(idx)
+	if err != nil {
+		return nil, err
+	}
+	m := durable.ProllyMapFromIndex(rows)
+	kd, _ := m.Descriptors()
+	pd := prefixDesc(kd, len(values))
+	pb := val.NewTupleBuilder(pd)
+	for i, v := range values {
+		if err = index.PutField(ctx, m.NodeStore(), pb, i, v); err != nil {
+			return nil, err
+		}
+	}
+	// normalize the values like the build does
+	prefix := pb.Build(m.Pool())
+	reverse := newStringReversal(idx)
+	for i := range values {
+		f := norm.NormalizeField(i, prefix.GetField(i))
+		pb.PutRaw(i, reverse.ReverseField(i, f))
+	}
+	itr, err := NewPrefixItr(ctx, pb.Build(m.Pool()), pd, m)
+	if err != nil {
+		return nil, err
+	}
+	return &itr, nil
+}
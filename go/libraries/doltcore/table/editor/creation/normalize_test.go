@@ -0,0 +1,157 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/dolthub/dolt/go/libraries/doltcore/doltdb/durable"
+	"github.com/dolthub/dolt/go/libraries/doltcore/schema"
+	"github.com/dolthub/dolt/go/store/types"
+)
+
+func TestNormalizeE164Phone(t *testing.T) {
+	tests := []struct {
+		in, out string
+	}{
+		{"+15551234567", "+15551234567"},
+		{"+1 (555) 123-4567", "+15551234567"},
+		{"1.555.123.4567", "+15551234567"},
+		{"1 555 123 4567", "+15551234567"},
+		{"001-555-123-4567", "+15551234567"},
+		{"+44 20 7946 0958", "+442079460958"},
+		{"0044 (20) 7946/0958", "+442079460958"},
+		{"\t+49 30 123456 ", "+4930123456"},
+		// values that are not phone numbers are unchanged
+		{"", ""},
+		{"+", "+"},
+		{"555-CALL-NOW", "555-CALL-NOW"},
+		{"+1 555 123 4567 x89", "+1 555 123 4567 x89"},
+		{"1+5551234567", "1+5551234567"},
+		{"+1234567890123456", "+1234567890123456"},
+		{"020 7946 0958", "020 7946 0958"},
+	}
+	for _, test := range tests {
+		require.Equal(t, test.out, NormalizeE164Phone(test.in), "%q", test.in)
+	}
+}
+
+func TestNormalizeEmail(t *testing.T) {
+	tests := []struct {
+		in, out string
+	}{
+		{"john.doe@example.com", "john.doe@example.com"},
+		{"John.Doe@Example.COM", "john.doe@example.com"},
+		{"  JOHN.DOE@EXAMPLE.COM\n", "john.doe@example.com"},
+		{"Jöhn+Tag@Exämple.Org", "jöhn+tag@exämple.org"},
+		{"\"A@B\"@Example.com", "\"a@b\"@example.com"},
+		{"Straße@example.com", "strasse@example.com"},
+		// values that are not email addresses are unchanged
+		{"", ""},
+		{"John Doe", "John Doe"},
+		{"@Example.com", "@Example.com"},
+		{"John@", "John@"},
+	}
+	for _, test := range tests {
+		require.Equal(t, test.out, NormalizeEmail(test.in), "%q", test.in)
+	}
+}
+
+func TestNormalizedIndex(t *testing.T) {
+	ctx := context.Background()
+	vrw := newTestVRW()
+	sch, err := schema.SchemaFromCols(schema.NewColCollection(
+		schema.NewColumn("pk", 1, types.IntKind, true, schema.NotNullConstraint{}),
+		schema.NewColumn("phone", 2, types.StringKind, false),
+		schema.NewColumn("email", 3, types.StringKind, false),
+	))
+	require.NoError(t, err)
+	rows := [][]interface{}{
+		{1, "+1 (555) 123-4567", "John.Doe@Example.com"},
+		{2, "1.555.987.6543", "jane@EXAMPLE.com"},
+		{3, "001 555 123 4567", "JOHN.DOE@example.COM"},
+		{4, "unknown", nil},
+		{5, nil, "not an address"},
+	}
+	tbl := newTestTable(t, ctx, vrw, sch, rows)
+
+	// lookup returns the primary keys of the entries of |rowData| that match |value|
+	lookup := func(idx schema.Index, rowData durable.Index, value interface{}) (pks []int64) {
+		iter, err := IterNormalizedIndexRows(ctx, idx, rowData, value)
+		require.NoError(t, err)
+		kd, _ := durable.ProllyMapFromIndex(rowData).Descriptors()
+		for {
+			k, _, err := iter.Next(ctx)
+			if err == io.EOF {
+				return pks
+			}
+			require.NoError(t, err)
+			pk, _ := kd.GetInt64(1, k)
+			pks = append(pks, pk)
+		}
+	}
+
+	// build returns |idx| of |sch| and its data built from |rows|
+	primary, err := tbl.GetRowData(ctx)
+	require.NoError(t, err)
+	build := func(name string, tag uint64, props schema.IndexProperties) (schema.Index, durable.Index, error) {
+		props.IsUserDefined = true
+		idx, err := sch.Indexes().AddIndexByColTags(name, []uint64{tag}, props)
+		require.NoError(t, err)
+		rowData, err := BuildSecondaryProllyIndex(ctx, vrw, sch, idx, durable.ProllyMapFromIndex(primary), BuildOptions{})
+		return idx, rowData, err
+	}
+
+	idx, rowData, err := build("phone_idx", 2, schema.IndexProperties{Normalization: E164PhoneNormalization})
+	require.NoError(t, err)
+	require.Equal(t, E164PhoneNormalization, idx.Normalization())
+	require.Equal(t, []string{"[+15551234567,1]", "[+15551234567,3]", "[+15559876543,2]", "[unknown,4]", "[NULL,5]"}, collectKeys(t, ctx, durable.ProllyMapFromIndex(rowData)))
+	require.Equal(t, []int64{1, 3}, lookup(idx, rowData, "(+1) 555-123-4567"))
+	require.Equal(t, []int64{2}, lookup(idx, rowData, "+15559876543"))
+	require.Empty(t, lookup(idx, rowData, "555-123-4567"))
+
+	// DML and lookups do not normalize values, so the index cannot be stored
+	// in a table
+	_, err = CreateIndexWithProperties(ctx, tbl, "phone_idx", []uint64{2}, schema.IndexProperties{Normalization: E164PhoneNormalization, IsUserDefined: true}, BuildOptions{})
+	require.Error(t, err)
+
+	// unique indexes are unique in their normalized values
+	_, _, err = build("email_uniq", 3, schema.IndexProperties{Normalization: EmailNormalization, IsUnique: true})
+	require.Error(t, err)
+	idx, rowData, err = build("email_idx", 3, schema.IndexProperties{Normalization: EmailNormalization})
+	require.NoError(t, err)
+	require.Equal(t, []int64{1, 3}, lookup(idx, rowData, " john.doe@EXAMPLE.com"))
+	require.Equal(t, []int64{2}, lookup(idx, rowData, "Jane@Example.Com"))
+
+	// custom normalizations can be registered
+	require.NoError(t, RegisterIndexNormalizer("test_upper", strings.ToUpper))
+	t.Cleanup(func() {
+		normalizersMu.Lock()
+		defer normalizersMu.Unlock()
+		delete(indexNormalizers, "test_upper")
+	})
+	require.Error(t, RegisterIndexNormalizer("test_upper", strings.ToLower))
+	require.Error(t, RegisterIndexNormalizer(EmailNormalization, strings.ToLower))
+	idx, rowData, err = build("email_upper", 3, schema.IndexProperties{Normalization: "test_upper"})
+	require.NoError(t, err)
+	require.Equal(t, []int64{1, 3}, lookup(idx, rowData, "john.doe@example.com"))
+	_, _, err = build("email_unknown", 3, schema.IndexProperties{Normalization: "unregistered"})
+	require.Error(t, err)
+}
@@ -0,0 +1,129 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"fmt"
+
+	"github.com/dolthub/dolt/go/libraries/doltcore/schema"
+	"github.com/dolthub/dolt/go/store/pool"
+	"github.com/dolthub/dolt/go/store/val"
+)
+
+// nullCanonicalizer canonicalizes the NULLs and zero values of the fields of
+// index keys with BuildOptions.IndexNullCanonicalization. A nil
+// *nullCanonicalizer leaves keys unchanged.
+type nullCanonicalizer struct {
+	kd     val.TupleDesc
+	fields map[int]canonicalField
+}
+
+type canonicalField struct {
+	how  IndexNullCanonicalization
+	zero []byte
+}
+
+// newNullCanonicalizer returns the nullCanonicalizer of the keys of |idx|,
+// encoded by |kd|, or nil if |opts| has no IndexNullCanonicalization.
+func newNullCanonicalizer(sch schema.Schema, idx schema.Index, kd val.TupleDesc, opts BuildOptions) (*nullCanonicalizer, error) {
+	if len(opts.IndexNullCanonicalization) == 0 {
+		return nil, nil
+	}
+	c := &nullCanonicalizer{kd: kd, fields: make(map[int]canonicalField)}
+	bp := pool.NewBuffPool()
+	for name, how := range opts.IndexNullCanonicalization {
+		col, ok := sch.GetAllCols().GetByNameCaseInsensitive(name)
+		if !ok {
+			return nil, fmt.Errorf("index `%s`: column `%s` does not exist", idx.Name(), name)
+		}
+		if how != IndexZeroAsNull && how != IndexNullAsZero {
+			return nil, fmt.Errorf("index `%s`: invalid NULL canonicalization %d of column `%s`", idx.Name(), how, col.Name)
+		}
+		to := -1
+		for i, tag := range idx.IndexedColumnTags() {
+			if tag == col.Tag {
+				to = i
+			}
+		}
+		if to < 0 {
+			return nil, fmt.Errorf("index `%s`: column `%s` is not indexed", idx.Name(), col.Name)
+		}
+		if how == IndexZeroAsNull && !kd.Types[to].Nullable {
+			return nil, fmt.Errorf("index `%s`: column `%s` cannot be NULL", idx.Name(), col.Name)
+		}
+		zero, ok := zeroField(kd.Types[to], bp)
+		if !ok {
+			return nil, fmt.Errorf("index `%s`: column `%s` has no zero value", idx.Name(), col.Name)
+		}
+		c.fields[to] = canonicalField{how: how, zero: zero}
+	}
+	return c, nil
+}
+
+// zeroField returns the encoding of the zero value of |typ|: an empty string
+// or a zero number.
+func zeroField(typ val.Type, bp pool.BuffPool) ([]byte, bool) {
+	tb := val.NewTupleBuilder(val.NewTupleDescriptor(typ))
+	switch typ.Enc {
+	case val.Int8Enc:
+		tb.PutInt8(0, 0)
+	case val.Uint8Enc:
+		tb.PutUint8(0, 0)
+	case val.Int16Enc:
+		tb.PutInt16(0, 0)
+	case val.Uint16Enc:
+		tb.PutUint16(0, 0)
+	case val.Int32Enc:
+		tb.PutInt32(0, 0)
+	case val.Uint32Enc:
+		tb.PutUint32(0, 0)
+	case val.Int64Enc:
+		tb.PutInt64(0, 0)
+	case val.Uint64Enc:
+		tb.PutUint64(0, 0)
+	case val.Float32Enc:
+		tb.PutFloat32(0, 0)
+	case val.Float64Enc:
+		tb.PutFloat64(0, 0)
+	case val.StringEnc:
+		tb.PutString(0, "")
+	case val.ByteStringEnc:
+		tb.PutByteString(0, []byte{})
+	default:
+		return nil, false
+	}
+	return tb.Build(bp).GetField(0), true
+}
+
+// CanonicalizeField returns the index key field |f| at position |to|: NULL if
+// it is the zero value of a column with IndexZeroAsNull, and the zero value
+// if it is NULL in a column with IndexNullAsZero. Zero values are compared
+// like keys, so -0 is a zero float.
+func (c *nullCanonicalizer) CanonicalizeField(to int, f []byte) []byte {
+	if c == nil {
+		return f
+	}
+	cf, ok := c.fields[to]
+	if !ok {
+		return f
+	}
+	switch {
+	case cf.how == IndexNullAsZero && f == nil:
+		return cf.zero
+	case cf.how == IndexZeroAsNull && f != nil && c.kd.Comparator().CompareValues(f, cf.zero, c.kd.Types[to]) == 0:
+		return nil
+	}
+	return f
+}
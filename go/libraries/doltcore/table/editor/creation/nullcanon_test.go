@@ -0,0 +1,95 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/dolthub/dolt/go/libraries/doltcore/doltdb/durable"
+	"github.com/dolthub/dolt/go/libraries/doltcore/schema"
+	"github.com/dolthub/dolt/go/store/val"
+)
+
+func TestIndexNullCanonicalization(t *testing.T) {
+	ctx := context.Background()
+	vrw := newTestVRW()
+	sch := newTestSchema(t)
+	rows := [][]interface{}{
+		{1, 0, ""},
+		{2, 5, "a"},
+		{3, nil, ""},
+		{4, 0, nil},
+	}
+	primary := newTestPrimary(t, ctx, vrw, sch, rows)
+	coll := schema.NewIndexCollection(sch.GetAllCols(), sch.GetPKCols())
+	uniq, err := coll.AddIndexByColNames("c2_uniq", []string{"c2"}, schema.IndexProperties{IsUnique: true})
+	require.NoError(t, err)
+	idx, err := coll.AddIndexByColNames("c1_idx", []string{"c1"}, schema.IndexProperties{})
+	require.NoError(t, err)
+
+	// dups builds |uniq| with |opts|, returning its keys and the number of duplicates found
+	dups := func(opts BuildOptions) ([]string, int) {
+		var n int
+		rowData, err := BuildUniqueProllyIndex(ctx, vrw, sch, uniq, primary, opts, func(context.Context, val.Tuple, val.Tuple) error {
+			n++
+			return nil
+		})
+		require.NoError(t, err)
+		return collectKeys(t, ctx, durable.ProllyMapFromIndex(rowData)), n
+	}
+
+	// the empty strings of rows 1 and 3 are duplicates
+	_, n := dups(BuildOptions{})
+	require.Equal(t, 1, n)
+
+	// as NULLs, they never conflict
+	keys, n := dups(BuildOptions{KeyEncodingOptions: KeyEncodingOptions{IndexNullCanonicalization: map[string]IndexNullCanonicalization{"C2": IndexZeroAsNull}}})
+	require.Equal(t, 0, n)
+	require.Equal(t, []string{"[a,2]", "[NULL,1]", "[NULL,3]", "[NULL,4]"}, keys)
+
+	// and as empty strings, the NULL of row 4 conflicts with them too
+	keys, n = dups(BuildOptions{KeyEncodingOptions: KeyEncodingOptions{IndexNullCanonicalization: map[string]IndexNullCanonicalization{"c2": IndexNullAsZero}}})
+	require.Equal(t, 2, n)
+	require.Equal(t, []string{"[,1]", "[,3]", "[,4]", "[a,2]"}, keys)
+
+	// zero numbers are NULLs too, and order like them
+	rowData, err := BuildSecondaryProllyIndex(ctx, vrw, sch, idx, primary, BuildOptions{
+		KeyEncodingOptions: KeyEncodingOptions{
+			IndexNullCanonicalization: map[string]IndexNullCanonicalization{"c1": IndexZeroAsNull},
+		},
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{"[5,2]", "[NULL,1]", "[NULL,3]", "[NULL,4]"}, collectKeys(t, ctx, durable.ProllyMapFromIndex(rowData)))
+
+	for _, canon := range []map[string]IndexNullCanonicalization{
+		{"missing": IndexZeroAsNull},
+		{"c2": IndexZeroAsNull},
+		{"pk": IndexZeroAsNull},
+		{"c1": 0},
+	} {
+		_, err = BuildSecondaryProllyIndex(ctx, vrw, sch, idx, primary, BuildOptions{KeyEncodingOptions: KeyEncodingOptions{IndexNullCanonicalization: canon}})
+		require.Error(t, err, "%v", canon)
+	}
+	tbl := newTestTable(t, ctx, vrw, sch, rows)
+	_, err = CreateIndex(ctx, tbl, "idx", []string{"c1"}, false, true, "", BuildOptions{
+		KeyEncodingOptions: KeyEncodingOptions{
+			IndexNullCanonicalization: map[string]IndexNullCanonicalization{"c1": IndexZeroAsNull},
+		},
+	})
+	require.Error(t, err)
+}
@@ -0,0 +1,42 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dolthub/dolt/go/libraries/doltcore/doltdb/durable"
+	"github.com/dolthub/dolt/go/libraries/doltcore/schema"
+	"github.com/dolthub/dolt/go/store/prolly"
+)
+
+// IterIndexNulls returns an iterator over the entries of |rows|, the data of
+// |idx|, whose first indexed column is NULL. NULL has a single encoding in
+// index keys, an empty field, that orders after every value, so the NULL
+// entries of a prolly index are stored together at its end and this is a
+// single range scan.
+func IterIndexNulls(ctx context.Context, idx schema.Index, rows durable.Index) (prolly.MapIter, error) {
+	if idx.TimeBucket() != 0 {
+		return nil, fmt.Errorf("index `%s`: NULL scans of time bucketed indexes are not supported", idx.Name())
+	}
+	m := durable.ProllyMapFromIndex(rows)
+	kd, _ := m.Descriptors()
+	return m.IterRange(ctx, prolly.Range{
+		Start: []prolly.RangeCut{{Null: true}},
+		Stop:  []prolly.RangeCut{{Null: true}},
+		Desc:  kd,
+	})
+}
@@ -0,0 +1,87 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/dolthub/dolt/go/libraries/doltcore/doltdb/durable"
+	"github.com/dolthub/dolt/go/libraries/doltcore/schema"
+)
+
+func TestIterIndexNulls(t *testing.T) {
+	ctx := context.Background()
+	vrw := newTestVRW()
+	sch := newTestSchema(t)
+
+	// every third row has a NULL c1
+	var rows [][]interface{}
+	var nulls []int64
+	for i := 0; i < 300; i++ {
+		if i%3 == 0 {
+			rows = append(rows, []interface{}{i, nil, "x"})
+			nulls = append(nulls, int64(i))
+		} else {
+			rows = append(rows, []interface{}{i, 1000 - i, "x"})
+		}
+	}
+	primary := newTestPrimary(t, ctx, vrw, sch, rows)
+
+	coll := schema.NewIndexCollection(sch.GetAllCols(), sch.GetPKCols())
+	for _, unique := range []bool{false, true} {
+		idx, err := coll.AddIndexByColNames("c1_idx", []string{"c1"}, schema.IndexProperties{IsUnique: unique})
+		require.NoError(t, err)
+		built, err := BuildSecondaryProllyIndex(ctx, vrw, sch, idx, primary, BuildOptions{})
+		require.NoError(t, err)
+		m := durable.ProllyMapFromIndex(built)
+		kd, _ := m.Descriptors()
+
+		iter, err := IterIndexNulls(ctx, idx, built)
+		require.NoError(t, err)
+		var pks []int64
+		for {
+			k, _, err := iter.Next(ctx)
+			if err == io.EOF {
+				break
+			}
+			require.NoError(t, err)
+			require.True(t, kd.IsNull(0, k))
+			pk, ok := kd.GetInt64(1, k)
+			require.True(t, ok)
+			pks = append(pks, pk)
+		}
+		require.Equal(t, nulls, pks)
+
+		// the NULL entries are the last entries of the index
+		cnt := m.Count()
+		iter, err = m.IterOrdinalRange(ctx, uint64(cnt-len(nulls)), uint64(cnt))
+		require.NoError(t, err)
+		for {
+			k, _, err := iter.Next(ctx)
+			if err == io.EOF {
+				break
+			}
+			require.NoError(t, err)
+			require.True(t, kd.IsNull(0, k))
+		}
+
+		_, err = coll.RemoveIndex("c1_idx")
+		require.NoError(t, err)
+	}
+}
@@ -0,0 +1,520 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/dolthub/dolt/go/libraries/doltcore/doltdb/durable"
+	"github.com/dolthub/dolt/go/libraries/doltcore/table/editor"
+	"github.com/dolthub/dolt/go/store/hash"
+	"github.com/dolthub/dolt/go/store/pool"
+	"github.com/dolthub/dolt/go/store/val"
+)
+
+// BuildOptions configure the builds of secondary indexes. They are grouped by the phase of a build they configure: the
+// KeyEncodingOptions determine the keys of index entries, the ValueLayoutOptions their values, and the
+// ExecutionOptions how a build runs and what it reports, without changing the index data. Validate checks that the
+// options can be combined.
+type BuildOptions struct {
+	// EditOptions are the options of the table editor that rebuilds the indexes of tables of the old storage formats.
+	EditOptions editor.Options
+
+	KeyEncodingOptions
+	ValueLayoutOptions
+	ExecutionOptions
+}
+
+// KeyEncodingOptions are the BuildOptions that determine how the keys of secondary index entries are encoded and
+// ordered.
+type KeyEncodingOptions struct {
+	// IndexKeyEncryption, if non-nil, encrypts the values of some indexed columns in secondary indexes built with
+	// these BuildOptions. Lookups into such an index must encrypt their keys the same way.
+	IndexKeyEncryption *IndexKeyEncryption
+	// MaxIndexFieldSize, if positive, is the largest encoded size, in bytes, of an indexed field of the keys of secondary
+	// indexes built with these BuildOptions. Larger fields are handled as OversizedIndexFieldPolicy selects. Without a limit, a
+	// key larger than val.MaxTupleDataSize fails the build deep in the tuple builder.
+	MaxIndexFieldSize int
+	// OversizedIndexFieldPolicy is how fields larger than MaxIndexFieldSize are handled. Truncated keys are not the keys
+	// of their rows, so indexes built with OversizedIndexFieldTruncate cannot be stored in a table.
+	OversizedIndexFieldPolicy OversizedIndexFieldPolicy
+	// ReverseIndexOrder, if true, builds secondary index data in descending key order, so that the first leaves of the
+	// index hold its largest keys and descending scans read the start of the tree. The order is not stored with the
+	// index data, which must be read through ReverseOrderedIndexMap, so such indexes cannot be stored in a
+	// table.
+	ReverseIndexOrder bool
+	// IndexGeohashPrecision, if non-zero, prefixes the keys of secondary indexes with the geohash, of
+	// IndexGeohashPrecision characters from 1 to 12, of the location in their first two indexed columns, which must be
+	// a latitude and a longitude column of floating point type. Nearby locations share geohash prefixes, so they are
+	// stored together and proximity queries are prefix scans of IterIndexGeohashPrefix. The geohash is not
+	// stored with the index data, so such indexes cannot be stored in a table, and they cannot be unique.
+	IndexGeohashPrecision int
+	// IndexEnumsByLabel, if true, orders the ENUM and SET fields of secondary index keys by their string labels, in
+	// byte order, rather than by their ordinals. By default, index keys are ordered like MySQL orders ENUM and SET
+	// values, by the order in which their values were declared. Keys ordered by label cannot be read as the keys of the
+	// index, so such indexes cannot be stored in a table.
+	IndexEnumsByLabel bool
+	// DistinctIndex, if true, builds secondary indexes with one entry per distinct value of their indexed columns, keyed
+	// by the value alone, without the primary key of any row, so that a scan of such an index returns the distinct
+	// values, as for SELECT DISTINCT. Entries cannot be mapped back to rows, so such indexes cannot be stored in a
+	// table, and they cannot be unique.
+	DistinctIndex bool
+	// EqualityOnlyIndex, if true, builds secondary indexes for lookups of whole values only, so that columns whose
+	// types have no order-preserving encoding, such as JSON, can be indexed. The fields of such columns are ordered by
+	// their encoded bytes, so values equal only once decoded are different keys, and range scans of them are
+	// meaningless. Without it, indexing such a column fails with ErrIndexKeyNotOrdered. The order is not
+	// stored with the index data, which must be read through EqualityOnlyIndexMap, so such indexes cannot be
+	// stored in a table.
+	EqualityOnlyIndex bool
+	// IndexSourceCharsets maps the names of indexed string columns to the character sets their stored strings are in,
+	// for data imported without transcoding from a source with another character set. Secondary index keys hold the
+	// strings of such columns transcoded to UTF-8, the character set of the columns, so that the index orders them by
+	// their UTF-8 bytes. Writes do not transcode strings, so such indexes cannot be stored in a table.
+	IndexSourceCharsets map[string]string
+	// IndexNullCanonicalization, if non-empty, maps the names of indexed columns to how their NULLs and zero values are
+	// canonicalized in secondary index keys, so that NULL handling, such as the uniqueness of NULLs, applies to the
+	// values that an import meant as missing. Keys that are canonicalized are not the keys of their rows, so such indexes
+	// cannot be stored in a table.
+	IndexNullCanonicalization map[string]IndexNullCanonicalization
+}
+
+// ValueLayoutOptions are the BuildOptions that determine the values of secondary index entries, which are empty by
+// default. At most one of them can be set.
+type ValueLayoutOptions struct {
+	// MirrorPrimaryRowInIndex, if true, stores the entire primary value of each row as the value of its secondary
+	// index entry, so that index scans never read the primary index. The values are encoded with the primary index's
+	// value descriptor rather than the index's own empty one, so indexes built this way cannot hold tombstones.
+	MirrorPrimaryRowInIndex bool
+	// RecordSourceChunkInIndex, if true, stores the address of the primary leaf chunk holding each row as the value
+	// of its secondary index entry, for storage and locality analysis. See IndexSourceChunk for the value
+	// layout. Only builds that scan a primary index can record source chunks, and they cannot be checkpointed.
+	RecordSourceChunkInIndex bool
+	// RecordRowLocatorInIndex, if true, stores the address of the primary leaf chunk holding each row and the row's
+	// position within it as the value of its secondary index entry, so that LookupRowByLocator can read the
+	// row with a single chunk fetch instead of a search of the primary index. See IndexRowLocator for the
+	// value layout. Like RecordSourceChunkInIndex, it requires a build that scans a primary index and cannot be
+	// checkpointed. Writes do not maintain locators, so such indexes cannot be stored in a table.
+	RecordRowLocatorInIndex bool
+	// IndexIntervalEnd, if set, builds secondary indexes over the start column of ranges as interval indexes. The value
+	// of each entry holds the end of its row's range, read from the column named IndexIntervalEnd, and the largest end
+	// of the ranges at or before it, so that IterIntervalsContaining finds the ranges that contain a value
+	// with a bounded scan. The largest ends are not maintained by writes, so such indexes cannot be stored in a table.
+	IndexIntervalEnd string
+	// IndexExpiryColumn, if set, stores in the value of each secondary index entry the time its row expires: the value
+	// of the datetime or timestamp column named IndexExpiryColumn, plus IndexExpiryTTL. Entries of rows whose column is
+	// NULL never expire. CompactExpiredIndexEntries removes the expired entries of such an index. Writes do not
+	// maintain expiries, so such indexes cannot be stored in a table.
+	IndexExpiryColumn string
+	// IndexExpiryTTL is the time from the value of IndexExpiryColumn until a row expires.
+	IndexExpiryTTL time.Duration
+	// IndexEntryChecksums, if true, stores in the value of each secondary index entry a CRC-32C checksum of its key,
+	// so that VerifyIndexChecksums can detect corrupted entries without reading the primary rows. Writes do
+	// not maintain checksums, so such indexes cannot be stored in a table.
+	IndexEntryChecksums bool
+}
+
+// ExecutionOptions are the BuildOptions that determine how secondary index builds run, verify and report on the
+// index data they build, and which rows they index.
+type ExecutionOptions struct {
+	// IndexRowFilter, if non-nil, restricts the rows included in secondary indexes built with these BuildOptions.
+	IndexRowFilter IndexRowFilter
+	// VerifyIndexRowCount is a debugging aid. If true, CreateIndex checks that a newly built non-unique, non-partial
+	// index has exactly one entry per row of the table, returning an error if it does not.
+	VerifyIndexRowCount bool
+	// MaxIndexBuildDuration, if non-zero, is the longest a secondary index build may run before it is aborted.
+	MaxIndexBuildDuration time.Duration
+	// IndexBuildStats, if non-nil, is populated with statistics collected while building a secondary index.
+	IndexBuildStats *IndexBuildStats
+	// VerifySampleSize, if non-zero, limits secondary index verification to a sample of approximately this many rows
+	// and index entries. Sampled verification is faster on large tables but may miss inconsistencies.
+	VerifySampleSize uint64
+	// IndexSampleSeed, if non-zero, seeds the choice of the rows read by AnalyzeIndexCandidate and by sampled index
+	// verification, which otherwise read evenly spaced blocks of rows. The same seed samples the same rows of a table.
+	IndexSampleSeed int64
+	// IndexEntryWriter, if non-nil, receives a copy of every entry written to a secondary index built with these
+	// Options, in the encoding read by ReadIndexEntry.
+	IndexEntryWriter io.Writer
+	// IndexSortedRunDir, if non-empty, is a directory to which secondary index builds with these BuildOptions spill their
+	// entries as sorted run files of IndexSortedRunEntries entries each, for an external merge step such as
+	// MergeSortedRuns. The run files are reported in IndexBuildStats.SortedRuns, and left for the caller to
+	// remove. Interval and checkpointed builds cannot spill runs.
+	IndexSortedRunDir string
+	// IndexSortedRunEntries is the number of entries in each sorted run spilled to IndexSortedRunDir, 65536 if zero.
+	IndexSortedRunEntries int
+	// IndexDocumentSink, if non-nil, receives the indexed column values of every row indexed by a secondary index
+	// build with these BuildOptions, for example to build a full-text search index from the same scan.
+	IndexDocumentSink IndexDocumentSink
+	// IndexColumnSink, if non-nil, receives the indexed values of every secondary index built with these BuildOptions, in
+	// index order, in batches of IndexColumnBatchSize entries. The values are those of the index keys, so they are
+	// encrypted, reversed or canonicalized when the keys are.
+	IndexColumnSink IndexColumnSink
+	// IndexColumnBatchSize is the number of entries in each batch passed to IndexColumnSink, 1024 if zero.
+	IndexColumnBatchSize int
+	// IndexHistogramBuckets, if positive, is the number of buckets of the equi-depth histogram of the indexed values
+	// computed after a secondary index is built with these BuildOptions and reported in IndexBuildStats.Histogram, e.g. to
+	// be stored with other statistics of the index by an IndexBuildHook. See BuildIndexHistogram.
+	IndexHistogramBuckets int
+	// DetectDeFactoUnique, if true, makes CreateIndexWithProperties check whether a non-unique index it builds
+	// has no two entries with equal non-NULL indexed values, and if so record schema.IndexProperties.IsDeFactoUnique on
+	// the index as a hint to the planner. The check never fails the build.
+	DetectDeFactoUnique bool
+	// DetectIndexKeyCollisions is a debugging aid. If true, building a non-unique secondary index returns an error if
+	// two rows produce the same index key, which can only happen if the primary index is corrupt.
+	DetectIndexKeyCollisions bool
+	// IndexBuildLimiter, if non-nil, throttles the building of secondary indexes, so that background index builds do
+	// not starve foreground queries of I/O.
+	IndexBuildLimiter IndexBuildLimiter
+	// IndexTuplePool, if non-nil, allocates the key tuples of secondary indexes built with these BuildOptions in place of
+	// the pool of the primary index. Built tuples are retained by the index, so the pool must never return the same
+	// buffer twice; see pool.NewSlabBuffPool.
+	IndexTuplePool pool.BuffPool
+	// FlushPartialIndexOnCancel is a debugging aid. If true, a secondary index build whose context is canceled returns
+	// a ErrPartialIndexBuild holding the entries built so far, rather than discarding them.
+	FlushPartialIndexOnCancel bool
+	// MaxIndexDistinctValues, if non-zero, is the most distinct values of its indexed columns that a secondary index
+	// may have. Builds of indexes with more values fail, which surfaces dirty data in columns of bounded cardinality.
+	MaxIndexDistinctValues uint64
+	// AssertIndexKeyOrder, if true, checks that builds which write the entries of secondary indexes in key order, rather
+	// than as edits, are given their keys in ascending order, and fails on a key out of order with
+	// ErrIndexKeyOrder. Without it, such builds fall back to edits. It is meant for tests and debugging.
+	AssertIndexKeyOrder bool
+	// RejectRedundantIndexes, if true, makes CreateIndex refuse to create an index over a prefix of the primary key,
+	// which would duplicate the primary index. MySQL allows such indexes, so this is off by default.
+	RejectRedundantIndexes bool
+	// IndexBuildTracer, if non-nil, traces the steps of secondary index builds.
+	IndexBuildTracer IndexBuildTracer
+	// RebuildOnSchemaMismatch, if true, makes BuildSecondaryIndexWithSchema build an index defined against a
+	// schema other than the table's over the same columns of the table's schema, rather than fail.
+	RebuildOnSchemaMismatch bool
+	// IndexBuildCheckpoints, if non-nil, stores a checkpoint of secondary index builds by
+	// BuildSecondaryProllyIndex and BuildUniqueProllyIndex every IndexBuildCheckpointRows rows, and
+	// such builds resume from the last checkpoint of an earlier build of the same index that did not complete. The
+	// index data of each checkpoint is persisted, without moving the root of the chunk store, so checkpointed builds
+	// must write to a *types.ValueStore.
+	IndexBuildCheckpoints IndexBuildCheckpointStore
+	// IndexBuildCheckpointRows is the number of rows between the checkpoints of IndexBuildCheckpoints.
+	IndexBuildCheckpointRows uint64
+	// UniqueIndexCheckWorkers, if positive, pipelines the builds of unique indexes by BuildUniqueProllyIndex:
+	// one goroutine reads and encodes the primary rows, UniqueIndexCheckWorkers goroutines check their entries for
+	// duplicates, and one goroutine inserts them in scan order. Duplicates are found as by a serial build. Builds
+	// resumed from checkpoints, or with FlushPartialIndexOnCancel, are not pipelined.
+	UniqueIndexCheckWorkers int
+	// IndexBuildHook, if non-nil, is called by CreateIndex, ExtendIndex and
+	// MaterializeDeferredIndex after the data of the index they build is stored in its table. Its errors are
+	// returned, failing the index creation, unless LogIndexBuildHookErrors is set.
+	IndexBuildHook IndexBuildHook
+	// LogIndexBuildHookErrors, if true, logs the errors of IndexBuildHook, rather than returning them, for hooks whose
+	// failure must not fail the index
+	LogIndexBuildHookErrors bool
+}
+
+// Validate returns an error if the options cannot be combined. If |stored|, it also returns an error if the options
+// build index data that cannot be stored in a table, whose writes maintain only plain secondary index entries. The
+// options are validated on their own; a build also checks them against the index it builds.
+func (o BuildOptions) Validate(stored bool) error {
+	if o.MaxIndexFieldSize < 0 {
+		return fmt.Errorf("invalid maximum field size %d", o.MaxIndexFieldSize)
+	}
+	switch o.OversizedIndexFieldPolicy {
+	case OversizedIndexFieldError, OversizedIndexFieldTruncate, OversizedIndexFieldSkipRow:
+	default:
+		return fmt.Errorf("invalid oversized field policy %d", o.OversizedIndexFieldPolicy)
+	}
+	if o.IndexGeohashPrecision < 0 || o.IndexGeohashPrecision > maxGeohashPrecision {
+		return fmt.Errorf("invalid geohash precision %d", o.IndexGeohashPrecision)
+	}
+	if o.IndexGeohashPrecision != 0 && o.IndexKeyEncryption != nil {
+		return fmt.Errorf("the keys of geohash indexes cannot be encrypted")
+	}
+
+	values := o.valueOptions()
+	if len(values) > 1 {
+		return fmt.Errorf("%s cannot be combined, as each of them sets the values of the index", strings.Join(values, " and "))
+	}
+	if o.IndexExpiryTTL < 0 {
+		return fmt.Errorf("invalid index expiry TTL %s", o.IndexExpiryTTL)
+	}
+	if o.IndexIntervalEnd != "" && (o.IndexGeohashPrecision != 0 || o.ReverseIndexOrder) {
+		return fmt.Errorf("interval indexes must be ascending indexes without key prefixes")
+	}
+	if o.DistinctIndex && len(values) != 0 {
+		return fmt.Errorf("the entries of distinct indexes do not hold the values of rows")
+	}
+	if o.DistinctIndex && o.DetectIndexKeyCollisions {
+		return fmt.Errorf("the entries of distinct indexes are shared by rows with equal values")
+	}
+
+	if o.IndexBuildCheckpoints != nil {
+		if o.RecordSourceChunkInIndex {
+			return fmt.Errorf("builds that record source chunks cannot be checkpointed")
+		}
+		if o.RecordRowLocatorInIndex {
+			return fmt.Errorf("builds that record row locators cannot be checkpointed")
+		}
+		if o.IndexBuildCheckpointRows == 0 {
+			return fmt.Errorf("invalid index build checkpoint interval of 0 rows")
+		}
+	}
+	if o.IndexSortedRunDir != "" {
+		if o.IndexSortedRunEntries < 0 {
+			return fmt.Errorf("invalid sorted run size of %d entries", o.IndexSortedRunEntries)
+		}
+		if o.IndexIntervalEnd != "" || o.IndexBuildCheckpoints != nil {
+			return fmt.Errorf("interval and checkpointed builds cannot spill sorted runs")
+		}
+	}
+
+	if stored {
+		if what := o.unstoredIndexData(); what != "" {
+			return fmt.Errorf("%s cannot be stored in a table", what)
+		}
+	}
+	return nil
+}
+
+// valueOptions returns the names of the ValueLayoutOptions that set the values of index entries.
+func (o ValueLayoutOptions) valueOptions() (set []string) {
+	if o.MirrorPrimaryRowInIndex {
+		set = append(set, "MirrorPrimaryRowInIndex")
+	}
+	if o.RecordSourceChunkInIndex {
+		set = append(set, "RecordSourceChunkInIndex")
+	}
+	if o.RecordRowLocatorInIndex {
+		set = append(set, "RecordRowLocatorInIndex")
+	}
+	if o.IndexIntervalEnd != "" {
+		set = append(set, "IndexIntervalEnd")
+	}
+	if o.IndexExpiryColumn != "" {
+		set = append(set, "IndexExpiryColumn")
+	}
+	if o.IndexEntryChecksums {
+		set = append(set, "IndexEntryChecksums")
+	}
+	return set
+}
+
+// unstoredIndexData describes the index data built with the options if it cannot be stored in a table, or returns
+// the empty string if it can.
+func (o BuildOptions) unstoredIndexData() string {
+	switch {
+	case o.IndexRowFilter != nil:
+		return "partial indexes"
+	case o.IndexKeyEncryption != nil:
+		return "indexes with encrypted keys"
+	case o.MirrorPrimaryRowInIndex:
+		return "indexes mirroring primary rows"
+	case o.RecordSourceChunkInIndex:
+		return "indexes with source chunks"
+	case o.ReverseIndexOrder:
+		return "reverse ordered indexes"
+	case o.IndexGeohashPrecision != 0:
+		return "geohash indexes"
+	case o.IndexIntervalEnd != "":
+		return "interval indexes"
+	case o.IndexExpiryColumn != "":
+		return "indexes with expiries"
+	case o.IndexEntryChecksums:
+		return "indexes with entry checksums"
+	case o.IndexEnumsByLabel:
+		return "indexes ordered by enum label"
+	case o.DistinctIndex:
+		return "distinct indexes"
+	case o.EqualityOnlyIndex:
+		return "equality-only indexes"
+	case len(o.IndexSourceCharsets) != 0:
+		return "indexes of transcoded strings"
+	case len(o.IndexNullCanonicalization) != 0:
+		return "indexes with canonicalized NULLs"
+	case o.MaxIndexFieldSize != 0 && o.OversizedIndexFieldPolicy == OversizedIndexFieldTruncate:
+		return "indexes with truncated fields"
+	case o.RecordRowLocatorInIndex:
+		return "indexes with row locators"
+	default:
+		return ""
+	}
+}
+
+// IndexRowFilter reports whether the primary row with key |k| and value |v| should be included in a secondary index.
+// Rows for which it returns false are skipped, producing a partial index.
+type IndexRowFilter func(ctx context.Context, k, v val.Tuple) (bool, error)
+
+// IndexNullCanonicalization is how a secondary index build canonicalizes the NULLs and zero values of a column, e.g. of
+// data imported from a system that represents missing values as empty strings or zeros.
+type IndexNullCanonicalization int
+
+const (
+	// IndexZeroAsNull indexes the zero value of a column, an empty string or a zero number, as NULL.
+	IndexZeroAsNull IndexNullCanonicalization = iota + 1
+	// IndexNullAsZero indexes NULL as the zero value of a column.
+	IndexNullAsZero
+)
+
+// OversizedIndexFieldPolicy is how a secondary index build handles an indexed field larger than
+// BuildOptions.MaxIndexFieldSize.
+type OversizedIndexFieldPolicy int
+
+const (
+	// OversizedIndexFieldError fails the build with a ErrIndexFieldTooLarge.
+	OversizedIndexFieldError OversizedIndexFieldPolicy = iota
+	// OversizedIndexFieldTruncate truncates the field to BuildOptions.MaxIndexFieldSize bytes, like the column prefix of a
+	// MySQL prefix index. Strings are truncated at a character boundary. Only string and binary fields can be
+	// truncated, so other fields fail the build.
+	OversizedIndexFieldTruncate
+	// OversizedIndexFieldSkipRow leaves the row out of the index, and reports it in IndexBuildStats.OversizedRows.
+	OversizedIndexFieldSkipRow
+)
+
+// IndexBuildStats are statistics collected while building a secondary index.
+type IndexBuildStats struct {
+	// RowsScanned is the number of primary rows read.
+	RowsScanned uint64
+	// RowsIndexed is the number of entries written to the index.
+	RowsIndexed uint64
+	// NullCounts is the number of indexed rows with a NULL value, keyed by index column name.
+	NullCounts map[string]uint64
+	// LogicalBytes is the total size of the keys and values of the index entries.
+	LogicalBytes uint64
+	// StoredBytes is the total size of the chunks of the index tree, internal nodes and chunk headers included, before
+	// the chunk store compresses them.
+	StoredBytes uint64
+	// OversizedRows are the formatted primary keys of the rows left out of the index because of an indexed field larger
+	// than BuildOptions.MaxIndexFieldSize, with OversizedIndexFieldSkipRow.
+	OversizedRows []string
+	// SortedRuns are the paths of the sorted run files spilled to BuildOptions.IndexSortedRunDir, in the order they were
+	// written.
+	SortedRuns []string
+	// Histogram is the equi-depth histogram of the index, if BuildOptions.IndexHistogramBuckets is positive.
+	Histogram *IndexHistogram
+	// WriteAmplification is StoredBytes divided by LogicalBytes, the bytes written to the store per byte of index
+	// entries. It is zero for an empty index.
+	WriteAmplification float64
+}
+
+// IndexHistogram is an equi-depth histogram of the indexed values of a secondary index, for cardinality estimates.
+// Each bucket holds about the same number of entries, and the entries of each indexed value are all in one bucket.
+type IndexHistogram struct {
+	// Desc describes the bounds of the buckets: the indexed fields of the index keys.
+	Desc val.TupleDesc
+	// Buckets are the buckets of the histogram, in index order.
+	Buckets []IndexHistogramBucket
+	// Entries is the number of entries in the index, the sum of the counts of the buckets.
+	Entries uint64
+}
+
+// IndexHistogramBucket is a bucket of an IndexHistogram.
+type IndexHistogramBucket struct {
+	// UpperBound is the largest indexed value in the bucket, and is greater than the upper bound of the bucket before.
+	UpperBound val.Tuple
+	// Count is the number of entries in the bucket.
+	Count uint64
+	// Distinct is the number of distinct indexed values in the bucket.
+	Distinct uint64
+}
+
+// IndexKeyEncryption configures deterministic encryption of indexed column values. Equal values produce equal
+// ciphertexts, so an encrypted index supports equality lookups, but its order is unrelated to the order of the
+// plaintext values and range scans over encrypted columns are meaningless.
+type IndexKeyEncryption struct {
+	// Key is the key material used to derive the encryption keys. It must be at least 16 bytes.
+	Key []byte
+	// Columns are the names of the indexed columns whose values are encrypted. Only string and binary columns can
+	// be encrypted.
+	Columns []string
+}
+
+// IndexDocumentSink receives the rows indexed by a secondary index build as documents, such as the documents of an
+// in-process search library like bleve.
+type IndexDocumentSink interface {
+	// Index receives the document of a row. |docID| is the primary key of the row, encoded like the keys of the
+	// primary index, and |values| are the values of the indexed columns of the row, in index column order, as returned
+	// by the SQL engine. An error stops the build.
+	Index(ctx context.Context, docID val.Tuple, values []interface{}) error
+}
+
+// IndexColumnSink receives the indexed values of a secondary index in index order, as columnar batches, e.g. to be
+// converted to Arrow record batches for an analytics system.
+type IndexColumnSink interface {
+	// WriteBatch receives the next |batch| of entries of the index. An error stops the build.
+	WriteBatch(ctx context.Context, batch IndexColumnBatch) error
+}
+
+// IndexColumnBatch holds the indexed values of consecutive entries of a secondary index, one column per indexed column.
+type IndexColumnBatch struct {
+	// Names are the names of the indexed columns, in index column order.
+	Names []string
+	// Columns holds the values of each indexed column, as returned by the SQL engine, with one value for each entry of
+	// the batch. NULLs are nil.
+	Columns [][]interface{}
+}
+
+// IndexBuildHook is called once a secondary index is built and its data |rows| is stored in its table, e.g. to warm
+// caches by scanning the new index, or to notify downstream systems. |stats| are the statistics of the build, which
+// are zero for an index that was not built from the primary rows of its table.
+type IndexBuildHook func(ctx context.Context, indexName string, rows durable.Index, stats IndexBuildStats) error
+
+// IndexBuildLimiter throttles index builds. It is satisfied by *rate.Limiter from golang.org/x/time/rate, with one
+// token per primary row read.
+type IndexBuildLimiter interface {
+	// WaitN blocks until |n| more rows may be read, or returns an error if |ctx| is canceled first.
+	WaitN(ctx context.Context, n int) error
+}
+
+// IndexBuildTracer creates tracing spans for the steps of index builds. It is a minimal interface, so that callers
+// can adapt the tracer of any tracing library, such as OpenTelemetry, without this package depending on it.
+type IndexBuildTracer interface {
+	// StartSpan starts a span named |name| as a child of any span in |ctx|, returning a context holding the new span.
+	StartSpan(ctx context.Context, name string) (context.Context, IndexBuildSpan)
+}
+
+// IndexBuildSpan is a span started by an IndexBuildTracer.
+type IndexBuildSpan interface {
+	// SetAttribute records the attribute |key| of the span.
+	SetAttribute(key string, value interface{})
+	// End ends the span. |err| is the error that ended the step the span traces, if any.
+	End(err error)
+}
+
+// IndexBuildCheckpoint is the progress of a secondary index build, from which an interrupted build can be resumed.
+type IndexBuildCheckpoint struct {
+	// Primary is the hash of the primary index the build reads. A checkpoint is only resumed by a build of the same
+	// primary index.
+	Primary hash.Hash
+	// Definition is the hash of the definition of the index and of the BuildOptions that determine its data. A
+	// checkpoint is only resumed by a build of the same definition with the same options.
+	Definition hash.Hash
+	// Root is the address of the index data built so far, persisted in the chunk store of the build's ValueStore
+	// before the checkpoint is saved.
+	Root hash.Hash
+	// LastKey is the primary key of the last row processed, the build resumes with the rows after it.
+	LastKey val.Tuple
+	// RowsProcessed is the number of primary rows processed.
+	RowsProcessed uint64
+}
+
+// IndexBuildCheckpointStore durably stores the checkpoints of secondary index builds.
+type IndexBuildCheckpointStore interface {
+	// SaveCheckpoint stores |cp| as the latest checkpoint of building the index |indexName|.
+	SaveCheckpoint(ctx context.Context, indexName string, cp IndexBuildCheckpoint) error
+	// LoadCheckpoint returns the latest checkpoint of building the index |indexName|, and false if there is none.
+	LoadCheckpoint(ctx context.Context, indexName string) (IndexBuildCheckpoint, bool, error)
+	// ClearCheckpoint removes the checkpoints of building the index |indexName| once the build is complete.
+	ClearCheckpoint(ctx context.Context, indexName string) error
+}
@@ -0,0 +1,155 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/dolthub/dolt/go/store/val"
+)
+
+func TestValidateBuildOptions(t *testing.T) {
+	checkpoints := NewFileCheckpointStore(t.TempDir())
+	tests := []struct {
+		name   string
+		opts   BuildOptions
+		stored bool
+		err    string
+	}{
+		{
+			name:   "no options",
+			stored: true,
+		},
+		{
+			name:   "execution options",
+			opts:   BuildOptions{ExecutionOptions: ExecutionOptions{VerifyIndexRowCount: true, IndexHistogramBuckets: 8, IndexBuildCheckpoints: checkpoints, IndexBuildCheckpointRows: 100}},
+			stored: true,
+		},
+		{
+			name:   "field size limit",
+			opts:   BuildOptions{KeyEncodingOptions: KeyEncodingOptions{MaxIndexFieldSize: 16}},
+			stored: true,
+		},
+		{
+			name: "negative field size",
+			opts: BuildOptions{KeyEncodingOptions: KeyEncodingOptions{MaxIndexFieldSize: -1}},
+			err:  "invalid maximum field size -1",
+		},
+		{
+			name: "unknown field size policy",
+			opts: BuildOptions{KeyEncodingOptions: KeyEncodingOptions{OversizedIndexFieldPolicy: 42}},
+			err:  "invalid oversized field policy 42",
+		},
+		{
+			name: "geohash precision",
+			opts: BuildOptions{KeyEncodingOptions: KeyEncodingOptions{IndexGeohashPrecision: maxGeohashPrecision + 1}},
+			err:  "invalid geohash precision",
+		},
+		{
+			name: "encrypted geohash",
+			opts: BuildOptions{KeyEncodingOptions: KeyEncodingOptions{IndexGeohashPrecision: 6, IndexKeyEncryption: &IndexKeyEncryption{}}},
+			err:  "geohash indexes cannot be encrypted",
+		},
+		{
+			name: "value options",
+			opts: BuildOptions{ValueLayoutOptions: ValueLayoutOptions{MirrorPrimaryRowInIndex: true, IndexEntryChecksums: true}},
+			err:  "MirrorPrimaryRowInIndex and IndexEntryChecksums cannot be combined",
+		},
+		{
+			name: "expiry TTL",
+			opts: BuildOptions{ValueLayoutOptions: ValueLayoutOptions{IndexExpiryColumn: "c1", IndexExpiryTTL: -time.Second}},
+			err:  "invalid index expiry TTL",
+		},
+		{
+			name: "reverse interval",
+			opts: BuildOptions{KeyEncodingOptions: KeyEncodingOptions{ReverseIndexOrder: true}, ValueLayoutOptions: ValueLayoutOptions{IndexIntervalEnd: "c2"}},
+			err:  "interval indexes must be ascending",
+		},
+		{
+			name: "distinct with values",
+			opts: BuildOptions{KeyEncodingOptions: KeyEncodingOptions{DistinctIndex: true}, ValueLayoutOptions: ValueLayoutOptions{IndexExpiryColumn: "c1"}},
+			err:  "distinct indexes do not hold the values of rows",
+		},
+		{
+			name: "distinct with collisions",
+			opts: BuildOptions{KeyEncodingOptions: KeyEncodingOptions{DistinctIndex: true}, ExecutionOptions: ExecutionOptions{DetectIndexKeyCollisions: true}},
+			err:  "distinct indexes are shared by rows",
+		},
+		{
+			name: "checkpointed row locators",
+			opts: BuildOptions{ValueLayoutOptions: ValueLayoutOptions{RecordRowLocatorInIndex: true}, ExecutionOptions: ExecutionOptions{IndexBuildCheckpoints: checkpoints, IndexBuildCheckpointRows: 100}},
+			err:  "builds that record row locators cannot be checkpointed",
+		},
+		{
+			name: "checkpoint interval",
+			opts: BuildOptions{ExecutionOptions: ExecutionOptions{IndexBuildCheckpoints: checkpoints}},
+			err:  "invalid index build checkpoint interval of 0 rows",
+		},
+		{
+			name: "sorted run size",
+			opts: BuildOptions{ExecutionOptions: ExecutionOptions{IndexSortedRunDir: t.TempDir(), IndexSortedRunEntries: -1}},
+			err:  "invalid sorted run size of -1 entries",
+		},
+		{
+			name: "checkpointed sorted runs",
+			opts: BuildOptions{ExecutionOptions: ExecutionOptions{IndexSortedRunDir: t.TempDir(), IndexBuildCheckpoints: checkpoints, IndexBuildCheckpointRows: 100}},
+			err:  "checkpointed builds cannot spill sorted runs",
+		},
+		{
+			name: "unstored partial index",
+			opts: BuildOptions{ExecutionOptions: ExecutionOptions{IndexRowFilter: func(context.Context, val.Tuple, val.Tuple) (bool, error) { return true, nil }}},
+		},
+		{
+			name:   "stored partial index",
+			opts:   BuildOptions{ExecutionOptions: ExecutionOptions{IndexRowFilter: func(context.Context, val.Tuple, val.Tuple) (bool, error) { return true, nil }}},
+			stored: true,
+			err:    "partial indexes cannot be stored in a table",
+		},
+		{
+			name:   "stored equality-only index",
+			opts:   BuildOptions{KeyEncodingOptions: KeyEncodingOptions{EqualityOnlyIndex: true}},
+			stored: true,
+			err:    "equality-only indexes cannot be stored in a table",
+		},
+		{
+			name:   "stored truncated fields",
+			opts:   BuildOptions{KeyEncodingOptions: KeyEncodingOptions{MaxIndexFieldSize: 16, OversizedIndexFieldPolicy: OversizedIndexFieldTruncate}},
+			stored: true,
+			err:    "indexes with truncated fields cannot be stored in a table",
+		},
+		{
+			name:   "stored row locators",
+			opts:   BuildOptions{ValueLayoutOptions: ValueLayoutOptions{RecordRowLocatorInIndex: true}},
+			stored: true,
+			err:    "indexes with row locators cannot be stored in a table",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.opts.Validate(test.stored)
+			if test.err == "" {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), test.err)
+		})
+	}
+}
@@ -0,0 +1,130 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+
+	"github.com/dolthub/go-mysql-server/sql"
+
+	"github.com/dolthub/dolt/go/libraries/doltcore/schema"
+	"github.com/dolthub/dolt/go/store/prolly"
+	"github.com/dolthub/dolt/go/store/val"
+)
+
+// padSpaceKeys finds duplicate unique index entries whose indexed strings
+// differ only in trailing spaces, which collations with the PAD SPACE
+// attribute compare as equal. Index keys are ordered by their encoded bytes,
+// so such entries are not adjacent in the index, and are found by their
+// indexed values with trailing spaces trimmed instead. Encrypted columns are
+// compared as ciphertexts, so their trailing spaces are significant. A nil
+// *padSpaceKeys finds no duplicates.
+type padSpaceKeys struct {
+	// pad holds whether each indexed column has a PAD SPACE collation
+	pad  []bool
+	seen map[string]val.Tuple
+	buf  []byte
+}
+
+// newPadSpaceKeys returns the padSpaceKeys of the unique index |idx| whose
+// keys are encoded by |kd|, or nil if no indexed column of |idx| has a PAD
+// SPACE collation.
+func newPadSpaceKeys(sch schema.Schema, idx schema.Index, kd val.TupleDesc, encr *IndexKeyEncrypter) *padSpaceKeys {
+	pad := make([]bool, idx.Count())
+	padded := false
+	for i, tag := range idx.IndexedColumnTags() {
+		col, ok := sch.GetAllCols().GetByTag(tag)
+		if !ok || kd.Types[i].Enc != val.StringEnc || encr.encryptsField(i) {
+			continue
+		}
+		st, ok := col.TypeInfo.ToSqlType().(sql.StringType)
+		if ok && st.Collation().PadSpace() == sql.PadSpace {
+			pad[i], padded = true, true
+		}
+	}
+	if !padded {
+		return nil
+	}
+	return &padSpaceKeys{pad: pad, seen: make(map[string]val.Tuple)}
+}
+
+// seed adds the entries of |secondary|, the index data a resumed build starts
+// from.
+func (p *padSpaceKeys) seed(ctx context.Context, secondary prolly.Map) error {
+	if p == nil || secondary.Count() == 0 {
+		return nil
+	}
+	iter, err := secondary.IterAll(ctx)
+	if err != nil {
+		return err
+	}
+	for {
+		k, _, err := iter.Next(ctx)
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		p.add(k)
+	}
+}
+
+// find returns the first entry added whose indexed values equal those of the
+// index key |k|, ignoring trailing spaces.
+func (p *padSpaceKeys) find(k val.Tuple) (val.Tuple, bool) {
+	if p == nil {
+		return nil, false
+	}
+	existing, ok := p.seen[string(p.key(k))]
+	return existing, ok
+}
+
+// add adds the index key |k|, unless it has a NULL indexed value or an entry
+// with equal values was added before.
+func (p *padSpaceKeys) add(k val.Tuple) {
+	if p == nil {
+		return
+	}
+	for i := range p.pad {
+		if k.FieldIsNull(i) {
+			return
+		}
+	}
+	key := p.key(k)
+	if _, ok := p.seen[string(key)]; !ok {
+		p.seen[string(key)] = k
+	}
+}
+
+// key returns the indexed values of |k| with the trailing spaces of PAD SPACE
+// strings trimmed. Each value is preceded by its length, so that different
+// splits of the same bytes are different keys.
+func (p *padSpaceKeys) key(k val.Tuple) []byte {
+	p.buf = p.buf[:0]
+	var n [binary.MaxVarintLen64]byte
+	for i, pad := range p.pad {
+		f := k.GetField(i)
+		if pad {
+			// strings are null terminated
+			f = bytes.TrimRight(f[:len(f)-1], " ")
+		}
+		p.buf = append(p.buf, n[:binary.PutUvarint(n[:], uint64(len(f)))]...)
+		p.buf = append(p.buf, f...)
+	}
+	return p.buf
+}
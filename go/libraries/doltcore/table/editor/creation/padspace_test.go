@@ -0,0 +1,102 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dolthub/go-mysql-server/sql"
+	"github.com/dolthub/vitess/go/sqltypes"
+	"github.com/stretchr/testify/require"
+
+	"github.com/dolthub/dolt/go/libraries/doltcore/schema"
+	"github.com/dolthub/dolt/go/libraries/doltcore/schema/typeinfo"
+	"github.com/dolthub/dolt/go/store/prolly/shim"
+	"github.com/dolthub/dolt/go/store/types"
+	"github.com/dolthub/dolt/go/store/val"
+)
+
+// newCollatedTestSchema returns a schema of (pk int primary key, c1 int, c2 varchar(20)) where c2 has |collation|.
+func newCollatedTestSchema(t *testing.T, collation sql.Collation) schema.Schema {
+	ti, err := typeinfo.FromSqlType(sql.MustCreateString(sqltypes.VarChar, 20, collation))
+	require.NoError(t, err)
+	c2, err := schema.NewColumnWithTypeInfo("c2", c2Tag, ti, false, "", false, "")
+	require.NoError(t, err)
+	sch, err := schema.SchemaFromCols(schema.NewColCollection(
+		schema.NewColumn("pk", pkTag, types.IntKind, true, schema.NotNullConstraint{}),
+		schema.NewColumn("c1", c1Tag, types.IntKind, false),
+		c2,
+	))
+	require.NoError(t, err)
+	return sch
+}
+
+func TestUniqueIndexPadSpace(t *testing.T) {
+	ctx := context.Background()
+	vrw := newTestVRW()
+	rows := [][]interface{}{
+		{1, 1, "a"},
+		{2, 1, "a\x01"},
+		{3, 1, "a  "},
+		{4, 2, "a "},
+		{5, 1, " a"},
+		{6, 1, "b "},
+	}
+
+	tests := []struct {
+		name      string
+		collation sql.Collation
+		// dups are the primary keys of the existing and new entries of each duplicate
+		dups [][2]int64
+	}{
+		{
+			name:      "pad space",
+			collation: sql.Collation_utf8mb4_bin,
+			dups:      [][2]int64{{1, 3}},
+		},
+		{
+			name:      "no pad",
+			collation: sql.Collation_utf8mb4_0900_bin,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sch := newCollatedTestSchema(t, test.collation)
+			primary := newTestPrimary(t, ctx, vrw, sch, rows)
+			idx, err := sch.Indexes().AddIndexByColNames("c1_c2", []string{"c1", "c2"}, schema.IndexProperties{IsUnique: true})
+			require.NoError(t, err)
+			kd := shim.KeyDescriptorFromSchema(idx.Schema())
+
+			var dups [][2]int64
+			_, err = BuildUniqueProllyIndex(ctx, vrw, sch, idx, primary, BuildOptions{}, func(ctx context.Context, existingKey, newKey val.Tuple) error {
+				existing, _ := kd.GetInt64(2, existingKey)
+				dup, _ := kd.GetInt64(2, newKey)
+				dups = append(dups, [2]int64{existing, dup})
+				return nil
+			})
+			require.NoError(t, err)
+			require.Equal(t, test.dups, dups)
+
+			_, err = BuildSecondaryProllyIndex(ctx, vrw, sch, idx, primary, BuildOptions{})
+			if test.dups == nil {
+				require.NoError(t, err)
+			} else {
+				require.True(t, sql.ErrDuplicateEntry.Is(err))
+			}
+		})
+	}
+}
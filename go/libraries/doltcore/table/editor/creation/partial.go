@@ -0,0 +1,100 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dolthub/dolt/go/libraries/doltcore/doltdb/durable"
+	"github.com/dolthub/dolt/go/libraries/doltcore/schema"
+	"github.com/dolthub/dolt/go/store/prolly"
+	"github.com/dolthub/dolt/go/store/types"
+	"github.com/dolthub/dolt/go/store/val"
+)
+
+// NullColumnFilter returns an IndexRowFilter that accepts rows whose column |colName| is NULL if |isNull| is
+// true, or rows whose column |colName| is non-NULL if |isNull| is false.
+func NullColumnFilter(sch schema.Schema, colName string, isNull bool) (IndexRowFilter, error) {
+	col, ok := sch.GetAllCols().GetByNameCaseInsensitive(colName)
+	if !ok {
+		return nil, fmt.Errorf("column `%s` does not exist for the table", colName)
+	}
+
+	if col.IsPartOfPK {
+		// primary key columns are never NULL
+		return func(ctx context.Context, k, v val.Tuple) (bool, error) {
+			return !isNull, nil
+		}, nil
+	}
+
+	i := sch.GetNonPKCols().TagToIdx[col.Tag]
+	return func(ctx context.Context, k, v val.Tuple) (bool, error) {
+		return v.FieldIsNull(i) == isNull, nil
+	}, nil
+}
+
+// SoftDeleteFilter returns an IndexRowFilter for tables that mark deleted rows by setting |colName| (e.g.
+// deleted_at). Only live rows, where |colName| is NULL, are accepted.
+func SoftDeleteFilter(sch schema.Schema, colName string) (IndexRowFilter, error) {
+	return NullColumnFilter(sch, colName, true)
+}
+
+// BuildPartialIndexWithExclusions builds the partial secondary index |idx| over |primary|, as
+// BuildSecondaryProllyIndex does with |opts.IndexRowFilter|, and additionally returns the primary keys of the rows
+// rejected by the filter as a map with empty values.
+//
+// This is an alternative to a skip-only partial index. Keeping the excluded keys lets callers answer which rows are
+// not in the index, and re-evaluate just those rows when the predicate changes, at the cost of writing an entry for
+// every row of the table. The excluded keys are kept apart from the index rather than as sentinel entries within it,
+// as index keys have no spare field to hold a sentinel and readers of the index would otherwise have to filter it.
+func BuildPartialIndexWithExclusions(
+	ctx context.Context,
+	vrw types.ValueReadWriter,
+	sch schema.Schema,
+	idx schema.Index,
+	primary prolly.Map,
+	opts BuildOptions,
+) (included, excluded durable.Index, err error) {
+	if opts.IndexRowFilter == nil {
+		return nil, nil, fmt.Errorf("index `%s`: an IndexRowFilter is required to build a partial index", idx.Name())
+	}
+
+	kd, _ := primary.Descriptors()
+	empty, err := prolly.NewMapFromTuples(ctx, primary.NodeStore(), kd, val.NewTupleDescriptor())
+	if err != nil {
+		return nil, nil, err
+	}
+	mut := empty.Mutate()
+
+	filter := opts.IndexRowFilter
+	opts.IndexRowFilter = func(ctx context.Context, k, v val.Tuple) (bool, error) {
+		ok, err := filter(ctx, k, v)
+		if err != nil || ok {
+			return ok, err
+		}
+		return false, mut.Put(ctx, k, val.EmptyTuple)
+	}
+
+	included, err = BuildSecondaryProllyIndex(ctx, vrw, sch, idx, primary, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	m, err := mut.Map(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	return included, durable.IndexFromProllyMap(m), nil
+}
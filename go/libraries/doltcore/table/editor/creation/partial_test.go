@@ -0,0 +1,188 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dolthub/go-mysql-server/sql"
+	"github.com/stretchr/testify/require"
+
+	"github.com/dolthub/dolt/go/libraries/doltcore/doltdb/durable"
+	"github.com/dolthub/dolt/go/libraries/doltcore/schema"
+	"github.com/dolthub/dolt/go/store/prolly/shim"
+	"github.com/dolthub/dolt/go/store/types"
+	"github.com/dolthub/dolt/go/store/val"
+)
+
+// newSoftDeleteSchema returns a schema of (pk int primary key, email varchar, deleted_at int).
+func newSoftDeleteSchema(t *testing.T) schema.Schema {
+	sch, err := schema.SchemaFromCols(schema.NewColCollection(
+		schema.NewColumn("pk", pkTag, types.IntKind, true, schema.NotNullConstraint{}),
+		schema.NewColumn("email", c1Tag, types.StringKind, false),
+		schema.NewColumn("deleted_at", c2Tag, types.IntKind, false),
+	))
+	require.NoError(t, err)
+	return sch
+}
+
+func TestSoftDeleteFilter(t *testing.T) {
+	ctx := context.Background()
+	vrw := newTestVRW()
+	sch := newSoftDeleteSchema(t)
+	rows := [][]interface{}{
+		{1, "a@example.com", nil},
+		{2, "b@example.com", 100},
+		{3, "c@example.com", nil},
+		{4, "a@example.com", 200},
+	}
+
+	filter, err := SoftDeleteFilter(sch, "DELETED_AT")
+	require.NoError(t, err)
+	idx, err := sch.Indexes().AddIndexByColNames("live_email", []string{"email"}, schema.IndexProperties{IsUserDefined: true})
+	require.NoError(t, err)
+	built, err := BuildSecondaryProllyIndex(ctx, vrw, sch, idx, newTestPrimary(t, ctx, vrw, sch, rows), BuildOptions{
+		ExecutionOptions: ExecutionOptions{
+			IndexRowFilter: filter,
+		},
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{"[a@example.com,1]", "[c@example.com,3]"}, collectKeys(t, ctx, durable.ProllyMapFromIndex(built)))
+
+	// the filter is not stored with the index, so DML would maintain it as an
+	// ordinary index
+	_, err = CreateIndex(ctx, newTestTable(t, ctx, vrw, newSoftDeleteSchema(t), rows), "live_email", []string{"email"}, false, true, "", BuildOptions{
+		ExecutionOptions: ExecutionOptions{
+			IndexRowFilter: filter,
+		},
+	})
+	require.Error(t, err)
+
+	_, err = SoftDeleteFilter(sch, "missing")
+	require.Error(t, err)
+}
+
+func TestNullColumnFilter(t *testing.T) {
+	ctx := context.Background()
+	vrw := newTestVRW()
+	sch := newSoftDeleteSchema(t)
+	primary := newTestPrimary(t, ctx, vrw, sch, [][]interface{}{
+		{1, "a@example.com", nil},
+		{2, "b@example.com", 100},
+	})
+
+	filter, err := NullColumnFilter(sch, "deleted_at", false)
+	require.NoError(t, err)
+	idx, err := sch.Indexes().AddIndexByColNames("deleted_email", []string{"email"}, schema.IndexProperties{IsUserDefined: true})
+	require.NoError(t, err)
+	built, err := BuildSecondaryProllyIndex(ctx, vrw, sch, idx, primary, BuildOptions{
+		ExecutionOptions: ExecutionOptions{
+			IndexRowFilter: filter,
+		},
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{"[b@example.com,2]"}, collectKeys(t, ctx, durable.ProllyMapFromIndex(built)))
+
+	// primary key columns are never NULL, so no row is indexed
+	filter, err = NullColumnFilter(sch, "pk", true)
+	require.NoError(t, err)
+	uniq, err := sch.Indexes().AddIndexByColNames("unique_email", []string{"email"}, schema.IndexProperties{IsUnique: true, IsUserDefined: true})
+	require.NoError(t, err)
+	built, err = BuildSecondaryProllyIndex(ctx, vrw, sch, uniq, primary, BuildOptions{
+		ExecutionOptions: ExecutionOptions{
+			IndexRowFilter: filter,
+		},
+	})
+	require.NoError(t, err)
+	require.Empty(t, collectKeys(t, ctx, durable.ProllyMapFromIndex(built)))
+}
+
+func TestPartialUniqueIndex(t *testing.T) {
+	ctx := context.Background()
+	vrw := newTestVRW()
+	sch := newSoftDeleteSchema(t)
+	filter, err := SoftDeleteFilter(sch, "deleted_at")
+	require.NoError(t, err)
+	opts := BuildOptions{ExecutionOptions: ExecutionOptions{IndexRowFilter: filter}}
+	uniq, err := sch.Indexes().AddIndexByColNames("live_email", []string{"email"}, schema.IndexProperties{IsUnique: true})
+	require.NoError(t, err)
+
+	// deleted rows may share an email with each other and with a live row
+	primary := newTestPrimary(t, ctx, vrw, sch, [][]interface{}{
+		{1, "a@example.com", nil},
+		{2, "a@example.com", 100},
+		{3, "a@example.com", 200},
+		{4, "b@example.com", nil},
+		{5, "c@example.com", 300},
+		{6, "c@example.com", 400},
+	})
+	built, err := BuildSecondaryProllyIndex(ctx, vrw, sch, uniq, primary, opts)
+	require.NoError(t, err)
+	require.Equal(t, []string{"[a@example.com,1]", "[b@example.com,4]"}, collectKeys(t, ctx, durable.ProllyMapFromIndex(built)))
+
+	// live rows must be unique
+	primary = newTestPrimary(t, ctx, vrw, sch, [][]interface{}{
+		{1, "a@example.com", nil},
+		{2, "a@example.com", 100},
+		{3, "a@example.com", nil},
+	})
+	_, err = BuildSecondaryProllyIndex(ctx, vrw, sch, uniq, primary, opts)
+	require.Error(t, err)
+	require.True(t, sql.ErrDuplicateEntry.Is(err))
+
+	// duplicate callbacks only receive live rows
+	primary = newTestPrimary(t, ctx, vrw, sch, [][]interface{}{
+		{1, "a@example.com", 100},
+		{2, "a@example.com", nil},
+		{3, "a@example.com", nil},
+		{4, "a@example.com", 200},
+	})
+	kd := shim.KeyDescriptorFromSchema(uniq.Schema())
+	var dups [][2]int64
+	_, err = BuildUniqueProllyIndex(ctx, vrw, sch, uniq, primary, opts, func(ctx context.Context, existingKey, newKey val.Tuple) error {
+		existing, _ := kd.GetInt64(1, existingKey)
+		dup, _ := kd.GetInt64(1, newKey)
+		dups = append(dups, [2]int64{existing, dup})
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, [][2]int64{{2, 3}}, dups)
+}
+
+func TestBuildPartialIndexWithExclusions(t *testing.T) {
+	ctx := context.Background()
+	vrw := newTestVRW()
+	sch := newSoftDeleteSchema(t)
+	primary := newTestPrimary(t, ctx, vrw, sch, [][]interface{}{
+		{1, "a@example.com", nil},
+		{2, "b@example.com", 100},
+		{3, "c@example.com", nil},
+		{4, "a@example.com", 200},
+	})
+	idx, err := schema.NewIndexCollection(sch.GetAllCols(), sch.GetPKCols()).AddIndexByColNames(
+		"live_email", []string{"email"}, schema.IndexProperties{IsUserDefined: true})
+	require.NoError(t, err)
+
+	filter, err := SoftDeleteFilter(sch, "deleted_at")
+	require.NoError(t, err)
+	included, excluded, err := BuildPartialIndexWithExclusions(ctx, vrw, sch, idx, primary, BuildOptions{ExecutionOptions: ExecutionOptions{IndexRowFilter: filter}})
+	require.NoError(t, err)
+	require.Equal(t, []string{"[a@example.com,1]", "[c@example.com,3]"}, collectKeys(t, ctx, durable.ProllyMapFromIndex(included)))
+	require.Equal(t, []string{"[2]", "[4]"}, collectKeys(t, ctx, durable.ProllyMapFromIndex(excluded)))
+
+	_, _, err = BuildPartialIndexWithExclusions(ctx, vrw, sch, idx, primary, BuildOptions{})
+	require.Error(t, err)
+}
@@ -0,0 +1,370 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"context"
+	"io"
+	"sync"
+
+	"golang.org/x/sync/errgroup"
+
+	"github.com/dolthub/dolt/go/libraries/doltcore/doltdb/durable"
+	"github.com/dolthub/dolt/go/libraries/doltcore/schema"
+	"github.com/dolthub/dolt/go/store/prolly"
+	"github.com/dolthub/dolt/go/store/skip"
+	"github.com/dolthub/dolt/go/store/types"
+	"github.com/dolthub/dolt/go/store/val"
+)
+
+const (
+	// uniqueBatchSize is the number of entries passed between the stages of
+	// a pipelined unique index build at a time.
+	uniqueBatchSize = 256
+	// uniqueSnapshotEntries is the number of entries inserted between the
+	// snapshots of a pipelined unique index build.
+	uniqueSnapshotEntries = 64 * 1024
+)
+
+// uniquePipeline configures a pipelined unique index build.
+type uniquePipeline struct {
+	// workers is the number of goroutines checking entries for duplicates.
+	workers int
+	// batchSize is the number of entries in each batch.
+	batchSize int
+	// snapshotEvery is the number of entries inserted between snapshots.
+	snapshotEvery uint64
+}
+
+// canPipelineUniqueBuild returns whether a unique index build with |opts|
+// and the checkpointer |ckpt| can be pipelined.
+func canPipelineUniqueBuild(opts BuildOptions, ckpt *buildCheckpointer) bool {
+	return opts.UniqueIndexCheckWorkers > 0 && ckpt == nil && !opts.FlushPartialIndexOnCancel
+}
+
+// uniqueSnapshot is the index data of a pipelined unique index build after
+// its first |covers| entries were inserted.
+type uniqueSnapshot struct {
+	m      prolly.Map
+	covers uint64
+}
+
+// uniqueEntry is an entry of a pipelined unique index build.
+type uniqueEntry struct {
+	// k and v are the primary row of the entry.
+	k, v       val.Tuple
+	key, value val.Tuple
+	// prefix holds the indexed values of the entry, nil if any is NULL.
+	prefix val.Tuple
+	// existing is the first key with the prefix of the entry in the
+	// snapshot of its batch, if any.
+	existing val.Tuple
+}
+
+// uniqueBatch is a batch of consecutive entries of a pipelined unique index
+// build, which are checked for duplicates against the snapshot |snap|.
+type uniqueBatch struct {
+	seq     uint64
+	snap    *uniqueSnapshot
+	entries []uniqueEntry
+}
+
+// pendingKeys are the keys inserted by a pipelined unique index build since
+// the snapshot that covers the first |from| entries. |keys| maps each prefix
+// to the least such key with that prefix.
+type pendingKeys struct {
+	from uint64
+	keys *skip.List
+}
+
+// buildUniqueProllyIndexPipelined builds a unique index like
+// buildUniqueProllyIndex, in three stages that run concurrently: one goroutine
+// reads and encodes the primary rows, |pl.workers| goroutines look up the
+// prefixes of the entries in a snapshot of the index built so far, and one
+// goroutine inserts the entries into the index, in scan order.
+//
+// An entry duplicates an earlier one if the snapshot has its prefix, or if an
+// entry inserted after the snapshot was taken has it. The inserting stage
+// keeps the keys it inserted since the last snapshot in memory, and completes
+// the lookups of the checking stage with them, so that duplicates are found,
+// and passed to |cb|, exactly as a serial build finds them. Snapshots are
+// taken every |pl.snapshotEvery| entries, which bounds the keys kept.
+func buildUniqueProllyIndexPipelined(ctx context.Context, vrw types.ValueReadWriter, sch schema.Schema, idx schema.Index, iter prolly.MapIter, opts BuildOptions, cb DupEntryCb, pl uniquePipeline) (durable.Index, error) {
+	secondary, err := newSecondaryMap(ctx, vrw, sch, idx, opts)
+	if err != nil {
+		return nil, err
+	}
+	kd, _ := secondary.Descriptors()
+	enc, err := newUniqueKeyEncoder(sch, idx, kd, opts)
+	if err != nil {
+		return nil, err
+	}
+	pads := newPadSpaceKeys(sch, idx, kd, enc.encr)
+	pkd := enc.pkd
+	p := tuplePool(secondary, opts)
+	// the reading stage counts rows and the inserting stage counts entries
+	mon := newBuildMonitor(idx, opts)
+	docs, err := newDocumentFeeder(sch, idx, secondary.NodeStore(), opts)
+	if err != nil {
+		return nil, err
+	}
+	spill, err := newRunSpiller(idx, kd, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer spill.abort()
+	exp, err := newExpiryValues(sch, idx, opts)
+	if err != nil {
+		return nil, err
+	}
+	sums, err := newEntryChecksums(idx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var mu sync.Mutex
+	latest := &uniqueSnapshot{m: secondary}
+	snapshot := func() *uniqueSnapshot {
+		mu.Lock()
+		defer mu.Unlock()
+		return latest
+	}
+
+	eg, ectx := errgroup.WithContext(ctx)
+	encoded := make(chan *uniqueBatch, pl.workers)
+	checked := make(chan *uniqueBatch, pl.workers)
+
+	// read and encode the primary rows. Snapshots are assigned in batch
+	// order, so later batches never see older snapshots.
+	eg.Go(func() error {
+		defer close(encoded)
+		b := &uniqueBatch{}
+		send := func() error {
+			b.snap = snapshot()
+			select {
+			case encoded <- b:
+			case <-ectx.Done():
+				return ectx.Err()
+			}
+			b = &uniqueBatch{seq: b.seq + 1}
+			return nil
+		}
+		for {
+			k, v, err := iter.Next(ectx)
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return ioErr(idx, pkd, nil, err)
+			}
+			if err = mon.row(ectx); err != nil {
+				return err
+			}
+
+			if opts.IndexRowFilter != nil {
+				ok, err := opts.IndexRowFilter(ectx, k, v)
+				if err != nil {
+					return err
+				}
+				if !ok {
+					continue
+				}
+			}
+
+			nullPrefix, err := enc.put(k, v, mon)
+			if err != nil {
+				if skipsOversizedRow(err, opts) {
+					enc.kb.Recycle()
+					mon.oversized(pkd, k)
+					continue
+				}
+				return err
+			}
+			e := uniqueEntry{k: k, v: v, key: enc.kb.Build(p)}
+			if e.value, err = indexValue(v, iter, p, opts); err != nil {
+				return err
+			}
+			if exp != nil {
+				e.value = exp.value(k, v, p)
+			}
+			if sums != nil {
+				e.value = sums.value(e.key, p)
+			}
+			if err = mon.value(e.key); err != nil {
+				return err
+			}
+			// like MySQL, an entry with a NULL in any unique column never conflicts
+			if !nullPrefix {
+				e.prefix = enc.prefixKB.Build(p)
+			}
+			b.entries = append(b.entries, e)
+			if len(b.entries) == pl.batchSize {
+				if err = send(); err != nil {
+					return err
+				}
+			}
+		}
+		if len(b.entries) == 0 {
+			return nil
+		}
+		return send()
+	})
+
+	// look up the prefixes of the entries in their snapshots
+	var workers sync.WaitGroup
+	for i := 0; i < pl.workers; i++ {
+		workers.Add(1)
+		eg.Go(func() error {
+			defer workers.Done()
+			for b := range encoded {
+				for i := range b.entries {
+					e := &b.entries[i]
+					if e.prefix == nil {
+						continue
+					}
+					existing, err := firstWithPrefix(ectx, b.snap.m, enc.prefixKD, e.prefix)
+					if err != nil {
+						return ioErr(idx, pkd, e.k, err)
+					}
+					e.existing = existing
+				}
+				select {
+				case checked <- b:
+				case <-ectx.Done():
+					return ectx.Err()
+				}
+			}
+			return nil
+		})
+	}
+	eg.Go(func() error {
+		workers.Wait()
+		close(checked)
+		return nil
+	})
+
+	// insert the entries in scan order
+	cmp := func(l, r []byte) int {
+		return enc.prefixKD.Compare(l, r)
+	}
+	pending := []pendingKeys{{keys: skip.NewSkipList(cmp)}}
+	eg.Go(func() error {
+		mut := secondary.Mutate()
+		var inserted uint64
+		insert := func(b *uniqueBatch) error {
+			// drop the keys of the snapshot of |b|
+			for len(pending) > 1 && pending[1].from <= b.snap.covers {
+				pending = pending[1:]
+			}
+			for _, e := range b.entries {
+				if e.prefix != nil {
+					existing := e.existing
+					for _, pk := range pending {
+						if k, ok := pk.keys.Get(e.prefix); ok && (existing == nil || kd.Compare(k, existing) < 0) {
+							existing = k
+						}
+					}
+					found := existing != nil
+					if !found {
+						existing, found = pads.find(e.key)
+					}
+					pads.add(e.key)
+					if found {
+						// We found a duplicate entry so delegate behavior to callback.
+						dctx, span := startBuildSpan(ectx, opts, idx, "index.duplicate")
+						err := cb(dctx, existing, e.key)
+						if err = span.end(nil, err); err != nil {
+							return err
+						}
+					}
+					last := pending[len(pending)-1].keys
+					if k, ok := last.Get(e.prefix); !ok || kd.Compare(e.key, k) < 0 {
+						last.Put(e.prefix, e.key)
+					}
+				}
+
+				if err := mut.Put(ectx, e.key, e.value); err != nil {
+					return ioErr(idx, pkd, e.k, err)
+				}
+				if opts.IndexEntryWriter != nil {
+					if err := WriteIndexEntry(opts.IndexEntryWriter, e.key, e.value); err != nil {
+						return ioErr(idx, pkd, e.k, err)
+					}
+				}
+				if err := spill.put(e.key, e.value); err != nil {
+					return ioErr(idx, pkd, e.k, err)
+				}
+				if err := docs.feed(ectx, e.k, e.v); err != nil {
+					return err
+				}
+				mon.indexed()
+
+				inserted++
+				if inserted%pl.snapshotEvery == 0 {
+					m, err := mut.Map(ectx)
+					if err != nil {
+						return ioErr(idx, pkd, e.k, err)
+					}
+					mu.Lock()
+					latest = &uniqueSnapshot{m: m, covers: inserted}
+					mu.Unlock()
+					mut = m.Mutate()
+					pending = append(pending, pendingKeys{from: inserted, keys: skip.NewSkipList(cmp)})
+				}
+			}
+			return nil
+		}
+
+		// batches are checked out of order, and inserted in order
+		waiting := make(map[uint64]*uniqueBatch)
+		var next uint64
+		for b := range checked {
+			if ectx.Err() != nil {
+				return ectx.Err()
+			}
+			waiting[b.seq] = b
+			for b, ok := waiting[next]; ok; b, ok = waiting[next] {
+				delete(waiting, next)
+				next++
+				if err := insert(b); err != nil {
+					return err
+				}
+			}
+		}
+
+		fctx, span := startBuildSpan(ectx, opts, idx, "index.flush")
+		m, err := mut.Map(fctx)
+		span.end(nil, err)
+		if err != nil {
+			return ioErr(idx, pkd, nil, err)
+		}
+		secondary = m
+		return nil
+	})
+
+	if err = eg.Wait(); err != nil {
+		return nil, err
+	}
+	if err = spill.finish(); err != nil {
+		return nil, ioErr(idx, pkd, nil, err)
+	}
+	if err = mon.finish(ctx, secondary); err != nil {
+		return nil, err
+	}
+	if err = emitIndexColumns(ctx, idx, secondary, opts); err != nil {
+		return nil, err
+	}
+	return durable.IndexFromProllyMap(secondary), nil
+}
@@ -0,0 +1,103 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/dolthub/go-mysql-server/sql"
+	"github.com/stretchr/testify/require"
+
+	"github.com/dolthub/dolt/go/libraries/doltcore/schema"
+	"github.com/dolthub/dolt/go/store/prolly/shim"
+	"github.com/dolthub/dolt/go/store/val"
+)
+
+func TestPipelinedUniqueIndex(t *testing.T) {
+	ctx := context.Background()
+	vrw := newTestVRW()
+	sch := newTestSchema(t)
+	// most rows duplicate an earlier one, and rows with a NULL never do
+	var rows [][]interface{}
+	for i := 0; i < 5000; i++ {
+		var c1 interface{} = (i * 7919) % 400
+		if i%9 == 0 {
+			c1 = nil
+		}
+		rows = append(rows, []interface{}{i, c1, "row"})
+	}
+	primary := newTestPrimary(t, ctx, vrw, sch, rows)
+	coll := schema.NewIndexCollection(sch.GetAllCols(), sch.GetPKCols())
+	idx, err := coll.AddIndexByColNames("c1_uniq", []string{"c1"}, schema.IndexProperties{IsUnique: true})
+	require.NoError(t, err)
+	kd := shim.KeyDescriptorFromSchema(idx.Schema())
+
+	// dups collects the duplicates passed to the callback
+	dups := func(dups *[]string) DupEntryCb {
+		return func(ctx context.Context, existingKey, newKey val.Tuple) error {
+			*dups = append(*dups, fmt.Sprintf("%s %s", kd.Format(existingKey), kd.Format(newKey)))
+			return nil
+		}
+	}
+	var serialDups []string
+	serial, err := BuildUniqueProllyIndex(ctx, vrw, sch, idx, primary, BuildOptions{}, dups(&serialDups))
+	require.NoError(t, err)
+	require.Len(t, serialDups, 5000-556-400)
+
+	for _, pl := range []uniquePipeline{
+		{workers: 1, batchSize: 1, snapshotEvery: 1},
+		{workers: 1, batchSize: 7, snapshotEvery: 100},
+		{workers: 4, batchSize: 16, snapshotEvery: 50},
+		{workers: 3, batchSize: uniqueBatchSize, snapshotEvery: uniqueSnapshotEntries},
+	} {
+		t.Run(fmt.Sprintf("%d workers, batches of %d, snapshots every %d", pl.workers, pl.batchSize, pl.snapshotEvery), func(t *testing.T) {
+			iter, err := primary.IterAll(ctx)
+			require.NoError(t, err)
+			var pipelinedDups []string
+			pipelined, err := buildUniqueProllyIndexPipelined(ctx, vrw, sch, idx, iter, BuildOptions{}, dups(&pipelinedDups), pl)
+			require.NoError(t, err)
+			requireSameIndex(t, serial, pipelined)
+			require.Equal(t, serialDups, pipelinedDups)
+		})
+	}
+
+	// the first duplicate stops the build
+	stop := errors.New("stop")
+	var calls int
+	_, err = BuildUniqueProllyIndex(ctx, vrw, sch, idx, primary, BuildOptions{ExecutionOptions: ExecutionOptions{UniqueIndexCheckWorkers: 2}}, func(ctx context.Context, existingKey, newKey val.Tuple) error {
+		calls++
+		return stop
+	})
+	require.ErrorIs(t, err, stop)
+	require.Equal(t, 1, calls)
+
+	var stats IndexBuildStats
+	_, err = BuildSecondaryProllyIndex(ctx, vrw, sch, idx, primary, BuildOptions{ExecutionOptions: ExecutionOptions{UniqueIndexCheckWorkers: 2, IndexBuildStats: &stats}})
+	require.True(t, sql.ErrDuplicateEntry.Is(err))
+
+	// without duplicates, the pipelined build gives the serial build's index
+	c2Idx, err := coll.AddIndexByColNames("pk_c1_uniq", []string{"pk", "c1"}, schema.IndexProperties{IsUnique: true})
+	require.NoError(t, err)
+	expected, err := BuildSecondaryProllyIndex(ctx, vrw, sch, c2Idx, primary, BuildOptions{})
+	require.NoError(t, err)
+	actual, err := BuildSecondaryProllyIndex(ctx, vrw, sch, c2Idx, primary, BuildOptions{ExecutionOptions: ExecutionOptions{UniqueIndexCheckWorkers: 4, IndexBuildStats: &stats}})
+	require.NoError(t, err)
+	requireSameIndex(t, expected, actual)
+	require.Equal(t, uint64(len(rows)), stats.RowsScanned)
+	require.Equal(t, uint64(len(rows)), stats.RowsIndexed)
+}
@@ -0,0 +1,162 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/dolthub/dolt/go/libraries/doltcore/doltdb/durable"
+	"github.com/dolthub/dolt/go/libraries/doltcore/schema"
+	"github.com/dolthub/dolt/go/store/prolly"
+	"github.com/dolthub/dolt/go/store/types"
+	"github.com/dolthub/dolt/go/store/val"
+)
+
+// BuildPrefixSharingIndexes builds the non-unique secondary indexes |idxs| in a
+// single scan of |primary|. The indexed columns of each index must be a prefix
+// of the indexed columns of the longest index, e.g. (a), (a, b) and (a, b, c).
+// The fields of each row are read once and shared by every index. Index data is
+// returned in the same order as |idxs|. If |opts| has IndexBuildStats, they
+// describe the longest index.
+func BuildPrefixSharingIndexes(ctx context.Context, vrw types.ValueReadWriter, sch schema.Schema, idxs []schema.Index, primary prolly.Map, opts BuildOptions) ([]durable.Index, error) {
+	if len(idxs) == 0 {
+		return nil, nil
+	}
+	if opts.IndexKeyEncryption != nil {
+		return nil, fmt.Errorf("encrypted indexes cannot be built with shared prefixes")
+	}
+	longest, err := checkPrefixSharing(idxs)
+	if err != nil {
+		return nil, err
+	}
+
+	pkLen := sch.GetPKCols().Size()
+	muts := make([]prolly.MutableMap, len(idxs))
+	keyBlds := make([]*val.TupleBuilder, len(idxs))
+	keyMaps := make([]val.OrdinalMapping, len(idxs))
+	for i, idx := range idxs {
+		empty, err := durable.NewEmptyIndex(ctx, vrw, idx.Schema())
+		if err != nil {
+			return nil, err
+		}
+		secondary := durable.ProllyMapFromIndex(empty)
+		kd, _ := secondary.Descriptors()
+		muts[i] = secondary.Mutate()
+		keyBlds[i] = val.NewTupleBuilder(kd)
+		keyMaps[i], err = GetIndexKeyMapping(sch, idx)
+		if err != nil {
+			return nil, err
+		}
+	}
+	p := tuplePool(primary, opts)
+	mon := newBuildMonitor(idxs[longest], opts)
+
+	// fields holds the fields of the current row, indexed by row ordinal
+	fields := make([][]byte, sch.GetAllCols().Size())
+
+	iter, err := primary.IterAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		k, v, err := iter.Next(ctx)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err = mon.row(ctx); err != nil {
+			return nil, err
+		}
+
+		if opts.IndexRowFilter != nil {
+			ok, err := opts.IndexRowFilter(ctx, k, v)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				continue
+			}
+		}
+
+		for i := range fields {
+			if i < pkLen {
+				fields[i] = k.GetField(i)
+			} else {
+				fields[i] = v.GetField(i - pkLen)
+			}
+		}
+
+		for i := range idxs {
+			for to := range keyMaps[i] {
+				f := fields[keyMaps[i].MapOrdinal(to)]
+				keyBlds[i].PutRaw(to, f)
+				if i == longest && f == nil {
+					mon.null(to)
+				}
+			}
+			if err = muts[i].Put(ctx, keyBlds[i].Build(p), val.EmptyTuple); err != nil {
+				return nil, err
+			}
+		}
+		mon.indexed()
+	}
+
+	indexes := make([]durable.Index, len(idxs))
+	for i := range muts {
+		m, err := muts[i].Map(ctx)
+		if err != nil {
+			return nil, err
+		}
+		indexes[i] = durable.IndexFromProllyMap(m)
+		if i == longest {
+			if err = mon.finish(ctx, m); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return indexes, nil
+}
+
+// checkPrefixSharing returns the position of the longest index in |idxs|, or an error if the indexed columns of
+// every index are not a prefix of those of the longest index, or if any index is unique.
+func checkPrefixSharing(idxs []schema.Index) (int, error) {
+	order := make([]int, len(idxs))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return idxs[order[i]].Count() > idxs[order[j]].Count()
+	})
+	longest := order[0]
+
+	tags := idxs[longest].IndexedColumnTags()
+	for _, idx := range idxs {
+		if idx.IsUnique() {
+			return 0, fmt.Errorf("index `%s`: unique indexes cannot be built with shared prefixes", idx.Name())
+		}
+		for i, tag := range idx.IndexedColumnTags() {
+			if tags[i] != tag {
+				return 0, fmt.Errorf("index `%s` does not share a prefix with index `%s`", idx.Name(), idxs[longest].Name())
+			}
+		}
+	}
+	return longest, nil
+}
@@ -0,0 +1,106 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/dolthub/dolt/go/libraries/doltcore/doltdb/durable"
+	"github.com/dolthub/dolt/go/libraries/doltcore/schema"
+	"github.com/dolthub/dolt/go/store/prolly"
+	"github.com/dolthub/dolt/go/store/types"
+)
+
+// newPrefixTestSchema returns a schema of (pk int primary key, c1 int, c2 varchar) with the indexes (c1), (c1, c2)
+// and (c1, c2, pk).
+func newPrefixTestSchema(t testing.TB) (schema.Schema, []schema.Index) {
+	sch, err := schema.SchemaFromCols(schema.NewColCollection(
+		schema.NewColumn("pk", pkTag, types.IntKind, true, schema.NotNullConstraint{}),
+		schema.NewColumn("c1", c1Tag, types.IntKind, false),
+		schema.NewColumn("c2", c2Tag, types.StringKind, false),
+	))
+	require.NoError(t, err)
+	var idxs []schema.Index
+	for _, cols := range [][]string{{"c1"}, {"c1", "c2", "pk"}, {"c1", "c2"}} {
+		idx, err := sch.Indexes().AddIndexByColNames("idx_"+cols[len(cols)-1], cols, schema.IndexProperties{})
+		require.NoError(t, err)
+		idxs = append(idxs, idx)
+	}
+	return sch, idxs
+}
+
+func newPrefixTestPrimary(t testing.TB, ctx context.Context, sch schema.Schema, n int) prolly.Map {
+	var rows [][]interface{}
+	for i := 0; i < n; i++ {
+		var c2 interface{} = "row"
+		if i%3 == 0 {
+			c2 = nil
+		}
+		rows = append(rows, []interface{}{i, i % 7, c2})
+	}
+	return newTestPrimary(t, ctx, newTestVRW(), sch, rows)
+}
+
+func TestBuildPrefixSharingIndexes(t *testing.T) {
+	ctx := context.Background()
+	vrw := newTestVRW()
+	sch, idxs := newPrefixTestSchema(t)
+	primary := newPrefixTestPrimary(t, ctx, sch, 100)
+
+	var stats IndexBuildStats
+	built, err := BuildPrefixSharingIndexes(ctx, vrw, sch, idxs, primary, BuildOptions{ExecutionOptions: ExecutionOptions{IndexBuildStats: &stats}})
+	require.NoError(t, err)
+	require.Len(t, built, len(idxs))
+	assert.Equal(t, uint64(34), stats.NullCounts["c2"])
+
+	for i, idx := range idxs {
+		expected, err := BuildSecondaryProllyIndex(ctx, vrw, sch, idx, primary, BuildOptions{})
+		require.NoError(t, err)
+		assert.Equal(t,
+			collectKeys(t, ctx, durable.ProllyMapFromIndex(expected)),
+			collectKeys(t, ctx, durable.ProllyMapFromIndex(built[i])), idx.Name())
+	}
+
+	c2Idx, err := schema.NewIndexCollection(sch.GetAllCols(), sch.GetPKCols()).AddIndexByColNames("c2", []string{"c2"}, schema.IndexProperties{})
+	require.NoError(t, err)
+	_, err = BuildPrefixSharingIndexes(ctx, vrw, sch, []schema.Index{idxs[0], c2Idx}, primary, BuildOptions{})
+	require.Error(t, err)
+}
+
+func BenchmarkBuildPrefixSharingIndexes(b *testing.B) {
+	ctx := context.Background()
+	vrw := newTestVRW()
+	sch, idxs := newPrefixTestSchema(b)
+	primary := newPrefixTestPrimary(b, ctx, sch, 10_000)
+
+	b.Run("shared", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			_, err := BuildPrefixSharingIndexes(ctx, vrw, sch, idxs, primary, BuildOptions{})
+			require.NoError(b, err)
+		}
+	})
+	b.Run("separate", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for _, idx := range idxs {
+				_, err := BuildSecondaryProllyIndex(ctx, vrw, sch, idx, primary, BuildOptions{})
+				require.NoError(b, err)
+			}
+		}
+	})
+}
@@ -0,0 +1,79 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/dolthub/dolt/go/libraries/doltcore/doltdb"
+	"github.com/dolthub/dolt/go/libraries/doltcore/doltdb/durable"
+	"github.com/dolthub/dolt/go/libraries/doltcore/schema"
+	"github.com/dolthub/dolt/go/store/prolly/shim"
+	"github.com/dolthub/dolt/go/store/types"
+)
+
+// PreviewIndexKeys returns the keys that building the index |idx| would write
+// for the first |n| rows of |tbl|, formatted as in duplicate key errors. The
+// keys are encoded as BuildSecondaryProllyIndex encodes them, but are not
+// written anywhere.
+func PreviewIndexKeys(ctx context.Context, tbl *doltdb.Table, idx schema.Index, n int) ([]string, error) {
+	if !types.IsFormat_DOLT_1(tbl.Format()) {
+		return nil, fmt.Errorf("index key preview is not supported for format %s", tbl.Format().VersionString())
+	}
+	sch, err := tbl.GetSchema(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := tbl.GetRowData(ctx)
+	if err != nil {
+		return nil, err
+	}
+	primary := durable.ProllyMapFromIndex(rows)
+
+	kd := shim.KeyDescriptorFromSchema(idx.Schema())
+	if idx.TimeBucket() != 0 {
+		kd = timeBucketKeyDesc(kd)
+	}
+	enc, err := newIndexKeyEncoder(sch, idx, kd, BuildOptions{})
+	if err != nil {
+		return nil, err
+	}
+	mon := newBuildMonitor(idx, BuildOptions{})
+
+	iter, err := primary.IterAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var keys []string
+	for len(keys) < n {
+		k, v, err := iter.Next(ctx)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		if err = enc.put(k, v, mon); err != nil {
+			return nil, err
+		}
+		keyStr, err := formatKey(enc.kb.Build(primary.Pool()), kd)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, keyStr)
+	}
+	return keys, nil
+}
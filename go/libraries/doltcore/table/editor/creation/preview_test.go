@@ -0,0 +1,56 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/dolthub/dolt/go/libraries/doltcore/doltdb/durable"
+	"github.com/dolthub/dolt/go/libraries/doltcore/schema"
+)
+
+func TestPreviewIndexKeys(t *testing.T) {
+	ctx := context.Background()
+	vrw := newTestVRW()
+	sch := newTestSchema(t)
+	idx, err := schema.NewIndexCollection(sch.GetAllCols(), sch.GetPKCols()).AddIndexByColNames(
+		"c2_c1_idx", []string{"c2", "c1"}, schema.IndexProperties{})
+	require.NoError(t, err)
+	tbl := newTestTable(t, ctx, vrw, sch, [][]interface{}{
+		{1, 30, "c"},
+		{2, 10, nil},
+		{3, 20, "a"},
+	})
+
+	keys, err := PreviewIndexKeys(ctx, tbl, idx, 2)
+	require.NoError(t, err)
+	require.Equal(t, []string{"[c,30,1]", "[NULL,10,2]"}, keys)
+
+	// the preview of every row has the keys of the built index
+	keys, err = PreviewIndexKeys(ctx, tbl, idx, 10)
+	require.NoError(t, err)
+	rows, err := tbl.GetRowData(ctx)
+	require.NoError(t, err)
+	secondary, err := BuildSecondaryProllyIndex(ctx, vrw, sch, idx, durable.ProllyMapFromIndex(rows), BuildOptions{})
+	require.NoError(t, err)
+	require.ElementsMatch(t, collectKeys(t, ctx, durable.ProllyMapFromIndex(secondary)), keys)
+
+	keys, err = PreviewIndexKeys(ctx, tbl, idx, 0)
+	require.NoError(t, err)
+	require.Empty(t, keys)
+}
@@ -0,0 +1,66 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"github.com/dolthub/dolt/go/libraries/doltcore/doltdb/durable"
+	"github.com/dolthub/dolt/go/store/prolly"
+	"github.com/dolthub/dolt/go/store/val"
+)
+
+// reverseCompare orders tuples in the reverse of the order of |cmp|. NULLs,
+// which |cmp| orders last, are ordered first.
+type reverseCompare struct {
+	cmp val.TupleComparator
+}
+
+var _ val.TupleComparator = reverseCompare{}
+
+// Compare implements val.TupleComparator.
+func (c reverseCompare) Compare(left, right val.Tuple, desc val.TupleDesc) int {
+	return c.cmp.Compare(right, left, desc)
+}
+
+// CompareValues implements val.TupleComparator.
+func (c reverseCompare) CompareValues(left, right []byte, typ val.Type) int {
+	return c.cmp.CompareValues(right, left, typ)
+}
+
+// reverseKeyDesc returns |kd| with its order reversed, for the keys of an
+// index built with BuildOptions.ReverseIndexOrder.
+func reverseKeyDesc(kd val.TupleDesc) val.TupleDesc {
+	if _, ok := kd.Comparator().(reverseCompare); ok {
+		return kd
+	}
+	return val.NewTupleDescriptorWithComparator(reverseCompare{cmp: kd.Comparator()}, kd.Types...)
+}
+
+// prefixDesc returns a descriptor for the first |n| fields of |kd|, ordered
+// like |kd|, so that prefix lookups into indexes built with a custom key order,
+// such as the unique checks of reverse ordered indexes, agree with their order.
+func prefixDesc(kd val.TupleDesc, n int) val.TupleDesc {
+	return val.NewTupleDescriptorWithComparator(kd.Comparator(), kd.Types[:n]...)
+}
+
+// ReverseOrderedIndexMap returns |idx|, index data built with
+// BuildOptions.ReverseIndexOrder, as a map in reverse key order. The order
+// of an index is not stored with its data, so reverse ordered index data that
+// was written and read back must be read through this map; read as an
+// ordinary index, its lookups and range scans would miss entries.
+func ReverseOrderedIndexMap(idx durable.Index) prolly.Map {
+	m := durable.ProllyMapFromIndex(idx)
+	kd, vd := m.Descriptors()
+	return prolly.NewMap(m.Node(), m.NodeStore(), reverseKeyDesc(kd), vd)
+}
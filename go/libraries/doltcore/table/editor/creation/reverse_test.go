@@ -0,0 +1,109 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dolthub/go-mysql-server/sql"
+	"github.com/stretchr/testify/require"
+
+	"github.com/dolthub/dolt/go/libraries/doltcore/doltdb/durable"
+	"github.com/dolthub/dolt/go/libraries/doltcore/schema"
+	"github.com/dolthub/dolt/go/store/prolly"
+	"github.com/dolthub/dolt/go/store/prolly/shim"
+	"github.com/dolthub/dolt/go/store/prolly/tree"
+	"github.com/dolthub/dolt/go/store/val"
+)
+
+func TestReverseIndexOrder(t *testing.T) {
+	ctx := context.Background()
+	vrw := newTestVRW()
+	sch := newTestSchema(t)
+	var rows [][]interface{}
+	for i := 0; i < 5000; i++ {
+		rows = append(rows, []interface{}{i, i, "row"})
+	}
+	primary := newTestPrimary(t, ctx, vrw, sch, rows)
+	coll := schema.NewIndexCollection(sch.GetAllCols(), sch.GetPKCols())
+	idx, err := coll.AddIndexByColNames("c1_idx", []string{"c1"}, schema.IndexProperties{})
+	require.NoError(t, err)
+
+	asc, err := BuildSecondaryProllyIndex(ctx, vrw, sch, idx, primary, BuildOptions{})
+	require.NoError(t, err)
+	desc, err := BuildSecondaryProllyIndex(ctx, vrw, sch, idx, primary, BuildOptions{KeyEncodingOptions: KeyEncodingOptions{ReverseIndexOrder: true}})
+	require.NoError(t, err)
+	require.Equal(t, asc.Count(), desc.Count())
+
+	// the values of the first and last leaves of |m|
+	leafValues := func(m prolly.Map) (first, last []int64) {
+		kd, _ := m.Descriptors()
+		var leaves [][]int64
+		require.NoError(t, m.WalkNodes(ctx, func(ctx context.Context, nd tree.Node) error {
+			if nd.IsLeaf() {
+				var vals []int64
+				for i := 0; i < nd.Count(); i++ {
+					v, _ := kd.GetInt64(0, val.Tuple(nd.GetKey(i)))
+					vals = append(vals, v)
+				}
+				leaves = append(leaves, vals)
+			}
+			return nil
+		}))
+		require.Greater(t, len(leaves), 1)
+		return leaves[0], leaves[len(leaves)-1]
+	}
+
+	// ORDER BY c1 DESC LIMIT 10 reads the last leaf of an ascending index, and
+	// the first leaf of a reverse ordered one
+	top := []int64{4999, 4998, 4997, 4996, 4995, 4994, 4993, 4992, 4991, 4990}
+	_, last := leafValues(durable.ProllyMapFromIndex(asc))
+	require.Subset(t, last, top)
+	first, _ := leafValues(durable.ProllyMapFromIndex(desc))
+	require.Equal(t, top, first[:len(top)])
+
+	// reverse ordered index data must be read back through ReverseOrderedIndexMap
+	ref, err := durable.RefFromIndex(ctx, vrw, desc)
+	require.NoError(t, err)
+	v, err := vrw.ReadValue(ctx, ref.TargetHash())
+	require.NoError(t, err)
+	m := ReverseOrderedIndexMap(durable.IndexFromProllyMap(shim.MapFromValue(v, idx.Schema(), vrw)))
+	iter, err := m.IterAll(ctx)
+	require.NoError(t, err)
+	kd, _ := m.Descriptors()
+	for _, want := range top {
+		k, _, err := iter.Next(ctx)
+		require.NoError(t, err)
+		got, _ := kd.GetInt64(0, k)
+		require.Equal(t, want, got)
+	}
+	kb := val.NewTupleBuilder(kd)
+	kb.PutInt64(0, 1234)
+	kb.PutInt64(1, 1234)
+	ok, err := m.Has(ctx, kb.Build(sharePool))
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	// prefix lookups find duplicates in reverse ordered unique indexes
+	dupRows := append(rows, []interface{}{5000, 1234, "dup"})
+	uniq, err := coll.AddIndexByColNames("c1_uniq", []string{"c1"}, schema.IndexProperties{IsUnique: true})
+	require.NoError(t, err)
+	_, err = BuildSecondaryProllyIndex(ctx, vrw, sch, uniq, newTestPrimary(t, ctx, vrw, sch, dupRows), BuildOptions{KeyEncodingOptions: KeyEncodingOptions{ReverseIndexOrder: true}})
+	require.True(t, sql.ErrDuplicateEntry.Is(err))
+
+	_, err = CreateIndex(ctx, newTestTable(t, ctx, vrw, sch, rows), "c1_desc", []string{"c1"}, false, true, "", BuildOptions{KeyEncodingOptions: KeyEncodingOptions{ReverseIndexOrder: true}})
+	require.Error(t, err)
+}
@@ -0,0 +1,147 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"unicode/utf8"
+
+	"github.com/dolthub/go-mysql-server/sql"
+
+	"github.com/dolthub/dolt/go/libraries/doltcore/doltdb/durable"
+	"github.com/dolthub/dolt/go/libraries/doltcore/schema"
+	"github.com/dolthub/dolt/go/store/prolly"
+	"github.com/dolthub/dolt/go/store/val"
+)
+
+// stringReversal reverses the string fields of the keys of an index with
+// schema.IndexProperties.ReverseStrings set. A nil *stringReversal leaves keys
+// unchanged.
+type stringReversal struct {
+	// bytewise holds, for each reversed field, whether the characters of its
+	// column are single bytes
+	bytewise map[int]bool
+}
+
+// newStringReversal returns the string reversal of the keys of |idx|, or nil
+// if |idx| does not reverse its strings.
+func newStringReversal(idx schema.Index) *stringReversal {
+	if !idx.ReverseStrings() {
+		return nil
+	}
+	r := &stringReversal{bytewise: make(map[int]bool)}
+	for i, tag := range idx.IndexedColumnTags() {
+		col, ok := idx.GetColumn(tag)
+		if ok && schema.IsColReverseStringType(col) {
+			r.bytewise[i] = isSingleByteColumn(col)
+		}
+	}
+	return r
+}
+
+// ReverseField returns the index key field |f| at position |to| with its
+// characters reversed, if it is a reversed string field.
+func (r *stringReversal) ReverseField(to int, f []byte) []byte {
+	if r == nil || f == nil {
+		return f
+	}
+	bytewise, ok := r.bytewise[to]
+	if !ok {
+		return f
+	}
+	// strings are null terminated
+	return append(reverseString(f[:len(f)-1], bytewise), 0)
+}
+
+// isSingleByteColumn returns whether the characters of the string column
+// |col| are single bytes, as they are for binary strings.
+func isSingleByteColumn(col schema.Column) bool {
+	st, ok := col.TypeInfo.ToSqlType().(sql.StringType)
+	return ok && st.Collation().CharacterSet().MaxLength() == 1
+}
+
+// reverseString returns |s| with its characters in reverse order. Unless
+// |bytewise| is set, its characters are UTF-8 sequences, and the bytes of
+// invalid sequences are reversed as single characters.
+func reverseString(s []byte, bytewise bool) []byte {
+	r := make([]byte, len(s))
+	if bytewise {
+		for i, b := range s {
+			r[len(s)-1-i] = b
+		}
+		return r
+	}
+	end := len(r)
+	for len(s) > 0 {
+		_, n := utf8.DecodeRune(s)
+		copy(r[end-n:end], s[:n])
+		s, end = s[n:], end-n
+	}
+	return r
+}
+
+// IterIndexSuffix returns an iterator over the entries of |rows|, the data of
+// |idx|, whose first indexed column ends with |suffix|. |idx| must reverse its
+// strings, so that these entries are those whose first key field starts with
+// the reversed |suffix|, and this is a single range scan. Strings are matched
+// byte for byte, as with a binary collation.
+func IterIndexSuffix(ctx context.Context, idx schema.Index, rows durable.Index, suffix string) (prolly.MapIter, error) {
+	if !idx.ReverseStrings() {
+		return nil, fmt.Errorf("index `%s`: suffix scans require an index with reversed strings", idx.Name())
+	}
+	if idx.TimeBucket() != 0 {
+		return nil, fmt.Errorf("index `%s`: suffix scans of time bucketed indexes are not supported", idx.Name())
+	}
+	col, ok := idx.GetColumn(idx.IndexedColumnTags()[0])
+	if !ok || !schema.IsColReverseStringType(col) {
+		return nil, fmt.Errorf("index `%s`: suffix scans require an index whose first column is a string column", idx.Name())
+	}
+
+	m := durable.ProllyMapFromIndex(rows)
+	kd, _ := m.Descriptors()
+	prefix := reverseString([]byte(suffix), isSingleByteColumn(col))
+	iter, err := m.IterRange(ctx, prolly.Range{
+		Start: []prolly.RangeCut{{Value: append(append([]byte{}, prefix...), 0), Inclusive: true}},
+		Stop:  []prolly.RangeCut{{}},
+		Desc:  kd,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &stringPrefixIter{iter: iter, prefix: prefix}, nil
+}
+
+// stringPrefixIter returns the entries of an iterator that starts at the first
+// key whose first string field has the prefix |prefix|, until the first key
+// whose first field does not.
+type stringPrefixIter struct {
+	iter   prolly.MapIter
+	prefix []byte
+}
+
+func (it *stringPrefixIter) Next(ctx context.Context) (val.Tuple, val.Tuple, error) {
+	k, v, err := it.iter.Next(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	f := k.GetField(0)
+	if f == nil || !bytes.HasPrefix(f[:len(f)-1], it.prefix) {
+		return nil, nil, io.EOF
+	}
+	return k, v, nil
+}
@@ -0,0 +1,138 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"context"
+	"io"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/dolthub/go-mysql-server/sql"
+	"github.com/dolthub/vitess/go/sqltypes"
+	"github.com/stretchr/testify/require"
+
+	"github.com/dolthub/dolt/go/libraries/doltcore/doltdb/durable"
+	"github.com/dolthub/dolt/go/libraries/doltcore/schema"
+	"github.com/dolthub/dolt/go/libraries/doltcore/schema/typeinfo"
+	"github.com/dolthub/dolt/go/store/types"
+)
+
+func TestReverseStringIndex(t *testing.T) {
+	ctx := context.Background()
+	vrw := newTestVRW()
+	sch := newTestSchema(t)
+	words := []string{"ending", "bending", "send", "sender", "añadiendo", "€ing", "ing", "in", "gni", "thing"}
+	var rows [][]interface{}
+	for i, w := range words {
+		rows = append(rows, []interface{}{i, i, w})
+	}
+	rows = append(rows, []interface{}{len(words), len(words), nil})
+	primary := newTestPrimary(t, ctx, vrw, sch, rows)
+	coll := schema.NewIndexCollection(sch.GetAllCols(), sch.GetPKCols())
+	props := schema.IndexProperties{ReverseStrings: true}
+	idx, err := coll.AddIndexByColNames("c2_rev", []string{"c2"}, props)
+	require.NoError(t, err)
+	props.IsUnique = true
+	uniq, err := coll.AddIndexByColNames("c2_rev_uniq", []string{"c2"}, props)
+	require.NoError(t, err)
+
+	// suffixes returns the words of the index entries ending with |suffix|, in index order
+	suffixes := func(i schema.Index, rowData durable.Index, suffix string) (matches []string) {
+		iter, err := IterIndexSuffix(ctx, i, rowData, suffix)
+		require.NoError(t, err)
+		kd, _ := durable.ProllyMapFromIndex(rowData).Descriptors()
+		for {
+			k, _, err := iter.Next(ctx)
+			if err == io.EOF {
+				return matches
+			}
+			require.NoError(t, err)
+			s, ok := kd.GetString(0, k)
+			require.True(t, ok)
+			// the key holds the reversed word
+			matches = append(matches, string(reverseString([]byte(s), false)))
+		}
+	}
+
+	for _, i := range []schema.Index{idx, uniq} {
+		rowData, err := BuildSecondaryProllyIndex(ctx, vrw, sch, i, primary, BuildOptions{})
+		require.NoError(t, err)
+		require.Equal(t, uint64(len(rows)), rowData.Count())
+
+		for _, suffix := range []string{"ing", "nding", "end", "ñadiendo", "€ing", "g", "", "xyz"} {
+			var expected []string
+			for _, w := range words {
+				if strings.HasSuffix(w, suffix) {
+					expected = append(expected, w)
+				}
+			}
+			// entries are ordered by their reversed words
+			sort.Slice(expected, func(a, b int) bool {
+				return string(reverseString([]byte(expected[a]), false)) < string(reverseString([]byte(expected[b]), false))
+			})
+			require.Equal(t, expected, suffixes(i, rowData, suffix), "suffix %q", suffix)
+		}
+	}
+
+	plain, err := coll.AddIndexByColNames("c2_idx", []string{"c2"}, schema.IndexProperties{})
+	require.NoError(t, err)
+	_, err = IterIndexSuffix(ctx, plain, durable.IndexFromProllyMap(primary), "ing")
+	require.Error(t, err)
+	_, err = coll.AddIndexByColNames("c1_rev", []string{"c1"}, schema.IndexProperties{ReverseStrings: true})
+	require.Error(t, err)
+
+	// DML and lookups do not reverse strings, so the index cannot be stored
+	// in a table
+	_, err = CreateIndexWithProperties(ctx, newTestTable(t, ctx, vrw, sch, rows), "c2_rev", []uint64{c2Tag}, schema.IndexProperties{ReverseStrings: true, IsUserDefined: true}, BuildOptions{})
+	require.Error(t, err)
+}
+
+func TestReverseStringIndexBinary(t *testing.T) {
+	ctx := context.Background()
+	vrw := newTestVRW()
+	binTI, err := typeinfo.FromSqlType(sql.MustCreateBinary(sqltypes.VarBinary, 16))
+	require.NoError(t, err)
+	data, err := schema.NewColumnWithTypeInfo("data", 2, binTI, false, "", false, "")
+	require.NoError(t, err)
+	sch, err := schema.SchemaFromCols(schema.NewColCollection(
+		schema.NewColumn("pk", 1, types.IntKind, true, schema.NotNullConstraint{}),
+		data,
+	))
+	require.NoError(t, err)
+	primary := newTestPrimary(t, ctx, vrw, sch, [][]interface{}{{1, []byte("\xe2\x82\xac")}, {2, []byte("\x82\xac")}, {3, []byte("x\xac")}})
+	idx, err := schema.NewIndexCollection(sch.GetAllCols(), sch.GetPKCols()).AddIndexByColNames(
+		"data_rev", []string{"data"}, schema.IndexProperties{ReverseStrings: true})
+	require.NoError(t, err)
+	rowData, err := BuildSecondaryProllyIndex(ctx, vrw, sch, idx, primary, BuildOptions{})
+	require.NoError(t, err)
+
+	// binary strings are reversed byte by byte, so a byte suffix of a UTF-8 character matches
+	iter, err := IterIndexSuffix(ctx, idx, rowData, "\x82\xac")
+	require.NoError(t, err)
+	kd, _ := durable.ProllyMapFromIndex(rowData).Descriptors()
+	var pks []int64
+	for {
+		k, _, err := iter.Next(ctx)
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		pk, _ := kd.GetInt64(1, k)
+		pks = append(pks, pk)
+	}
+	require.Equal(t, []int64{2, 1}, pks)
+}
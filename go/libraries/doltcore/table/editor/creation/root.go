@@ -0,0 +1,57 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dolthub/dolt/go/libraries/doltcore/doltdb"
+)
+
+// CreateIndexOnRoot creates an index on the table |tableName| of |root|, as CreateIndex does, and returns the updated
+// root along with the result of CreateIndex. |root| may be the working root of any branch; the index is built without
+// checking out the branch or touching any session state. The table name is resolved case-insensitively, and an error
+// wrapping doltdb.ErrTableNotFound is returned if the table does not exist on |root|.
+func CreateIndexOnRoot(
+	ctx context.Context,
+	root *doltdb.RootValue,
+	tableName string,
+	indexName string,
+	columns []string,
+	isUnique bool,
+	isUserDefined bool,
+	comment string,
+	opts BuildOptions,
+) (*doltdb.RootValue, *CreateIndexReturn, error) {
+	tbl, realName, ok, err := root.GetTableInsensitive(ctx, tableName)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !ok {
+		return nil, nil, fmt.Errorf("%w: %s", doltdb.ErrTableNotFound, tableName)
+	}
+
+	ret, err := CreateIndex(ctx, tbl, indexName, columns, isUnique, isUserDefined, comment, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	root, err = root.PutTable(ctx, realName, ret.NewTable)
+	if err != nil {
+		return nil, nil, err
+	}
+	return root, ret, nil
+}
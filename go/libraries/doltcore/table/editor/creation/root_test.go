@@ -0,0 +1,61 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/dolthub/dolt/go/libraries/doltcore/doltdb"
+)
+
+func TestCreateIndexOnRoot(t *testing.T) {
+	ctx := context.Background()
+	vrw := newTestVRW()
+	sch := newTestSchema(t)
+	tbl := newTestTable(t, ctx, vrw, sch, [][]interface{}{
+		{1, 10, "a"},
+		{2, 20, "b"},
+	})
+
+	root, err := doltdb.EmptyRootValue(ctx, vrw)
+	require.NoError(t, err)
+	root, err = root.PutTable(ctx, "test", tbl)
+	require.NoError(t, err)
+
+	newRoot, ret, err := CreateIndexOnRoot(ctx, root, "TEST", "c1_idx", []string{"c1"}, false, true, "", BuildOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "c1_idx", ret.NewIndex.Name())
+
+	newTbl, ok, err := newRoot.GetTable(ctx, "test")
+	require.NoError(t, err)
+	require.True(t, ok)
+	newSch, err := newTbl.GetSchema(ctx)
+	require.NoError(t, err)
+	assert.True(t, newSch.Indexes().Contains("c1_idx"))
+
+	// the original root is unchanged
+	oldTbl, _, err := root.GetTable(ctx, "test")
+	require.NoError(t, err)
+	oldSch, err := oldTbl.GetSchema(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 0, oldSch.Indexes().Count())
+
+	_, _, err = CreateIndexOnRoot(ctx, root, "missing", "c1_idx", []string{"c1"}, false, true, "", BuildOptions{})
+	require.ErrorIs(t, err, doltdb.ErrTableNotFound)
+}
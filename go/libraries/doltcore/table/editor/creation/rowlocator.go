@@ -0,0 +1,83 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"context"
+	"errors"
+
+	"github.com/dolthub/dolt/go/store/hash"
+	"github.com/dolthub/dolt/go/store/prolly"
+	"github.com/dolthub/dolt/go/store/prolly/tree"
+	"github.com/dolthub/dolt/go/store/val"
+)
+
+// rowLocatorValueDesc describes the values of an index built with
+// BuildOptions.RecordRowLocatorInIndex: the 20 byte address of the primary
+// leaf chunk of the entry's row, followed by the position of the row within
+// that leaf.
+var rowLocatorValueDesc = val.NewTupleDescriptor(
+	val.Type{Enc: val.ByteStringEnc},
+	val.Type{Enc: val.Uint32Enc},
+)
+
+// IndexRowLocator returns the address of the primary leaf chunk and the
+// position within it recorded in |v|, the value of an entry of an index built
+// with BuildOptions.RecordRowLocatorInIndex.
+func IndexRowLocator(v val.Tuple) (hash.Hash, uint32, bool) {
+	b, ok := rowLocatorValueDesc.GetBytes(0, v)
+	if !ok || len(b) != hash.ByteLen {
+		return hash.Hash{}, 0, false
+	}
+	off, ok := rowLocatorValueDesc.GetUint32(1, v)
+	if !ok {
+		return hash.Hash{}, 0, false
+	}
+	return hash.New(b), off, true
+}
+
+// LookupRowByLocator calls |cb| with the row of |primary| whose primary key is
+// |pk|, using the locator in |v|, the value of its entry in an index built
+// with BuildOptions.RecordRowLocatorInIndex. A locator is a soft reference:
+// the row is read directly from its leaf chunk, without searching the tree of
+// |primary|, and if the leaf does not hold the row at the recorded position,
+// the row is looked up by its primary key instead. Locators are only meaningful
+// for the primary index that the index was built from, as a leaf of an earlier
+// version of |primary| can still hold the key of a row with outdated values.
+// Like IndexSourceChunk, the leaf chunk is not retained by the index, and must
+// still be readable from the node store of |primary|. If |primary| has no row
+// with the key |pk|, |cb| is called with nil tuples.
+func LookupRowByLocator(ctx context.Context, primary prolly.Map, pk, v val.Tuple, cb prolly.KeyValueFn[val.Tuple, val.Tuple]) error {
+	addr, off, ok := IndexRowLocator(v)
+	if !ok {
+		return errors.New("index value does not hold a row locator")
+	}
+	ns := primary.NodeStore()
+	nd, err := ns.Read(ctx, addr)
+	if err != nil {
+		return err
+	}
+	if nd.IsLeaf() && int(off) < nd.Count() {
+		cur, err := tree.NewCursorAtOrdinal(ctx, ns, nd, uint64(off))
+		if err != nil {
+			return err
+		}
+		kd, _ := primary.Descriptors()
+		if k := val.Tuple(cur.CurrentKey()); kd.Compare(k, pk) == 0 {
+			return cb(k, val.Tuple(cur.CurrentValue()))
+		}
+	}
+	return primary.Get(ctx, pk, cb)
+}
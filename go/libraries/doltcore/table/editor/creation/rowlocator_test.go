@@ -0,0 +1,178 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/dolthub/dolt/go/libraries/doltcore/doltdb/durable"
+	"github.com/dolthub/dolt/go/libraries/doltcore/schema"
+	"github.com/dolthub/dolt/go/store/prolly"
+	"github.com/dolthub/dolt/go/store/val"
+)
+
+// newRowLocatorTestIndex returns a primary index of |n| rows, and the
+// entries of an index of its column c1 built with row locators.
+func newRowLocatorTestIndex(t testing.TB, ctx context.Context, n int) (prolly.Map, prolly.Map) {
+	vrw := newTestVRW()
+	sch := newTestSchema(t)
+	var rows [][]interface{}
+	for i := 0; i < n; i++ {
+		rows = append(rows, []interface{}{i, i % 10, "row"})
+	}
+	primary := newTestPrimary(t, ctx, vrw, sch, rows)
+	idx, err := schema.NewIndexCollection(sch.GetAllCols(), sch.GetPKCols()).AddIndexByColNames(
+		"loc_idx", []string{"c1"}, schema.IndexProperties{})
+	require.NoError(t, err)
+	built, err := BuildSecondaryProllyIndex(ctx, vrw, sch, idx, primary, BuildOptions{ValueLayoutOptions: ValueLayoutOptions{RecordRowLocatorInIndex: true}})
+	require.NoError(t, err)
+	return primary, durable.ProllyMapFromIndex(built)
+}
+
+// locatorEntry is the primary key and value of an entry of an index built
+// with row locators.
+type locatorEntry struct {
+	pk, v val.Tuple
+}
+
+// locatorEntries returns the entries of |secondary|, an index of the test
+// schema built with row locators, with their primary keys.
+func locatorEntries(t testing.TB, ctx context.Context, primary, secondary prolly.Map) []locatorEntry {
+	pkd, _ := primary.Descriptors()
+	kd, _ := secondary.Descriptors()
+	pkb := val.NewTupleBuilder(pkd)
+	iter, err := secondary.IterAll(ctx)
+	require.NoError(t, err)
+	var entries []locatorEntry
+	for {
+		k, v, err := iter.Next(ctx)
+		if err == io.EOF {
+			return entries
+		}
+		require.NoError(t, err)
+		pkb.PutRaw(0, k.GetField(kd.Count()-1))
+		entries = append(entries, locatorEntry{pk: pkb.Build(sharePool), v: v})
+	}
+}
+
+func TestRowLocatorIndex(t *testing.T) {
+	ctx := context.Background()
+	primary, secondary := newRowLocatorTestIndex(t, ctx, 3000)
+	require.Greater(t, primary.Height(), 1)
+	require.Equal(t, primary.Count(), secondary.Count())
+	pkd, _ := primary.Descriptors()
+
+	// every locator finds its row in its leaf chunk
+	entries := locatorEntries(t, ctx, primary, secondary)
+	leaves := make(map[string]struct{})
+	for _, e := range entries {
+		addr, off, ok := IndexRowLocator(e.v)
+		require.True(t, ok)
+		leaves[addr.String()] = struct{}{}
+		nd, err := primary.NodeStore().Read(ctx, addr)
+		require.NoError(t, err)
+		require.True(t, nd.IsLeaf())
+		require.Less(t, int(off), nd.Count())
+		require.Equal(t, 0, pkd.Compare(val.Tuple(nd.GetKey(int(off))), e.pk))
+
+		var expected, actual val.Tuple
+		require.NoError(t, primary.Get(ctx, e.pk, func(_, v val.Tuple) error {
+			expected = v
+			return nil
+		}))
+		require.NoError(t, LookupRowByLocator(ctx, primary, e.pk, e.v, func(k, v val.Tuple) error {
+			require.Equal(t, 0, pkd.Compare(k, e.pk))
+			actual = v
+			return nil
+		}))
+		require.Equal(t, expected, actual)
+	}
+	require.Greater(t, len(leaves), 1)
+
+	// a locator whose leaf does not hold its row at the recorded position
+	// falls back to a primary key lookup
+	addr, off, _ := IndexRowLocator(entries[0].v)
+	vb := val.NewTupleBuilder(rowLocatorValueDesc)
+	vb.PutByteString(0, addr[:])
+	vb.PutUint32(1, off+1)
+	found := false
+	err := LookupRowByLocator(ctx, primary, entries[0].pk, vb.Build(sharePool), func(k, v val.Tuple) error {
+		found = k != nil && pkd.Compare(k, entries[0].pk) == 0
+		return nil
+	})
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Error(t, LookupRowByLocator(ctx, primary, entries[0].pk, val.EmptyTuple, func(_, _ val.Tuple) error {
+		return nil
+	}))
+
+	// locator lookups read a single chunk rather than searching the tree; the
+	// timings are only logged, as they are noisy, see BenchmarkLookupRowByLocator
+	start := time.Now()
+	for _, e := range entries {
+		require.NoError(t, primary.Get(ctx, e.pk, func(_, _ val.Tuple) error { return nil }))
+	}
+	byKey := time.Since(start)
+	start = time.Now()
+	for _, e := range entries {
+		require.NoError(t, LookupRowByLocator(ctx, primary, e.pk, e.v, func(_, _ val.Tuple) error { return nil }))
+	}
+	t.Logf("%d lookups: %s by primary key, %s by locator", len(entries), byKey, time.Since(start))
+
+	vrw := newTestVRW()
+	sch := newTestSchema(t)
+	idx, err := schema.NewIndexCollection(sch.GetAllCols(), sch.GetPKCols()).AddIndexByColNames(
+		"loc_idx", []string{"c1"}, schema.IndexProperties{})
+	require.NoError(t, err)
+	for _, bad := range []BuildOptions{
+		{ValueLayoutOptions: ValueLayoutOptions{RecordRowLocatorInIndex: true, MirrorPrimaryRowInIndex: true}},
+		{ValueLayoutOptions: ValueLayoutOptions{RecordRowLocatorInIndex: true, RecordSourceChunkInIndex: true}},
+		{ValueLayoutOptions: ValueLayoutOptions{RecordRowLocatorInIndex: true}, ExecutionOptions: ExecutionOptions{IndexBuildCheckpoints: NewFileCheckpointStore(t.TempDir()), IndexBuildCheckpointRows: 100}},
+	} {
+		_, err = BuildSecondaryProllyIndex(ctx, vrw, sch, idx, primary, bad)
+		require.Error(t, err, "%+v", bad)
+	}
+	iter, err := primary.IterAll(ctx)
+	require.NoError(t, err)
+	_, err = BuildSecondaryProllyIndexFromIter(ctx, vrw, sch, idx, iter, BuildOptions{ValueLayoutOptions: ValueLayoutOptions{RecordRowLocatorInIndex: true}})
+	require.ErrorIs(t, err, errNoSourceChunks)
+	_, err = CreateIndex(ctx, newTestTable(t, ctx, vrw, sch, [][]interface{}{{1, 1, "row"}}), "loc_idx", []string{"c1"}, false, true, "", BuildOptions{ValueLayoutOptions: ValueLayoutOptions{RecordRowLocatorInIndex: true}})
+	require.Error(t, err)
+}
+
+func BenchmarkLookupRowByLocator(b *testing.B) {
+	ctx := context.Background()
+	primary, secondary := newRowLocatorTestIndex(b, ctx, 50_000)
+	entries := locatorEntries(b, ctx, primary, secondary)
+	noop := func(_, _ val.Tuple) error { return nil }
+
+	b.Run("primary key", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			e := entries[i%len(entries)]
+			require.NoError(b, primary.Get(ctx, e.pk, noop))
+		}
+	})
+	b.Run("locator", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			e := entries[i%len(entries)]
+			require.NoError(b, LookupRowByLocator(ctx, primary, e.pk, e.v, noop))
+		}
+	})
+}
@@ -0,0 +1,111 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"context"
+	"io"
+
+	"github.com/dolthub/dolt/go/libraries/doltcore/doltdb/durable"
+	"github.com/dolthub/dolt/go/store/prolly"
+	"github.com/dolthub/dolt/go/store/prolly/tree"
+	"github.com/dolthub/dolt/go/store/val"
+)
+
+// rowSizeKeyDesc returns the key descriptor of a row size index of a primary
+// index with the key descriptor |pkd|.
+func rowSizeKeyDesc(pkd val.TupleDesc) val.TupleDesc {
+	types := make([]val.Type, 0, pkd.Count()+1)
+	types = append(types, val.Type{Enc: val.Uint64Enc, Nullable: false})
+	types = append(types, pkd.Types...)
+	return val.NewTupleDescriptor(types...)
+}
+
+// BuildRowSizeIndex builds a diagnostic index of the rows of |primary| keyed
+// by their size, followed by their primary key. Scanning it in reverse, e.g.
+// with prolly.Map.IterAllReverse, finds the largest rows first. The size of a
+// row is the size of its encoded key and value, plus the size of the chunks of
+// every value stored out of band, such as a BLOB column, which the row's value
+// only holds the address of. Like BuildSequenceIndex, the index is not
+// maintained by writes to the table.
+func BuildRowSizeIndex(ctx context.Context, primary prolly.Map) (durable.Index, error) {
+	pkd, pvd := primary.Descriptors()
+	kd := rowSizeKeyDesc(pkd)
+	empty, err := prolly.NewMapFromTuples(ctx, primary.NodeStore(), kd, val.NewTupleDescriptor())
+	if err != nil {
+		return nil, err
+	}
+
+	iter, err := primary.IterAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	kb := val.NewTupleBuilder(kd)
+	mut := empty.Mutate()
+	for {
+		k, v, err := iter.Next(ctx)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		size, err := RowSize(ctx, primary.NodeStore(), pvd, k, v)
+		if err != nil {
+			return nil, err
+		}
+		kb.PutUint64(0, size)
+		for i := 0; i < k.Count(); i++ {
+			kb.PutRaw(i+1, k.GetField(i))
+		}
+		if err = mut.Put(ctx, kb.Build(primary.Pool()), val.EmptyTuple); err != nil {
+			return nil, err
+		}
+	}
+
+	m, err := mut.Map(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return durable.IndexFromProllyMap(m), nil
+}
+
+// RowSize returns the size in bytes of the primary row |k|, |v|, whose value is
+// described by |vd|, as indexed by BuildRowSizeIndex. Values stored out of band
+// are read from |ns|.
+func RowSize(ctx context.Context, ns tree.NodeStore, vd val.TupleDesc, k, v val.Tuple) (uint64, error) {
+	size := uint64(len(k) + len(v))
+	for i, typ := range vd.Types {
+		if typ.Enc != val.BytesAddrEnc {
+			continue
+		}
+		b, ok := vd.GetBlob(i, v)
+		if !ok || b.Addr.IsEmpty() {
+			continue
+		}
+		nd, err := ns.Read(ctx, b.Addr)
+		if err != nil {
+			return 0, err
+		}
+		err = tree.WalkNodes(ctx, nd, ns, func(ctx context.Context, nd tree.Node) error {
+			size += uint64(nd.Size())
+			return nil
+		})
+		if err != nil {
+			return 0, err
+		}
+	}
+	return size, nil
+}
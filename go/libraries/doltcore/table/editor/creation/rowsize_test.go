@@ -0,0 +1,98 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/dolthub/dolt/go/libraries/doltcore/doltdb/durable"
+	"github.com/dolthub/dolt/go/libraries/doltcore/schema"
+	"github.com/dolthub/dolt/go/libraries/doltcore/sqle/index"
+	"github.com/dolthub/dolt/go/store/hash"
+	"github.com/dolthub/dolt/go/store/types"
+	"github.com/dolthub/dolt/go/store/val"
+)
+
+func TestRowSizeIndex(t *testing.T) {
+	ctx := context.Background()
+	vrw := newTestVRW()
+	sch, err := schema.SchemaFromCols(schema.NewColCollection(
+		schema.NewColumn("pk", 1, types.IntKind, true, schema.NotNullConstraint{}),
+		schema.NewColumn("name", 2, types.StringKind, false),
+		schema.NewColumn("data", 3, types.BlobKind, false),
+	))
+	require.NoError(t, err)
+	empty, err := durable.NewEmptyIndex(ctx, vrw, sch)
+	require.NoError(t, err)
+	ns := durable.ProllyMapFromIndex(empty).NodeStore()
+
+	// putBlob stores |b| out of band and returns its address
+	putBlob := func(b []byte) hash.Hash {
+		tb := val.NewTupleBuilder(val.NewTupleDescriptor(val.Type{Enc: val.BytesAddrEnc, Nullable: true}))
+		require.NoError(t, index.PutField(ctx, ns, tb, 0, b))
+		addr, _ := tb.Desc.GetBlob(0, tb.Build(ns.Pool()))
+		return addr.Addr
+	}
+
+	// the payload of each row is in its name or in its blob
+	payloads := map[int]int{0: 10, 1: 250_000, 2: 3_000, 3: 40_000, 4: 0, 5: 900, 6: 120_000, 7: 7}
+	var rows [][]interface{}
+	for pk := 0; pk < len(payloads); pk++ {
+		n := payloads[pk]
+		if n < 1000 {
+			rows = append(rows, []interface{}{pk, strings.Repeat("x", n), nil})
+		} else {
+			rows = append(rows, []interface{}{pk, "blob", putBlob(bytes.Repeat([]byte{byte(pk)}, n))})
+		}
+	}
+	primary := newTestPrimary(t, ctx, vrw, sch, rows)
+
+	rowData, err := BuildRowSizeIndex(ctx, primary)
+	require.NoError(t, err)
+	require.Equal(t, uint64(len(rows)), rowData.Count())
+
+	// a reverse scan returns the largest rows first
+	m := durable.ProllyMapFromIndex(rowData)
+	kd, _ := m.Descriptors()
+	iter, err := m.IterAllReverse(ctx)
+	require.NoError(t, err)
+	var pks []int
+	for {
+		k, _, err := iter.Next(ctx)
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		size, _ := kd.GetUint64(0, k)
+		pk, _ := kd.GetInt64(1, k)
+		pks = append(pks, int(pk))
+		require.GreaterOrEqual(t, size, uint64(payloads[int(pk)]))
+		// blob chunks carry some overhead of their own
+		require.Less(t, size, uint64(payloads[int(pk)]*21/20+200))
+	}
+	expected := make([]int, 0, len(payloads))
+	for pk := range payloads {
+		expected = append(expected, pk)
+	}
+	sort.Slice(expected, func(i, j int) bool { return payloads[expected[i]] > payloads[expected[j]] })
+	require.Equal(t, expected, pks)
+}
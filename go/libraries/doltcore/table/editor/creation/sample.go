@@ -0,0 +1,61 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"context"
+	"encoding/binary"
+	"hash/fnv"
+
+	"github.com/dolthub/dolt/go/libraries/doltcore/schema"
+	"github.com/dolthub/dolt/go/store/val"
+)
+
+// SampledIndexIncludesRow returns whether the row with the primary key |k| is
+// included in |idx|. Every row is included in an index that is not sampled.
+// The rows of a sampled index are those whose primary key, hashed with the
+// SampleSeed of |idx|, is below its SamplePercent modulo 100, so the same rows
+// are included by every build and the included fraction of a large table is
+// close to the SamplePercent.
+func SampledIndexIncludesRow(idx schema.Index, k val.Tuple) bool {
+	if idx.SamplePercent() == 0 {
+		return true
+	}
+	h := fnv.New64a()
+	var seed [8]byte
+	binary.BigEndian.PutUint64(seed[:], idx.SampleSeed())
+	h.Write(seed[:])
+	h.Write(k)
+	return h.Sum64()%100 < uint64(idx.SamplePercent())
+}
+
+// withIndexSample returns |opts| with an IndexRowFilter that also rejects the
+// rows not included in |idx|, if it is a sampled index.
+func withIndexSample(idx schema.Index, opts BuildOptions) BuildOptions {
+	if idx.SamplePercent() == 0 {
+		return opts
+	}
+	filter := opts.IndexRowFilter
+	opts.IndexRowFilter = func(ctx context.Context, k, v val.Tuple) (bool, error) {
+		if !SampledIndexIncludesRow(idx, k) {
+			return false, nil
+		}
+		if filter == nil {
+			return true, nil
+		}
+		return filter(ctx, k, v)
+	}
+	return opts
+}
@@ -0,0 +1,87 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/dolthub/dolt/go/libraries/doltcore/doltdb/durable"
+	"github.com/dolthub/dolt/go/libraries/doltcore/schema"
+)
+
+func TestSampledIndex(t *testing.T) {
+	ctx := context.Background()
+	vrw := newTestVRW()
+	sch := newTestSchema(t)
+	const n = 4000
+	rows := make([][]interface{}, n)
+	for i := range rows {
+		rows[i] = []interface{}{i, i % 50, "v"}
+	}
+	tbl := newTestTable(t, ctx, vrw, sch, rows)
+	rowData, err := tbl.GetRowData(ctx)
+	require.NoError(t, err)
+	primary := durable.ProllyMapFromIndex(rowData)
+
+	build := func(name string, props schema.IndexProperties) []string {
+		props.IsUserDefined = true
+		idx, err := sch.Indexes().AddIndexByColTags(name, []uint64{c1Tag}, props)
+		require.NoError(t, err)
+		built, err := BuildSecondaryProllyIndex(ctx, vrw, sch, idx, primary, BuildOptions{})
+		require.NoError(t, err)
+		verified, err := ValidateImportedIndex(ctx, tbl, idx, built, BuildOptions{})
+		require.NoError(t, err)
+		require.Zero(t, verified.MissingEntries+verified.ExtraEntries)
+		verified, err = ValidateImportedIndex(ctx, tbl, idx, built, BuildOptions{ExecutionOptions: ExecutionOptions{VerifySampleSize: 100}})
+		require.NoError(t, err)
+		require.Zero(t, verified.MissingEntries+verified.ExtraEntries)
+		return collectKeys(t, ctx, durable.ProllyMapFromIndex(built))
+	}
+
+	// about a tenth of the rows are included
+	keys := build("c1_sample", schema.IndexProperties{SamplePercent: 10, SampleSeed: 42})
+	require.InDelta(t, n/10, len(keys), n/50)
+
+	// the same rows are included by every build
+	require.Equal(t, keys, build("c1_again", schema.IndexProperties{SamplePercent: 10, SampleSeed: 42}))
+	require.NotEqual(t, keys, build("c1_other", schema.IndexProperties{SamplePercent: 10, SampleSeed: 7}))
+	require.InDelta(t, n/2, len(build("c1_half", schema.IndexProperties{SamplePercent: 50})), n/25)
+	require.Len(t, build("c1_all", schema.IndexProperties{}), n)
+
+	// the included rows are those SampledIndexIncludesRow reports
+	idx := sch.Indexes().GetByName("c1_sample")
+	var want uint64
+	iter, err := primary.IterAll(ctx)
+	require.NoError(t, err)
+	for {
+		k, _, err := iter.Next(ctx)
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		if SampledIndexIncludesRow(idx, k) {
+			want++
+		}
+	}
+	require.Equal(t, uint64(len(keys)), want)
+
+	// DML does not sample rows, so a sampled index cannot be stored in a table
+	_, err = CreateIndexWithProperties(ctx, tbl, "c1_sample", []uint64{c1Tag}, schema.IndexProperties{SamplePercent: 10, IsUserDefined: true}, BuildOptions{})
+	require.Error(t, err)
+}
@@ -0,0 +1,84 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"context"
+	"io"
+
+	"github.com/dolthub/dolt/go/libraries/doltcore/doltdb/durable"
+	"github.com/dolthub/dolt/go/store/prolly"
+	"github.com/dolthub/dolt/go/store/val"
+)
+
+// seqKeyDesc is the key descriptor of a sequence index.
+var seqKeyDesc = val.NewTupleDescriptor(val.Type{Enc: val.Uint64Enc, Nullable: false})
+
+// BuildSequenceIndex builds an index mapping a sequence number to the primary
+// key of each row in |primary|, for tailing append-only tables.
+//
+// Rows are numbered from zero in the order they are scanned, which is primary
+// key order: Dolt does not record the order in which rows were inserted. For a
+// table whose primary key increases with every insert, such as an
+// auto-increment key, scan order is insertion order. For any other table the
+// sequence only reflects the table at the time the index was built. The index
+// is not maintained by writes to the table, so rows inserted later must be
+// appended by the caller starting at NextSeq, and the index must be rebuilt if
+// rows are deleted or their primary keys are updated.
+func BuildSequenceIndex(ctx context.Context, primary prolly.Map) (durable.Index, error) {
+	pkd, _ := primary.Descriptors()
+	empty, err := prolly.NewMapFromTuples(ctx, primary.NodeStore(), seqKeyDesc, pkd)
+	if err != nil {
+		return nil, err
+	}
+
+	iter, err := primary.IterAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	kb := val.NewTupleBuilder(seqKeyDesc)
+	mut := empty.Mutate()
+	for seq := uint64(0); ; seq++ {
+		k, _, err := iter.Next(ctx)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		kb.PutUint64(0, seq)
+		if err = mut.Put(ctx, kb.Build(primary.Pool()), k); err != nil {
+			return nil, err
+		}
+	}
+
+	m, err := mut.Map(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return durable.IndexFromProllyMap(m), nil
+}
+
+// NextSeq returns the sequence number to assign to the next row appended to
+// the sequence index |idx|.
+func NextSeq(ctx context.Context, idx durable.Index) (uint64, error) {
+	m := durable.ProllyMapFromIndex(idx)
+	k, _, err := m.Last(ctx)
+	if err != nil || k == nil {
+		return 0, err
+	}
+	seq, _ := seqKeyDesc.GetUint64(0, k)
+	return seq + 1, nil
+}
@@ -0,0 +1,64 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/dolthub/dolt/go/libraries/doltcore/doltdb/durable"
+	"github.com/dolthub/dolt/go/store/val"
+)
+
+func TestBuildSequenceIndex(t *testing.T) {
+	ctx := context.Background()
+	vrw := newTestVRW()
+	sch := newTestSchema(t)
+
+	empty := newTestPrimary(t, ctx, vrw, sch, nil)
+	idx, err := BuildSequenceIndex(ctx, empty)
+	require.NoError(t, err)
+	seq, err := NextSeq(ctx, idx)
+	require.NoError(t, err)
+	require.Equal(t, uint64(0), seq)
+
+	primary := newTestPrimary(t, ctx, vrw, sch, [][]interface{}{
+		{30, 1, "c"},
+		{10, 2, "a"},
+		{20, 3, "b"},
+	})
+	idx, err = BuildSequenceIndex(ctx, primary)
+	require.NoError(t, err)
+
+	m := durable.ProllyMapFromIndex(idx)
+	pkd, _ := primary.Descriptors()
+	var pks []int64
+	for i := uint64(0); i < 3; i++ {
+		kb := val.NewTupleBuilder(seqKeyDesc)
+		kb.PutUint64(0, i)
+		require.NoError(t, m.Get(ctx, kb.Build(sharePool), func(k, v val.Tuple) error {
+			pk, _ := pkd.GetInt64(0, v)
+			pks = append(pks, pk)
+			return nil
+		}))
+	}
+	require.Equal(t, []int64{10, 20, 30}, pks)
+
+	seq, err = NextSeq(ctx, idx)
+	require.NoError(t, err)
+	require.Equal(t, uint64(3), seq)
+}
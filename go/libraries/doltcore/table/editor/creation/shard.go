@@ -0,0 +1,126 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/dolthub/dolt/go/libraries/doltcore/doltdb/durable"
+	"github.com/dolthub/dolt/go/store/prolly"
+	"github.com/dolthub/dolt/go/store/prolly/tree"
+	"github.com/dolthub/dolt/go/store/types"
+	"github.com/dolthub/dolt/go/store/val"
+)
+
+// IndexShard is one of the shards of an index split by ShardIndex.
+type IndexShard struct {
+	// Start is the smallest key of the shard. Every key of the index that is
+	// not smaller than Start, and smaller than the Start of the next shard,
+	// belongs to the shard.
+	Start val.Tuple
+	// Rows is the index data of the shard.
+	Rows durable.Index
+}
+
+// ShardIndex splits the index data |idx| into at most |n| shards, each a
+// separate prolly map holding a contiguous range of its keys. Shards are split
+// at the boundaries of the leaf chunks of |idx| and hold about as many entries
+// each, so an index with fewer than |n| leaves has fewer than |n| shards. An
+// empty index has a single empty shard.
+func ShardIndex(ctx context.Context, idx durable.Index, n int) ([]IndexShard, error) {
+	if !types.IsFormat_DOLT_1(idx.Format()) {
+		return nil, fmt.Errorf("index sharding is not supported for format %s", idx.Format().VersionString())
+	}
+	if n < 1 {
+		return nil, fmt.Errorf("invalid index shard count %d", n)
+	}
+	m := durable.ProllyMapFromIndex(idx)
+	if m.Count() == 0 {
+		return []IndexShard{{Rows: idx}}, nil
+	}
+
+	// the ordinals of the first entry of each leaf
+	var leaves []uint64
+	var ord uint64
+	err := m.WalkNodes(ctx, func(ctx context.Context, nd tree.Node) error {
+		if nd.IsLeaf() {
+			leaves = append(leaves, ord)
+			ord += uint64(nd.Count())
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// the first leaf of each shard, the leaf whose first entry is closest to
+	// the shard's share of the entries
+	starts := []uint64{0}
+	for i := 1; i < n; i++ {
+		target := ord * uint64(i) / uint64(n)
+		j := sort.Search(len(leaves), func(j int) bool { return leaves[j] >= target })
+		if j > 0 && (j == len(leaves) || target-leaves[j-1] < leaves[j]-target) {
+			j--
+		}
+		if j < len(leaves) && leaves[j] > starts[len(starts)-1] {
+			starts = append(starts, leaves[j])
+		}
+	}
+
+	kd, vd := m.Descriptors()
+	shards := make([]IndexShard, len(starts))
+	for i, start := range starts {
+		stop := ord
+		if i+1 < len(starts) {
+			stop = starts[i+1]
+		}
+		iter, err := m.IterOrdinalRange(ctx, start, stop)
+		if err != nil {
+			return nil, err
+		}
+		tups := make([]val.Tuple, 0, 2*(stop-start))
+		for {
+			k, v, err := iter.Next(ctx)
+			if err == io.EOF {
+				break
+			} else if err != nil {
+				return nil, err
+			}
+			tups = append(tups, k, v)
+		}
+		shard, err := prolly.NewMapFromTuples(ctx, m.NodeStore(), kd, vd, tups...)
+		if err != nil {
+			return nil, err
+		}
+		shards[i] = IndexShard{Start: tups[0], Rows: durable.IndexFromProllyMap(shard)}
+	}
+	return shards, nil
+}
+
+// IndexShardFor returns the position in |shards|, as returned by ShardIndex,
+// of the shard that holds |key|. |kd| describes |key|, which may be a prefix
+// of the index keys. The keys with a prefix equal to the prefix of a shard's
+// Start may begin in the shards before it, so prefix scans must also read the
+// end of those shards.
+func IndexShardFor(shards []IndexShard, kd val.TupleDesc, key val.Tuple) int {
+	// the first shard whose Start is greater than |key|
+	i := sort.Search(len(shards), func(i int) bool {
+		return i > 0 && kd.Compare(shards[i].Start, key) > 0
+	})
+	return i - 1
+}
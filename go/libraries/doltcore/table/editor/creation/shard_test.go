@@ -0,0 +1,106 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/dolthub/dolt/go/libraries/doltcore/doltdb/durable"
+	"github.com/dolthub/dolt/go/libraries/doltcore/schema"
+	"github.com/dolthub/dolt/go/store/val"
+)
+
+func TestShardIndex(t *testing.T) {
+	ctx := context.Background()
+	vrw := newTestVRW()
+	sch := newTestSchema(t)
+	idx, err := schema.NewIndexCollection(sch.GetAllCols(), sch.GetPKCols()).AddIndexByColNames(
+		"c1_idx", []string{"c1"}, schema.IndexProperties{})
+	require.NoError(t, err)
+
+	empty, err := BuildSecondaryProllyIndex(ctx, vrw, sch, idx, newTestPrimary(t, ctx, vrw, sch, nil), BuildOptions{})
+	require.NoError(t, err)
+	shards, err := ShardIndex(ctx, empty, 4)
+	require.NoError(t, err)
+	require.Len(t, shards, 1)
+	require.Zero(t, shards[0].Rows.Count())
+
+	var rows [][]interface{}
+	for i := 0; i < 5000; i++ {
+		rows = append(rows, []interface{}{i, (i * 7919) % 1000, nil})
+	}
+	built, err := BuildSecondaryProllyIndex(ctx, vrw, sch, idx, newTestPrimary(t, ctx, vrw, sch, rows), BuildOptions{})
+	require.NoError(t, err)
+	m := durable.ProllyMapFromIndex(built)
+	kd, _ := m.Descriptors()
+	keys := collectKeys(t, ctx, m)
+
+	_, err = ShardIndex(ctx, built, 0)
+	require.Error(t, err)
+
+	shards, err = ShardIndex(ctx, built, 1)
+	require.NoError(t, err)
+	require.Len(t, shards, 1)
+	requireSameIndex(t, built, shards[0].Rows)
+
+	shards, err = ShardIndex(ctx, built, 4)
+	require.NoError(t, err)
+	require.Len(t, shards, 4)
+
+	// the shards are contiguous ranges whose union is the index
+	var union []string
+	for i, shard := range shards {
+		sm := durable.ProllyMapFromIndex(shard.Rows)
+		require.InDelta(t, m.Count()/4, sm.Count(), float64(m.Count()/8))
+		first, err := sm.IterAll(ctx)
+		require.NoError(t, err)
+		k, _, err := first.Next(ctx)
+		require.NoError(t, err)
+		require.Equal(t, shard.Start, k)
+
+		iter, err := sm.IterAll(ctx)
+		require.NoError(t, err)
+		for {
+			k, _, err := iter.Next(ctx)
+			if err != nil {
+				break
+			}
+			require.Equal(t, i, IndexShardFor(shards, kd, k))
+		}
+		union = append(union, collectKeys(t, ctx, sm)...)
+	}
+	require.Equal(t, keys, union)
+
+	// a prefix routes to the last shard that may hold it
+	pd := kd.PrefixDesc(1)
+	pb := val.NewTupleBuilder(pd)
+	pb.PutInt64(0, -1)
+	require.Equal(t, 0, IndexShardFor(shards, pd, pb.Build(sharePool)))
+	pb.PutInt64(0, 1000)
+	require.Equal(t, 3, IndexShardFor(shards, pd, pb.Build(sharePool)))
+
+	// an index has no more shards than leaves
+	shards, err = ShardIndex(ctx, built, m.Count())
+	require.NoError(t, err)
+	require.Less(t, len(shards), m.Count())
+	var n int
+	for _, shard := range shards {
+		n += int(shard.Rows.Count())
+	}
+	require.Equal(t, m.Count(), n)
+}
@@ -0,0 +1,104 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dolthub/dolt/go/libraries/doltcore/doltdb"
+	"github.com/dolthub/dolt/go/libraries/doltcore/doltdb/durable"
+	"github.com/dolthub/dolt/go/libraries/doltcore/schema"
+	"github.com/dolthub/dolt/go/store/prolly/shim"
+	"github.com/dolthub/dolt/go/store/types"
+)
+
+// CopyIdenticalIndex returns the data of the index |srcIndexName| of |src| if it is identical to the data that would
+// be built for the index |idx| of |tbl|, e.g. when |src| is an archive copy of |tbl|. This is the case if both tables
+// have the same primary row data and both indexes map the same row fields to the same key encoding. |ok| is false if
+// the index must be built instead.
+//
+// Index chunks are content-addressed, so an index built from scratch shares its storage with an identical index
+// anyway; copying it only saves the cost of the build. The caller must ensure that the source index was built with the
+// same BuildOptions, such as IndexRowFilter, as |idx| would be.
+func CopyIdenticalIndex(ctx context.Context, tbl *doltdb.Table, idx schema.Index, src *doltdb.Table, srcIndexName string) (rows durable.Index, ok bool, err error) {
+	if !types.IsFormat_DOLT_1(tbl.Format()) || !types.IsFormat_DOLT_1(src.Format()) {
+		return nil, false, nil
+	}
+
+	srcSch, err := src.GetSchema(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	srcIdx := srcSch.Indexes().GetByName(srcIndexName)
+	if srcIdx == nil {
+		return nil, false, fmt.Errorf("index `%s` does not exist", srcIndexName)
+	}
+	if srcIdx.IsDeferred() {
+		return nil, false, nil
+	}
+	sch, err := tbl.GetSchema(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if !shim.KeyDescriptorFromSchema(idx.Schema()).Equals(shim.KeyDescriptorFromSchema(srcIdx.Schema())) {
+		return nil, false, nil
+	}
+	keyMap, err := GetIndexKeyMapping(sch, idx)
+	if err != nil {
+		return nil, false, err
+	}
+	srcKeyMap, err := GetIndexKeyMapping(srcSch, srcIdx)
+	if err != nil {
+		return nil, false, err
+	}
+	for i := range keyMap {
+		if keyMap[i] != srcKeyMap[i] {
+			return nil, false, nil
+		}
+	}
+
+	primary, err := tbl.GetRowData(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	srcPrimary, err := src.GetRowData(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	pkd, pvd := durable.ProllyMapFromIndex(primary).Descriptors()
+	srcPkd, srcPvd := durable.ProllyMapFromIndex(srcPrimary).Descriptors()
+	if !pkd.Equals(srcPkd) || !pvd.Equals(srcPvd) {
+		return nil, false, nil
+	}
+	h, err := primary.HashOf()
+	if err != nil {
+		return nil, false, err
+	}
+	srcH, err := srcPrimary.HashOf()
+	if err != nil {
+		return nil, false, err
+	}
+	if h != srcH {
+		return nil, false, nil
+	}
+
+	rows, err = src.GetIndexRowData(ctx, srcIndexName)
+	if err != nil {
+		return nil, false, err
+	}
+	return rows, true, nil
+}
@@ -0,0 +1,79 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/dolthub/dolt/go/libraries/doltcore/schema"
+)
+
+func TestCopyIdenticalIndex(t *testing.T) {
+	ctx := context.Background()
+	vrw := newTestVRW()
+	sch := newTestSchema(t)
+	rows := [][]interface{}{
+		{1, 30, "a"},
+		{2, 10, "b"},
+	}
+	src, err := CreateIndex(ctx, newTestTable(t, ctx, vrw, sch, rows), "c1_idx", []string{"c1"}, false, true, "", BuildOptions{})
+	require.NoError(t, err)
+	srcRows, err := src.NewTable.GetIndexRowData(ctx, "c1_idx")
+	require.NoError(t, err)
+	srcHash, err := srcRows.HashOf()
+	require.NoError(t, err)
+
+	archive := newTestTable(t, ctx, vrw, newTestSchema(t), rows)
+	archiveSch, err := archive.GetSchema(ctx)
+	require.NoError(t, err)
+	idx, err := archiveSch.Indexes().AddIndexByColNames("archive_c1_idx", []string{"c1"}, schema.IndexProperties{IsUserDefined: true})
+	require.NoError(t, err)
+
+	copied, ok, err := CopyIdenticalIndex(ctx, archive, idx, src.NewTable, "c1_idx")
+	require.NoError(t, err)
+	require.True(t, ok)
+	h, err := copied.HashOf()
+	require.NoError(t, err)
+	require.Equal(t, srcHash, h)
+
+	// the copy is identical to a fresh build
+	built, err := BuildSecondaryIndex(ctx, archive, idx, BuildOptions{})
+	require.NoError(t, err)
+	h, err = built.HashOf()
+	require.NoError(t, err)
+	require.Equal(t, srcHash, h)
+
+	// an index over different columns or different rows is not identical
+	idx, err = archiveSch.Indexes().AddIndexByColNames("archive_c2_idx", []string{"c2"}, schema.IndexProperties{IsUserDefined: true})
+	require.NoError(t, err)
+	_, ok, err = CopyIdenticalIndex(ctx, archive, idx, src.NewTable, "c1_idx")
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	other := newTestTable(t, ctx, vrw, newTestSchema(t), rows[:1])
+	otherSch, err := other.GetSchema(ctx)
+	require.NoError(t, err)
+	idx, err = otherSch.Indexes().AddIndexByColNames("c1_idx", []string{"c1"}, schema.IndexProperties{IsUserDefined: true})
+	require.NoError(t, err)
+	_, ok, err = CopyIdenticalIndex(ctx, other, idx, src.NewTable, "c1_idx")
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	_, _, err = CopyIdenticalIndex(ctx, other, idx, src.NewTable, "missing")
+	require.Error(t, err)
+}
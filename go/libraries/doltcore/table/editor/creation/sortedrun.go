@@ -0,0 +1,302 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"bufio"
+	"bytes"
+	"container/heap"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/dolthub/dolt/go/libraries/doltcore/doltdb/durable"
+	"github.com/dolthub/dolt/go/libraries/doltcore/schema"
+	"github.com/dolthub/dolt/go/store/types"
+	"github.com/dolthub/dolt/go/store/val"
+)
+
+// A sorted run file holds some of the entries of a secondary index, in
+// ascending order of their keys under the index's key descriptor. It starts
+// with the 8 byte header sortedRunMagic, followed by one record per entry in
+// the encoding of WriteIndexEntry. The keys of a run are distinct, and the
+// runs of a build partition its entries, so merging them gives its index data.
+var sortedRunMagic = []byte("DOLTRUN1")
+
+// ErrNotSortedRun is returned when reading a file that does not start with the
+// header of a sorted run file.
+var ErrNotSortedRun = errors.New("file is not a sorted run of index entries")
+
+// defaultSortedRunEntries is the number of entries in each sorted run if
+// BuildOptions.IndexSortedRunEntries is zero.
+const defaultSortedRunEntries = 64 * 1024
+
+// runSpiller spills the entries of an index build to sorted run files in
+// BuildOptions.IndexSortedRunDir.
+type runSpiller struct {
+	idx   schema.Index
+	kd    val.TupleDesc
+	dir   string
+	size  int
+	stats *IndexBuildStats
+	// keys and values are the buffered entries of the next run
+	keys, values []val.Tuple
+	// paths are the run files written so far, in order
+	paths    []string
+	finished bool
+}
+
+// newRunSpiller returns a runSpiller for a build of |idx|, whose keys are
+// described by |kd|, or nil if BuildOptions.IndexSortedRunDir is not set.
+func newRunSpiller(idx schema.Index, kd val.TupleDesc, opts BuildOptions) (*runSpiller, error) {
+	if opts.IndexSortedRunDir == "" {
+		return nil, nil
+	}
+	size := opts.IndexSortedRunEntries
+	if size == 0 {
+		size = defaultSortedRunEntries
+	}
+	return &runSpiller{idx: idx, kd: kd, dir: opts.IndexSortedRunDir, size: size, stats: opts.IndexBuildStats}, nil
+}
+
+// put buffers the entry |k|, |v|, spilling the buffered entries to a run file
+// once there are enough of them.
+func (s *runSpiller) put(k, v val.Tuple) error {
+	if s == nil {
+		return nil
+	}
+	// tuples may be built from a pool that reuses their buffers
+	s.keys = append(s.keys, append(val.Tuple(nil), k...))
+	s.values = append(s.values, append(val.Tuple(nil), v...))
+	if len(s.keys) < s.size {
+		return nil
+	}
+	return s.spill()
+}
+
+// spill writes the buffered entries to a new run file, in key order.
+func (s *runSpiller) spill() (err error) {
+	sort.Sort(runEntries{s})
+	f, err := os.CreateTemp(s.dir, s.idx.Name()+"-*.run")
+	if err != nil {
+		return err
+	}
+	s.paths = append(s.paths, f.Name())
+	defer func() {
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+	}()
+
+	w := bufio.NewWriter(f)
+	if _, err = w.Write(sortedRunMagic); err != nil {
+		return err
+	}
+	for i := range s.keys {
+		if err = WriteIndexEntry(w, s.keys[i], s.values[i]); err != nil {
+			return err
+		}
+	}
+	s.keys, s.values = s.keys[:0], s.values[:0]
+	return w.Flush()
+}
+
+// finish spills the remaining buffered entries and reports the run files to
+// BuildOptions.IndexBuildStats.
+func (s *runSpiller) finish() error {
+	if s == nil {
+		return nil
+	}
+	if len(s.keys) > 0 {
+		if err := s.spill(); err != nil {
+			return err
+		}
+	}
+	s.finished = true
+	if s.stats != nil {
+		s.stats.SortedRuns = s.paths
+	}
+	return nil
+}
+
+// abort removes the run files of a build that failed before it finished.
+func (s *runSpiller) abort() {
+	if s == nil || s.finished {
+		return
+	}
+	for _, path := range s.paths {
+		_ = os.Remove(path)
+	}
+}
+
+// runEntries sorts the buffered entries of a runSpiller by key.
+type runEntries struct {
+	s *runSpiller
+}
+
+func (r runEntries) Len() int {
+	return len(r.s.keys)
+}
+
+func (r runEntries) Less(i, j int) bool {
+	return r.s.kd.Compare(r.s.keys[i], r.s.keys[j]) < 0
+}
+
+func (r runEntries) Swap(i, j int) {
+	r.s.keys[i], r.s.keys[j] = r.s.keys[j], r.s.keys[i]
+	r.s.values[i], r.s.values[j] = r.s.values[j], r.s.values[i]
+}
+
+// SortedRunReader reads the entries of a sorted run file in key order.
+type SortedRunReader struct {
+	f *os.File
+	r *bufio.Reader
+}
+
+// OpenSortedRun opens the sorted run file at |path|, returning ErrNotSortedRun
+// if it does not start with the header of a sorted run.
+func OpenSortedRun(path string) (*SortedRunReader, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	r := bufio.NewReader(f)
+	hdr := make([]byte, len(sortedRunMagic))
+	if _, err = io.ReadFull(r, hdr); err != nil || !bytes.Equal(hdr, sortedRunMagic) {
+		f.Close()
+		if err == nil || err == io.EOF || err == io.ErrUnexpectedEOF {
+			err = fmt.Errorf("%w: %s", ErrNotSortedRun, path)
+		}
+		return nil, err
+	}
+	return &SortedRunReader{f: f, r: r}, nil
+}
+
+// Next returns the next entry of the run, or io.EOF once all of its entries
+// have been read.
+func (r *SortedRunReader) Next() (k, v val.Tuple, err error) {
+	return ReadIndexEntry(r.r)
+}
+
+// Close closes the run file.
+func (r *SortedRunReader) Close() error {
+	return r.f.Close()
+}
+
+// MergeSortedRuns builds the data of |idx| of |sch| by merging the sorted run
+// files at |paths|, spilled by builds of |idx| with |opts|. The entries of all
+// the runs must have distinct keys; a key found in more than one run fails the
+// merge with ErrIndexKeyOrder. The run files are not removed.
+func MergeSortedRuns(ctx context.Context, vrw types.ValueReadWriter, sch schema.Schema, idx schema.Index, opts BuildOptions, paths ...string) (durable.Index, error) {
+	if !types.IsFormat_DOLT_1(vrw.Format()) {
+		return nil, fmt.Errorf("merging sorted runs is not supported for format %s", vrw.Format().VersionString())
+	}
+	secondary, err := newSecondaryMap(ctx, vrw, sch, idx, opts)
+	if err != nil {
+		return nil, err
+	}
+	kd, _ := secondary.Descriptors()
+
+	h := &runHeap{kd: kd}
+	defer func() {
+		for _, e := range h.runs {
+			e.r.Close()
+		}
+	}()
+	for _, path := range paths {
+		r, err := OpenSortedRun(path)
+		if err != nil {
+			return nil, err
+		}
+		e := &runHead{r: r}
+		if err = e.next(); err == io.EOF {
+			r.Close()
+			continue
+		} else if err != nil {
+			r.Close()
+			return nil, err
+		}
+		h.runs = append(h.runs, e)
+	}
+	heap.Init(h)
+
+	app, err := newAppendIndexBuilder(ctx, secondary)
+	if err != nil {
+		return nil, err
+	}
+	app.strict = true
+	for h.Len() > 0 {
+		e := h.runs[0]
+		if _, err = app.put(ctx, e.k, e.v); err != nil {
+			return nil, err
+		}
+		if err = e.next(); err == io.EOF {
+			heap.Pop(h)
+			e.r.Close()
+		} else if err != nil {
+			return nil, err
+		} else {
+			heap.Fix(h, 0)
+		}
+	}
+
+	secondary, err = app.build(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return durable.IndexFromProllyMap(secondary), nil
+}
+
+// runHead is a sorted run being merged, and its next entry.
+type runHead struct {
+	r    *SortedRunReader
+	k, v val.Tuple
+}
+
+func (e *runHead) next() (err error) {
+	e.k, e.v, err = e.r.Next()
+	return err
+}
+
+// runHeap orders sorted runs being merged by their next keys.
+type runHeap struct {
+	kd   val.TupleDesc
+	runs []*runHead
+}
+
+func (h *runHeap) Len() int {
+	return len(h.runs)
+}
+
+func (h *runHeap) Less(i, j int) bool {
+	return h.kd.Compare(h.runs[i].k, h.runs[j].k) < 0
+}
+
+func (h *runHeap) Swap(i, j int) {
+	h.runs[i], h.runs[j] = h.runs[j], h.runs[i]
+}
+
+func (h *runHeap) Push(x interface{}) {
+	h.runs = append(h.runs, x.(*runHead))
+}
+
+func (h *runHeap) Pop() interface{} {
+	e := h.runs[len(h.runs)-1]
+	h.runs = h.runs[:len(h.runs)-1]
+	return e
+}
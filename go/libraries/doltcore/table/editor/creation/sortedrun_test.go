@@ -0,0 +1,127 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/dolthub/dolt/go/libraries/doltcore/doltdb/durable"
+	"github.com/dolthub/dolt/go/libraries/doltcore/schema"
+	"github.com/dolthub/dolt/go/store/val"
+)
+
+func TestSortedRuns(t *testing.T) {
+	ctx := context.Background()
+	vrw := newTestVRW()
+	sch := newTestSchema(t)
+	var rows [][]interface{}
+	for i := 0; i < 1000; i++ {
+		rows = append(rows, []interface{}{i, (i * 7) % 100, "row"})
+	}
+	primary := newTestPrimary(t, ctx, vrw, sch, rows)
+	coll := schema.NewIndexCollection(sch.GetAllCols(), sch.GetPKCols())
+	idx, err := coll.AddIndexByColNames("c1_idx", []string{"c1"}, schema.IndexProperties{})
+	require.NoError(t, err)
+	uniq, err := coll.AddIndexByColNames("c1_pk_uniq", []string{"c1", "pk"}, schema.IndexProperties{IsUnique: true})
+	require.NoError(t, err)
+
+	pipelined := BuildOptions{ExecutionOptions: ExecutionOptions{UniqueIndexCheckWorkers: 2}}
+	for _, tc := range []struct {
+		idx  schema.Index
+		opts BuildOptions
+	}{
+		{idx, BuildOptions{}},
+		{uniq, BuildOptions{}},
+		{uniq, pipelined},
+	} {
+		expected, err := BuildSecondaryProllyIndex(ctx, vrw, sch, tc.idx, primary, tc.opts)
+		require.NoError(t, err)
+
+		var stats IndexBuildStats
+		opts := tc.opts
+		opts.IndexSortedRunDir = t.TempDir()
+		opts.IndexSortedRunEntries = 128
+		opts.IndexBuildStats = &stats
+		actual, err := BuildSecondaryProllyIndex(ctx, vrw, sch, tc.idx, primary, opts)
+		require.NoError(t, err)
+		requireSameIndex(t, expected, actual)
+		require.Len(t, stats.SortedRuns, 8)
+
+		// each run holds its entries in key order
+		kd, _ := durable.ProllyMapFromIndex(expected).Descriptors()
+		total := 0
+		for _, path := range stats.SortedRuns {
+			require.Equal(t, opts.IndexSortedRunDir, filepath.Dir(path))
+			r, err := OpenSortedRun(path)
+			require.NoError(t, err)
+			var last val.Tuple
+			for {
+				k, _, err := r.Next()
+				if err == io.EOF {
+					break
+				}
+				require.NoError(t, err)
+				if last != nil {
+					require.Less(t, kd.Compare(last, k), 0)
+				}
+				last = k
+				total++
+			}
+			require.NoError(t, r.Close())
+		}
+		require.Equal(t, len(rows), total)
+
+		// merging the runs gives the index data
+		merged, err := MergeSortedRuns(ctx, vrw, sch, tc.idx, tc.opts, stats.SortedRuns...)
+		require.NoError(t, err)
+		requireSameIndex(t, expected, merged)
+
+		// a run merged twice has duplicate keys
+		_, err = MergeSortedRuns(ctx, vrw, sch, tc.idx, tc.opts, append(stats.SortedRuns, stats.SortedRuns[0])...)
+		require.ErrorIs(t, err, ErrIndexKeyOrder)
+	}
+
+	// a failed build removes its runs
+	dir := t.TempDir()
+	dupRows := append(rows, []interface{}{1000, 0, "row"})
+	c1Uniq, err := coll.AddIndexByColNames("c1_uniq", []string{"c1"}, schema.IndexProperties{IsUnique: true})
+	require.NoError(t, err)
+	_, err = BuildSecondaryProllyIndex(ctx, vrw, sch, c1Uniq, newTestPrimary(t, ctx, vrw, sch, dupRows), BuildOptions{
+		ExecutionOptions: ExecutionOptions{
+			IndexSortedRunDir:     dir,
+			IndexSortedRunEntries: 10,
+		},
+	})
+	require.Error(t, err)
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Empty(t, entries)
+
+	bad := filepath.Join(dir, "bad.run")
+	require.NoError(t, os.WriteFile(bad, []byte("not a run"), 0644))
+	_, err = OpenSortedRun(bad)
+	require.ErrorIs(t, err, ErrNotSortedRun)
+	_, err = MergeSortedRuns(ctx, vrw, sch, idx, BuildOptions{}, bad)
+	require.ErrorIs(t, err, ErrNotSortedRun)
+
+	_, err = BuildSecondaryProllyIndex(ctx, vrw, sch, idx, primary, BuildOptions{ExecutionOptions: ExecutionOptions{IndexSortedRunDir: dir, IndexSortedRunEntries: -1}})
+	require.Error(t, err)
+}
@@ -0,0 +1,128 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+
+	"github.com/dolthub/dolt/go/libraries/doltcore/doltdb/durable"
+	"github.com/dolthub/dolt/go/store/prolly"
+	"github.com/dolthub/dolt/go/store/val"
+)
+
+// SortKeyFunc returns the sort key of the ith field of |tup|. Sort keys are
+// compared bytewise, so a SortKeyFunc defines an ordering as long as it is
+// deterministic. A nil sort key is NULL and sorts last, as NULL index keys do.
+type SortKeyFunc func(d val.TupleDesc, i int, tup val.Tuple) []byte
+
+// BuildSortKeyIndex builds an index ordered by the sort key of the leading
+// column of the secondary index data |rows|, as returned by |sortKey|. The key
+// of each entry is the sort key followed by the complete secondary index key,
+// so entries with equal sort keys keep the order of |rows|. Tombstones in
+// |rows| are skipped.
+func BuildSortKeyIndex(ctx context.Context, rows durable.Index, sortKey SortKeyFunc) (durable.Index, error) {
+	m := durable.ProllyMapFromIndex(rows)
+	kd, _ := m.Descriptors()
+	skd := val.NewTupleDescriptor(append([]val.Type{{Enc: val.ByteStringEnc, Nullable: true}}, kd.Types...)...)
+	empty, err := prolly.NewMapFromTuples(ctx, m.NodeStore(), skd, val.NewTupleDescriptor())
+	if err != nil {
+		return nil, err
+	}
+
+	all, err := m.IterAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	iter := NewTombstoneSkippingIter(all)
+
+	kb := val.NewTupleBuilder(skd)
+	mut := empty.Mutate()
+	for {
+		k, v, err := iter.Next(ctx)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		if sk := sortKey(kd, 0, k); sk != nil {
+			kb.PutByteString(0, sk)
+		}
+		for i := 0; i < k.Count(); i++ {
+			kb.PutRaw(i+1, k.GetField(i))
+		}
+		if err = mut.Put(ctx, kb.BuildPermissive(m.Pool()), v); err != nil {
+			return nil, err
+		}
+	}
+
+	sorted, err := mut.Map(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return durable.IndexFromProllyMap(sorted), nil
+}
+
+// Tags of the runs of a NaturalSortKey. Numbers sort before text.
+const (
+	naturalNumber byte = 1
+	naturalText   byte = 2
+)
+
+// NaturalSortKey is a SortKeyFunc for string fields that orders runs of digits
+// by their numeric value, so that "1.9" < "1.10" and "v2" < "v10". Leading
+// zeros are ignored, so "01" and "1" have equal sort keys.
+var NaturalSortKey SortKeyFunc = func(d val.TupleDesc, i int, tup val.Tuple) []byte {
+	s, ok := d.GetString(i, tup)
+	if !ok {
+		return nil
+	}
+
+	var sk []byte
+	for len(s) > 0 {
+		n := 0
+		if isDigit(s[0]) {
+			for n < len(s) && isDigit(s[n]) {
+				n++
+			}
+			digits := s[:n]
+			for len(digits) > 1 && digits[0] == '0' {
+				digits = digits[1:]
+			}
+			// a longer number is a larger number
+			var l [4]byte
+			binary.BigEndian.PutUint32(l[:], uint32(len(digits)))
+			sk = append(sk, naturalNumber)
+			sk = append(sk, l[:]...)
+			sk = append(sk, digits...)
+		} else {
+			for n < len(s) && !isDigit(s[n]) {
+				n++
+			}
+			// the terminator sorts a shorter run before a longer one
+			sk = append(sk, naturalText)
+			sk = append(sk, s[:n]...)
+			sk = append(sk, 0)
+		}
+		s = s[n:]
+	}
+	return sk
+}
+
+func isDigit(b byte) bool {
+	return '0' <= b && b <= '9'
+}
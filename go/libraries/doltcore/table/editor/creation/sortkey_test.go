@@ -0,0 +1,89 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/dolthub/dolt/go/libraries/doltcore/doltdb/durable"
+	"github.com/dolthub/dolt/go/libraries/doltcore/schema"
+	"github.com/dolthub/dolt/go/store/val"
+)
+
+func TestNaturalSortKey(t *testing.T) {
+	d := val.NewTupleDescriptor(val.Type{Enc: val.StringEnc, Nullable: true})
+	sortKey := func(s string) []byte {
+		tb := val.NewTupleBuilder(d)
+		tb.PutString(0, s)
+		return NaturalSortKey(d, 0, tb.Build(sharePool))
+	}
+
+	ordered := []string{"", "1", "1.9", "1.10", "1.10.1", "1.10a", "2", "10", "v2", "v10", "va"}
+	for i := 1; i < len(ordered); i++ {
+		require.Equal(t, -1, bytes.Compare(sortKey(ordered[i-1]), sortKey(ordered[i])), "%q < %q", ordered[i-1], ordered[i])
+	}
+	require.Equal(t, sortKey("v01"), sortKey("v1"))
+	require.Equal(t, sortKey("0"), sortKey("00"))
+}
+
+func TestBuildSortKeyIndex(t *testing.T) {
+	ctx := context.Background()
+	vrw := newTestVRW()
+	sch := newTestSchema(t)
+	idx, err := schema.NewIndexCollection(sch.GetAllCols(), sch.GetPKCols()).AddIndexByColNames(
+		"c2_idx", []string{"c2"}, schema.IndexProperties{})
+	require.NoError(t, err)
+
+	primary := newTestPrimary(t, ctx, vrw, sch, [][]interface{}{
+		{1, 0, "1.10"},
+		{2, 0, "1.9"},
+		{3, 0, nil},
+		{4, 0, "1.2"},
+		{5, 0, "10.0"},
+		{6, 0, "1.9"},
+	})
+	rows, err := BuildSecondaryProllyIndex(ctx, vrw, sch, idx, primary, BuildOptions{})
+	require.NoError(t, err)
+	sorted, err := BuildSortKeyIndex(ctx, rows, NaturalSortKey)
+	require.NoError(t, err)
+
+	m := durable.ProllyMapFromIndex(sorted)
+	kd, _ := m.Descriptors()
+	iter, err := m.IterAll(ctx)
+	require.NoError(t, err)
+	var versions []interface{}
+	var pks []int64
+	for {
+		k, _, err := iter.Next(ctx)
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		if s, ok := kd.GetString(1, k); ok {
+			versions = append(versions, s)
+		} else {
+			versions = append(versions, nil)
+		}
+		pk, _ := kd.GetInt64(2, k)
+		pks = append(pks, pk)
+	}
+	require.Equal(t, []interface{}{"1.2", "1.9", "1.9", "1.10", "10.0", nil}, versions)
+	require.Equal(t, []int64{4, 2, 6, 1, 5, 3}, pks)
+}
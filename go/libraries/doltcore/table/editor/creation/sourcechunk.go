@@ -0,0 +1,112 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"context"
+	"errors"
+
+	"github.com/dolthub/dolt/go/store/hash"
+	"github.com/dolthub/dolt/go/store/pool"
+	"github.com/dolthub/dolt/go/store/prolly"
+	"github.com/dolthub/dolt/go/store/prolly/tree"
+	"github.com/dolthub/dolt/go/store/val"
+)
+
+// errNoSourceChunks is returned by builds with
+// BuildOptions.RecordSourceChunkInIndex or
+// BuildOptions.RecordRowLocatorInIndex whose rows are not read from a
+// primary index.
+var errNoSourceChunks = errors.New("source chunks can only be recorded by index builds that scan a primary index")
+
+// sourceChunkValueDesc describes the values of an index built with
+// BuildOptions.RecordSourceChunkInIndex: a single field holding the 20 byte
+// address of the primary leaf chunk of the entry's row.
+var sourceChunkValueDesc = val.NewTupleDescriptor(val.Type{Enc: val.ByteStringEnc})
+
+// IndexSourceChunk returns the address of the primary leaf chunk recorded in
+// |v|, the value of an entry of an index built with
+// BuildOptions.RecordSourceChunkInIndex. The address is not a reference:
+// the chunk is not retained by the index, and can be collected once the
+// primary index no longer holds it.
+func IndexSourceChunk(v val.Tuple) (hash.Hash, bool) {
+	b, ok := sourceChunkValueDesc.GetBytes(0, v)
+	if !ok || len(b) != hash.ByteLen {
+		return hash.Hash{}, false
+	}
+	return hash.New(b), true
+}
+
+// sourceChunkIter iterates a primary index, tracking the leaf chunk of the
+// current row and its position within it.
+type sourceChunkIter struct {
+	iter prolly.MapIter
+	// leaves holds the address and entry count of the leaves of the primary
+	// index, in order
+	leaves []hash.Hash
+	counts []int
+	i, n   int
+	vb     *val.TupleBuilder
+	// locate is set if values also record the position of the row in its leaf
+	locate bool
+}
+
+var _ prolly.MapIter = &sourceChunkIter{}
+
+// iterPrimary returns an iterator over all of the rows of |primary|, which
+// tracks the leaf chunk of each row if BuildOptions.RecordSourceChunkInIndex
+// or BuildOptions.RecordRowLocatorInIndex is set. The leaves are found by
+// walking the tree of |primary| before it is iterated.
+func iterPrimary(ctx context.Context, primary prolly.Map, opts BuildOptions) (prolly.MapIter, error) {
+	iter, err := primary.IterAll(ctx)
+	if err != nil || (!opts.RecordSourceChunkInIndex && !opts.RecordRowLocatorInIndex) {
+		return iter, err
+	}
+	sc := &sourceChunkIter{iter: iter, i: -1, vb: val.NewTupleBuilder(sourceChunkValueDesc)}
+	if opts.RecordRowLocatorInIndex {
+		sc.vb, sc.locate = val.NewTupleBuilder(rowLocatorValueDesc), true
+	}
+	err = primary.WalkNodes(ctx, func(ctx context.Context, nd tree.Node) error {
+		if nd.IsLeaf() && nd.Count() > 0 {
+			sc.leaves = append(sc.leaves, nd.HashOf())
+			sc.counts = append(sc.counts, nd.Count())
+		}
+		return nil
+	})
+	return sc, err
+}
+
+// Next implements prolly.MapIter.
+func (itr *sourceChunkIter) Next(ctx context.Context) (val.Tuple, val.Tuple, error) {
+	k, v, err := itr.iter.Next(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	if itr.i < 0 || itr.n == itr.counts[itr.i] {
+		itr.i, itr.n = itr.i+1, 0
+	}
+	itr.n++
+	return k, v, nil
+}
+
+// value returns the index value recording the leaf chunk of the current row,
+// and its position within the leaf if the iterator locates rows.
+func (itr *sourceChunkIter) value(p pool.BuffPool) val.Tuple {
+	itr.vb.PutByteString(0, itr.leaves[itr.i][:])
+	if itr.locate {
+		itr.vb.PutUint32(1, uint32(itr.n-1))
+	}
+	return itr.vb.Build(p)
+}
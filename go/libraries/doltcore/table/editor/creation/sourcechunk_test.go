@@ -0,0 +1,120 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/dolthub/dolt/go/libraries/doltcore/doltdb/durable"
+	"github.com/dolthub/dolt/go/libraries/doltcore/schema"
+	"github.com/dolthub/dolt/go/store/val"
+)
+
+func TestRecordSourceChunkInIndex(t *testing.T) {
+	ctx := context.Background()
+	vrw := newTestVRW()
+	sch := newTestSchema(t)
+	coll := schema.NewIndexCollection(sch.GetAllCols(), sch.GetPKCols())
+
+	var rows [][]interface{}
+	for i := 0; i < 3000; i++ {
+		rows = append(rows, []interface{}{i, i % 10, "row"})
+	}
+	primary := newTestPrimary(t, ctx, vrw, sch, rows)
+	require.Greater(t, primary.Height(), 1)
+	pkd, _ := primary.Descriptors()
+	opts := BuildOptions{ValueLayoutOptions: ValueLayoutOptions{RecordSourceChunkInIndex: true}}
+
+	for _, unique := range []bool{false, true} {
+		cols := []string{"c1"}
+		if unique {
+			cols = []string{"c1", "pk"}
+		}
+		idx, err := coll.AddIndexByColNames("src_idx", cols, schema.IndexProperties{IsUnique: unique})
+		require.NoError(t, err)
+		built, err := BuildSecondaryProllyIndex(ctx, vrw, sch, idx, primary, opts)
+		require.NoError(t, err)
+		m := durable.ProllyMapFromIndex(built)
+		require.Equal(t, primary.Count(), m.Count())
+		kd, _ := m.Descriptors()
+
+		// every entry's address is a leaf of the primary index holding its row
+		chunks := make(map[string]struct{})
+		iter, err := m.IterAll(ctx)
+		require.NoError(t, err)
+		pkb := val.NewTupleBuilder(pkd)
+		for {
+			k, v, err := iter.Next(ctx)
+			if err == io.EOF {
+				break
+			}
+			require.NoError(t, err)
+			addr, ok := IndexSourceChunk(v)
+			require.True(t, ok)
+			chunks[addr.String()] = struct{}{}
+
+			nd, err := primary.NodeStore().Read(ctx, addr)
+			require.NoError(t, err)
+			require.True(t, nd.IsLeaf())
+			pk, ok := kd.GetInt64(kd.Count()-1, k)
+			require.True(t, ok)
+			pkb.PutInt64(0, pk)
+			want := pkb.Build(sharePool)
+			found := false
+			for i := 0; i < nd.Count(); i++ {
+				if pkd.Compare(val.Tuple(nd.GetKey(i)), want) == 0 {
+					found = true
+					break
+				}
+			}
+			require.True(t, found, "row %d is not in its source chunk", pk)
+		}
+		require.Greater(t, len(chunks), 1)
+
+		_, err = coll.RemoveIndex("src_idx")
+		require.NoError(t, err)
+	}
+
+	idx, err := coll.AddIndexByColNames("src_idx", []string{"c1"}, schema.IndexProperties{})
+	require.NoError(t, err)
+
+	// rows that are not read from a primary index have no source chunk
+	iter, err := primary.IterAll(ctx)
+	require.NoError(t, err)
+	_, err = BuildSecondaryProllyIndexFromIter(ctx, vrw, sch, idx, iter, opts)
+	require.ErrorIs(t, err, errNoSourceChunks)
+
+	_, err = BuildSecondaryProllyIndex(ctx, vrw, sch, idx, primary, BuildOptions{ValueLayoutOptions: ValueLayoutOptions{RecordSourceChunkInIndex: true, MirrorPrimaryRowInIndex: true}})
+	require.Error(t, err)
+	_, err = BuildSecondaryProllyIndex(ctx, vrw, sch, idx, primary, BuildOptions{
+		ValueLayoutOptions: ValueLayoutOptions{
+			RecordSourceChunkInIndex: true,
+		},
+		ExecutionOptions: ExecutionOptions{
+			IndexBuildCheckpoints:    NewFileCheckpointStore(t.TempDir()),
+			IndexBuildCheckpointRows: 100,
+		},
+	})
+	require.Error(t, err)
+
+	// the value layout is not stored with the index, so DML would write the
+	// values of an ordinary index
+	_, err = CreateIndex(ctx, newTestTable(t, ctx, vrw, newTestSchema(t), rows[:10]), "src_idx", []string{"c1"}, false, true, "", opts)
+	require.Error(t, err)
+}
@@ -0,0 +1,110 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dolthub/dolt/go/libraries/doltcore/doltdb"
+	"github.com/dolthub/dolt/go/libraries/doltcore/doltdb/durable"
+	"github.com/dolthub/dolt/go/libraries/doltcore/schema"
+	"github.com/dolthub/dolt/go/libraries/doltcore/schema/encoding"
+	"github.com/dolthub/dolt/go/store/hash"
+	"github.com/dolthub/dolt/go/store/types"
+)
+
+// BuildSecondaryIndexWithSchema builds the data of |idx|, an index defined
+// against the schema |sch|, for |tbl| like BuildSecondaryIndex. If |sch| is not
+// the schema stored in |tbl|, e.g. because the table was altered after |idx|
+// was defined, the build returns an ErrIndexSchemaMismatch, or, with
+// BuildOptions.RebuildOnSchemaMismatch, builds the index over the same
+// columns of the table's schema instead. Schemas are compared by hash, so any
+// change to the stored schema, including to its indexes, is a mismatch.
+// Returns the definition of the index
+// that was built, which is |idx| unless it was redefined against the table's
+// schema.
+func BuildSecondaryIndexWithSchema(ctx context.Context, tbl *doltdb.Table, sch schema.Schema, idx schema.Index, opts BuildOptions) (durable.Index, schema.Index, error) {
+	want, err := schemaHash(ctx, tbl.ValueReadWriter(), sch)
+	if err != nil {
+		return nil, nil, err
+	}
+	have, err := tbl.GetSchemaHash(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	if want != have {
+		if !opts.RebuildOnSchemaMismatch {
+			return nil, nil, fmt.Errorf("%w: index `%s` was defined against schema %s, but the table's schema is %s",
+				ErrIndexSchemaMismatch, idx.Name(), want, have)
+		}
+		current, err := tbl.GetSchema(ctx)
+		if err != nil {
+			return nil, nil, err
+		}
+		if idx, err = redefineIndex(current, idx); err != nil {
+			return nil, nil, err
+		}
+	}
+	rows, err := BuildSecondaryIndex(ctx, tbl, idx, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	return rows, idx, nil
+}
+
+// schemaHash returns the hash of |sch| as it would be stored in a table.
+func schemaHash(ctx context.Context, vrw types.ValueReadWriter, sch schema.Schema) (hash.Hash, error) {
+	v, err := encoding.MarshalSchemaAsNomsValue(ctx, vrw, sch)
+	if err != nil {
+		return hash.Hash{}, err
+	}
+	return v.Hash(vrw.Format())
+}
+
+// redefineIndex returns an index of |sch| with the name, columns and
+// properties of |idx|, an index of another schema of the same table. The index
+// belongs to a scratch index collection, leaving |sch| unchanged.
+func redefineIndex(sch schema.Schema, idx schema.Index) (schema.Index, error) {
+	cols := idx.ColumnNames()
+	for _, name := range cols {
+		if _, ok := sch.GetAllCols().GetByName(name); !ok {
+			return nil, fmt.Errorf("%w: column `%s` of index `%s` no longer exists", ErrIndexSchemaMismatch, name, idx.Name())
+		}
+	}
+	// the primary key suffix order names the columns of the old schema by tag
+	var pkSuffix []uint64
+	for _, tag := range idx.PkSuffixOrder() {
+		col, _ := idx.GetColumn(tag)
+		cur, ok := sch.GetPKCols().GetByName(col.Name)
+		if !ok {
+			pkSuffix = nil
+			break
+		}
+		pkSuffix = append(pkSuffix, cur.Tag)
+	}
+	coll := schema.NewIndexCollection(sch.GetAllCols(), sch.GetPKCols())
+	return coll.AddIndexByColNames(idx.Name(), cols, schema.IndexProperties{
+		IsUnique:       idx.IsUnique(),
+		IsUserDefined:  idx.IsUserDefined(),
+		Comment:        idx.Comment(),
+		PkSuffixOrder:  pkSuffix,
+		TimeBucket:     idx.TimeBucket(),
+		ReverseStrings: idx.ReverseStrings(),
+		Normalization:  idx.Normalization(),
+		SamplePercent:  idx.SamplePercent(),
+		SampleSeed:     idx.SampleSeed(),
+	})
+}
@@ -0,0 +1,75 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/dolthub/dolt/go/libraries/doltcore/schema"
+	"github.com/dolthub/dolt/go/store/types"
+)
+
+func TestBuildSecondaryIndexWithSchema(t *testing.T) {
+	ctx := context.Background()
+	vrw := newTestVRW()
+	sch := newTestSchema(t)
+	rows := [][]interface{}{{1, 30, "c"}, {2, 10, "a"}, {3, 20, "b"}, {4, nil, nil}}
+	tbl := newTestTable(t, ctx, vrw, sch, rows)
+	idx, err := schema.NewIndexCollection(sch.GetAllCols(), sch.GetPKCols()).AddIndexByColNames(
+		"c1_idx", []string{"c1"}, schema.IndexProperties{IsUnique: true, Comment: "comment"})
+	require.NoError(t, err)
+	expected, err := BuildSecondaryIndex(ctx, tbl, idx, BuildOptions{})
+	require.NoError(t, err)
+
+	// the schema matches the table's
+	actual, built, err := BuildSecondaryIndexWithSchema(ctx, tbl, sch, idx, BuildOptions{})
+	require.NoError(t, err)
+	require.Same(t, idx, built)
+	requireSameIndex(t, expected, actual)
+
+	// the table is altered after the index was defined
+	altered := newTestSchema(t)
+	_, err = altered.Indexes().AddIndexByColNames("c2_idx", []string{"c2"}, schema.IndexProperties{})
+	require.NoError(t, err)
+	alteredTbl, err := tbl.UpdateSchema(ctx, altered)
+	require.NoError(t, err)
+	_, _, err = BuildSecondaryIndexWithSchema(ctx, alteredTbl, sch, idx, BuildOptions{})
+	require.ErrorIs(t, err, ErrIndexSchemaMismatch)
+
+	// the index is redefined against the table's schema
+	opts := BuildOptions{ExecutionOptions: ExecutionOptions{RebuildOnSchemaMismatch: true}}
+	actual, built, err = BuildSecondaryIndexWithSchema(ctx, alteredTbl, sch, idx, opts)
+	require.NoError(t, err)
+	require.NotSame(t, idx, built)
+	require.Equal(t, idx.Name(), built.Name())
+	require.Equal(t, "comment", built.Comment())
+	require.True(t, schema.IndexesAreDataCompatible(idx, built))
+	requireSameIndex(t, expected, actual)
+
+	// an index cannot be redefined over a column that no longer exists
+	renamed, err := schema.SchemaFromCols(schema.NewColCollection(
+		schema.NewColumn("pk", pkTag, types.IntKind, true, schema.NotNullConstraint{}),
+		schema.NewColumn("c3", c1Tag, types.IntKind, false),
+		schema.NewColumn("c2", c2Tag, types.StringKind, false),
+	))
+	require.NoError(t, err)
+	renamedTbl, err := tbl.UpdateSchema(ctx, renamed)
+	require.NoError(t, err)
+	_, _, err = BuildSecondaryIndexWithSchema(ctx, renamedTbl, sch, idx, opts)
+	require.ErrorIs(t, err, ErrIndexSchemaMismatch)
+}
@@ -0,0 +1,110 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/dolthub/dolt/go/libraries/doltcore/doltdb/durable"
+	"github.com/dolthub/dolt/go/libraries/doltcore/schema"
+	"github.com/dolthub/dolt/go/store/types"
+	"github.com/dolthub/dolt/go/store/val"
+)
+
+// Index entries are streamed as a sequence of records, one per entry. Each record is the entry's key tuple followed
+// by its value tuple, each preceded by its length in bytes as a big-endian uint32. Tuples are in their stored val
+// encoding, as described by the index's key and value descriptors.
+
+// WriteIndexEntry writes the index entry |k|, |v| to |w|. Writers are not buffered, so callers streaming many entries
+// should wrap |w| in a bufio.Writer.
+func WriteIndexEntry(w io.Writer, k, v val.Tuple) error {
+	var hdr [4]byte
+	for _, t := range []val.Tuple{k, v} {
+		binary.BigEndian.PutUint32(hdr[:], uint32(len(t)))
+		if _, err := w.Write(hdr[:]); err != nil {
+			return err
+		}
+		if _, err := w.Write(t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadIndexEntry reads an index entry written by WriteIndexEntry from |r|. It returns io.EOF if |r| has no more
+// entries, and io.ErrUnexpectedEOF if an entry is truncated.
+func ReadIndexEntry(r io.Reader) (k, v val.Tuple, err error) {
+	if k, err = readTuple(r); err != nil {
+		return nil, nil, err
+	}
+	if v, err = readTuple(r); err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return k, v, nil
+}
+
+func readTuple(r io.Reader) (val.Tuple, error) {
+	var hdr [4]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return nil, err
+	}
+	t := make(val.Tuple, binary.BigEndian.Uint32(hdr[:]))
+	if _, err := io.ReadFull(r, t); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return t, nil
+}
+
+// LoadIndexEntries builds the data of |idx| from the index entries streamed to |r|, e.g. by a build with an
+// BuildOptions IndexEntryWriter. Entries may be in any order.
+func LoadIndexEntries(ctx context.Context, vrw types.ValueReadWriter, idx schema.Index, r io.Reader) (durable.Index, error) {
+	if !types.IsFormat_DOLT_1(vrw.Format()) {
+		return nil, fmt.Errorf("loading index entries is not supported for format %s", vrw.Format().VersionString())
+	}
+
+	empty, err := durable.NewEmptyIndex(ctx, vrw, idx.Schema())
+	if err != nil {
+		return nil, err
+	}
+	secondary := durable.ProllyMapFromIndex(empty)
+	mut := secondary.Mutate()
+	for {
+		k, v, err := ReadIndexEntry(r)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err = mut.Put(ctx, k, v); err != nil {
+			return nil, err
+		}
+	}
+
+	secondary, err = mut.Map(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return durable.IndexFromProllyMap(secondary), nil
+}
@@ -0,0 +1,54 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/dolthub/dolt/go/libraries/doltcore/doltdb/durable"
+)
+
+func TestStreamIndexEntries(t *testing.T) {
+	ctx := context.Background()
+	vrw := newTestVRW()
+	sch := newTestSchema(t)
+	tbl := newTestTable(t, ctx, vrw, sch, [][]interface{}{
+		{1, 30, "a"},
+		{2, 10, "b"},
+		{3, 20, nil},
+	})
+
+	for _, unique := range []bool{false, true} {
+		var buf bytes.Buffer
+		ret, err := CreateIndex(ctx, tbl, "c1_idx", []string{"c1"}, unique, true, "", BuildOptions{ExecutionOptions: ExecutionOptions{IndexEntryWriter: &buf}})
+		require.NoError(t, err)
+		built, err := ret.NewTable.GetIndexRowData(ctx, "c1_idx")
+		require.NoError(t, err)
+
+		loaded, err := LoadIndexEntries(ctx, vrw, ret.NewIndex, bytes.NewReader(buf.Bytes()))
+		require.NoError(t, err)
+		assert.Equal(t, uint64(3), loaded.Count())
+		assert.Equal(t, durable.ProllyMapFromIndex(built).HashOf(), durable.ProllyMapFromIndex(loaded).HashOf())
+
+		_, err = LoadIndexEntries(ctx, vrw, ret.NewIndex, bytes.NewReader(buf.Bytes()[:buf.Len()-1]))
+		assert.Equal(t, io.ErrUnexpectedEOF, err)
+	}
+}
@@ -0,0 +1,92 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dolthub/dolt/go/libraries/doltcore/doltdb"
+	"github.com/dolthub/dolt/go/libraries/doltcore/doltdb/durable"
+	"github.com/dolthub/dolt/go/libraries/doltcore/schema"
+	"github.com/dolthub/dolt/go/store/types"
+	"github.com/dolthub/dolt/go/store/val"
+)
+
+// SwapIndexRows replaces the data of the index of |tbl| named like |built| with |rows|, data built out of band for
+// the index definition |built|, e.g. by a resumed or checkpointed build. This is the last step of an online rebuild.
+// Tables are immutable, so readers of |tbl| keep reading the old data, and readers of the returned table read the new
+// data; no reader sees a mix of the two. An error wrapping ErrIndexDefinitionChanged is returned if the index of |tbl|
+// no longer has the definition |built|, and an error is returned if |rows| is not stored like the data of |built|.
+//
+// |rows| is not checked against the primary rows of |tbl|, which may have changed since it was built. Callers that
+// build from an older version of the table must bring |rows| up to date, e.g. with an IndexMaintainer, first.
+func SwapIndexRows(ctx context.Context, tbl *doltdb.Table, built schema.Index, rows durable.Index) (*doltdb.Table, error) {
+	sch, err := tbl.GetSchema(ctx)
+	if err != nil {
+		return nil, err
+	}
+	idx := sch.Indexes().GetByName(built.Name())
+	if idx == nil {
+		return nil, fmt.Errorf("%w: index `%s` does not exist", ErrIndexDefinitionChanged, built.Name())
+	}
+	if !schema.IndexesAreDataCompatible(built, idx) {
+		return nil, fmt.Errorf("%w: index `%s` is no longer the index its new data was built for", ErrIndexDefinitionChanged, idx.Name())
+	}
+	if rows.Format() != tbl.Format() {
+		return nil, fmt.Errorf("index `%s`: new data of format %s cannot be stored in a table of format %s",
+			idx.Name(), rows.Format().VersionString(), tbl.Format().VersionString())
+	}
+
+	if types.IsFormat_DOLT_1(tbl.Format()) {
+		// data built with options that change its encoding, e.g. reverse or
+		// geohash prefixed keys, cannot be read through the table
+		empty, err := newSecondaryMap(ctx, tbl.ValueReadWriter(), sch, idx, BuildOptions{})
+		if err != nil {
+			return nil, err
+		}
+		ekd, evd := empty.Descriptors()
+		kd, vd := durable.ProllyMapFromIndex(rows).Descriptors()
+		if !typesEqual(ekd, kd) || !typesEqual(evd, vd) || !storedOrder(ekd, kd) {
+			return nil, fmt.Errorf("index `%s`: new data is not encoded like the data of the index", idx.Name())
+		}
+	}
+	return tbl.SetIndexRows(ctx, idx.Name(), rows)
+}
+
+// defaultComparator is the comparator of the keys of index data stored in tables.
+var defaultComparator = val.NewTupleDescriptor().Comparator()
+
+// storedOrder returns whether the keys described by |a| and |b| are both in
+// the order of index data stored in tables. The order of keys is not stored
+// with index data, so data ordered by another comparator, e.g. reversed or
+// equality-only data, would be read in the wrong order.
+func storedOrder(a, b val.TupleDesc) bool {
+	ac, bc := a.Comparator(), b.Comparator()
+	return ac == defaultComparator && bc == defaultComparator && ac == bc
+}
+
+// typesEqual returns whether |a| and |b| describe tuples of the same types.
+func typesEqual(a, b val.TupleDesc) bool {
+	if a.Count() != b.Count() {
+		return false
+	}
+	for i := range a.Types {
+		if a.Types[i] != b.Types[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,143 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/dolthub/dolt/go/libraries/doltcore/doltdb"
+	"github.com/dolthub/dolt/go/libraries/doltcore/doltdb/durable"
+	"github.com/dolthub/dolt/go/libraries/doltcore/schema"
+)
+
+func TestSwapIndexRows(t *testing.T) {
+	ctx := context.Background()
+	vrw := newTestVRW()
+	var rows [][]interface{}
+	for i := 0; i < 2000; i++ {
+		rows = append(rows, []interface{}{i, i % 10, "row"})
+	}
+	newIndexedTable := func(cols ...string) (*doltdb.Table, schema.Index) {
+		sch := newTestSchema(t)
+		idx, err := sch.Indexes().AddIndexByColNames("idx", cols, schema.IndexProperties{IsUserDefined: true})
+		require.NoError(t, err)
+		return newTestTable(t, ctx, vrw, sch, rows), idx
+	}
+
+	// the table starts with empty index data, which is rebuilt out of band
+	tbl, idx := newIndexedTable("c1")
+	built, err := BuildSecondaryIndex(ctx, tbl, idx, BuildOptions{})
+	require.NoError(t, err)
+	require.Equal(t, uint64(len(rows)), built.Count())
+
+	// readers see either the old or the new index data, never a mix
+	var current atomic.Value
+	current.Store(tbl)
+	var swapped atomic.Bool
+	errs := make(chan error, 4)
+	var wg sync.WaitGroup
+	for r := 0; r < 4; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for done := false; !done; {
+				done = swapped.Load()
+				data, err := current.Load().(*doltdb.Table).GetIndexRowData(ctx, "idx")
+				var n int
+				if err == nil {
+					n, err = countEntries(ctx, data)
+				}
+				if err == nil && n != 0 && n != len(rows) {
+					err = fmt.Errorf("read %d index entries", n)
+				}
+				if err != nil {
+					errs <- err
+					return
+				}
+			}
+		}()
+	}
+	newTbl, err := SwapIndexRows(ctx, tbl, idx, built)
+	require.NoError(t, err)
+	current.Store(newTbl)
+	swapped.Store(true)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		require.NoError(t, err)
+	}
+
+	data, err := newTbl.GetIndexRowData(ctx, "idx")
+	require.NoError(t, err)
+	requireSameIndex(t, built, data)
+	res, err := VerifySecondaryIndex(ctx, newTbl, idx, BuildOptions{})
+	require.NoError(t, err)
+	require.True(t, res.Consistent())
+	old, err := tbl.GetIndexRowData(ctx, "idx")
+	require.NoError(t, err)
+	require.True(t, old.Empty())
+
+	// swaps are rejected once the index definition changed
+	changed, _ := newIndexedTable("c2")
+	_, err = SwapIndexRows(ctx, changed, idx, built)
+	require.ErrorIs(t, err, ErrIndexDefinitionChanged)
+	dropped, _ := newIndexedTable("c1")
+	dropSch, err := dropped.GetSchema(ctx)
+	require.NoError(t, err)
+	_, err = dropSch.Indexes().RemoveIndex("idx")
+	require.NoError(t, err)
+	dropped, err = dropped.UpdateSchema(ctx, dropSch)
+	require.NoError(t, err)
+	_, err = SwapIndexRows(ctx, dropped, idx, built)
+	require.ErrorIs(t, err, ErrIndexDefinitionChanged)
+
+	// as is data that cannot be read through the table
+	sch, err := tbl.GetSchema(ctx)
+	require.NoError(t, err)
+	primary, err := tbl.GetRowData(ctx)
+	require.NoError(t, err)
+	reversed, err := BuildSecondaryProllyIndex(ctx, vrw, sch, idx, durable.ProllyMapFromIndex(primary), BuildOptions{KeyEncodingOptions: KeyEncodingOptions{ReverseIndexOrder: true}})
+	require.NoError(t, err)
+	_, err = SwapIndexRows(ctx, tbl, idx, reversed)
+	require.Error(t, err)
+	require.NotErrorIs(t, err, ErrIndexDefinitionChanged)
+	unordered, err := BuildSecondaryProllyIndex(ctx, vrw, sch, idx, durable.ProllyMapFromIndex(primary), BuildOptions{KeyEncodingOptions: KeyEncodingOptions{EqualityOnlyIndex: true}})
+	require.NoError(t, err)
+	_, err = SwapIndexRows(ctx, tbl, idx, unordered)
+	require.Error(t, err)
+	require.NotErrorIs(t, err, ErrIndexDefinitionChanged)
+}
+
+// countEntries returns the number of entries read by a scan of |idx|.
+func countEntries(ctx context.Context, idx durable.Index) (int, error) {
+	iter, err := durable.ProllyMapFromIndex(idx).IterAll(ctx)
+	if err != nil {
+		return 0, err
+	}
+	for n := 0; ; n++ {
+		if _, _, err = iter.Next(ctx); err == io.EOF {
+			return n, nil
+		} else if err != nil {
+			return n, err
+		}
+	}
+}
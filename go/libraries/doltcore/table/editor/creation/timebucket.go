@@ -0,0 +1,118 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/dolthub/dolt/go/libraries/doltcore/doltdb/durable"
+	"github.com/dolthub/dolt/go/libraries/doltcore/schema"
+	"github.com/dolthub/dolt/go/store/prolly"
+	"github.com/dolthub/dolt/go/store/prolly/shim"
+	"github.com/dolthub/dolt/go/store/val"
+)
+
+// timeBucketing computes the time bucket that prefixes the keys of an index
+// with schema.IndexProperties.TimeBucket set.
+type timeBucketing struct {
+	bucket time.Duration
+	// from is the field of the primary row that the bucket is computed from,
+	// numbered like the fields of a val.OrdinalMapping from GetIndexKeyMapping
+	from  int
+	pkLen int
+	pkd   val.TupleDesc
+	pvd   val.TupleDesc
+}
+
+// newTimeBucketing returns the time bucketing of the keys of |idx|, or nil if
+// |idx| has no time bucket. |keyMap| is the key mapping of |idx|.
+func newTimeBucketing(sch schema.Schema, idx schema.Index, keyMap val.OrdinalMapping, opts BuildOptions) (*timeBucketing, error) {
+	if idx.TimeBucket() == 0 {
+		return nil, nil
+	}
+	if opts.IndexKeyEncryption != nil {
+		return nil, fmt.Errorf("index `%s`: the keys of time bucketed indexes cannot be encrypted", idx.Name())
+	}
+	for i, tag := range idx.IndexedColumnTags() {
+		col, ok := sch.GetAllCols().GetByTag(tag)
+		if ok && schema.IsColTimeBucketType(col) {
+			pkd, pvd := shim.MapDescriptorsFromSchema(sch)
+			return &timeBucketing{
+				bucket: idx.TimeBucket(),
+				from:   keyMap.MapOrdinal(i),
+				pkLen:  sch.GetPKCols().Size(),
+				pkd:    pkd,
+				pvd:    pvd,
+			}, nil
+		}
+	}
+	return nil, fmt.Errorf("index `%s` has a time bucket but no datetime or timestamp column", idx.Name())
+}
+
+// put writes the time bucket of the primary row |k|, |v| to the first field of
+// |kb|. The bucket of a NULL time is NULL.
+func (b *timeBucketing) put(kb *val.TupleBuilder, k, v val.Tuple) {
+	var t time.Time
+	var ok bool
+	if b.from < b.pkLen {
+		t, ok = b.pkd.GetDatetime(b.from, k)
+	} else {
+		t, ok = b.pvd.GetDatetime(b.from-b.pkLen, v)
+	}
+	if !ok {
+		kb.PutRaw(0, nil)
+		return
+	}
+	kb.PutDatetime(0, t.Truncate(b.bucket))
+}
+
+// timeBucketKeyDesc returns the key descriptor of a time bucketed index whose
+// keys are otherwise described by |kd|.
+func timeBucketKeyDesc(kd val.TupleDesc) val.TupleDesc {
+	types := make([]val.Type, 0, kd.Count()+1)
+	types = append(types, val.Type{Enc: val.DatetimeEnc, Nullable: true})
+	types = append(types, kd.Types...)
+	return val.NewTupleDescriptor(types...)
+}
+
+// withTimeBucketDesc returns |m|, the data of |idx|, described by the key
+// descriptor of a time bucketed index if |idx| has a time bucket. Index data
+// read back from storage is described by the schema of the index, which does
+// not include the bucket.
+func withTimeBucketDesc(idx schema.Index, m prolly.Map) prolly.Map {
+	kd, vd := m.Descriptors()
+	if idx.TimeBucket() == 0 || kd.Count() > len(idx.AllTags()) {
+		return m
+	}
+	return prolly.NewMap(m.Node(), m.NodeStore(), timeBucketKeyDesc(kd), vd)
+}
+
+// IterIndexTimeBucket returns an iterator over the entries of |rows|, the data
+// of the time bucketed index |idx|, in the time bucket that contains |t|. As
+// the entries of a bucket are stored together, this is a single range scan.
+func IterIndexTimeBucket(ctx context.Context, idx schema.Index, rows durable.Index, t time.Time) (prolly.MapIter, error) {
+	if idx.TimeBucket() == 0 {
+		return nil, fmt.Errorf("index `%s` has no time bucket", idx.Name())
+	}
+	m := withTimeBucketDesc(idx, durable.ProllyMapFromIndex(rows))
+	kd, _ := m.Descriptors()
+	pd := prefixDesc(kd, 1)
+	pb := val.NewTupleBuilder(pd)
+	pb.PutDatetime(0, t.UTC().Truncate(idx.TimeBucket()))
+	p := pb.Build(m.Pool())
+	return m.IterRange(ctx, prolly.ClosedRange(p, p, pd))
+}
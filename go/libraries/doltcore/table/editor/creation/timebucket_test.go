@@ -0,0 +1,123 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/dolthub/dolt/go/libraries/doltcore/doltdb/durable"
+	"github.com/dolthub/dolt/go/libraries/doltcore/schema"
+	"github.com/dolthub/dolt/go/store/prolly"
+	"github.com/dolthub/dolt/go/store/types"
+)
+
+func TestTimeBucketIndex(t *testing.T) {
+	ctx := context.Background()
+	vrw := newTestVRW()
+	sch, err := schema.SchemaFromCols(schema.NewColCollection(
+		schema.NewColumn("pk", 1, types.IntKind, true, schema.NotNullConstraint{}),
+		schema.NewColumn("device", 2, types.StringKind, false),
+		schema.NewColumn("ts", 3, types.TimestampKind, false),
+	))
+	require.NoError(t, err)
+
+	// readings of three devices, every six hours for three days
+	start := time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC)
+	var rows [][]interface{}
+	for i := 0; i < 12; i++ {
+		for _, device := range []string{"a", "b", "c"} {
+			rows = append(rows, []interface{}{len(rows), device, start.Add(time.Duration(i) * 6 * time.Hour)})
+		}
+	}
+	tbl := newTestTable(t, ctx, vrw, sch, rows)
+
+	// positions returns the positions in |m| of the entries whose ts falls on the second day
+	day := start.Add(24 * time.Hour)
+	positions := func(m prolly.Map, tsField int) (pos []int) {
+		kd, _ := m.Descriptors()
+		iter, err := m.IterAll(ctx)
+		require.NoError(t, err)
+		for i := 0; ; i++ {
+			k, _, err := iter.Next(ctx)
+			if err == io.EOF {
+				return pos
+			}
+			require.NoError(t, err)
+			ts, ok := kd.GetDatetime(tsField, k)
+			require.True(t, ok)
+			if !ts.Before(day) && ts.Before(day.Add(24*time.Hour)) {
+				pos = append(pos, i)
+			}
+		}
+	}
+
+	// without a bucket, the readings of a day are spread over the devices
+	ret, err := CreateIndexWithProperties(ctx, tbl, "device_ts", []uint64{2, 3}, schema.IndexProperties{IsUserDefined: true}, BuildOptions{})
+	require.NoError(t, err)
+	rowData, err := ret.NewTable.GetIndexRowData(ctx, "device_ts")
+	require.NoError(t, err)
+	pos := positions(durable.ProllyMapFromIndex(rowData), 1)
+	require.Len(t, pos, 12)
+	require.Greater(t, pos[len(pos)-1]-pos[0], len(pos)-1)
+
+	// with a bucket, they are stored together
+	idx, err := sch.Indexes().AddIndexByColTags("bucketed", []uint64{2, 3}, schema.IndexProperties{IsUserDefined: true, TimeBucket: 24 * time.Hour})
+	require.NoError(t, err)
+	require.Equal(t, 24*time.Hour, idx.TimeBucket())
+	primary, err := tbl.GetRowData(ctx)
+	require.NoError(t, err)
+	rowData, err = BuildSecondaryProllyIndex(ctx, vrw, sch, idx, durable.ProllyMapFromIndex(primary), BuildOptions{})
+	require.NoError(t, err)
+	m := withTimeBucketDesc(idx, durable.ProllyMapFromIndex(rowData))
+	pos = positions(m, 2)
+	require.Len(t, pos, 12)
+	require.Equal(t, len(pos)-1, pos[len(pos)-1]-pos[0])
+
+	// the bucket of a day can be scanned by itself
+	iter, err := IterIndexTimeBucket(ctx, idx, rowData, day.Add(13*time.Hour))
+	require.NoError(t, err)
+	kd, _ := m.Descriptors()
+	var n int
+	for {
+		k, _, err := iter.Next(ctx)
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		bucket, ok := kd.GetDatetime(0, k)
+		require.True(t, ok)
+		require.True(t, bucket.Equal(day))
+		n++
+	}
+	require.Equal(t, 12, n)
+
+	// the index verifies against the rows it was built from
+	res, err := ValidateImportedIndex(ctx, tbl, idx, rowData, BuildOptions{})
+	require.NoError(t, err)
+	require.True(t, res.Consistent())
+	res, err = ValidateImportedIndex(ctx, tbl, idx, rowData, BuildOptions{ExecutionOptions: ExecutionOptions{VerifySampleSize: 4}})
+	require.NoError(t, err)
+	require.True(t, res.Consistent())
+
+	// the bucket is not a column of the index schema, so DML would write keys
+	// without it
+	_, err = CreateIndexWithProperties(ctx, tbl, "bucketed", []uint64{2, 3}, schema.IndexProperties{IsUserDefined: true, TimeBucket: 24 * time.Hour}, BuildOptions{})
+	require.Error(t, err)
+}
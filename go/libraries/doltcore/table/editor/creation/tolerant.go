@@ -0,0 +1,244 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/dolthub/dolt/go/libraries/doltcore/doltdb/durable"
+	"github.com/dolthub/dolt/go/libraries/doltcore/schema"
+	"github.com/dolthub/dolt/go/store/prolly"
+	"github.com/dolthub/dolt/go/store/types"
+	"github.com/dolthub/dolt/go/store/val"
+)
+
+// SkipReason is the kind of error that kept a row out of an index built by
+// BuildSecondaryProllyIndexTolerant.
+type SkipReason string
+
+const (
+	// SkipReasonFilter is an error returned by BuildOptions.IndexRowFilter.
+	SkipReasonFilter SkipReason = "filter"
+	// SkipReasonEncode is an ErrIndexEncode.
+	SkipReasonEncode SkipReason = "encode"
+	// SkipReasonDuplicate is a duplicate entry of a unique index.
+	SkipReasonDuplicate SkipReason = "duplicate"
+	// SkipReasonCollision is an ErrIndexKeyCollision.
+	SkipReasonCollision SkipReason = "collision"
+	// SkipReasonOversized is an ErrIndexFieldTooLarge.
+	SkipReasonOversized SkipReason = "oversized"
+)
+
+// SkippedIndexRow is a row that could not be indexed.
+type SkippedIndexRow struct {
+	// PrimaryKey is the formatted primary key of the row.
+	PrimaryKey string
+	Err        error
+}
+
+// TolerantBuildReport summarizes an index build by BuildSecondaryProllyIndexTolerant.
+type TolerantBuildReport struct {
+	IndexName   string
+	RowsScanned uint64
+	RowsIndexed uint64
+	// Skipped are the rows that were not indexed because of an error, grouped by the kind of error.
+	Skipped map[SkipReason][]SkippedIndexRow
+}
+
+// RowsSkipped returns the number of rows that were not indexed because of an error.
+func (r TolerantBuildReport) RowsSkipped() uint64 {
+	var n uint64
+	for _, rows := range r.Skipped {
+		n += uint64(len(rows))
+	}
+	return n
+}
+
+// BuildSecondaryProllyIndexTolerant builds the secondary index |idx| over |primary| like BuildSecondaryProllyIndex,
+// but does not stop at rows that cannot be indexed. Such rows are left out of the index and reported, with the error
+// that kept them out, in the returned TolerantBuildReport. Of the rows with duplicate entries in a unique index, the
+// first is indexed. Errors that are not specific to a row, such as I/O errors and the limits set in |opts|, still stop
+// the build.
+func BuildSecondaryProllyIndexTolerant(ctx context.Context, vrw types.ValueReadWriter, sch schema.Schema, idx schema.Index, primary prolly.Map, opts BuildOptions) (durable.Index, TolerantBuildReport, error) {
+	iter, err := iterPrimary(ctx, primary, opts)
+	if err != nil {
+		return nil, TolerantBuildReport{IndexName: idx.Name()}, err
+	}
+	return BuildSecondaryProllyIndexTolerantFromIter(ctx, vrw, sch, idx, iter, opts)
+}
+
+// BuildSecondaryProllyIndexTolerantFromIter builds a secondary index like
+// BuildSecondaryProllyIndexTolerant from the primary rows returned by |iter|.
+func BuildSecondaryProllyIndexTolerantFromIter(ctx context.Context, vrw types.ValueReadWriter, sch schema.Schema, idx schema.Index, iter prolly.MapIter, opts BuildOptions) (durable.Index, TolerantBuildReport, error) {
+	report := TolerantBuildReport{IndexName: idx.Name(), Skipped: make(map[SkipReason][]SkippedIndexRow)}
+	secondary, err := newSecondaryMap(ctx, vrw, sch, idx, opts)
+	if err != nil {
+		return nil, report, err
+	}
+
+	kd, _ := secondary.Descriptors()
+	enc, err := newIndexKeyEncoder(sch, idx, kd, opts)
+	if err != nil {
+		return nil, report, err
+	}
+	pkd := enc.pkd
+	prefixKD := prefixDesc(kd, idx.Count())
+	prefixKB := val.NewTupleBuilder(prefixKD)
+	p := tuplePool(secondary, opts)
+	mon := newBuildMonitor(idx, opts)
+	docs, err := newDocumentFeeder(sch, idx, secondary.NodeStore(), opts)
+	if err != nil {
+		return nil, report, err
+	}
+	spill, err := newRunSpiller(idx, kd, opts)
+	if err != nil {
+		return nil, report, err
+	}
+	defer spill.abort()
+	ends, err := newIntervalEnds(sch, idx, opts)
+	if err != nil {
+		return nil, report, err
+	}
+	exp, err := newExpiryValues(sch, idx, opts)
+	if err != nil {
+		return nil, report, err
+	}
+	sums, err := newEntryChecksums(idx, opts)
+	if err != nil {
+		return nil, report, err
+	}
+
+	skip := func(reason SkipReason, k val.Tuple, err error) {
+		report.Skipped[reason] = append(report.Skipped[reason], SkippedIndexRow{PrimaryKey: pkd.Format(k), Err: err})
+	}
+
+	mut := secondary.Mutate()
+	for {
+		k, v, err := iter.Next(ctx)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, report, ioErr(idx, pkd, nil, err)
+		}
+		if err = mon.row(ctx); err != nil {
+			return nil, report, err
+		}
+
+		if opts.IndexRowFilter != nil {
+			ok, err := opts.IndexRowFilter(ctx, k, v)
+			if err != nil {
+				skip(SkipReasonFilter, k, err)
+				continue
+			}
+			if !ok {
+				continue
+			}
+		}
+
+		if err = enc.put(k, v, mon); err != nil {
+			enc.kb.Recycle()
+			if errors.Is(err, ErrIndexFieldTooLarge) {
+				skip(SkipReasonOversized, k, err)
+			} else {
+				skip(SkipReasonEncode, k, err)
+			}
+			continue
+		}
+		idxKey := enc.kb.Build(p)
+		idxVal, err := indexValue(v, iter, p, opts)
+		if err != nil {
+			return nil, report, err
+		}
+		if ends != nil {
+			idxVal = ends.value(k, v, p)
+		}
+		if exp != nil {
+			idxVal = exp.value(k, v, p)
+		}
+		if sums != nil {
+			idxVal = sums.value(idxKey, p)
+		}
+		if err = mon.value(idxKey); err != nil {
+			return nil, report, err
+		}
+
+		if idx.IsUnique() && !hasNullPrefix(idxKey, idx.Count()) {
+			for i := 0; i < idx.Count(); i++ {
+				prefixKB.PutRaw(i, idxKey.GetField(i))
+			}
+			existing, err := firstWithPrefix(ctx, mut, prefixKD, prefixKB.Build(p))
+			if err != nil {
+				return nil, report, ioErr(idx, pkd, k, err)
+			}
+			if existing != nil {
+				existingStr, _ := formatKey(existing, kd)
+				skip(SkipReasonDuplicate, k, fmt.Errorf("duplicate unique key given: %s", existingStr))
+				continue
+			}
+		}
+
+		if opts.DetectIndexKeyCollisions {
+			ok, err := mut.Has(ctx, idxKey)
+			if err != nil {
+				return nil, report, ioErr(idx, pkd, k, err)
+			}
+			if ok {
+				keyStr, _ := formatKey(idxKey, kd)
+				skip(SkipReasonCollision, k, fmt.Errorf("%w: index `%s` has multiple rows with key %s", ErrIndexKeyCollision, idx.Name(), keyStr))
+				continue
+			}
+		}
+
+		if err = mut.Put(ctx, idxKey, idxVal); err != nil {
+			return nil, report, ioErr(idx, pkd, k, err)
+		}
+		if opts.IndexEntryWriter != nil {
+			if err = WriteIndexEntry(opts.IndexEntryWriter, idxKey, idxVal); err != nil {
+				return nil, report, ioErr(idx, pkd, k, err)
+			}
+		}
+		if err = spill.put(idxKey, idxVal); err != nil {
+			return nil, report, ioErr(idx, pkd, k, err)
+		}
+		if err = docs.feed(ctx, k, v); err != nil {
+			return nil, report, err
+		}
+		mon.indexed()
+	}
+
+	secondary, err = mut.Map(ctx)
+	if err == nil {
+		secondary, err = ends.finish(ctx, secondary)
+	}
+	if err != nil {
+		return nil, report, ioErr(idx, pkd, nil, err)
+	}
+	if err = spill.finish(); err != nil {
+		return nil, report, ioErr(idx, pkd, nil, err)
+	}
+	if err = mon.finish(ctx, secondary); err != nil {
+		return nil, report, err
+	}
+	if err = emitIndexColumns(ctx, idx, secondary, opts); err != nil {
+		return nil, report, err
+	}
+	report.RowsScanned = mon.rows
+	report.RowsIndexed = mon.indexes
+	return durable.IndexFromProllyMap(secondary), report, nil
+}
@@ -0,0 +1,106 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/dolthub/dolt/go/libraries/doltcore/doltdb/durable"
+	"github.com/dolthub/dolt/go/libraries/doltcore/schema"
+	"github.com/dolthub/dolt/go/store/val"
+)
+
+func TestBuildSecondaryProllyIndexTolerant(t *testing.T) {
+	ctx := context.Background()
+	vrw := newTestVRW()
+	sch := newTestSchema(t)
+	primary := newTestPrimary(t, ctx, vrw, sch, [][]interface{}{
+		{1, 10, "a"},
+		{2, 20, "b"},
+		{3, 10, "c"},
+		{4, nil, "d"},
+		{5, nil, "e"},
+		{6, 30, "f"},
+		{7, 40, "g"},
+	})
+
+	// a row with a NULL primary key can only come from a corrupt table
+	kd, vd := primary.Descriptors()
+	kb, vb := val.NewTupleBuilder(kd), val.NewTupleBuilder(vd)
+	vb.PutInt64(0, 50)
+	kvs := [][2]val.Tuple{{kb.BuildPermissive(sharePool), vb.Build(sharePool)}}
+	iter, err := primary.IterAll(ctx)
+	require.NoError(t, err)
+	for {
+		k, v, err := iter.Next(ctx)
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		kvs = append(kvs, [2]val.Tuple{k, v})
+	}
+
+	filterErr := errors.New("bad row")
+	opts := BuildOptions{
+		ExecutionOptions: ExecutionOptions{
+			IndexRowFilter: func(ctx context.Context, k, v val.Tuple) (bool, error) {
+				if k.FieldIsNull(0) {
+					return true, nil
+				}
+				if pk, _ := kd.GetInt64(0, k); pk == 7 {
+					return false, filterErr
+				}
+				return true, nil
+			},
+		},
+	}
+
+	idx, err := schema.NewIndexCollection(sch.GetAllCols(), sch.GetPKCols()).AddIndexByColNames(
+		"c1_idx", []string{"c1"}, schema.IndexProperties{IsUnique: true})
+	require.NoError(t, err)
+	rows, report, err := BuildSecondaryProllyIndexTolerantFromIter(ctx, vrw, sch, idx, &sliceMapIter{kvs: kvs}, opts)
+	require.NoError(t, err)
+	require.Equal(t, []string{"[10,1]", "[20,2]", "[30,6]", "[NULL,4]", "[NULL,5]"}, collectKeys(t, ctx, durable.ProllyMapFromIndex(rows)))
+
+	require.Equal(t, "c1_idx", report.IndexName)
+	require.Equal(t, uint64(8), report.RowsScanned)
+	require.Equal(t, uint64(5), report.RowsIndexed)
+	require.Equal(t, uint64(3), report.RowsSkipped())
+	require.Len(t, report.Skipped, 3)
+
+	require.Len(t, report.Skipped[SkipReasonDuplicate], 1)
+	require.Equal(t, "( 3 )", report.Skipped[SkipReasonDuplicate][0].PrimaryKey)
+	require.Len(t, report.Skipped[SkipReasonFilter], 1)
+	require.Equal(t, "( 7 )", report.Skipped[SkipReasonFilter][0].PrimaryKey)
+	require.ErrorIs(t, report.Skipped[SkipReasonFilter][0].Err, filterErr)
+	require.Len(t, report.Skipped[SkipReasonEncode], 1)
+	require.Equal(t, "( NULL )", report.Skipped[SkipReasonEncode][0].PrimaryKey)
+	var encErr ErrIndexEncode
+	require.ErrorAs(t, report.Skipped[SkipReasonEncode][0].Err, &encErr)
+
+	// without errors, the build matches BuildSecondaryProllyIndex
+	clean := newTestPrimary(t, ctx, vrw, sch, [][]interface{}{{1, 10, "a"}, {2, 20, "b"}, {3, nil, "c"}})
+	rows, report, err = BuildSecondaryProllyIndexTolerant(ctx, vrw, sch, idx, clean, BuildOptions{})
+	require.NoError(t, err)
+	require.Zero(t, report.RowsSkipped())
+	expected, err := BuildSecondaryProllyIndex(ctx, vrw, sch, idx, clean, BuildOptions{})
+	require.NoError(t, err)
+	requireSameIndex(t, expected, rows)
+}
@@ -0,0 +1,108 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"context"
+	"io"
+
+	"github.com/dolthub/dolt/go/libraries/doltcore/doltdb/durable"
+	"github.com/dolthub/dolt/go/store/pool"
+	"github.com/dolthub/dolt/go/store/prolly"
+	"github.com/dolthub/dolt/go/store/val"
+)
+
+// indexTombstone is the value of a deleted secondary index entry. Live entries
+// have an empty value, so a single field marks a tombstone.
+var indexTombstone = val.NewTuple(pool.NewBuffPool(), []byte{1})
+
+// IsIndexTombstone returns true if |v| is the value of a deleted secondary
+// index entry.
+//
+// Tombstones are only understood by NewTombstoneSkippingIter and the functions
+// of this package that document it, such as BuildSortKeyIndex. Other readers,
+// such as the SQL engine's index lookups, treat them as live entries, so index
+// data with tombstones must be compacted with CompactIndexTombstones before it
+// is stored as the data of an index.
+func IsIndexTombstone(v val.Tuple) bool {
+	return v.Count() == 1
+}
+
+// DeleteIndexEntryWithTombstone marks the entry |key| of |mut| as deleted by
+// replacing its value with a tombstone, which is cheaper than removing it
+// from the tree when deletes are frequent.
+func DeleteIndexEntryWithTombstone(ctx context.Context, mut prolly.MutableMap, key val.Tuple) error {
+	return mut.Put(ctx, key, indexTombstone)
+}
+
+// NewTombstoneSkippingIter returns a prolly.MapIter over the secondary index
+// entries of |iter| that skips tombstones.
+func NewTombstoneSkippingIter(iter prolly.MapIter) prolly.MapIter {
+	return tombstoneSkippingIter{iter: iter}
+}
+
+type tombstoneSkippingIter struct {
+	iter prolly.MapIter
+}
+
+var _ prolly.MapIter = tombstoneSkippingIter{}
+
+func (itr tombstoneSkippingIter) Next(ctx context.Context) (k, v val.Tuple, err error) {
+	for {
+		k, v, err = itr.iter.Next(ctx)
+		if err != nil || !IsIndexTombstone(v) {
+			return k, v, err
+		}
+	}
+}
+
+// CompactIndexTombstones removes the tombstones from the secondary index data
+// |rows|, returning the compacted data and the number of tombstones removed.
+// The result is identical to index data that never had the deleted entries.
+func CompactIndexTombstones(ctx context.Context, rows durable.Index) (durable.Index, uint64, error) {
+	m := durable.ProllyMapFromIndex(rows)
+	iter, err := m.IterAll(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var tombstones []val.Tuple
+	for {
+		k, v, err := iter.Next(ctx)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, 0, err
+		}
+		if IsIndexTombstone(v) {
+			tombstones = append(tombstones, k)
+		}
+	}
+	if len(tombstones) == 0 {
+		return rows, 0, nil
+	}
+
+	mut := m.Mutate()
+	for _, k := range tombstones {
+		if err = mut.Delete(ctx, k); err != nil {
+			return nil, 0, err
+		}
+	}
+	m, err = mut.Map(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	return durable.IndexFromProllyMap(m), uint64(len(tombstones)), nil
+}
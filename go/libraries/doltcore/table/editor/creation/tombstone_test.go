@@ -0,0 +1,105 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/dolthub/dolt/go/libraries/doltcore/doltdb/durable"
+	"github.com/dolthub/dolt/go/libraries/doltcore/schema"
+	"github.com/dolthub/dolt/go/store/val"
+)
+
+func TestIndexTombstones(t *testing.T) {
+	ctx := context.Background()
+	vrw := newTestVRW()
+	sch := newTestSchema(t)
+	idx, err := schema.NewIndexCollection(sch.GetAllCols(), sch.GetPKCols()).AddIndexByColNames(
+		"c1_idx", []string{"c1"}, schema.IndexProperties{})
+	require.NoError(t, err)
+
+	rows := [][]interface{}{{1, 10, "a"}, {2, 20, "b"}, {3, 30, "c"}, {4, 40, "d"}}
+	built, err := BuildSecondaryProllyIndex(ctx, vrw, sch, idx, newTestPrimary(t, ctx, vrw, sch, rows), BuildOptions{})
+	require.NoError(t, err)
+	m := durable.ProllyMapFromIndex(built)
+
+	// delete the index entries of rows 2 and 4
+	deleted := make(map[string]bool)
+	iter, err := m.IterAll(ctx)
+	require.NoError(t, err)
+	mut := m.Mutate()
+	kd, _ := m.Descriptors()
+	for {
+		k, _, err := iter.Next(ctx)
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		if pk, _ := kd.GetInt64(1, k); pk%2 == 0 {
+			require.NoError(t, DeleteIndexEntryWithTombstone(ctx, mut, k))
+			deleted[kd.Format(k)] = true
+		}
+	}
+	m, err = mut.Map(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 4, m.Count())
+
+	// scans skip tombstones
+	all, err := m.IterAll(ctx)
+	require.NoError(t, err)
+	iter = NewTombstoneSkippingIter(all)
+	var live int
+	for {
+		k, _, err := iter.Next(ctx)
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		require.False(t, deleted[kd.Format(k)])
+		live++
+	}
+	require.Equal(t, 2, live)
+	sorted, err := BuildSortKeyIndex(ctx, durable.IndexFromProllyMap(m), func(d val.TupleDesc, i int, tup val.Tuple) []byte {
+		return tup.GetField(i)
+	})
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), sorted.Count())
+
+	// compaction removes the tombstones, leaving the index of the remaining rows
+	compacted, removed, err := CompactIndexTombstones(ctx, durable.IndexFromProllyMap(m))
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), removed)
+	require.Equal(t, uint64(2), compacted.Count())
+	expected, err := BuildSecondaryProllyIndex(ctx, vrw, sch, idx, newTestPrimary(t, ctx, vrw, sch, [][]interface{}{rows[0], rows[2]}), BuildOptions{})
+	require.NoError(t, err)
+	requireSameIndex(t, expected, compacted)
+
+	compacted, removed, err = CompactIndexTombstones(ctx, compacted)
+	require.NoError(t, err)
+	require.Zero(t, removed)
+	requireSameIndex(t, expected, compacted)
+}
+
+func requireSameIndex(t *testing.T, expected, actual durable.Index) {
+	eh, err := expected.HashOf()
+	require.NoError(t, err)
+	ah, err := actual.HashOf()
+	require.NoError(t, err)
+	require.Equal(t, eh, ah)
+}
@@ -0,0 +1,119 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"fmt"
+
+	"github.com/dolthub/go-mysql-server/sql"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+
+	"github.com/dolthub/dolt/go/libraries/doltcore/schema"
+	"github.com/dolthub/dolt/go/store/val"
+)
+
+// charsetEncodings are the encodings of the single byte character sets whose
+// strings can be transcoded to UTF-8 by a fieldTranscoder.
+var charsetEncodings = map[sql.CharacterSet]encoding.Encoding{
+	// MySQL's latin1 is cp1252, rather than ISO 8859-1
+	sql.CharacterSet_latin1:   charmap.Windows1252,
+	sql.CharacterSet_latin2:   charmap.ISO8859_2,
+	sql.CharacterSet_latin5:   charmap.ISO8859_9,
+	sql.CharacterSet_latin7:   charmap.ISO8859_13,
+	sql.CharacterSet_greek:    charmap.ISO8859_7,
+	sql.CharacterSet_hebrew:   charmap.ISO8859_8,
+	sql.CharacterSet_cp1250:   charmap.Windows1250,
+	sql.CharacterSet_cp1251:   charmap.Windows1251,
+	sql.CharacterSet_cp1256:   charmap.Windows1256,
+	sql.CharacterSet_cp1257:   charmap.Windows1257,
+	sql.CharacterSet_cp850:    charmap.CodePage850,
+	sql.CharacterSet_cp852:    charmap.CodePage852,
+	sql.CharacterSet_cp866:    charmap.CodePage866,
+	sql.CharacterSet_koi8r:    charmap.KOI8R,
+	sql.CharacterSet_koi8u:    charmap.KOI8U,
+	sql.CharacterSet_macroman: charmap.Macintosh,
+}
+
+// fieldTranscoder transcodes the string fields of index keys from the
+// character sets of BuildOptions.IndexSourceCharsets to UTF-8, the
+// character set of their columns. A nil *fieldTranscoder leaves keys
+// unchanged.
+type fieldTranscoder struct {
+	decoders map[int]*encoding.Decoder
+}
+
+// newFieldTranscoder returns the fieldTranscoder of the keys of |idx|,
+// encoded by |kd|, or nil if |opts| has no IndexSourceCharsets.
+func newFieldTranscoder(sch schema.Schema, idx schema.Index, kd val.TupleDesc, opts BuildOptions) (*fieldTranscoder, error) {
+	if len(opts.IndexSourceCharsets) == 0 {
+		return nil, nil
+	}
+	t := &fieldTranscoder{decoders: make(map[int]*encoding.Decoder)}
+	for name, charset := range opts.IndexSourceCharsets {
+		col, ok := sch.GetAllCols().GetByNameCaseInsensitive(name)
+		if !ok {
+			return nil, fmt.Errorf("index `%s`: column `%s` does not exist", idx.Name(), name)
+		}
+		to := -1
+		for i, tag := range idx.IndexedColumnTags() {
+			if tag == col.Tag {
+				to = i
+			}
+		}
+		if to < 0 {
+			return nil, fmt.Errorf("index `%s`: column `%s` is not indexed", idx.Name(), col.Name)
+		}
+		st, ok := col.TypeInfo.ToSqlType().(sql.StringType)
+		if !ok || kd.Types[to].Enc != val.StringEnc {
+			return nil, fmt.Errorf("index `%s`: column `%s` is not a string column", idx.Name(), col.Name)
+		}
+		if cs := st.Collation().CharacterSet(); cs != sql.CharacterSet_utf8mb4 && cs != sql.CharacterSet_utf8mb3 {
+			return nil, fmt.Errorf("index `%s`: strings can only be transcoded to UTF-8, not to the %s character set of column `%s`", idx.Name(), cs, col.Name)
+		}
+		cs, err := sql.ParseCharacterSet(charset)
+		if err != nil {
+			return nil, err
+		}
+		if cs == sql.CharacterSet_utf8mb4 || cs == sql.CharacterSet_utf8mb3 || cs == sql.CharacterSet_ascii {
+			// the strings are UTF-8 already
+			continue
+		}
+		enc, ok := charsetEncodings[cs]
+		if !ok {
+			return nil, fmt.Errorf("index `%s`: strings of the %s character set cannot be transcoded", idx.Name(), cs)
+		}
+		t.decoders[to] = enc.NewDecoder()
+	}
+	return t, nil
+}
+
+// TranscodeField returns the index key field |f| at position |to|, transcoded
+// to UTF-8 if its column has a source character set.
+func (t *fieldTranscoder) TranscodeField(to int, f []byte) ([]byte, error) {
+	if t == nil || f == nil {
+		return f, nil
+	}
+	d, ok := t.decoders[to]
+	if !ok {
+		return f, nil
+	}
+	// strings are null terminated
+	s, err := d.Bytes(f[:len(f)-1])
+	if err != nil {
+		return nil, err
+	}
+	return append(s, 0), nil
+}
@@ -0,0 +1,82 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/dolthub/dolt/go/libraries/doltcore/doltdb/durable"
+	"github.com/dolthub/dolt/go/libraries/doltcore/schema"
+)
+
+func TestIndexSourceCharsets(t *testing.T) {
+	ctx := context.Background()
+	vrw := newTestVRW()
+	sch := newTestSchema(t)
+	// c2 is a utf8mb4 column holding latin1 strings, imported without transcoding
+	rows := [][]interface{}{
+		{1, 1, "abc"},
+		{2, 2, "\xe9t\xe9"},
+		{3, 3, "\x80uro"},
+		{4, 4, nil},
+	}
+	primary := newTestPrimary(t, ctx, vrw, sch, rows)
+	coll := schema.NewIndexCollection(sch.GetAllCols(), sch.GetPKCols())
+	idx, err := coll.AddIndexByColNames("c2_idx", []string{"c2"}, schema.IndexProperties{})
+	require.NoError(t, err)
+	uniq, err := coll.AddIndexByColNames("c2_uniq", []string{"c2"}, schema.IndexProperties{IsUnique: true})
+	require.NoError(t, err)
+
+	// without transcoding, keys are ordered by their latin1 bytes
+	rowData, err := BuildSecondaryProllyIndex(ctx, vrw, sch, idx, primary, BuildOptions{})
+	require.NoError(t, err)
+	require.Equal(t, []string{"[abc,1]", "[\x80uro,3]", "[\xe9t\xe9,2]", "[NULL,4]"},
+		collectKeys(t, ctx, durable.ProllyMapFromIndex(rowData)))
+
+	// with transcoding, keys hold the UTF-8 strings and are ordered by their UTF-8 bytes
+	opts := BuildOptions{KeyEncodingOptions: KeyEncodingOptions{IndexSourceCharsets: map[string]string{"c2": "latin1"}}}
+	expected := []string{"[abc,1]", "[été,2]", "[€uro,3]", "[NULL,4]"}
+	for _, i := range []schema.Index{idx, uniq} {
+		rowData, err = BuildSecondaryProllyIndex(ctx, vrw, sch, i, primary, opts)
+		require.NoError(t, err)
+		require.Equal(t, expected, collectKeys(t, ctx, durable.ProllyMapFromIndex(rowData)))
+	}
+
+	// strings that are UTF-8 already are left unchanged
+	rowData, err = BuildSecondaryProllyIndex(ctx, vrw, sch, idx, primary, BuildOptions{KeyEncodingOptions: KeyEncodingOptions{IndexSourceCharsets: map[string]string{"c2": "utf8mb4"}}})
+	require.NoError(t, err)
+	require.Equal(t, []string{"[abc,1]", "[\x80uro,3]", "[\xe9t\xe9,2]", "[NULL,4]"},
+		collectKeys(t, ctx, durable.ProllyMapFromIndex(rowData)))
+
+	for _, charsets := range []map[string]string{
+		{"c3": "latin1"},
+		{"pk": "latin1"},
+		{"c2": "utf16"},
+		{"c2": "no_such_charset"},
+	} {
+		_, err = BuildSecondaryProllyIndex(ctx, vrw, sch, idx, primary, BuildOptions{KeyEncodingOptions: KeyEncodingOptions{IndexSourceCharsets: charsets}})
+		require.Error(t, err, "%v", charsets)
+	}
+	both, err := coll.AddIndexByColNames("c1_c2_idx", []string{"c1", "c2"}, schema.IndexProperties{})
+	require.NoError(t, err)
+	_, err = BuildSecondaryProllyIndex(ctx, vrw, sch, both, primary, BuildOptions{KeyEncodingOptions: KeyEncodingOptions{IndexSourceCharsets: map[string]string{"c1": "latin1"}}})
+	require.Error(t, err)
+
+	_, err = CreateIndex(ctx, newTestTable(t, ctx, vrw, sch, rows), "c2_idx", []string{"c2"}, false, true, "", opts)
+	require.Error(t, err)
+}
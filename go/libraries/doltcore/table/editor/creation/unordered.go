@@ -0,0 +1,132 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/dolthub/go-mysql-server/sql"
+
+	"github.com/dolthub/dolt/go/libraries/doltcore/doltdb/durable"
+	"github.com/dolthub/dolt/go/libraries/doltcore/schema"
+	"github.com/dolthub/dolt/go/store/prolly"
+	"github.com/dolthub/dolt/go/store/val"
+)
+
+// IsOrderPreservingEncoding returns whether the values encoded with |enc| are
+// ordered by the order of the values themselves, so that the keys of an index
+// with such fields can be range scanned. The addresses of values stored out of
+// band are ordered by hash, and JSON, geometries and encodings unknown to this
+// package have no order at all.
+func IsOrderPreservingEncoding(enc val.Encoding) bool {
+	switch enc {
+	case val.Int8Enc, val.Uint8Enc, val.Int16Enc, val.Uint16Enc, val.Int32Enc, val.Uint32Enc, val.Int64Enc, val.Uint64Enc,
+		val.Float32Enc, val.Float64Enc, val.Bit64Enc, val.DecimalEnc, val.YearEnc, val.DateEnc, val.TimeEnc,
+		val.DatetimeEnc, val.EnumEnc, val.SetEnc, val.StringEnc, val.ByteStringEnc, val.Hash128Enc:
+		return true
+	default:
+		return false
+	}
+}
+
+// IsRangeIndexable returns whether the columns of type |typ| can be indexed by
+// an index that supports range scans, rather than only by an index built with
+// BuildOptions.EqualityOnlyIndex.
+func IsRangeIndexable(typ sql.Type) bool {
+	return IsOrderPreservingEncoding(val.Encoding(schema.EncodingFromSqlType(typ.Type())))
+}
+
+// validateKeyOrder returns an ErrIndexKeyNotOrdered if a field of |kd|, the
+// key descriptor of |idx|, has an encoding that does not preserve the order of
+// its values, unless |idx| is built with BuildOptions.EqualityOnlyIndex.
+func validateKeyOrder(idx schema.Index, kd val.TupleDesc, opts BuildOptions) error {
+	for i, typ := range kd.Types {
+		if IsOrderPreservingEncoding(typ.Enc) {
+			continue
+		}
+		if opts.EqualityOnlyIndex {
+			if opts.IndexBuildCheckpoints != nil {
+				return fmt.Errorf("index `%s`: builds of equality-only indexes cannot be checkpointed", idx.Name())
+			}
+			return nil
+		}
+		field := fmt.Sprintf("field %d", i)
+		if i < len(idx.AllTags()) {
+			if col, ok := idx.GetColumn(idx.AllTags()[i]); ok {
+				field = fmt.Sprintf("column `%s` of type %s", col.Name, col.TypeInfo.ToSqlType().String())
+			}
+		}
+		return fmt.Errorf("%w: index `%s`: %s has no order-preserving encoding, so it can only be indexed by an equality-only index",
+			ErrIndexKeyNotOrdered, idx.Name(), field)
+	}
+	return nil
+}
+
+// equalityCompare orders the fields of tuples with an order-preserving
+// encoding with |cmp|, and the other fields by their encoded bytes. Tuples
+// with equal values are equal, but the order is otherwise meaningless.
+type equalityCompare struct {
+	cmp val.TupleComparator
+}
+
+var _ val.TupleComparator = equalityCompare{}
+
+// Compare implements val.TupleComparator.
+func (c equalityCompare) Compare(left, right val.Tuple, desc val.TupleDesc) int {
+	for i, typ := range desc.Types {
+		if cmp := c.CompareValues(left.GetField(i), right.GetField(i), typ); cmp != 0 {
+			return cmp
+		}
+	}
+	return 0
+}
+
+// CompareValues implements val.TupleComparator.
+func (c equalityCompare) CompareValues(left, right []byte, typ val.Type) int {
+	if IsOrderPreservingEncoding(typ.Enc) {
+		return c.cmp.CompareValues(left, right, typ)
+	}
+	// order NULLs last, like |cmp|
+	switch {
+	case left == nil && right == nil:
+		return 0
+	case left == nil:
+		return 1
+	case right == nil:
+		return -1
+	}
+	return bytes.Compare(left, right)
+}
+
+// equalityKeyDesc returns |kd| ordered by equalityCompare, for the keys of an
+// index built with BuildOptions.EqualityOnlyIndex.
+func equalityKeyDesc(kd val.TupleDesc) val.TupleDesc {
+	if _, ok := kd.Comparator().(equalityCompare); ok {
+		return kd
+	}
+	return val.NewTupleDescriptorWithComparator(equalityCompare{cmp: kd.Comparator()}, kd.Types...)
+}
+
+// EqualityOnlyIndexMap returns |idx|, index data built with
+// BuildOptions.EqualityOnlyIndex, as a map in the order it was built in.
+// The order is not stored with the index data, so equality-only index data
+// that was written and read back must be read through this map. Only lookups
+// of whole values are meaningful; range scans of its unordered fields are not.
+func EqualityOnlyIndexMap(idx durable.Index) prolly.Map {
+	m := durable.ProllyMapFromIndex(idx)
+	kd, vd := m.Descriptors()
+	return prolly.NewMap(m.Node(), m.NodeStore(), equalityKeyDesc(kd), vd)
+}
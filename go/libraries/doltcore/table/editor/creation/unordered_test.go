@@ -0,0 +1,122 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/dolthub/go-mysql-server/sql"
+	"github.com/stretchr/testify/require"
+
+	"github.com/dolthub/dolt/go/libraries/doltcore/doltdb/durable"
+	"github.com/dolthub/dolt/go/libraries/doltcore/schema"
+	"github.com/dolthub/dolt/go/store/prolly/shim"
+	"github.com/dolthub/dolt/go/store/types"
+	"github.com/dolthub/dolt/go/store/val"
+)
+
+// syntheticEnc stands for the encoding of a type added without an
+// order-preserving encoding.
+const syntheticEnc = val.Encoding(100)
+
+func TestIsOrderPreservingEncoding(t *testing.T) {
+	require.True(t, IsOrderPreservingEncoding(val.Int64Enc))
+	require.True(t, IsOrderPreservingEncoding(val.StringEnc))
+	require.False(t, IsOrderPreservingEncoding(val.JSONEnc))
+	require.False(t, IsOrderPreservingEncoding(val.BytesAddrEnc))
+	require.False(t, IsOrderPreservingEncoding(syntheticEnc))
+	require.True(t, IsRangeIndexable(sql.Int64))
+	require.False(t, IsRangeIndexable(sql.JSON))
+}
+
+func TestEqualityOnlyKeyDesc(t *testing.T) {
+	kd := val.NewTupleDescriptor(val.Type{Enc: syntheticEnc, Nullable: true}, val.Type{Enc: val.Int64Enc})
+	coll := schema.NewIndexCollection(nil, nil)
+	idx, err := coll.UnsafeAddIndexByColTags("synthetic_idx", nil, schema.IndexProperties{})
+	require.NoError(t, err)
+	err = validateKeyOrder(idx, kd, BuildOptions{})
+	require.ErrorIs(t, err, ErrIndexKeyNotOrdered)
+	require.Contains(t, err.Error(), "field 0")
+	require.NoError(t, validateKeyOrder(idx, kd, BuildOptions{KeyEncodingOptions: KeyEncodingOptions{EqualityOnlyIndex: true}}))
+
+	// the synthetic fields are ordered by their bytes, NULLs last, and the
+	// other fields by their values
+	tup := func(b []byte, i int64) val.Tuple {
+		tb := val.NewTupleBuilder(kd)
+		tb.PutRaw(0, b)
+		tb.PutInt64(1, i)
+		return tb.Build(sharePool)
+	}
+	ekd := equalityKeyDesc(kd)
+	require.Equal(t, ekd, equalityKeyDesc(ekd))
+	require.Zero(t, ekd.Compare(tup([]byte{2, 1}, 1), tup([]byte{2, 1}, 1)))
+	require.Negative(t, ekd.Compare(tup([]byte{1, 9}, 9), tup([]byte{2, 1}, 1)))
+	require.Negative(t, ekd.Compare(tup([]byte{2, 1}, -1), tup([]byte{2, 1}, 1)))
+	require.Negative(t, ekd.Compare(tup([]byte{2, 1}, 1), tup(nil, 1)))
+}
+
+func TestEqualityOnlyIndex(t *testing.T) {
+	ctx := context.Background()
+	vrw := newTestVRW()
+	sch, err := schema.SchemaFromCols(schema.NewColCollection(
+		schema.NewColumn("pk", 1, types.IntKind, true, schema.NotNullConstraint{}),
+		schema.NewColumn("doc", 2, types.JSONKind, false),
+	))
+	require.NoError(t, err)
+	rows := [][]interface{}{
+		{1, json.RawMessage(`{"a": 1}`)},
+		{2, json.RawMessage(`[1, 2]`)},
+		{3, json.RawMessage(`{"a": 1}`)},
+		{4, nil},
+	}
+	primary := newTestPrimary(t, ctx, vrw, sch, rows)
+	coll := schema.NewIndexCollection(sch.GetAllCols(), sch.GetPKCols())
+	idx, err := coll.AddIndexByColNames("doc_idx", []string{"doc"}, schema.IndexProperties{})
+	require.NoError(t, err)
+
+	// JSON has no order-preserving encoding, so it cannot be range indexed
+	_, err = BuildSecondaryProllyIndex(ctx, vrw, sch, idx, primary, BuildOptions{})
+	require.ErrorIs(t, err, ErrIndexKeyNotOrdered)
+	require.Contains(t, err.Error(), "column `doc` of type JSON")
+	_, err = BuildSecondaryProllyIndex(ctx, vrw, sch, idx, primary, BuildOptions{KeyEncodingOptions: KeyEncodingOptions{EqualityOnlyIndex: true}, ExecutionOptions: ExecutionOptions{IndexBuildCheckpoints: NewFileCheckpointStore(t.TempDir()), IndexBuildCheckpointRows: 2}})
+	require.Error(t, err)
+
+	rowData, err := BuildSecondaryProllyIndex(ctx, vrw, sch, idx, primary, BuildOptions{KeyEncodingOptions: KeyEncodingOptions{EqualityOnlyIndex: true}})
+	require.NoError(t, err)
+	require.Equal(t, uint64(4), rowData.Count())
+
+	// equality-only index data must be read back through EqualityOnlyIndexMap
+	ref, err := durable.RefFromIndex(ctx, vrw, rowData)
+	require.NoError(t, err)
+	v, err := vrw.ReadValue(ctx, ref.TargetHash())
+	require.NoError(t, err)
+	m := EqualityOnlyIndexMap(durable.IndexFromProllyMap(shim.MapFromValue(v, idx.Schema(), vrw)))
+	kd, _ := m.Descriptors()
+	kb := val.NewTupleBuilder(kd)
+	for _, row := range rows {
+		if row[1] != nil {
+			kb.PutJSON(0, row[1].(json.RawMessage))
+		}
+		kb.PutInt64(1, int64(row[0].(int)))
+		ok, err := m.Has(ctx, kb.Build(m.Pool()))
+		require.NoError(t, err)
+		require.True(t, ok)
+	}
+
+	_, err = CreateIndexWithProperties(ctx, newTestTable(t, ctx, vrw, sch, rows), "doc_idx", []uint64{2}, schema.IndexProperties{IsUserDefined: true}, BuildOptions{KeyEncodingOptions: KeyEncodingOptions{EqualityOnlyIndex: true}})
+	require.Error(t, err)
+}
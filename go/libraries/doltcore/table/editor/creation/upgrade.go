@@ -0,0 +1,121 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/dolthub/dolt/go/libraries/doltcore/doltdb/durable"
+	"github.com/dolthub/dolt/go/libraries/doltcore/row"
+	"github.com/dolthub/dolt/go/libraries/doltcore/schema"
+	"github.com/dolthub/dolt/go/libraries/doltcore/sqle/index"
+	"github.com/dolthub/dolt/go/store/prolly"
+	"github.com/dolthub/dolt/go/store/prolly/shim"
+	"github.com/dolthub/dolt/go/store/prolly/tree"
+	"github.com/dolthub/dolt/go/store/types"
+	"github.com/dolthub/dolt/go/store/val"
+)
+
+// BuildProllyIndexFromNoms builds a prolly secondary index of |idx| from
+// |rows|, the row data of a table stored in the noms format, and writes it to
+// |vrw|, which must be a DOLT_1 store. The rows are converted to prolly tuples
+// as they are read and built with BuildSecondaryProllyIndexFromIter, so the
+// build supports the options of the prolly builders and reports duplicate
+// unique entries as a prolly build does.
+//
+// This is a migration aid: the index it returns is in the DOLT_1 format, not
+// the format of the table it was built from, so it can only be stored in a
+// table that has been migrated to DOLT_1.
+func BuildProllyIndexFromNoms(ctx context.Context, vrw types.ValueReadWriter, sch schema.Schema, idx schema.Index, rows types.Map, opts BuildOptions) (durable.Index, error) {
+	if !types.IsFormat_DOLT_1(vrw.Format()) {
+		return nil, fmt.Errorf("index `%s`: prolly indexes must be written to a %s store", idx.Name(), types.Format_DOLT_1.VersionString())
+	}
+	if schema.IsKeyless(sch) {
+		return nil, fmt.Errorf("index `%s`: indexes of keyless noms tables cannot be built as prolly indexes", idx.Name())
+	}
+	iter, err := newNomsRowIter(ctx, vrw, sch, rows)
+	if err != nil {
+		return nil, err
+	}
+	return BuildSecondaryProllyIndexFromIter(ctx, vrw, sch, idx, iter, opts)
+}
+
+// nomsRowIter is a prolly.MapIter over the rows of a noms map, converted to
+// the prolly tuples of the same schema.
+type nomsRowIter struct {
+	iter   types.MapIterator
+	sch    schema.Schema
+	ns     tree.NodeStore
+	kb, vb *val.TupleBuilder
+}
+
+var _ prolly.MapIter = &nomsRowIter{}
+
+func newNomsRowIter(ctx context.Context, vrw types.ValueReadWriter, sch schema.Schema, rows types.Map) (*nomsRowIter, error) {
+	iter, err := rows.Iterator(ctx)
+	if err != nil {
+		return nil, err
+	}
+	kd, vd := shim.MapDescriptorsFromSchema(sch)
+	return &nomsRowIter{
+		iter: iter,
+		sch:  sch,
+		ns:   tree.NewNodeStore(shim.ChunkStoreFromVRW(vrw)),
+		kb:   val.NewTupleBuilder(kd),
+		vb:   val.NewTupleBuilder(vd),
+	}, nil
+}
+
+// Next implements prolly.MapIter.
+func (itr *nomsRowIter) Next(ctx context.Context) (val.Tuple, val.Tuple, error) {
+	k, v, err := itr.iter.Next(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	if k == nil {
+		return nil, nil, io.EOF
+	}
+	r, err := row.FromNoms(itr.sch, k.(types.Tuple), v.(types.Tuple))
+	if err != nil {
+		return nil, nil, err
+	}
+	if err = itr.put(ctx, itr.kb, itr.sch.GetPKCols(), r); err != nil {
+		return nil, nil, err
+	}
+	if err = itr.put(ctx, itr.vb, itr.sch.GetNonPKCols(), r); err != nil {
+		return nil, nil, err
+	}
+	pool := itr.ns.Pool()
+	return itr.kb.Build(pool), itr.vb.Build(pool), nil
+}
+
+func (itr *nomsRowIter) put(ctx context.Context, tb *val.TupleBuilder, cols *schema.ColCollection, r row.Row) error {
+	for i, col := range cols.GetColumns() {
+		nv, ok := r.GetColVal(col.Tag)
+		if !ok || types.IsNull(nv) {
+			continue
+		}
+		v, err := col.TypeInfo.ConvertNomsValueToValue(nv)
+		if err != nil {
+			return err
+		}
+		if err = index.PutField(ctx, itr.ns, tb, i, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
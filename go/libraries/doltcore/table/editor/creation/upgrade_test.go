@@ -0,0 +1,80 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/dolthub/go-mysql-server/sql"
+	"github.com/stretchr/testify/require"
+
+	"github.com/dolthub/dolt/go/libraries/doltcore/row"
+	"github.com/dolthub/dolt/go/libraries/doltcore/schema"
+	"github.com/dolthub/dolt/go/store/chunks"
+	"github.com/dolthub/dolt/go/store/types"
+)
+
+func TestBuildProllyIndexFromNoms(t *testing.T) {
+	ctx := context.Background()
+	sch := newTestSchema(t)
+	ts := &chunks.TestStorage{}
+	nomsVrw := types.NewValueStore(ts.NewViewWithFormat(types.Format_LD_1.VersionString()))
+
+	var rows [][]interface{}
+	var kvs []types.Value
+	for i := 0; i < 200; i++ {
+		vals := row.TaggedValues{pkTag: types.Int(i), c2Tag: types.String(fmt.Sprintf("value-%03d", i))}
+		if i%5 == 0 {
+			rows = append(rows, []interface{}{i, nil, fmt.Sprintf("value-%03d", i)})
+		} else {
+			vals[c1Tag] = types.Int(i % 10)
+			rows = append(rows, []interface{}{i, i % 10, fmt.Sprintf("value-%03d", i)})
+		}
+		r, err := row.New(types.Format_LD_1, sch, vals)
+		require.NoError(t, err)
+		k, err := r.NomsMapKey(sch).Value(ctx)
+		require.NoError(t, err)
+		v, err := r.NomsMapValue(sch).Value(ctx)
+		require.NoError(t, err)
+		kvs = append(kvs, k, v)
+	}
+	nomsRows, err := types.NewMap(ctx, nomsVrw, kvs...)
+	require.NoError(t, err)
+
+	vrw := newTestVRW()
+	primary := newTestPrimary(t, ctx, vrw, sch, rows)
+	coll := schema.NewIndexCollection(sch.GetAllCols(), sch.GetPKCols())
+	idx, err := coll.AddIndexByColNames("c1_c2_idx", []string{"c1", "c2"}, schema.IndexProperties{})
+	require.NoError(t, err)
+
+	// the upgraded index is the index of the same rows stored as prolly rows
+	expected, err := BuildSecondaryProllyIndex(ctx, vrw, sch, idx, primary, BuildOptions{})
+	require.NoError(t, err)
+	actual, err := BuildProllyIndexFromNoms(ctx, vrw, sch, idx, nomsRows, BuildOptions{})
+	require.NoError(t, err)
+	requireSameIndex(t, expected, actual)
+
+	// duplicate unique entries are reported as in a prolly build
+	uniqueIdx, err := coll.AddIndexByColNames("c1_idx", []string{"c1"}, schema.IndexProperties{IsUnique: true})
+	require.NoError(t, err)
+	_, err = BuildProllyIndexFromNoms(ctx, vrw, sch, uniqueIdx, nomsRows, BuildOptions{})
+	require.True(t, sql.ErrDuplicateEntry.Is(err))
+
+	// the index must be written to a prolly store
+	_, err = BuildProllyIndexFromNoms(ctx, nomsVrw, sch, idx, nomsRows, BuildOptions{})
+	require.Error(t, err)
+}
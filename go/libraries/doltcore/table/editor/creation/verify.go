@@ -0,0 +1,249 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/dolthub/dolt/go/libraries/doltcore/doltdb"
+	"github.com/dolthub/dolt/go/libraries/doltcore/doltdb/durable"
+	"github.com/dolthub/dolt/go/libraries/doltcore/schema"
+	"github.com/dolthub/dolt/go/store/prolly"
+	"github.com/dolthub/dolt/go/store/prolly/tree"
+	"github.com/dolthub/dolt/go/store/types"
+	"github.com/dolthub/dolt/go/store/val"
+)
+
+// IndexVerifyResult describes the discrepancies found between a secondary index and its table.
+type IndexVerifyResult struct {
+	IndexName string
+	// MissingEntries is the number of rows without a matching index entry.
+	MissingEntries uint64
+	// ExtraEntries is the number of index entries without a matching row.
+	ExtraEntries uint64
+	// Sampled is true if only a sample of the rows and index entries was checked.
+	Sampled bool
+}
+
+// Consistent returns true if no discrepancies were found.
+func (r IndexVerifyResult) Consistent() bool {
+	return r.MissingEntries == 0 && r.ExtraEntries == 0
+}
+
+// VerifySecondaryIndex checks that the stored data of |idx| matches the rows of |tbl|. By default the expected index
+// is rebuilt and compared to the stored index in full. If |opts| has a VerifySampleSize smaller than the table, only
+// a sample of rows is checked for matching index entries, and a sample of index entries for matching rows.
+func VerifySecondaryIndex(ctx context.Context, tbl *doltdb.Table, idx schema.Index, opts BuildOptions) (IndexVerifyResult, error) {
+	if !types.IsFormat_DOLT_1(tbl.Format()) {
+		return IndexVerifyResult{}, fmt.Errorf("index verification is not supported for format %s", tbl.Format().VersionString())
+	}
+
+	sch, err := tbl.GetSchema(ctx)
+	if err != nil {
+		return IndexVerifyResult{}, err
+	}
+	m, err := tbl.GetRowData(ctx)
+	if err != nil {
+		return IndexVerifyResult{}, err
+	}
+	primary := durable.ProllyMapFromIndex(m)
+	s, err := tbl.GetIndexRowData(ctx, idx.Name())
+	if err != nil {
+		return IndexVerifyResult{}, err
+	}
+	return verifyIndexData(ctx, tbl, sch, idx, primary, durable.ProllyMapFromIndex(s), opts)
+}
+
+// ValidateImportedIndex checks that the index data |imported|, e.g. loaded from a bulk-load file with
+// LoadIndexEntries, matches the rows of |tbl| before it is stored as the data of |idx|. It is checked as
+// VerifySecondaryIndex checks stored index data, so a VerifySampleSize in |opts| avoids building the index. |opts|
+// must be the options the imported index was built with.
+func ValidateImportedIndex(ctx context.Context, tbl *doltdb.Table, idx schema.Index, imported durable.Index, opts BuildOptions) (IndexVerifyResult, error) {
+	if !types.IsFormat_DOLT_1(tbl.Format()) {
+		return IndexVerifyResult{}, fmt.Errorf("index verification is not supported for format %s", tbl.Format().VersionString())
+	}
+
+	sch, err := tbl.GetSchema(ctx)
+	if err != nil {
+		return IndexVerifyResult{}, err
+	}
+	m, err := tbl.GetRowData(ctx)
+	if err != nil {
+		return IndexVerifyResult{}, err
+	}
+	return verifyIndexData(ctx, tbl, sch, idx, durable.ProllyMapFromIndex(m), durable.ProllyMapFromIndex(imported), opts)
+}
+
+// verifyIndexData checks that the index data |secondary| matches the rows |primary| of |tbl|.
+func verifyIndexData(ctx context.Context, tbl *doltdb.Table, sch schema.Schema, idx schema.Index, primary, secondary prolly.Map, opts BuildOptions) (IndexVerifyResult, error) {
+	secondary = withTimeBucketDesc(idx, secondary)
+	if opts.VerifySampleSize > 0 && opts.VerifySampleSize < uint64(primary.Count()) {
+		return verifySampled(ctx, sch, idx, primary, secondary, opts)
+	}
+
+	expected, err := BuildSecondaryProllyIndex(ctx, tbl.ValueReadWriter(), sch, idx, primary, opts)
+	if err != nil {
+		return IndexVerifyResult{}, err
+	}
+
+	res := IndexVerifyResult{IndexName: idx.Name()}
+	err = prolly.DiffMaps(ctx, secondary, durable.ProllyMapFromIndex(expected), func(ctx context.Context, diff tree.Diff) error {
+		switch diff.Type {
+		case tree.AddedDiff:
+			res.MissingEntries++
+		case tree.RemovedDiff:
+			res.ExtraEntries++
+		case tree.ModifiedDiff:
+			// the stored entry is wrong, and the expected entry is missing
+			res.MissingEntries++
+			res.ExtraEntries++
+		}
+		return nil
+	})
+	if err != nil && err != io.EOF {
+		return IndexVerifyResult{}, err
+	}
+	return res, nil
+}
+
+// verifySampled checks a sample of |primary| for rows missing from |secondary|, and a sample of |secondary| for
+// entries that do not match a row of |primary|.
+func verifySampled(ctx context.Context, sch schema.Schema, idx schema.Index, primary, secondary prolly.Map, opts BuildOptions) (IndexVerifyResult, error) {
+	opts = withIndexSample(idx, opts)
+	res := IndexVerifyResult{IndexName: idx.Name(), Sampled: true}
+	pkLen := sch.GetPKCols().Size()
+	kd, _ := secondary.Descriptors()
+	enc, err := newIndexKeyEncoder(sch, idx, kd, opts)
+	if err != nil {
+		return IndexVerifyResult{}, err
+	}
+	mon := newBuildMonitor(idx, BuildOptions{})
+
+	// expectedKey returns the index key for the row |k|, |v|, or nil if the row is excluded from the index
+	expectedKey := func(k, v val.Tuple) (val.Tuple, error) {
+		if opts.IndexRowFilter != nil {
+			ok, err := opts.IndexRowFilter(ctx, k, v)
+			if err != nil || !ok {
+				return nil, err
+			}
+		}
+		if err := enc.put(k, v, mon); err != nil {
+			if skipsOversizedRow(err, opts) {
+				enc.kb.Recycle()
+				return nil, nil
+			}
+			return nil, err
+		}
+		return enc.kb.Build(secondary.Pool()), nil
+	}
+
+	_, err = sampleBlocks(ctx, primary, opts.VerifySampleSize, opts.IndexSampleSeed, func(k, v val.Tuple) error {
+		idxKey, err := expectedKey(k, v)
+		if err != nil || idxKey == nil {
+			return err
+		}
+		ok, err := secondary.Has(ctx, idxKey)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			res.MissingEntries++
+		}
+		return nil
+	})
+	if err != nil {
+		return IndexVerifyResult{}, err
+	}
+
+	// the primary key of an index entry is found at the index key fields that map to primary key fields
+	pkd, _ := primary.Descriptors()
+	pkBld := val.NewTupleBuilder(pkd)
+	pkMap := make(val.OrdinalMapping, pkLen)
+	for to := range enc.keyMap {
+		if from := enc.keyMap.MapOrdinal(to); from < pkLen {
+			pkMap[from] = to + enc.offset()
+		}
+	}
+
+	_, err = sampleBlocks(ctx, secondary, opts.VerifySampleSize, opts.IndexSampleSeed, func(idxKey, _ val.Tuple) error {
+		for to, from := range pkMap {
+			pkBld.PutRaw(to, idxKey.GetField(from))
+		}
+		var expected val.Tuple
+		err := primary.Get(ctx, pkBld.Build(primary.Pool()), func(k, v val.Tuple) (err error) {
+			if k != nil {
+				expected, err = expectedKey(k, v)
+			}
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		if expected == nil || kd.Compare(expected, idxKey) != 0 {
+			res.ExtraEntries++
+		}
+		return nil
+	})
+	if err != nil {
+		return IndexVerifyResult{}, err
+	}
+	return res, nil
+}
+
+// IndexRepairReport describes the outcome of RepairIndexes.
+type IndexRepairReport struct {
+	// Results are the verification results of every index of the table.
+	Results []IndexVerifyResult
+	// Repaired are the names of the indexes that were found inconsistent and rebuilt.
+	Repaired []string
+}
+
+// RepairIndexes verifies each secondary index of |tbl| with VerifySecondaryIndex and rebuilds the indexes found to be
+// inconsistent. Consistent indexes, and deferred indexes that have not been built, are left untouched. Returns the
+// updated table and a report of the repair.
+func RepairIndexes(ctx context.Context, tbl *doltdb.Table, opts BuildOptions) (*doltdb.Table, IndexRepairReport, error) {
+	sch, err := tbl.GetSchema(ctx)
+	if err != nil {
+		return nil, IndexRepairReport{}, err
+	}
+
+	var report IndexRepairReport
+	for _, idx := range sch.Indexes().AllIndexes() {
+		if idx.IsDeferred() {
+			continue
+		}
+		res, err := VerifySecondaryIndex(ctx, tbl, idx, opts)
+		if err != nil {
+			return nil, IndexRepairReport{}, err
+		}
+		report.Results = append(report.Results, res)
+		if res.Consistent() {
+			continue
+		}
+
+		indexRows, err := BuildSecondaryIndex(ctx, tbl, idx, opts)
+		if err != nil {
+			return nil, IndexRepairReport{}, err
+		}
+		tbl, err = tbl.SetIndexRows(ctx, idx.Name(), indexRows)
+		if err != nil {
+			return nil, IndexRepairReport{}, err
+		}
+		report.Repaired = append(report.Repaired, idx.Name())
+	}
+	return tbl, report, nil
+}
@@ -0,0 +1,161 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package creation
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/dolthub/dolt/go/libraries/doltcore/doltdb"
+	"github.com/dolthub/dolt/go/libraries/doltcore/doltdb/durable"
+	"github.com/dolthub/dolt/go/store/val"
+)
+
+// newCorruptIndexTable returns a table with indexes `c1_idx` and `c2_idx`. The entry of `c1_idx` for the row with
+// pk 0 is missing, and `c1_idx` has an extra entry (-1, 5).
+func newCorruptIndexTable(t *testing.T, ctx context.Context) *doltdb.Table {
+	vrw := newTestVRW()
+	sch := newTestSchema(t)
+	var rows [][]interface{}
+	for i := 0; i < 300; i++ {
+		rows = append(rows, []interface{}{i, i, "row"})
+	}
+	tbl := newTestTable(t, ctx, vrw, sch, rows)
+
+	ret, err := CreateIndex(ctx, tbl, "c1_idx", []string{"c1"}, false, true, "", BuildOptions{})
+	require.NoError(t, err)
+	ret, err = CreateIndex(ctx, ret.NewTable, "c2_idx", []string{"c2"}, false, true, "", BuildOptions{})
+	require.NoError(t, err)
+	tbl = ret.NewTable
+
+	idx, err := tbl.GetIndexRowData(ctx, "c1_idx")
+	require.NoError(t, err)
+	secondary := durable.ProllyMapFromIndex(idx)
+	kd, _ := secondary.Descriptors()
+	kb := val.NewTupleBuilder(kd)
+	mut := secondary.Mutate()
+	kb.PutInt64(0, 0)
+	kb.PutInt64(1, 0)
+	require.NoError(t, mut.Delete(ctx, kb.Build(sharePool)))
+	kb.PutInt64(0, -1)
+	kb.PutInt64(1, 5)
+	require.NoError(t, mut.Put(ctx, kb.Build(sharePool), val.EmptyTuple))
+	secondary, err = mut.Map(ctx)
+	require.NoError(t, err)
+
+	tbl, err = tbl.SetIndexRows(ctx, "c1_idx", durable.IndexFromProllyMap(secondary))
+	require.NoError(t, err)
+	return tbl
+}
+
+func TestVerifySecondaryIndex(t *testing.T) {
+	ctx := context.Background()
+	tbl := newCorruptIndexTable(t, ctx)
+	sch, err := tbl.GetSchema(ctx)
+	require.NoError(t, err)
+	c1Idx := sch.Indexes().GetByName("c1_idx")
+	c2Idx := sch.Indexes().GetByName("c2_idx")
+
+	res, err := VerifySecondaryIndex(ctx, tbl, c1Idx, BuildOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, IndexVerifyResult{IndexName: "c1_idx", MissingEntries: 1, ExtraEntries: 1}, res)
+
+	res, err = VerifySecondaryIndex(ctx, tbl, c2Idx, BuildOptions{})
+	require.NoError(t, err)
+	assert.True(t, res.Consistent())
+
+	res, err = VerifySecondaryIndex(ctx, tbl, c1Idx, BuildOptions{ExecutionOptions: ExecutionOptions{VerifySampleSize: 200}})
+	require.NoError(t, err)
+	assert.Equal(t, IndexVerifyResult{IndexName: "c1_idx", MissingEntries: 1, ExtraEntries: 1, Sampled: true}, res)
+
+	res, err = VerifySecondaryIndex(ctx, tbl, c2Idx, BuildOptions{ExecutionOptions: ExecutionOptions{VerifySampleSize: 200}})
+	require.NoError(t, err)
+	assert.True(t, res.Consistent())
+	assert.True(t, res.Sampled)
+}
+
+func TestValidateImportedIndex(t *testing.T) {
+	ctx := context.Background()
+	tbl := newCorruptIndexTable(t, ctx)
+	sch, err := tbl.GetSchema(ctx)
+	require.NoError(t, err)
+
+	// importExport writes the stored entries of an index to a file and loads them back
+	importExport := func(name string) durable.Index {
+		rows, err := tbl.GetIndexRowData(ctx, name)
+		require.NoError(t, err)
+		iter, err := durable.ProllyMapFromIndex(rows).IterAll(ctx)
+		require.NoError(t, err)
+		var buf bytes.Buffer
+		for {
+			k, v, err := iter.Next(ctx)
+			if err == io.EOF {
+				break
+			}
+			require.NoError(t, err)
+			require.NoError(t, WriteIndexEntry(&buf, k, v))
+		}
+		loaded, err := LoadIndexEntries(ctx, tbl.ValueReadWriter(), sch.Indexes().GetByName(name), &buf)
+		require.NoError(t, err)
+		return loaded
+	}
+
+	c1Idx := sch.Indexes().GetByName("c1_idx")
+	res, err := ValidateImportedIndex(ctx, tbl, c1Idx, importExport("c1_idx"), BuildOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, IndexVerifyResult{IndexName: "c1_idx", MissingEntries: 1, ExtraEntries: 1}, res)
+	res, err = ValidateImportedIndex(ctx, tbl, c1Idx, importExport("c1_idx"), BuildOptions{ExecutionOptions: ExecutionOptions{VerifySampleSize: 200}})
+	require.NoError(t, err)
+	assert.Equal(t, IndexVerifyResult{IndexName: "c1_idx", MissingEntries: 1, ExtraEntries: 1, Sampled: true}, res)
+
+	c2Idx := sch.Indexes().GetByName("c2_idx")
+	res, err = ValidateImportedIndex(ctx, tbl, c2Idx, importExport("c2_idx"), BuildOptions{})
+	require.NoError(t, err)
+	assert.True(t, res.Consistent())
+
+	// the entries of one index are not valid for another
+	res, err = ValidateImportedIndex(ctx, tbl, c2Idx, importExport("c1_idx"), BuildOptions{})
+	require.NoError(t, err)
+	assert.False(t, res.Consistent())
+}
+
+func TestRepairIndexes(t *testing.T) {
+	ctx := context.Background()
+	tbl := newCorruptIndexTable(t, ctx)
+
+	before, err := tbl.GetIndexRowData(ctx, "c2_idx")
+	require.NoError(t, err)
+
+	tbl, report, err := RepairIndexes(ctx, tbl, BuildOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"c1_idx"}, report.Repaired)
+	require.Len(t, report.Results, 2)
+
+	after, err := tbl.GetIndexRowData(ctx, "c2_idx")
+	require.NoError(t, err)
+	assert.Equal(t, durable.ProllyMapFromIndex(before).HashOf(), durable.ProllyMapFromIndex(after).HashOf())
+
+	_, report, err = RepairIndexes(ctx, tbl, BuildOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, report.Repaired)
+	for _, res := range report.Results {
+		assert.True(t, res.Consistent())
+	}
+}
@@ -14,6 +14,8 @@
 
 package pool
 
+import "sync"
+
 type BuffPool interface {
 	Get(size uint64) []byte
 	GetSlices(size uint64) [][]byte
@@ -32,3 +34,39 @@ func (bp buffPool) Get(size uint64) []byte {
 func (bp buffPool) GetSlices(size uint64) [][]byte {
 	return make([][]byte, size)
 }
+
+// slabPool is a BuffPool that carves buffers out of large slabs, trading one
+// large allocation for many small ones. Buffers are never reused, so a slab is
+// only freed once every buffer carved from it is unreachable.
+type slabPool struct {
+	slabSize uint64
+
+	// mu guards slab
+	mu   sync.Mutex
+	slab []byte
+}
+
+// NewSlabBuffPool returns a BuffPool that allocates buffers from slabs of
+// |slabSize| bytes. Requests larger than |slabSize| are allocated directly.
+// The pool is safe for concurrent use.
+func NewSlabBuffPool(slabSize uint64) BuffPool {
+	return &slabPool{slabSize: slabSize}
+}
+
+func (sp *slabPool) Get(size uint64) []byte {
+	if size > sp.slabSize {
+		return make([]byte, size)
+	}
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+	if uint64(len(sp.slab)) < size {
+		sp.slab = make([]byte, sp.slabSize)
+	}
+	buf := sp.slab[:size:size]
+	sp.slab = sp.slab[size:]
+	return buf
+}
+
+func (sp *slabPool) GetSlices(size uint64) [][]byte {
+	return make([][]byte, size)
+}
@@ -0,0 +1,57 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pool
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSlabBuffPool(t *testing.T) {
+	sp := NewSlabBuffPool(64)
+	small := sp.Get(10)
+	require.Len(t, small, 10)
+	require.Equal(t, 10, cap(small))
+	require.Len(t, sp.Get(100), 100)
+
+	// buffers got concurrently never overlap
+	const getters, gets = 8, 1000
+	bufs := make([][][]byte, getters)
+	var wg sync.WaitGroup
+	for g := 0; g < getters; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < gets; i++ {
+				buf := sp.Get(8)
+				for j := range buf {
+					buf[j] = byte(g)
+				}
+				bufs[g] = append(bufs[g], buf)
+			}
+		}(g)
+	}
+	wg.Wait()
+	for g := range bufs {
+		for _, buf := range bufs[g] {
+			for _, b := range buf {
+				assert.Equal(t, byte(g), b)
+			}
+		}
+	}
+}